@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+)
+
+// 启动时交易所上有未被跟踪的持仓（手动开仓，或状态丢失）的处理策略（manual_position）
+const (
+	// ManualAdopt 按交易所持仓的数量和均价接管，按策略规则管理出场
+	ManualAdopt = "adopt"
+	// ManualRefuse 拒绝启动，由人工处理后再运行
+	ManualRefuse = "refuse"
+)
+
+// errUntrackedPosition manual_position 为 refuse 且交易所上有未跟踪的持仓
+var errUntrackedPosition = errors.New("untracked position on exchange")
+
+// ValidManualPosition 校验 manual_position，空值使用 adopt
+func ValidManualPosition(policy string) error {
+	switch policy {
+	case "", ManualAdopt, ManualRefuse:
+		return nil
+	}
+	return fmt.Errorf("unknown manual_position %q (want %s or %s)", policy, ManualAdopt, ManualRefuse)
+}
+
+// adoptPosition 接管交易所上未被跟踪的持仓：均价和数量取自交易所，仓位比例按 名义价值 / (账户权益 × 杠杆) 折算，
+// 批次数按仓位比例相对 position_size 估算；查询权益失败时按一批 position_size 计
+func (s *Strategy) adoptPosition(side string, amt, entryPrice float64) error {
+	amount := math.Abs(amt)
+	if s.config.ManualPosition == ManualRefuse {
+		msg := fmt.Sprintf("交易所上有未跟踪的 %s 持仓 %.6f @ %.2f，manual_position=refuse，拒绝启动", side, amount, entryPrice)
+		log.Printf("[告警] %s", msg)
+		s.webhook.Alert("untracked_position", s.config.Symbol, msg)
+		return fmt.Errorf("%w: %s %g @ %g (manual_position=%s)", errUntrackedPosition, side, amount, entryPrice, ManualRefuse)
+	}
+
+	exposure := s.config.PositionSize
+	equity, err := s.accountEquity()
+	if err == nil && equity > 0 {
+		exposure = amount * entryPrice / (equity * s.leverage())
+	} else {
+		log.Printf("查询账户权益失败，接管的持仓按一批仓位计: %v", err)
+	}
+	batches := 1
+	if s.config.PositionSize > 0 {
+		batches = max(1, int(math.Round(exposure/s.config.PositionSize)))
+	}
+
+	signal := SignalLong
+	if side == "SHORT" {
+		signal = SignalShort
+	}
+	s.resetPosition()
+	s.trackPosition(signal, entryPrice, amount, exposure)
+	s.entryCount = batches
+	s.saveState()
+
+	msg := fmt.Sprintf("接管交易所上的持仓: %s %.6f @ %.2f，折合仓位 %.1f%%（约 %d 批），按策略规则管理出场",
+		side, amount, entryPrice, exposure*100, batches)
+	log.Printf("%s", msg)
+	s.webhook.Alert("position_adopted", s.config.Symbol, msg)
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import "fmt"
+
+// 低周期 K 线所在的高周期 K 线尚未走完时的处理方式
+const (
+	// AlignClosed 只使用已收盘的高周期 K 线（前向填充），不会看到未来数据，回测与实盘一致
+	AlignClosed = "closed"
+	// AlignPartial 使用截至当前低周期 K 线收盘的未走完高周期 K 线，与实盘拉取到的最新一根相同
+	AlignPartial = "partial"
+)
+
+// checkAlignIntervals 高周期须为低周期的整数倍
+func checkAlignIntervals(baseInterval, interval int64) error {
+	if baseInterval <= 0 || interval < baseInterval || interval%baseInterval != 0 {
+		return fmt.Errorf("cannot align %ds klines to %ds", baseInterval, interval)
+	}
+	return nil
+}
+
+// AlignedKline 低周期 K 线上可见的高周期 K 线
+type AlignedKline struct {
+	Kline
+	Complete bool // 高周期 K 线已收盘
+	Valid    bool // false 表示还没有可用的高周期 K 线（AlignClosed 下第一根收盘之前）
+}
+
+// AlignKlines 为每根低周期 K 线（周期 baseInterval 秒）给出当时可见的 interval 秒 K 线，
+// 高周期按整点边界对齐（与 Resample 相同）。低周期 K 线收盘时刚好走完所在周期时，该周期视为已收盘；
+// 否则 AlignClosed 返回上一根已收盘的高周期 K 线，AlignPartial 返回由本周期内截至当前的
+// 低周期 K 线合成的未走完 K 线（Complete 为 false）。数据有缺口时只合并实际存在的 K 线
+func AlignKlines(base []Kline, baseInterval, interval int64, mode string) ([]AlignedKline, error) {
+	if err := checkAlignIntervals(baseInterval, interval); err != nil {
+		return nil, err
+	}
+	if mode != AlignClosed && mode != AlignPartial {
+		return nil, fmt.Errorf("unknown align mode %q (want %s or %s)", mode, AlignClosed, AlignPartial)
+	}
+
+	out := make([]AlignedKline, len(base))
+	var current, closed Kline
+	var hasCurrent, hasClosed bool
+	for i, k := range base {
+		start := k.Timestamp - k.Timestamp%interval
+		if hasCurrent && current.Timestamp != start {
+			// 进入新周期：上一周期虽未由最后一根低周期 K 线收盘（有缺口），也已结束
+			closed, hasClosed = current, true
+			hasCurrent = false
+		}
+		if !hasCurrent {
+			current = k
+			current.Timestamp = start
+			hasCurrent = true
+		} else {
+			current.High = max(current.High, k.High)
+			current.Low = min(current.Low, k.Low)
+			current.Close = k.Close
+			current.Volume += k.Volume
+		}
+
+		if k.Timestamp+baseInterval >= start+interval {
+			closed, hasClosed = current, true
+			hasCurrent = false
+			out[i] = AlignedKline{Kline: closed, Complete: true, Valid: true}
+			continue
+		}
+		if mode == AlignPartial {
+			out[i] = AlignedKline{Kline: current, Valid: true}
+			continue
+		}
+		out[i] = AlignedKline{Kline: closed, Complete: hasClosed, Valid: hasClosed}
+	}
+	return out, nil
+}
+
+// ForwardFill 将按高周期 K 线计算的序列（values[j] 对应开盘时间 higherTimes[j]）前向填充到
+// 低周期 K 线上：高周期 K 线收盘（开盘时间 + interval）不晚于低周期 K 线收盘时才可见，
+// 不会用到未走完的高周期数据。第一根高周期 K 线收盘之前填 0，与指标预热段一致
+func ForwardFill(baseTimes []int64, baseInterval int64, higherTimes []int64, interval int64, values []float64) ([]float64, error) {
+	if err := checkAlignIntervals(baseInterval, interval); err != nil {
+		return nil, err
+	}
+	if len(higherTimes) != len(values) {
+		return nil, fmt.Errorf("higher timeframe series length mismatch: %d times, %d values", len(higherTimes), len(values))
+	}
+
+	out := make([]float64, len(baseTimes))
+	j := -1 // 最近一根已收盘的高周期 K 线
+	for i, t := range baseTimes {
+		for j+1 < len(higherTimes) && higherTimes[j+1]+interval <= t+baseInterval {
+			j++
+		}
+		if j >= 0 {
+			out[i] = values[j]
+		}
+	}
+	return out, nil
+}
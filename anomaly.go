@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AnomalyConfig 实盘行情异常检测，防止交易所数据故障（插针、错误成交量）触发交易
+type AnomalyConfig struct {
+	// K 线真实波幅超过前 atr_period 根 ATR 的 atr_mult 倍视为价格跳变，0 表示不检查
+	ATRMult   float64 `json:"atr_mult,omitempty"`
+	ATRPeriod int     `json:"atr_period,omitempty"` // 默认 14
+	// 成交量超过近期成交量 volume_percentile 分位（0-1）的 volume_mult 倍视为异常放量，0 表示不检查
+	VolumePercentile float64 `json:"volume_percentile,omitempty"`
+	VolumeMult       float64 `json:"volume_mult,omitempty"` // 默认 1
+	// 成交量为 0 的 K 线视为异常
+	ZeroVolume bool `json:"zero_volume,omitempty"`
+	// 发现异常后暂停交易 pause_bars 个周期（默认 1），否则只告警
+	Pause     bool `json:"pause,omitempty"`
+	PauseBars int  `json:"pause_bars,omitempty"`
+}
+
+// anomalyMinHistory 计算成交量分位数至少需要的历史 K 线数
+const anomalyMinHistory = 20
+
+// enabled 是否配置了任一检查
+func (c AnomalyConfig) enabled() bool {
+	return c.ATRMult > 0 || c.VolumePercentile > 0 || c.ZeroVolume
+}
+
+// Validate 校验异常检测参数
+func (c AnomalyConfig) Validate() error {
+	if c.ATRMult < 0 || c.ATRPeriod < 0 || c.VolumeMult < 0 || c.PauseBars < 0 {
+		return fmt.Errorf("anomaly: atr_mult, atr_period, volume_mult and pause_bars must be non-negative")
+	}
+	if c.VolumePercentile < 0 || c.VolumePercentile >= 1 {
+		return fmt.Errorf("anomaly: volume_percentile must be in [0, 1)")
+	}
+	return nil
+}
+
+// withDefaults 填充未设置的默认值
+func (c AnomalyConfig) withDefaults() AnomalyConfig {
+	if c.ATRPeriod == 0 {
+		c.ATRPeriod = 14
+	}
+	if c.VolumeMult == 0 {
+		c.VolumeMult = 1
+	}
+	if c.PauseBars == 0 {
+		c.PauseBars = 1
+	}
+	return c
+}
+
+// trueRange 相对上一根收盘价的真实波幅
+func trueRange(k Kline, prevClose float64) float64 {
+	return max(k.High-k.Low, math.Abs(k.High-prevClose), math.Abs(k.Low-prevClose))
+}
+
+// DetectAnomalies 检查 klines[i] 相对之前 K 线是否异常，返回异常描述，正常时为空
+func DetectAnomalies(klines []Kline, i int, c AnomalyConfig) []string {
+	c = c.withDefaults()
+	k := klines[i]
+	var found []string
+
+	if c.ZeroVolume && k.Volume == 0 {
+		found = append(found, "成交量为 0")
+	}
+
+	if c.ATRMult > 0 && i > c.ATRPeriod {
+		atr := 0.0
+		for j := i - c.ATRPeriod; j < i; j++ {
+			atr += trueRange(klines[j], klines[j-1].Close)
+		}
+		atr /= float64(c.ATRPeriod)
+		if tr := trueRange(k, klines[i-1].Close); atr > 0 && tr > c.ATRMult*atr {
+			found = append(found, fmt.Sprintf("波幅 %.2f 为 ATR(%d) %.2f 的 %.1f 倍", tr, c.ATRPeriod, atr, tr/atr))
+		}
+	}
+
+	if c.VolumePercentile > 0 && i >= anomalyMinHistory {
+		volumes := make([]float64, i)
+		for j := range volumes {
+			volumes[j] = klines[j].Volume
+		}
+		sort.Float64s(volumes)
+		threshold := quantile(volumes, c.VolumePercentile) * c.VolumeMult
+		if threshold > 0 && k.Volume > threshold {
+			found = append(found, fmt.Sprintf("成交量 %.2f 超过近 %d 根 %.0f 分位的 %.1f 倍 (%.2f)",
+				k.Volume, i, c.VolumePercentile*100, c.VolumeMult, threshold))
+		}
+	}
+	return found
+}
+
+// lastClosedIndex 最新一根已收盘 K 线的下标，没有时为 -1
+func (s *Strategy) lastClosedIndex() int {
+	i := len(s.klines) - 1
+	if i >= 0 && s.klines[i].Timestamp+s.interval > time.Now().Unix() {
+		i--
+	}
+	return i
+}
+
+// checkAnomalies 检查最新一根已收盘 K 线，异常时告警；配置了 pause 时返回 false（暂停交易），
+// 暂停持续 pause_bars 个周期。每根 K 线只检查一次
+func (s *Strategy) checkAnomalies() bool {
+	c := s.config.Anomaly
+	if !c.enabled() {
+		return true
+	}
+
+	if i := s.lastClosedIndex(); i > 0 && s.klines[i].Timestamp > s.anomalyChecked {
+		k := s.klines[i]
+		s.anomalyChecked = k.Timestamp
+		if found := DetectAnomalies(s.klines, i, c); len(found) > 0 {
+			msg := fmt.Sprintf("%s K 线行情异常: %s", time.Unix(k.Timestamp, 0).Format("01-02 15:04"), strings.Join(found, "; "))
+			if c.Pause {
+				bars := c.withDefaults().PauseBars
+				s.anomalyPausedUntil = time.Now().Add(time.Duration(int64(bars)*s.interval) * time.Second)
+				msg += fmt.Sprintf("，暂停交易 %d 个周期", bars)
+			}
+			log.Printf("[告警] %s", msg)
+			s.webhook.Alert("market_anomaly", s.config.Symbol, msg)
+		}
+	}
+
+	if time.Now().Before(s.anomalyPausedUntil) {
+		log.Printf("行情异常暂停中，跳过本周期")
+		return false
+	}
+	return true
+}
@@ -1,12 +1,11 @@
 package main
 
 import (
-	"database/sql"
 	"fmt"
 	"log"
+	"math"
+	"strings"
 	"time"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 // BacktestConfig 回测配置
@@ -14,8 +13,53 @@ type BacktestConfig struct {
 	Symbol       string  // 交易对
 	StartBalance float64 // 初始资金
 	FeeRate      float64 // 手续费率
-	Leverage     float64 // 杠杆
+	// 手续费档位：按累计模拟成交额升档，maker / taker 分别计费，为空时均按 FeeRate
+	FeeTiers []FeeTier
+	// 入场成交方式：EntryMarket 市价（taker），EntryLimit 在信号收盘价挂限价单（maker，不计滑点）
+	EntryFill string
+	Leverage     float64 // 杠杆：逐仓保证金 = 名义价值 / Leverage，触及强平价时强制平仓，0 表示不模拟强平
 	PositionSize float64 // 仓位比例 (0-1)
+	Sizing       SizingConfig // 自适应仓位
+	// 按当日开盘资金（UTC）计算仓位，减少日内复利
+	DailyCompounding bool
+	Throttle         ThrottleConfig // 入场频率限制
+	Explain          bool           // 详细模式：RSI 触发时打印各入场条件
+	// 价格止损：相对持仓均价亏损 StopLossPct 时盘中触发，0 表示不设止损
+	StopLossPct float64
+	// 分批持仓的止损方式：StopAverage 按均价整体止损，StopPerBatch 每批按入场价单独止损
+	StopMode string
+	// 止损成交惩罚：触发后按 (止损价 - K 线极值) × StopPenalty 追加不利滑点，模拟快速下跌中止损单排队靠后
+	StopPenalty float64
+	// 价格止盈：相对持仓均价盈利 TakeProfitPct 时盘中按 K 线最高 / 最低价触发，0 表示不设止盈
+	TakeProfitPct float64
+	// 移动止损和保本止损：按持仓以来的最有利价格（不含当前 K 线）盘中触发，成交假设同价格止损
+	Trailing TrailingConfig
+	// 分批建仓：各批仓位比例、加仓触发和总仓位上限，未配置时按引擎的批次规则
+	Pyramid PyramidConfig
+	// 对冲模式：多空可同时持仓、各自逐仓保证金，反向开仓指令另开一侧而不是被忽略
+	Hedge bool
+	// 盘中止损成交假设：FillTrigger 按 StopPenalty，FillWorst 按 K 线极值，FillMid 取中点
+	IntrabarFill string
+	// 逐笔交易 CSV，nil 表示不写
+	TradeLog *TradeLog
+	// 只写 TradeLog，不在内存中保留逐笔交易（超长回测内存恒定，交易分布统计不可用）
+	StreamOnly bool
+	// 盘中判定：>0 时每根 K 线按只走完该比例（如 0.5）生成信号并成交，0 表示只用已收盘 K 线
+	IntrabarFraction float64
+	// 延迟成交：>0 时入场按下一根 K 线开盘价成交，开盘价已朝信号方向偏离信号收盘价超过该值 (bps) 时放弃入场
+	MaxChaseBps float64
+	// 维护时段：时段内平掉持仓并忽略开仓指令
+	Maintenance MaintenanceSchedule
+	// 滑点模型：对所有开平仓成交价施加不利滑点
+	Slippage SlippageModel
+	// K 线周期（秒），分布式优化的 worker 按此重采样
+	Interval int64
+	// 历史资金费率：结算时刻持仓按费率支付或收取资金费，nil 表示不模拟
+	Funding []FundingRate
+	// 净值曲线过滤：策略净值低于最近 N 笔的均线时新开的持仓只虚拟跟踪
+	EquityFilter EquityFilterConfig
+	// 波动率目标：按标的波动率调整新持仓的杠杆，名义价值按 杠杆 / Leverage 同比缩放
+	VolTarget VolTargetConfig
 }
 
 // DefaultBacktestConfig 默认回测配置（超短线）
@@ -37,8 +81,21 @@ type Trade struct {
 	Amount     float64
 	PnL        float64
 	Fee        float64
+	Batch      int     // 第几批入场
+	SizeMult   float64 // 入场时的仓位系数
+	R          float64 // R 倍数：盈亏 / 初始风险
+	EntryReason string // 入场原因（引擎开仓指令的 Reason）
+	ExitReason  string // 出场原因：引擎平仓指令的 Reason，或盘中止损、止盈、强平、维护时段
 }
 
+// 盘中出场原因
+const (
+	ExitStopLoss    = "止损"
+	ExitTakeProfit  = "止盈"
+	ExitLiquidation = "强平"
+	ExitMaintenance = "维护时段"
+)
+
 // BacktestResult 回测结果
 type BacktestResult struct {
 	TotalTrades   int
@@ -49,112 +106,39 @@ type BacktestResult struct {
 	WinRate       float64
 	ProfitFactor  float64
 	MaxDrawdown   float64
-	SharpeRatio   float64
+	Drawdown      DrawdownStats // 回撤持续时间与恢复时间
+	SharpeRatio   float64 // 同 Performance.Sharpe
+	Performance   PerformanceStats // 年化收益、夏普、索提诺、卡玛与持仓时间
 	Trades        []Trade
-	BalanceCurve  []float64
-}
-
-// loadKlinesFromDB 从 SQLite 加载 K 线数据
-func loadKlinesFromDB(dbPath, symbol string, startTime, endTime int64) ([]Kline, error) {
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, err
-	}
-	defer db.Close()
-
-	// 交易对 ID 映射
-	symbolMap := map[string]int{
-		"BTCUSDT": 1, "ETHUSDT": 2, "BNBUSDT": 3, "SOLUSDT": 4,
-	}
-
-	symbolID, ok := symbolMap[symbol]
-	if !ok {
-		return nil, fmt.Errorf("unknown symbol: %s", symbol)
-	}
-
-	query := `
-		SELECT ts, o, h, l, c, v
-		FROM klines_futures
-		WHERE symbol = ?
-	`
-	args := []any{symbolID}
-
-	if startTime > 0 {
-		query += " AND ts >= ?"
-		args = append(args, startTime)
-	}
-	if endTime > 0 {
-		query += " AND ts <= ?"
-		args = append(args, endTime)
-	}
-	query += " ORDER BY ts"
-
-	rows, err := db.Query(query, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var klines []Kline
-	for rows.Next() {
-		var ts int64
-		var o, h, l, c, v int64
-		if err := rows.Scan(&ts, &o, &h, &l, &c, &v); err != nil {
-			return nil, err
-		}
-
-		klines = append(klines, Kline{
-			Timestamp: ts,
-			Open:      float64(o) / 1e8,
-			High:      float64(h) / 1e8,
-			Low:       float64(l) / 1e8,
-			Close:     float64(c) / 1e8,
-			Volume:    float64(v) / 1e8,
-		})
-	}
-
-	return klines, nil
-}
-
-// ResampleTo5m 将 1m K 线重采样为 5m
-func ResampleTo5m(klines1m []Kline) []Kline {
-	if len(klines1m) == 0 {
-		return nil
-	}
-
-	var klines5m []Kline
-
-	for i := 0; i < len(klines1m); i += 5 {
-		end := i + 5
-		if end > len(klines1m) {
-			end = len(klines1m)
-		}
-
-		bucket := klines1m[i:end]
-		if len(bucket) == 0 {
-			continue
-		}
-
-		k5 := Kline{
-			Timestamp: bucket[0].Timestamp,
-			Open:      bucket[0].Open,
-			Close:     bucket[len(bucket)-1].Close,
-		}
-
-		for _, k := range bucket {
-			if k.High > k5.High {
-				k5.High = k.High
-			}
-			if k.Low < k5.Low {
-				k5.Low = k.Low
-			}
-			k5.Volume += k.Volume
-		}
-
-		klines5m = append(klines5m, k5)
-	}
-
-	return klines5m
+	BalanceCurve  []float64 // 已实现余额
+	EquityCurve   []float64 // 按收盘价计入持仓浮动盈亏的权益，回撤和夏普基于此曲线
+	BalanceTimes  []int64   // 与 BalanceCurve / EquityCurve 对应的时间戳
+	// 滚动指标（日频，窗口 RollingWindowDays）
+	RollingSharpe   []EquityPoint
+	RollingDrawdown []EquityPoint
+	ThrottledEntries int // 因频率限制跳过的入场次数
+	StopExits          int     // 止损出场次数
+	TakeProfitExits    int     // 盘中止盈出场次数
+	TrailingExits      int     // 移动止损（含保本止损）出场次数，同时计入 StopExits
+	Liquidations       int     // 强平次数
+	LiquidationFees    float64 // 强平清算费，已计入手续费
+	MaxMarginUsage     float64 // 持仓保证金占账户资金的最大比例
+	AvgStopSlippageBps float64 // 止损成交价相对止损价的平均滑点 (bps)
+	ChaseSkips         int     // 延迟成交时价格偏离过大而放弃的入场次数
+	LimitMisses        int     // 限价入场未成交而撤单的次数
+	MaintenanceExits   int     // 进入维护时段时的平仓次数
+	Exposure           ExposureStats // 资金占用与按占用资金计的收益
+	SlippageCost       float64       // 滑点模型造成的成本 (USDT)，已计入盈亏
+	AvgSlippageBps     float64       // 按成交名义价值加权的平均滑点 (bps)
+	Participation      ParticipationStats // 订单数量占成交所在 K 线成交量的比例
+	Fees               FeeStats      // 成交额、maker 占比、综合费率与手续费档位
+	FundingPaid        float64       // 净支付的资金费 (USDT)，负数为净收取，已计入总盈亏
+	FundingEvents      int           // 持仓经历的资金费结算次数
+	RStats             RStats        // 按 R 倍数计的期望和 SQN
+	RiskPct            float64       // 1R 对应的止损距离
+	RiskATRMult        float64       // ATR 仓位模式下 1R 为开仓时 ATR 的该倍数，0 表示按 RiskPct
+	EquityFilter       EquityFilterStats // 净值曲线过滤的暂停与虚拟持仓统计
+	VolTarget          VolTargetStats    // 波动率目标的杠杆调整统计
 }
 
 // Position 持仓信息（支持分批建仓）
@@ -163,6 +147,40 @@ type Position struct {
 	entries    []PositionEntry // 多个入场点
 	totalAmt   float64         // 总持仓量
 	avgPrice   float64         // 平均入场价
+	margin     float64         // 逐仓保证金
+	leverage   float64         // 持仓的杠杆，启用波动率目标时为开仓时生效的杠杆
+	openedAt   int64           // 第一批入场时间，部分批次平仓后不变
+	realized   float64         // 已平批次的盈亏，全部平仓时计入自适应仓位
+	cost       float64         // 各批入场名义价值之和，平仓收益率 = realized / cost
+	shadow     bool            // 净值曲线过滤暂停期间开的虚拟持仓，不计入余额和结果
+	stopPct    float64         // ATR 仓位模式下开仓时的止损距离，0 时使用配置的止损比例
+	extreme    float64         // 持仓以来的最有利价格（多头最高价，空头最低价），用于移动止损
+	trailStop  float64         // 当前的移动止损价，只朝有利方向移动，0 表示未启动
+	funding    float64         // 持仓累计的资金费收支，全部平仓时计入自适应仓位
+}
+
+// stopLoss 持仓的止损比例：ATR 仓位模式下为开仓时确定的 ATR 止损距离，否则为 fallback
+func (p *Position) stopLoss(fallback float64) float64 {
+	if p != nil && p.stopPct > 0 {
+		return p.stopPct
+	}
+	return fallback
+}
+
+// recompute 部分批次平仓后按剩余批次重算持仓量、均价和保证金
+func (p *Position) recompute(leverage float64) {
+	p.totalAmt, p.avgPrice, p.margin = 0, 0, 0
+	cost := 0.0
+	for _, e := range p.entries {
+		p.totalAmt += e.amount
+		cost += e.entryPrice * e.amount
+	}
+	if p.totalAmt > 0 {
+		p.avgPrice = cost / p.totalAmt
+	}
+	if leverage > 0 {
+		p.margin = cost / leverage
+	}
 }
 
 // PositionEntry 单次入场记录
@@ -170,252 +188,150 @@ type PositionEntry struct {
 	entryTime  int64
 	entryPrice float64
 	amount     float64
-	batch      int // 第几批
+	batch      int     // 第几批
+	sizeMult   float64 // 入场时的仓位系数
+	reason     string  // 入场原因
+	fee        float64 // 入场手续费，负数为 maker 返佣
 }
 
-// RunBacktest 执行回测（超短线 1分钟级别）
-func RunBacktest(klines []Kline, config BacktestConfig, strategyConfig StrategyConfig) *BacktestResult {
-	result := &BacktestResult{
-		BalanceCurve: []float64{config.StartBalance},
-	}
-
-	n := len(klines)
-	if n < 50 {
-		return result
-	}
-
-	// 预先计算所有指标
-	rsi := CalculateRSI(klines, strategyConfig.RSI_PERIOD)
-	emaFast := CalculateEMA(klines, strategyConfig.EMA_FAST)
-	emaSlow := CalculateEMA(klines, strategyConfig.EMA_SLOW)
-	volRatio := VolumeRatio(klines, strategyConfig.RSI_PERIOD)
-
-	balance := config.StartBalance
-	var position *Position
-	maxBalance := balance
-
-	// 超短线参数
-	firstBatchSize  := 0.30  // 第一批 30%
-	secondBatchSize := 0.30  // 第二批 30%
-
-	for i := 20; i < n; i++ {
-		k := klines[i]
+// 盘中止损成交假设
+const (
+	FillTrigger = ""      // 按触发价，再按 StopPenalty 追加滑点
+	FillWorst   = "worst" // 按 K 线极值成交；同时触及止损和止盈时先止损
+	FillMid     = "mid"   // 按触发价与 K 线极值的中点成交
+)
 
-		currentRSI := rsi[i]
-		prevRSI := rsi[i-1]
-		currentEMAFast := emaFast[i]
-		currentEMASlow := emaSlow[i]
-		prevEMAFast := emaFast[i-1]
-		prevEMASlow := emaSlow[i-1]
-		currentVolRatio := volRatio[i]
+// 分批持仓的止损方式
+const (
+	StopAverage  = ""      // 按持仓均价止损，触发时平掉全部批次
+	StopPerBatch = "batch" // 每批按自己的入场价止损，只平触发的批次
+)
 
-		// 趋势判断
-		uptrend := currentEMAFast > currentEMASlow
-		downtrend := currentEMAFast < currentEMASlow
+// gapLiquidation 开盘价已越过强平价（跳空强平），此时不再按逐批止损价成交
+func gapLiquidation(config BacktestConfig, p *Position, k Kline) bool {
+	if config.Leverage <= 0 {
+		return false
+	}
+	tier := maintenanceTier(config.Symbol, p.totalAmt*p.avgPrice)
+	price, hit := liquidationFill(p.side, liquidationPrice(p.side, p.totalAmt, p.avgPrice, p.margin, tier), k)
+	return hit && price == k.Open
+}
 
-		volumeOK := currentVolRatio >= strategyConfig.VOL_RATIO_THRESHOLD
+// stopPenaltyFor 按成交假设换算止损惩罚比例
+func stopPenaltyFor(fill string, penalty float64) float64 {
+	switch fill {
+	case FillWorst:
+		return 1
+	case FillMid:
+		return 0.5
+	}
+	return penalty
+}
 
-		// 计算前5根K线最高/最低价
-		high5 := klines[i-1].High
-		low5 := klines[i-1].Low
-		for j := 2; j <= 5 && i-j >= 0; j++ {
-			if klines[i-j].High > high5 {
-				high5 = klines[i-j].High
-			}
-			if klines[i-j].Low < low5 {
-				low5 = klines[i-j].Low
-			}
+// takeProfitFill 判断 K 线内是否触及止盈，跳空越过止盈价时按开盘价成交
+func takeProfitFill(side string, avgPrice float64, k Kline, takeProfitPct float64) (float64, bool) {
+	if takeProfitPct <= 0 || avgPrice <= 0 {
+		return 0, false
+	}
+	_, tp := protectivePrices(side, avgPrice, 0, takeProfitPct)
+	if side == "LONG" {
+		if k.High < tp {
+			return 0, false
 		}
+		return math.Max(tp, k.Open), true
+	}
+	if k.Low > tp {
+		return 0, false
+	}
+	return math.Min(tp, k.Open), true
+}
 
-		// ========== 出场逻辑（时间 + 技术指标）==========
-		if position != nil {
-			shouldCloseAll := false
-
-			// EMA 反转
-			crossDown := prevEMAFast > prevEMASlow && currentEMAFast <= currentEMASlow
-			crossUp := prevEMAFast < prevEMASlow && currentEMAFast >= currentEMASlow
-
-			if position.side == "LONG" {
-				// 多头出场条件：
-				// 1. EMA 死叉
-				// 2. RSI 跌破 40
-				// 3. 持仓超过 30 分钟 + RSI < 50
-				rsiExit := currentRSI < 40
-				emaExit := crossDown
-				timeExit := false
-				if len(position.entries) > 0 {
-					holdTime := k.Timestamp - position.entries[0].entryTime
-					if holdTime > 1800 && currentRSI < 50 { // 30分钟 + RSI偏弱
-						timeExit = true
-					}
-				}
-				shouldCloseAll = emaExit || rsiExit || timeExit
-			} else if position.side == "SHORT" {
-				// 空头出场条件：
-				// 1. EMA 金叉
-				// 2. RSI 突破 60
-				// 3. 持仓超过 30 分钟 + RSI > 50
-				rsiExit := currentRSI > 60
-				emaExit := crossUp
-				timeExit := false
-				if len(position.entries) > 0 {
-					holdTime := k.Timestamp - position.entries[0].entryTime
-					if holdTime > 1800 && currentRSI > 50 { // 30分钟 + RSI偏强
-						timeExit = true
-					}
-				}
-				shouldCloseAll = emaExit || rsiExit || timeExit
-			}
-
-			// 执行平仓
-			if shouldCloseAll && len(position.entries) > 0 {
-				for _, entry := range position.entries {
-					trade := Trade{
-						EntryTime:  entry.entryTime,
-						ExitTime:   k.Timestamp,
-						Side:       position.side,
-						EntryPrice: entry.entryPrice,
-						ExitPrice:  k.Close,
-						Amount:     entry.amount,
-					}
-					if position.side == "LONG" {
-						trade.PnL = (k.Close - entry.entryPrice) * entry.amount
-					} else {
-						trade.PnL = (entry.entryPrice - k.Close) * entry.amount
-					}
-					trade.Fee = (entry.entryPrice + k.Close) * entry.amount * config.FeeRate
-					trade.PnL -= trade.Fee
-
-					balance += trade.PnL
-					result.Trades = append(result.Trades, trade)
-					result.TotalPnL += trade.PnL
-					result.TotalFees += trade.Fee
-					result.TotalTrades++
-					if trade.PnL > 0 {
-						result.WinTrades++
-					} else {
-						result.LoseTrades++
-					}
-				}
-				position = nil
-			}
-		}
+// stopFirst 同一根 K 线内止损和止盈都被触及时判断哪个先成交：开盘已越过其一时以开盘为准；
+// FillWorst 假设先止损，否则假设价格先走向离开盘价较近的极值
+func stopFirst(side string, avgPrice float64, k Kline, stopPct, takeProfitPct float64, fill string) bool {
+	stop, tp := protectivePrices(side, avgPrice, stopPct, takeProfitPct)
+	return stopPriceFirst(side, stop, tp, k, fill)
+}
 
-		// ========== 建仓逻辑（技术指标驱动）==========
-		currentPositionPct := 0.0
-		if position != nil {
-			currentPositionPct = position.totalAmt * k.Close / balance
+// stopPriceFirst 按止损价和止盈价判断同一根 K 线内哪个先成交，规则同 stopFirst
+func stopPriceFirst(side string, stop, tp float64, k Kline, fill string) bool {
+	if side == "LONG" {
+		if k.Open <= stop {
+			return true
 		}
-
-		// --- 做多：技术指标确认反弹 ---
-		if (position == nil || position.side == "LONG") && uptrend {
-			// 第一批：RSI 超卖反弹 + 突破前高 + 成交量放大
-			rsiBull := prevRSI < strategyConfig.RSI_OVERSOLD_LONG && currentRSI >= strategyConfig.RSI_ENTRY_LONG
-			breakoutUp := k.Close > high5
-			if rsiBull && breakoutUp && volumeOK && currentPositionPct < firstBatchSize {
-				if position == nil {
-					position = &Position{side: "LONG"}
-				}
-				notional := balance * firstBatchSize
-				amount := notional / k.Close
-				position.entries = append(position.entries, PositionEntry{
-					entryTime:  k.Timestamp,
-					entryPrice: k.Close,
-					amount:     amount,
-					batch:      1,
-				})
-				position.totalAmt += amount
-				position.avgPrice = (position.avgPrice*(position.totalAmt-amount) + k.Close*amount) / position.totalAmt
-				balance -= k.Close * amount * config.FeeRate
-			}
-
-			// 第二批：EMA 金叉确认趋势（加仓）
-			crossUp := prevEMAFast <= prevEMASlow && currentEMAFast > currentEMASlow
-			if position != nil && len(position.entries) == 1 && crossUp && currentPositionPct < firstBatchSize + secondBatchSize {
-				notional := balance * secondBatchSize
-				amount := notional / k.Close
-				position.entries = append(position.entries, PositionEntry{
-					entryTime:  k.Timestamp,
-					entryPrice: k.Close,
-					amount:     amount,
-					batch:      2,
-				})
-				position.totalAmt += amount
-				position.avgPrice = (position.avgPrice*(position.totalAmt-amount) + k.Close*amount) / position.totalAmt
-				balance -= k.Close * amount * config.FeeRate
-			}
+		if k.Open >= tp {
+			return false
 		}
+		return fill == FillWorst || k.Open-k.Low <= k.High-k.Open
+	}
+	if k.Open >= stop {
+		return true
+	}
+	if k.Open <= tp {
+		return false
+	}
+	return fill == FillWorst || k.High-k.Open <= k.Open-k.Low
+}
 
-		// --- 做空：技术指标确认回落 ---
-		if (position == nil || position.side == "SHORT") && downtrend {
-			// 第一批：RSI 超买回落 + 跌破前低 + 成交量放大
-			rsiBear := prevRSI > strategyConfig.RSI_OVERBOUGHT_SHORT && currentRSI <= strategyConfig.RSI_ENTRY_SHORT
-			breakoutDown := k.Close < low5
-			if rsiBear && breakoutDown && volumeOK && currentPositionPct < firstBatchSize {
-				if position == nil {
-					position = &Position{side: "SHORT"}
-				}
-				notional := balance * firstBatchSize
-				amount := notional / k.Close
-				position.entries = append(position.entries, PositionEntry{
-					entryTime:  k.Timestamp,
-					entryPrice: k.Close,
-					amount:     amount,
-					batch:      1,
-				})
-				position.totalAmt += amount
-				position.avgPrice = (position.avgPrice*(position.totalAmt-amount) + k.Close*amount) / position.totalAmt
-				balance -= k.Close * amount * config.FeeRate
-			}
-
-			// 第二批：EMA 死叉确认趋势（加仓）
-			crossDown := prevEMAFast >= prevEMASlow && currentEMAFast < currentEMASlow
-			if position != nil && len(position.entries) == 1 && crossDown && currentPositionPct < firstBatchSize + secondBatchSize {
-				notional := balance * secondBatchSize
-				amount := notional / k.Close
-				position.entries = append(position.entries, PositionEntry{
-					entryTime:  k.Timestamp,
-					entryPrice: k.Close,
-					amount:     amount,
-					batch:      2,
-				})
-				position.totalAmt += amount
-				position.avgPrice = (position.avgPrice*(position.totalAmt-amount) + k.Close*amount) / position.totalAmt
-				balance -= k.Close * amount * config.FeeRate
-			}
-		}
+// stopLossFill 判断 K 线内是否触及止损，返回成交价和相对止损价的滑点 (bps)
+// 跳空越过止损价时以开盘价为基准，再按剩余振幅的 penalty 比例追加滑点
+func stopLossFill(side string, avgPrice float64, k Kline, stopPct, penalty float64) (float64, float64, bool) {
+	if stopPct <= 0 || avgPrice <= 0 {
+		return 0, 0, false
+	}
+	stop, _ := protectivePrices(side, avgPrice, stopPct, 0)
+	return stopPriceFill(side, stop, k, penalty)
+}
 
-		// 更新资金曲线
-		result.BalanceCurve = append(result.BalanceCurve, balance)
+// stopPriceFill 判断 K 线内是否触及止损价 stop，成交价和滑点的计算同 stopLossFill
+func stopPriceFill(side string, stop float64, k Kline, penalty float64) (float64, float64, bool) {
+	if stop <= 0 {
+		return 0, 0, false
+	}
 
-		// 计算最大回撤
-		if balance > maxBalance {
-			maxBalance = balance
-		}
-		drawdown := (maxBalance - balance) / maxBalance
-		if drawdown > result.MaxDrawdown {
-			result.MaxDrawdown = drawdown
+	if side == "LONG" {
+		if k.Low > stop {
+			return 0, 0, false
 		}
+		base := math.Min(stop, k.Open)
+		fill := base - (base-k.Low)*penalty
+		return fill, (stop - fill) / stop * 1e4, true
 	}
 
-	// 计算统计指标
-	if result.TotalTrades > 0 {
-		result.WinRate = float64(result.WinTrades) / float64(result.TotalTrades)
+	if k.High < stop {
+		return 0, 0, false
 	}
+	base := math.Max(stop, k.Open)
+	fill := base + (k.High-base)*penalty
+	return fill, (fill - stop) / stop * 1e4, true
+}
 
-	var totalWin, totalLose float64
-	for _, t := range result.Trades {
-		if t.PnL > 0 {
-			totalWin += t.PnL
-		} else {
-			totalLose += -t.PnL
-		}
+// RunBacktest 执行回测（超短线 1分钟级别），决策由与实盘相同的 RSIEngine 完成
+func RunBacktest(klines []Kline, config BacktestConfig, strategyConfig StrategyConfig) *BacktestResult {
+	config.applyExits(strategyConfig)
+	return RunEngineBacktest(klines, config, NewRSIEngine(strategyConfig, config.PositionSize).withPyramid(config.Pyramid))
+}
+
+// applyExits 策略参数中设置了止损 / 止盈比例（参数文件或优化的参数组合）时覆盖回测配置
+func (c *BacktestConfig) applyExits(sc StrategyConfig) {
+	if sc.StopLossPct > 0 {
+		c.StopLossPct = sc.StopLossPct
 	}
-	if totalLose > 0 {
-		result.ProfitFactor = totalWin / totalLose
+	if sc.TakeProfitPct > 0 {
+		c.TakeProfitPct = sc.TakeProfitPct
 	}
+}
 
-	return result
+// exitParams 参数组合中的止损 / 止盈比例，用于优化结果列表，未设置时为空
+func exitParams(sc StrategyConfig) string {
+	var s string
+	if sc.StopLossPct > 0 {
+		s += fmt.Sprintf(" sl=%g%%", sc.StopLossPct*100)
+	}
+	if sc.TakeProfitPct > 0 {
+		s += fmt.Sprintf(" tp=%g%%", sc.TakeProfitPct*100)
+	}
+	return s
 }
 
 // PrintResult 打印回测结果
@@ -429,6 +345,48 @@ func PrintResult(result *BacktestResult) {
 	fmt.Printf("总手续费: $%.2f\n", result.TotalFees)
 	fmt.Printf("盈亏比: %.2f\n", result.ProfitFactor)
 	fmt.Printf("最大回撤: %.2f%%\n", result.MaxDrawdown*100)
+	if result.ThrottledEntries > 0 {
+		fmt.Printf("限频跳过入场: %d 次\n", result.ThrottledEntries)
+	}
+	if result.MaintenanceExits > 0 {
+		fmt.Printf("维护时段平仓: %d 次\n", result.MaintenanceExits)
+	}
+	if result.ChaseSkips > 0 {
+		fmt.Printf("追价放弃入场: %d 次\n", result.ChaseSkips)
+	}
+	if result.LimitMisses > 0 {
+		fmt.Printf("限价入场未成交: %d 次\n", result.LimitMisses)
+	}
+	printFeeStats(result.Fees)
+	printEquityFilterStats(result.EquityFilter)
+	printVolTargetStats(result.VolTarget)
+	if result.StopExits > 0 {
+		fmt.Printf("止损出场: %d 次, 平均止损滑点 %.1f bps\n", result.StopExits, result.AvgStopSlippageBps)
+	}
+	if result.TrailingExits > 0 {
+		fmt.Printf("其中移动止损: %d 次\n", result.TrailingExits)
+	}
+	if result.TakeProfitExits > 0 {
+		fmt.Printf("止盈出场: %d 次\n", result.TakeProfitExits)
+	}
+	if result.Liquidations > 0 {
+		fmt.Printf("强平: %d 次, 清算费 $%.2f\n", result.Liquidations, result.LiquidationFees)
+	}
+	if result.MaxMarginUsage > 0 {
+		fmt.Printf("最大保证金占用: %.2f%%\n", result.MaxMarginUsage*100)
+	}
+	if result.SlippageCost > 0 {
+		fmt.Printf("滑点成本: $%.2f, 平均滑点 %.1f bps\n", result.SlippageCost, result.AvgSlippageBps)
+		printParticipation(result.Participation)
+	}
+	if result.FundingEvents > 0 {
+		fmt.Printf("资金费: 净支付 $%.2f (%d 次结算)\n", result.FundingPaid, result.FundingEvents)
+	}
+	printPerformanceStats(result.Performance, result.Exposure.TimeInMarket)
+	printDrawdownStats(result.Drawdown)
+	printRStats(result.RStats, result.RiskPct, result.RiskATRMult)
+	printExposureStats(result.Exposure)
+	printRollingStats(result.RollingSharpe, result.RollingDrawdown)
 
 	// 统计多空表现
 	var longTrades, longWins int
@@ -449,45 +407,415 @@ func PrintResult(result *BacktestResult) {
 			}
 		}
 	}
-	fmt.Println("\n--- 多空分开统计 ---")
-	fmt.Printf("做多: %d 次, 胜率 %.1f%%, 盈亏 $%.2f\n", longTrades, float64(longWins)/float64(longTrades)*100, longPnL)
-	fmt.Printf("做空: %d 次, 胜率 %.1f%%, 盈亏 $%.2f\n", shortTrades, float64(shortWins)/float64(shortTrades)*100, shortPnL)
+	if len(result.Trades) > 0 {
+		fmt.Println("\n--- 多空分开统计 ---")
+		fmt.Printf("做多: %d 次, 胜率 %.1f%%, 盈亏 $%.2f\n", longTrades, float64(longWins)/float64(longTrades)*100, longPnL)
+		fmt.Printf("做空: %d 次, 胜率 %.1f%%, 盈亏 $%.2f\n", shortTrades, float64(shortWins)/float64(shortTrades)*100, shortPnL)
+	}
+
+	pnls := make([]float64, len(result.Trades))
+	returns := make([]float64, len(result.Trades))
+	for i, t := range result.Trades {
+		pnls[i] = t.PnL
+		returns[i] = t.PnL / (t.EntryPrice * t.Amount)
+	}
+	printTradeDistribution(pnls, returns)
+
+	batches := make([]int, len(result.Trades))
+	for i, t := range result.Trades {
+		batches[i] = t.Batch
+	}
+	printBatchBreakdown(batches, pnls, returns, result.TotalPnL)
+
+	entryReasons := make([]string, len(result.Trades))
+	exitReasons := make([]string, len(result.Trades))
+	for i, t := range result.Trades {
+		entryReasons[i] = t.EntryReason
+		exitReasons[i] = t.ExitReason
+	}
+	printReasonBreakdown("入场原因", entryReasons, pnls)
+	printReasonBreakdown("出场原因", exitReasons, pnls)
 	fmt.Println("================================")
 }
 
-// runBacktestCmd 执行回测命令
-func runBacktestCmd(dbPath, symbol string, startTime, endTime int64) {
-	log.Printf("加载 K 线数据: %s", symbol)
-	klines, err := loadKlinesFromDB(dbPath, symbol, startTime, endTime)
+// costSummary 成本对比用的回测摘要
+type costSummary struct {
+	PnL         float64
+	WinRate     float64
+	Trades      int
+	MaxDrawdown float64
+	Fees        float64
+	Slippage    float64
+	Funding     float64
+}
+
+// frictionless 关闭手续费、滑点模型、止损惩罚和资金费的回测配置
+func (c BacktestConfig) frictionless() BacktestConfig {
+	c.FeeRate = 0
+	c.FeeTiers = nil
+	c.Slippage = SlippageModel{}
+	c.StopPenalty = 0
+	c.IntrabarFill = FillTrigger
+	c.Funding = nil
+	c.TradeLog = nil
+	c.Explain = false
+	return c
+}
+
+// summary 成本对比摘要
+func (r *BacktestResult) summary() costSummary {
+	return costSummary{r.TotalPnL, r.WinRate, r.TotalTrades, r.MaxDrawdown, r.TotalFees, r.SlippageCost, r.FundingPaid}
+}
+
+// printCostComparison 并排打印含成本与无成本的回测结果，以及成本消耗的毛利比例
+func printCostComparison(costed, gross costSummary) {
+	fmt.Println("\n--- 成本对比 ---")
+	fmt.Printf("%-10s %14s %14s\n", "", "含成本", "无成本")
+	fmt.Printf("%-10s %14.2f %14.2f\n", "总盈亏", costed.PnL, gross.PnL)
+	fmt.Printf("%-10s %13.2f%% %13.2f%%\n", "胜率", costed.WinRate*100, gross.WinRate*100)
+	fmt.Printf("%-10s %14d %14d\n", "交易次数", costed.Trades, gross.Trades)
+	fmt.Printf("%-10s %13.2f%% %13.2f%%\n", "最大回撤", costed.MaxDrawdown*100, gross.MaxDrawdown*100)
+	fmt.Printf("成本明细: 手续费 $%.2f, 滑点 $%.2f, 资金费 $%.2f\n", costed.Fees, costed.Slippage, costed.Funding)
+
+	consumed := gross.PnL - costed.PnL
+	if gross.PnL > 0 {
+		fmt.Printf("成本消耗: $%.2f，占无成本盈亏的 %.1f%%\n", consumed, consumed/gross.PnL*100)
+	} else {
+		fmt.Printf("成本消耗: $%.2f（无成本时也不盈利）\n", consumed)
+	}
+}
+
+// stopLossTotal 止损出场交易的笔数和合计盈亏（逐批止损时每批计一笔）
+func stopLossTotal(trades []Trade) (int, float64) {
+	n, pnl := 0, 0.0
+	for _, t := range trades {
+		if t.ExitReason == ExitStopLoss {
+			n++
+			pnl += t.PnL
+		}
+	}
+	return n, pnl
+}
+
+// printStopComparison 并排打印均价止损与逐批止损的回测结果
+func printStopComparison(average, batch *BacktestResult) {
+	fmt.Println("\n--- 止损方式对比 ---")
+	fmt.Printf("%-10s %14s %14s\n", "", "均价止损", "逐批止损")
+	fmt.Printf("%-10s %14.2f %14.2f\n", "总盈亏", average.TotalPnL, batch.TotalPnL)
+	fmt.Printf("%-10s %13.2f%% %13.2f%%\n", "胜率", average.WinRate*100, batch.WinRate*100)
+	fmt.Printf("%-10s %14d %14d\n", "交易次数", average.TotalTrades, batch.TotalTrades)
+	fmt.Printf("%-10s %13.2f%% %13.2f%%\n", "最大回撤", average.MaxDrawdown*100, batch.MaxDrawdown*100)
+	fmt.Printf("%-10s %14d %14d\n", "止损触发", average.StopExits, batch.StopExits)
+	an, apnl := stopLossTotal(average.Trades)
+	bn, bpnl := stopLossTotal(batch.Trades)
+	fmt.Printf("%-10s %14d %14d\n", "止损笔数", an, bn)
+	fmt.Printf("%-10s %14.2f %14.2f\n", "止损盈亏", apnl, bpnl)
+}
+
+// BacktestOptions 回测命令选项
+type BacktestOptions struct {
+	CurvePath        string       // 资金曲线 CSV 导出路径
+	Sizing           SizingConfig // 自适应仓位
+	DailyCompounding bool         // 按当日开盘资金计算仓位
+	InfluxURL        string       // InfluxDB 写入地址，非空时导出资金曲线和指标
+	Throttle         ThrottleConfig // 入场频率限制
+	Explain          bool           // 打印入场条件明细
+	StopLossPct      float64        // 价格止损比例
+	StopMode         string         // 分批持仓的止损方式
+	StopCompare      bool           // 额外用另一种止损方式跑一遍并对比
+	EquityMA         int            // 净值曲线过滤的均线笔数，0 表示不启用
+	VolTarget        VolTargetConfig // 波动率目标，Target 为 0 表示不启用
+	StopPenalty      float64        // 止损成交惩罚（K 线振幅比例）
+	TakeProfitPct    float64        // 价格止盈比例
+	Trailing         TrailingConfig // 移动止损和保本止损
+	Pyramid          PyramidConfig  // 分批建仓
+	Hedge            bool           // 对冲模式（多空同时持仓）
+	IntrabarFill     string         // 盘中止损成交假设
+	TradesPath       string         // 逐笔交易 CSV，回测过程中增量写入
+	StreamOnly       bool           // 逐笔交易只写 CSV，不保留在内存中
+	StreamKlines     bool           // 分块流式读取 K 线，不一次性加载到内存（每次回测重新读取）
+	ExportPath       string         // 回测结束后导出交易列表（.csv 或 .json）
+	ExportFormat     ExportFormat   // CSV 导出的区域格式（逐笔交易、交易列表、资金曲线）
+	ReportPath       string         // HTML 回测报告路径，为空时不生成
+	ChartPath        string         // 带买卖点的 K 线图 HTML 路径，为空时不生成
+	ChartFrom        int64          // K 线图起止时间（秒），0 表示不限
+	ChartTo          int64
+	IntrabarFraction float64        // 盘中判定比例，0 表示只用已收盘 K 线
+	MaxChaseBps      float64        // 延迟成交的最大追价 (bps)，0 表示按信号收盘价成交
+	FeeTiers         []FeeTier      // 手续费档位，为空时按固定费率
+	EntryFill        string         // 入场成交方式：市价或限价
+	Maintenance      string         // 维护时段，逗号分隔
+	Slippage         SlippageModel  // 成交滑点模型
+	Funding          bool           // 模拟资金费
+	CostCompare      bool           // 额外跑一遍无成本回测并对比
+	Engine           string         // 策略引擎，为空使用 DefaultEngine
+	Params           BacktestParams // -params 文件中的策略参数，nil 表示使用默认参数
+	Interval         int64          // K 线周期（秒），数据按此重采样
+	ExperimentsPath  string         // 实验记录库，为空时不记录
+}
+
+// maintenanceSchedule 解析 -maintenance，格式错误时退出
+func (opts BacktestOptions) maintenanceSchedule() MaintenanceSchedule {
+	schedule, err := ParseMaintenanceSchedule(strings.Split(opts.Maintenance, ","))
 	if err != nil {
-		log.Fatalf("加载数据失败: %v", err)
+		log.Fatalf("解析维护时段失败: %v", err)
 	}
-	log.Printf("加载 %d 根 1m K 线（超短线模式）", len(klines))
+	return schedule
+}
 
-	if len(klines) < 100 {
-		log.Fatalf("数据不足，至少需要 100 根 K 线")
+// openTradeLog 按选项创建逐笔交易 CSV，未指定时返回 nil
+func openTradeLog(opts BacktestOptions) *TradeLog {
+	if opts.TradesPath == "" {
+		if opts.StreamOnly {
+			log.Fatalf("-stream-only 需要同时指定 -trades")
+		}
+		return nil
+	}
+	tradeLog, err := CreateTradeLog(opts.TradesPath, opts.ExportFormat)
+	if err != nil {
+		log.Fatalf("创建交易 CSV 失败: %v", err)
+	}
+	return tradeLog
+}
+
+// closeTradeLog 关闭逐笔交易 CSV
+func closeTradeLog(tradeLog *TradeLog, opts BacktestOptions) {
+	if tradeLog == nil {
+		return
+	}
+	if err := tradeLog.Close(); err != nil {
+		log.Fatalf("写入交易 CSV 失败: %v", err)
+	}
+	log.Printf("逐笔交易已导出: %s", opts.TradesPath)
+}
+
+// exportTrades 回测结束后按 -export 导出交易列表，未指定时不导出
+func exportTrades(records []TradeRecord, opts BacktestOptions) {
+	if opts.ExportPath == "" {
+		return
+	}
+	if opts.StreamOnly {
+		log.Fatalf("-stream-only 不保留逐笔交易，无法 -export，请使用 -trades")
+	}
+	if err := ExportTrades(opts.ExportPath, records, opts.ExportFormat); err != nil {
+		log.Fatalf("导出交易列表失败: %v", err)
+	}
+	log.Printf("导出 %d 笔交易: %s", len(records), opts.ExportPath)
+}
+
+// runBacktestCmd 执行回测命令
+func runBacktestCmd(dbPath, symbol string, startTime, endTime int64, opts BacktestOptions) {
+	var klines []Kline
+	run := func(config BacktestConfig, engine StrategyEngine) *BacktestResult {
+		return RunEngineBacktest(klines, config, engine)
+	}
+	if opts.StreamKlines {
+		// 流式读取：每次回测（含各种对比）都从数据库重新分块读取
+		log.Printf("流式读取 K 线数据: %s", symbol)
+		run = func(config BacktestConfig, engine StrategyEngine) *BacktestResult {
+			src, err := OpenKlineStream(dbPath, symbol, startTime, endTime, opts.Interval, 0)
+			if err != nil {
+				log.Fatalf("读取数据失败: %v", err)
+			}
+			defer src.Close()
+			config.Interval = opts.Interval
+			result, err := RunEngineBacktestSource(src, config, engine)
+			if err != nil {
+				log.Fatalf("读取数据失败: %v", err)
+			}
+			if len(result.BalanceTimes) < 101 {
+				log.Fatalf("数据不足，至少需要 100 根 K 线")
+			}
+			return result
+		}
+	} else {
+		log.Printf("加载 K 线数据: %s", symbol)
+		var err error
+		klines, err = loadIntervalKlines(dbPath, symbol, startTime, endTime, opts.Interval)
+		if err != nil {
+			log.Fatalf("加载数据失败: %v", err)
+		}
+		log.Printf("加载 %d 根 %ds K 线", len(klines), opts.Interval)
+
+		if len(klines) < 100 {
+			log.Fatalf("数据不足，至少需要 100 根 K 线")
+		}
 	}
 
-	// 直接用 1 分钟 K 线，不重采样
 	config := DefaultBacktestConfig
 	config.Symbol = symbol
+	config.Sizing = opts.Sizing
+	config.DailyCompounding = opts.DailyCompounding
+	config.Throttle = opts.Throttle
+	config.Explain = opts.Explain
+	config.StopLossPct = opts.StopLossPct
+	config.StopMode = opts.StopMode
+	config.StopPenalty = opts.StopPenalty
+	config.TakeProfitPct = opts.TakeProfitPct
+	config.Trailing = opts.Trailing
+	config.Pyramid = opts.Pyramid
+	config.Hedge = opts.Hedge
+	config.IntrabarFill = opts.IntrabarFill
+	config.StreamOnly = opts.StreamOnly
+	config.IntrabarFraction = opts.IntrabarFraction
+	config.MaxChaseBps = opts.MaxChaseBps
+	config.FeeTiers = opts.FeeTiers
+	config.EntryFill = opts.EntryFill
+	config.EquityFilter = EquityFilterConfig{MATrades: opts.EquityMA}
+	config.VolTarget = opts.VolTarget
+	config.Maintenance = opts.maintenanceSchedule()
+	config.Slippage = opts.Slippage
+	config.Funding = loadBacktestFunding(opts, dbPath, symbol, startTime, endTime)
+	config.TradeLog = openTradeLog(opts)
 
 	strategyConfig := DefaultConfig
+	if opts.Params != nil {
+		opts.Params.applyStrategy(&strategyConfig, &config.PositionSize)
+		config.applyExits(strategyConfig)
+		log.Printf("策略参数: %s", opts.Params)
+	}
 
-	result := RunBacktest(klines, config, strategyConfig)
+	// 引擎与实盘的 engine 配置一致，策略参数取回测默认值（或 -params 文件）
+	engineConfig := defaultConfig
+	engineConfig.setStrategyConfig(strategyConfig)
+	engineConfig.PositionSize = config.PositionSize
+	engine, err := NewEngine(opts.Engine, &engineConfig)
+	if err != nil {
+		log.Fatalf("创建策略引擎失败: %v", err)
+	}
+	log.Printf("策略引擎: %s", engine.Name())
+
+	result := run(config, engine)
+	if opts.StreamKlines {
+		log.Printf("流式回测 %d 根 %ds K 线", len(result.BalanceTimes)-1, opts.Interval)
+	}
+	closeTradeLog(config.TradeLog, opts)
+	exportTrades(tradeRecords(result.Trades), opts)
 	PrintResult(result)
 
+	// RSI 以外的引擎不使用 StrategyConfig，只记录仓位比例
+	params := map[string]float64{"position_size": config.PositionSize}
+	if engine.Name() == DefaultEngine {
+		params = strategyParamValues(strategyConfig, config.PositionSize)
+	}
+	recordExperiment(opts.ExperimentsPath, Experiment{
+		Mode:      "backtest",
+		Symbol:    symbol,
+		Engine:    engine.Name(),
+		StartTime: startTime,
+		EndTime:   endTime,
+		Interval:  opts.Interval,
+		Params:    params,
+		Metrics:   backtestMetrics(result),
+	})
+
+	if opts.ReportPath != "" {
+		report := NewBacktestReport(result, symbol, engine.Name(), result.BalanceTimes[0], result.BalanceTimes[len(result.BalanceTimes)-1], backtestReportConfig(config, opts, params))
+		if err := WriteBacktestReport(opts.ReportPath, report); err != nil {
+			log.Fatalf("生成回测报告失败: %v", err)
+		}
+		log.Printf("回测报告已生成: %s", opts.ReportPath)
+	}
+	if opts.ChartPath != "" {
+		chart := NewTradeChart(klines, result.Trades, strategyConfig, symbol, engine.Name(), opts.Interval, opts.ChartFrom, opts.ChartTo)
+		if len(chart.Data.Times) == 0 {
+			log.Fatalf("-from / -to 区间内没有 K 线")
+		}
+		if err := WriteTradeChart(opts.ChartPath, chart); err != nil {
+			log.Fatalf("生成 K 线图失败: %v", err)
+		}
+		log.Printf("K 线图已生成: %s (%d 根 K 线, %d 笔交易)", opts.ChartPath, len(chart.Data.Times), len(chart.Data.Trades))
+	}
+
+	// 盘中模式同时跑一遍收盘模式作对比
+	if config.IntrabarFraction > 0 {
+		closed := config
+		closed.IntrabarFraction = 0
+		closed.TradeLog = nil
+		closed.Explain = false
+		baselineEngine, _ := NewEngine(opts.Engine, &engineConfig)
+		baseline := run(closed, baselineEngine)
+		fmt.Println("\n--- 盘中 vs 收盘判定 ---")
+		fmt.Printf("盘中 (%.0f%%): %d 笔, 胜率 %.1f%%, 盈亏 $%.2f, 最大回撤 %.2f%%\n",
+			config.IntrabarFraction*100, result.TotalTrades, result.WinRate*100, result.TotalPnL, result.MaxDrawdown*100)
+		fmt.Printf("收盘:       %d 笔, 胜率 %.1f%%, 盈亏 $%.2f, 最大回撤 %.2f%%\n",
+			baseline.TotalTrades, baseline.WinRate*100, baseline.TotalPnL, baseline.MaxDrawdown*100)
+	}
+
+	// 无成本诊断：同一引擎配置关闭所有摩擦成本再跑一遍
+	if opts.CostCompare {
+		grossEngine, _ := NewEngine(opts.Engine, &engineConfig)
+		gross := run(config.frictionless(), grossEngine)
+		printCostComparison(result.summary(), gross.summary())
+	}
+
+	// 止损方式对比：同一引擎配置换用另一种止损方式再跑一遍
+	if opts.StopCompare {
+		other := config
+		other.TradeLog = nil
+		other.Explain = false
+		other.StopMode = StopPerBatch
+		if config.StopMode == StopPerBatch {
+			other.StopMode = StopAverage
+		}
+		otherEngine, _ := NewEngine(opts.Engine, &engineConfig)
+		otherResult := run(other, otherEngine)
+		if config.StopMode == StopPerBatch {
+			printStopComparison(otherResult, result)
+		} else {
+			printStopComparison(result, otherResult)
+		}
+	}
+
+	// 净值曲线过滤对比：同一引擎配置不过滤再跑一遍
+	if config.EquityFilter.MATrades > 0 {
+		unfiltered := config
+		unfiltered.TradeLog = nil
+		unfiltered.Explain = false
+		unfiltered.EquityFilter = EquityFilterConfig{}
+		unfilteredEngine, _ := NewEngine(opts.Engine, &engineConfig)
+		printEquityFilterComparison(result, run(unfiltered, unfilteredEngine))
+	}
+
 	// 打印最近几笔交易
 	fmt.Println("\n最近 10 笔交易:")
 	for i := len(result.Trades) - 1; i >= 0 && i >= len(result.Trades)-10; i-- {
 		t := result.Trades[i]
-		fmt.Printf("%s | %s | 入场: %.2f | 出场: %.2f | 盈亏: $%.2f\n",
+		fmt.Printf("%s | %s | 入场: %.2f | 出场: %.2f | 盈亏: $%.2f",
 			time.Unix(t.EntryTime, 0).Format("2006-01-02 15:04"),
 			t.Side,
 			t.EntryPrice,
 			t.ExitPrice,
 			t.PnL,
 		)
+		if t.ExitReason != "" {
+			fmt.Printf(" | %s", t.ExitReason)
+		}
+		if opts.Sizing.Mode != SizingFixed {
+			fmt.Printf(" | 仓位系数: %.2f", t.SizeMult)
+		}
+		fmt.Println()
+	}
+
+	if opts.CurvePath != "" {
+		if err := WriteEquityCSV(opts.CurvePath, result.BalanceTimes, result.EquityCurve, result.RollingSharpe, result.RollingDrawdown, opts.ExportFormat); err != nil {
+			log.Fatalf("导出资金曲线失败: %v", err)
+		}
+		log.Printf("资金曲线已导出: %s", opts.CurvePath)
+	}
+
+	if opts.InfluxURL != "" {
+		exporter := NewInfluxExporter(opts.InfluxURL, backtestTags("rsi", symbol))
+		indicators := map[string][]float64{
+			"rsi":       CalculateRSI(klines, strategyConfig.RSI_PERIOD),
+			"ema_fast":  CalculateEMA(klines, strategyConfig.EMA_FAST),
+			"ema_slow":  CalculateEMA(klines, strategyConfig.EMA_SLOW),
+			"vol_ratio": VolumeRatio(klines, strategyConfig.RSI_PERIOD),
+		}
+		if err := ExportBacktestSeries(exporter, klines, result.BalanceTimes, result.BalanceCurve, result.EquityCurve, indicators); err != nil {
+			log.Fatalf("导出到 InfluxDB 失败: %v", err)
+		}
+		log.Printf("已导出到 InfluxDB")
 	}
 }
 
@@ -498,107 +826,102 @@ type OptimizeResult struct {
 	WinRate   float64
 	Trades    int
 	ProfitFactor float64
+	MaxDrawdown  float64
+	Sharpe       float64 // 日收益夏普比率
+	Calmar       float64 // 年化收益 / 最大回撤
+	MaxConsecLosses int  // 最大连续亏损笔数
 }
 
-// RunOptimize 参数优化（多空分开）
-func RunOptimize(klines []Kline, config BacktestConfig) {
+// evaluateConfig 回测单组参数并汇总为优化结果，指标序列取自 cache
+func evaluateConfig(cache *IndicatorCache, config BacktestConfig, strategyConfig StrategyConfig) OptimizeResult {
+	config.applyExits(strategyConfig)
+	engine := NewRSIEngine(strategyConfig, config.PositionSize).withPyramid(config.Pyramid)
+	if config.IntrabarFraction == 0 {
+		engine.withSeries(cache.series(strategyConfig))
+	}
+	result := RunEngineBacktest(cache.klines, config, engine)
+	pnls := make([]float64, len(result.Trades))
+	for i, t := range result.Trades {
+		pnls[i] = t.PnL
+	}
+	return OptimizeResult{
+		Config:       strategyConfig,
+		TotalPnL:     result.TotalPnL,
+		WinRate:      result.WinRate,
+		Trades:       result.TotalTrades,
+		ProfitFactor: result.ProfitFactor,
+		MaxDrawdown:  result.MaxDrawdown,
+		Sharpe:       result.Performance.Sharpe,
+		Calmar:       result.Performance.Calmar,
+		MaxConsecLosses: MaxConsecutiveLosses(pnls),
+	}
+}
+
+// RunOptimize 参数优化（多空分开），打印排名并返回所有回测过的组合
+func RunOptimize(klines []Kline, config BacktestConfig, opts OptimizeOptions) []OptimizeResult {
 	fmt.Println("\n========== 参数优化 ==========")
+	if opts.Optimizer == OptimizerGA {
+		results, err := RunGeneticOptimize(klines, config, opts.Spec, opts.GA, opts.Objective)
+		if err != nil {
+			log.Fatalf("遗传算法优化失败: %v", err)
+		}
+		reportOptimizeResults(results, opts)
+		return results
+	}
 	fmt.Println("遍历参数空间...")
 
-	var results []OptimizeResult
-
-	// 参数范围
-	oversoldLongRange := []float64{35, 40, 45}
-	entryLongRange := []float64{45, 50, 55}
-	overboughtShortRange := []float64{55, 60, 65}
-	entryShortRange := []float64{45, 50, 55}
-	volRatioRange := []float64{1.0, 1.5, 2.0}
-	emaFastRange := []int{5, 7, 10}
-	emaSlowRange := []int{14, 20, 30}
-
-	total := len(oversoldLongRange) * len(entryLongRange) * len(overboughtShortRange) * len(entryShortRange) * len(volRatioRange) * len(emaFastRange) * len(emaSlowRange)
-	count := 0
-
-	for _, oversoldLong := range oversoldLongRange {
-		for _, entryLong := range entryLongRange {
-			for _, overboughtShort := range overboughtShortRange {
-				for _, entryShort := range entryShortRange {
-					for _, volRatio := range volRatioRange {
-						for _, emaFast := range emaFastRange {
-							for _, emaSlow := range emaSlowRange {
-								// 跳过不合理的参数组合
-								if oversoldLong >= entryLong || overboughtShort <= entryShort || emaFast >= emaSlow {
-									continue
-								}
-
-								strategyConfig := StrategyConfig{
-									RSI_PERIOD:           14,
-									RSI_OVERSOLD_LONG:    oversoldLong,
-									RSI_ENTRY_LONG:       entryLong,
-									RSI_OVERBOUGHT_SHORT: overboughtShort,
-									RSI_ENTRY_SHORT:      entryShort,
-									EMA_FAST:             emaFast,
-									EMA_SLOW:             emaSlow,
-									VOL_RATIO_THRESHOLD:  volRatio,
-								}
-
-								result := RunBacktest(klines, config, strategyConfig)
-
-								results = append(results, OptimizeResult{
-									Config:     strategyConfig,
-									TotalPnL:   result.TotalPnL,
-									WinRate:    result.WinRate,
-									Trades:     result.TotalTrades,
-									ProfitFactor: result.ProfitFactor,
-								})
-
-								count++
-								if count%200 == 0 {
-									fmt.Printf("进度: %d/%d\n", count, total)
-								}
-							}
-						}
-					}
-				}
-			}
-		}
+	grid, err := optimizeGrid(opts.Spec)
+	if err != nil {
+		log.Fatalf("生成参数网格失败: %v", err)
 	}
+	fmt.Printf("共 %d 组参数\n", len(grid))
 
-	// 按盈亏排序
-	sortResults(results)
+	results := evaluateGrid(klines, config, grid)
+	reportOptimizeResults(results, opts)
+	return results
+}
 
-	// 打印 Top 10
-	fmt.Println("\n========== Top 10 参数组合 ==========")
-	fmt.Println("排名 | 总盈亏 | 胜率 | 交易次数 | 盈亏比 | 参数")
-	fmt.Println("-----|--------|------|----------|--------|------")
-	for i, r := range results[:10] {
-		fmt.Printf("%d | $%.2f | %.1f%% | %d | %.2f | long: %.0f->%.0f short: %.0f->%.0f vol=%.1f ema=%d/%d\n",
-			i+1, r.TotalPnL, r.WinRate*100, r.Trades, r.ProfitFactor,
-			r.Config.RSI_OVERSOLD_LONG, r.Config.RSI_ENTRY_LONG,
-			r.Config.RSI_OVERBOUGHT_SHORT, r.Config.RSI_ENTRY_SHORT,
-			r.Config.VOL_RATIO_THRESHOLD, r.Config.EMA_FAST, r.Config.EMA_SLOW)
-	}
+// printTopResults 按优化目标排序并打印 Top 10，交易次数不足的组合不参与排名
+func printTopResults(results []OptimizeResult, objective Objective) {
+	printRankedResults(results, objective, 10)
 }
 
-func sortResults(results []OptimizeResult) {
-	// 按总盈亏降序排序
-	for i := 0; i < len(results); i++ {
-		for j := i + 1; j < len(results); j++ {
-			if results[j].TotalPnL > results[i].TotalPnL {
-				results[i], results[j] = results[j], results[i]
+// printRankedResults 按优化目标排序并打印前 n 组，不满足约束的组合不参与排名
+func printRankedResults(results []OptimizeResult, objective Objective, n int) {
+	objective.Rank(results)
+
+	fmt.Printf("\n========== Top %d 参数组合 (按%s) ==========\n", n, objective.Label())
+	if constraints := objective.Filter.String(); constraints != "" {
+		excluded := 0
+		for _, r := range results {
+			if !objective.Qualified(r) {
+				excluded++
 			}
 		}
+		fmt.Printf("约束: %s，%d/%d 组不满足约束，不参与排名\n", constraints, excluded, len(results))
+	}
+	fmt.Printf("排名 | %s | 总盈亏 | 胜率 | 交易次数 | 盈亏比 | 夏普 | 卡玛 | 最大连亏 | 最大回撤 | 参数\n", objective.Label())
+	fmt.Println("-----|------|--------|------|----------|--------|------|------|----------|----------|------")
+	for i, r := range results {
+		if i >= n || !objective.Qualified(r) {
+			break
+		}
+		fmt.Printf("%d | %.4f | $%.2f | %.1f%% | %d | %.2f | %.2f | %.2f | %d | %.2f%% | long: %.0f->%.0f short: %.0f->%.0f vol=%.1f ema=%d/%d%s\n",
+			i+1, objective.Score(r), r.TotalPnL, r.WinRate*100, r.Trades, r.ProfitFactor, r.Sharpe, r.Calmar, r.MaxConsecLosses, r.MaxDrawdown*100,
+			r.Config.RSI_OVERSOLD_LONG, r.Config.RSI_ENTRY_LONG,
+			r.Config.RSI_OVERBOUGHT_SHORT, r.Config.RSI_ENTRY_SHORT,
+			r.Config.VOL_RATIO_THRESHOLD, r.Config.EMA_FAST, r.Config.EMA_SLOW, exitParams(r.Config))
 	}
 }
 
 // runOptimizeCmd 执行优化命令
-func runOptimizeCmd(dbPath, symbol string, startTime, endTime int64) {
+func runOptimizeCmd(dbPath, symbol string, startTime, endTime int64, opts OptimizeOptions) {
 	log.Printf("加载 K 线数据: %s", symbol)
-	klines, err := loadKlinesFromDB(dbPath, symbol, startTime, endTime)
+	klines, err := loadIntervalKlines(dbPath, symbol, startTime, endTime, opts.Interval)
 	if err != nil {
 		log.Fatalf("加载数据失败: %v", err)
 	}
-	log.Printf("加载 %d 根 1m K 线（超短线模式）", len(klines))
+	log.Printf("加载 %d 根 %ds K 线", len(klines), opts.Interval)
 
 	if len(klines) < 100 {
 		log.Fatalf("数据不足")
@@ -606,6 +929,22 @@ func runOptimizeCmd(dbPath, symbol string, startTime, endTime int64) {
 
 	config := DefaultBacktestConfig
 	config.Symbol = symbol
-
-	RunOptimize(klines, config)
+	config.Interval = opts.Interval
+
+	results := RunOptimize(klines, config, opts)
+	writeOptArchive(opts.ArchivePath, OptArchiveHeader{
+		Symbol:    symbol,
+		StartTime: startTime,
+		EndTime:   endTime,
+		Interval:  opts.Interval,
+		Optimizer: opts.Optimizer,
+	}, results)
+	recordOptimizeExperiment(opts.ExperimentsPath, Experiment{
+		Mode:      "optimize",
+		Symbol:    symbol,
+		Engine:    DefaultEngine,
+		StartTime: startTime,
+		EndTime:   endTime,
+		Interval:  opts.Interval,
+	}, config, results, opts.Objective)
 }
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"sort"
+	"time"
+)
+
+// ReportParam 回测报告中列出的一项配置
+type ReportParam struct {
+	Name  string
+	Value string
+}
+
+// MonthlyPnL 按 UTC 自然月汇总的回测表现，盈亏与收益率按权益（含浮动盈亏和资金费）计算，
+// 交易次数和胜率按平仓时间归属
+type MonthlyPnL struct {
+	Month  string
+	PnL    float64
+	Return float64
+	Trades int
+	Wins   int
+}
+
+// WinRate 当月胜率
+func (m MonthlyPnL) WinRate() float64 {
+	if m.Trades == 0 {
+		return 0
+	}
+	return float64(m.Wins) / float64(m.Trades)
+}
+
+// reportChart 嵌入页面的图表数据（日频）
+type reportChart struct {
+	Days     []string     `json:"days"`
+	Equity   []float64    `json:"equity"`
+	Drawdown []float64    `json:"drawdown"` // 当日最大回撤（%）
+	Hist     []HistBucket `json:"hist"`     // 每笔收益率（%）分布
+	PnLHist  []HistBucket `json:"pnl_hist"` // 每笔盈亏（$）分布
+	// 滚动 RollingWindowDays 天的夏普和最大回撤（%），从第一个完整窗口开始
+	RollingDays     []string  `json:"rolling_days"`
+	RollingSharpe   []float64 `json:"rolling_sharpe"`
+	RollingDrawdown []float64 `json:"rolling_drawdown"`
+}
+
+// DistributionStats 分布的均值、标准差、偏度和超额峰度
+type DistributionStats struct {
+	Mean, Std, Skew, Kurt float64
+}
+
+// newDistributionStats 按 Moments 计算
+func newDistributionStats(values []float64) DistributionStats {
+	var d DistributionStats
+	d.Mean, d.Std, d.Skew, d.Kurt = Moments(values)
+	return d
+}
+
+// BacktestReport 回测 HTML 报告
+type BacktestReport struct {
+	Symbol  string
+	Engine  string
+	From    int64
+	To      int64
+	Result  *BacktestResult
+	Config  []ReportParam
+	Monthly []MonthlyPnL
+	Chart   reportChart
+	// 每笔收益率（%）和每笔盈亏（$）的分布矩
+	ReturnStats DistributionStats
+	PnLStats    DistributionStats
+}
+
+// RollingWindow 滚动指标窗口（天），供模板使用
+func (r *BacktestReport) RollingWindow() int { return RollingWindowDays }
+
+// reportHistBuckets 收益率分布的桶数
+const reportHistBuckets = 20
+
+// NewBacktestReport 由回测结果生成报告数据，config 为页面中列出的回测配置
+func NewBacktestReport(result *BacktestResult, symbol, engine string, from, to int64, config []ReportParam) *BacktestReport {
+	r := &BacktestReport{Symbol: symbol, Engine: engine, From: from, To: to, Result: result, Config: config}
+
+	// 回撤按逐 K 线权益计算，每天取当日最大值
+	peak := 0.0
+	for i, eq := range result.EquityCurve {
+		if i >= len(result.BalanceTimes) {
+			break
+		}
+		peak = max(peak, eq)
+		dd := 0.0
+		if peak > 0 {
+			dd = (peak - eq) / peak * 100
+		}
+		day := time.Unix(result.BalanceTimes[i], 0).UTC().Format("2006-01-02")
+		if n := len(r.Chart.Days); n > 0 && r.Chart.Days[n-1] == day {
+			r.Chart.Equity[n-1] = eq
+			r.Chart.Drawdown[n-1] = max(r.Chart.Drawdown[n-1], dd)
+			continue
+		}
+		r.Chart.Days = append(r.Chart.Days, day)
+		r.Chart.Equity = append(r.Chart.Equity, eq)
+		r.Chart.Drawdown = append(r.Chart.Drawdown, dd)
+	}
+
+	returns := make([]float64, 0, len(result.Trades))
+	pnls := make([]float64, 0, len(result.Trades))
+	for _, t := range result.Trades {
+		pnls = append(pnls, t.PnL)
+		if notional := t.EntryPrice * t.Amount; notional > 0 {
+			returns = append(returns, t.PnL/notional*100)
+		}
+	}
+	r.Chart.Hist = Histogram(returns, reportHistBuckets)
+	r.Chart.PnLHist = Histogram(pnls, reportHistBuckets)
+	r.ReturnStats = newDistributionStats(returns)
+	r.PnLStats = newDistributionStats(pnls)
+
+	for i, p := range result.RollingSharpe {
+		if i >= len(result.RollingDrawdown) {
+			break
+		}
+		r.Chart.RollingDays = append(r.Chart.RollingDays, time.Unix(p.Timestamp, 0).UTC().Format("2006-01-02"))
+		r.Chart.RollingSharpe = append(r.Chart.RollingSharpe, p.Value)
+		r.Chart.RollingDrawdown = append(r.Chart.RollingDrawdown, result.RollingDrawdown[i].Value*100)
+	}
+
+	r.Monthly = monthlyPnL(result)
+	return r
+}
+
+// monthlyPnL 按月汇总权益变化和平仓交易
+func monthlyPnL(result *BacktestResult) []MonthlyPnL {
+	var months []MonthlyPnL
+	index := make(map[string]int)
+	prev := 0.0 // 上月末权益
+	for i, eq := range result.EquityCurve {
+		if i >= len(result.BalanceTimes) {
+			break
+		}
+		if i == 0 {
+			prev = eq
+		}
+		month := time.Unix(result.BalanceTimes[i], 0).UTC().Format("2006-01")
+		j, ok := index[month]
+		if !ok {
+			if n := len(months); n > 0 {
+				prev += months[n-1].PnL
+			}
+			j = len(months)
+			index[month] = j
+			months = append(months, MonthlyPnL{Month: month})
+		}
+		months[j].PnL = eq - prev
+		if prev > 0 {
+			months[j].Return = months[j].PnL / prev
+		}
+	}
+
+	for _, t := range result.Trades {
+		if j, ok := index[time.Unix(t.ExitTime, 0).UTC().Format("2006-01")]; ok {
+			months[j].Trades++
+			if t.PnL > 0 {
+				months[j].Wins++
+			}
+		}
+	}
+	return months
+}
+
+// backtestReportConfig 报告中列出的回测配置和策略参数（按参数名排序）
+func backtestReportConfig(config BacktestConfig, opts BacktestOptions, params map[string]float64) []ReportParam {
+	fees := fmt.Sprintf("%.4f%%", config.FeeRate*100)
+	if len(config.FeeTiers) > 0 {
+		fees = fmt.Sprintf("%d 档（按累计成交额）", len(config.FeeTiers))
+	}
+	entry := "市价"
+	if config.EntryFill == EntryLimit {
+		entry = "限价"
+	}
+	slip := "无"
+	if config.Slippage.Mode != SlipNone {
+		slip = fmt.Sprintf("%s (%g bps, 系数 %g)", config.Slippage.Mode, config.Slippage.Bps, config.Slippage.Factor)
+	}
+	sizing := "固定比例"
+	if config.Sizing.Mode != SizingFixed {
+		sizing = config.Sizing.Mode
+	}
+	out := []ReportParam{
+		{"K 线周期", intervalName(opts.Interval)},
+		{"初始资金", fmt.Sprintf("$%.2f", config.StartBalance)},
+		{"手续费", fees},
+		{"入场成交", entry},
+		{"滑点", slip},
+		{"杠杆", fmt.Sprintf("%gx", config.Leverage)},
+		{"仓位模式", sizing},
+		{"止损", fmt.Sprintf("%g", config.StopLossPct)},
+		{"止盈", fmt.Sprintf("%g", config.TakeProfitPct)},
+		{"移动止损", trailingName(config.Trailing)},
+		{"分批建仓", pyramidName(config.Pyramid)},
+		{"对冲模式", fmt.Sprintf("%v", config.Hedge)},
+		{"资金费", fmt.Sprintf("%v", len(config.Funding) > 0)},
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		out = append(out, ReportParam{name, fmt.Sprintf("%g", params[name])})
+	}
+	return out
+}
+
+// backtestReportHTML 回测报告模板，图表由内嵌脚本在 canvas 上绘制，不依赖外部资源
+var backtestReportHTML = template.Must(template.New("backtest").Funcs(template.FuncMap{
+	"date":    func(ts int64) string { return time.Unix(ts, 0).UTC().Format("2006-01-02") },
+	"money":   func(v float64) string { return fmt.Sprintf("%.2f", v) },
+	"percent": func(v float64) string { return fmt.Sprintf("%.2f%%", v*100) },
+	"ratio":   func(v float64) string { return fmt.Sprintf("%.2f", v) },
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>回测报告 {{.Symbol}} {{date .From}} ~ {{date .To}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+canvas { border: 1px solid #eee; margin-bottom: 2em; }
+.pos { color: #1a7f37; } .neg { color: #cf222e; }
+</style></head><body>
+<h1>回测报告 {{.Symbol}} ({{.Engine}}) {{date .From}} ~ {{date .To}} (UTC)</h1>
+{{with .Result}}<table>
+<tr><td>总交易次数</td><td>{{.TotalTrades}}</td><td>胜率</td><td>{{percent .WinRate}}</td></tr>
+<tr><td>总盈亏</td><td>${{money .TotalPnL}}</td><td>总手续费</td><td>${{money .TotalFees}}</td></tr>
+<tr><td>盈亏比</td><td>{{ratio .ProfitFactor}}</td><td>最大回撤</td><td>{{percent .MaxDrawdown}}</td></tr>
+<tr><td>年化收益</td><td>{{percent .Performance.CAGR}}</td><td>夏普</td><td>{{ratio .Performance.Sharpe}}</td></tr>
+<tr><td>索提诺</td><td>{{ratio .Performance.Sortino}}</td><td>卡玛</td><td>{{ratio .Performance.Calmar}}</td></tr>
+</table>{{end}}
+<h2>权益曲线</h2>
+<canvas id="equity" width="1000" height="300"></canvas>
+<h2>回撤 (%)</h2>
+<canvas id="drawdown" width="1000" height="200"></canvas>
+{{if .Chart.RollingDays}}<h2>滚动 {{.RollingWindow}} 天夏普</h2>
+<canvas id="rolling_sharpe" width="1000" height="200"></canvas>
+<h2>滚动 {{.RollingWindow}} 天最大回撤 (%)</h2>
+<canvas id="rolling_drawdown" width="1000" height="200"></canvas>
+{{end}}<h2>每笔收益率分布 (%)</h2>
+<canvas id="hist" width="1000" height="250"></canvas>
+{{if .Chart.Hist}}{{with .ReturnStats}}<p>均值 {{ratio .Mean}}% | 标准差 {{ratio .Std}}% | 偏度 {{ratio .Skew}} | 超额峰度 {{ratio .Kurt}}</p>{{end}}{{end}}
+<h2>每笔盈亏分布 ($)</h2>
+<canvas id="pnl_hist" width="1000" height="250"></canvas>
+{{if .Chart.PnLHist}}{{with .PnLStats}}<p>均值 ${{money .Mean}} | 标准差 ${{money .Std}} | 偏度 {{ratio .Skew}} | 超额峰度 {{ratio .Kurt}}</p>{{end}}{{end}}
+<h2>月度盈亏</h2>
+<table><tr><th>月份</th><th>盈亏</th><th>收益率</th><th>平仓</th><th>胜率</th></tr>
+{{range .Monthly}}<tr><td>{{.Month}}</td><td class="{{if lt .PnL 0.0}}neg{{else}}pos{{end}}">{{money .PnL}}</td><td>{{percent .Return}}</td><td>{{.Trades}}</td><td>{{percent .WinRate}}</td></tr>
+{{end}}</table>
+<h2>回测配置</h2>
+<table>{{range .Config}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+<script>
+var data = {{.Chart}};
+function frame(id, lo, hi) {
+  var c = document.getElementById(id), g = c.getContext("2d");
+  var pad = 60, w = c.width - pad - 10, h = c.height - 30;
+  g.font = "11px sans-serif"; g.fillStyle = "#555"; g.strokeStyle = "#ddd";
+  for (var i = 0; i <= 4; i++) {
+    var v = lo + (hi - lo) * i / 4, y = 10 + h - h * i / 4;
+    g.beginPath(); g.moveTo(pad, y); g.lineTo(pad + w, y); g.stroke();
+    g.fillText(v.toFixed(2), 2, y + 4);
+  }
+  return {g: g, x: function(f) { return pad + w * f; }, y: function(v) { return 10 + h - h * (v - lo) / (hi - lo || 1); }, h: h};
+}
+function line(id, days, values, color, invert) {
+  if (!values || values.length < 2) return;
+  var lo = Math.min.apply(null, values), hi = Math.max.apply(null, values);
+  if (invert) { var t = lo; lo = hi; hi = t; }
+  var f = frame(id, lo, hi), g = f.g;
+  g.strokeStyle = color; g.beginPath();
+  values.forEach(function(v, i) { var x = f.x(i / (values.length - 1)), y = f.y(v); i ? g.lineTo(x, y) : g.moveTo(x, y); });
+  g.stroke();
+  g.fillStyle = "#555";
+  g.fillText(days[0], f.x(0), f.h + 25);
+  g.fillText(days[days.length - 1], f.x(1) - 60, f.h + 25);
+}
+function hist(id, buckets) {
+  if (!buckets || !buckets.length) return;
+  var f = frame(id, 0, Math.max.apply(null, buckets.map(function(b) { return b.Count; }))), g = f.g;
+  var bw = 1 / buckets.length;
+  buckets.forEach(function(b, i) {
+    g.fillStyle = b.High <= 0 ? "#cf222e" : (b.Low >= 0 ? "#1a7f37" : "#888");
+    var x = f.x(i * bw), y = f.y(b.Count);
+    g.fillRect(x + 1, y, f.x(bw) - f.x(0) - 2, f.y(0) - y);
+    if (i % 2 === 0) { g.fillStyle = "#555"; g.fillText(b.Low.toFixed(2), x, f.h + 25); }
+  });
+}
+line("equity", data.days, data.equity, "#0969da", false);
+line("drawdown", data.days, data.drawdown, "#cf222e", true);
+line("rolling_sharpe", data.rolling_days, data.rolling_sharpe, "#8250df", false);
+line("rolling_drawdown", data.rolling_days, data.rolling_drawdown, "#cf222e", true);
+hist("hist", data.hist);
+hist("pnl_hist", data.pnl_hist);
+</script>
+</body></html>
+`))
+
+// WriteBacktestReport 将回测报告写为单个 HTML 文件
+func WriteBacktestReport(path string, r *BacktestReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := backtestReportHTML.Execute(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// premiumIndexURL 币安合约标记价格与指数价格接口
+const premiumIndexURL = "https://fapi.binance.com/fapi/v1/premiumIndex"
+
+// BasisSample 一次基差采样：指数价格、标记价格、最新成交价
+type BasisSample struct {
+	Time  int64
+	Index float64
+	Mark  float64
+	Last  float64
+}
+
+// Bps 基差：最新价相对指数价格 (bps)，永续溢价为正
+func (b BasisSample) Bps() float64 {
+	if b.Index <= 0 {
+		return 0
+	}
+	return (b.Last - b.Index) / b.Index * 1e4
+}
+
+// fetchPremiumIndex 查询标记价格和指数价格
+func fetchPremiumIndex(client *http.Client, symbol string) (mark, index float64, err error) {
+	resp, err := client.Get(premiumIndexURL + "?symbol=" + url.QueryEscape(symbol))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("premium index: http %d", resp.StatusCode)
+	}
+
+	var body struct {
+		MarkPrice  string `json:"markPrice"`
+		IndexPrice string `json:"indexPrice"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, 0, fmt.Errorf("premium index: %w", err)
+	}
+	mark, err = strconv.ParseFloat(body.MarkPrice, 64)
+	if err != nil || mark <= 0 {
+		return 0, 0, fmt.Errorf("premium index: invalid markPrice %q", body.MarkPrice)
+	}
+	index, err = strconv.ParseFloat(body.IndexPrice, 64)
+	if err != nil || index <= 0 {
+		return 0, 0, fmt.Errorf("premium index: invalid indexPrice %q", body.IndexPrice)
+	}
+	return mark, index, nil
+}
+
+// BasisMonitor 每周期采样基差，供日志展示、入场过滤和交易日志记录
+type BasisMonitor struct {
+	client *http.Client
+	last   BasisSample // 最近一次成功的采样，Time 为 0 表示尚无数据
+}
+
+// NewBasisMonitor 创建基差监控
+func NewBasisMonitor() *BasisMonitor {
+	return &BasisMonitor{client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// updateBasis 采样指数 / 标记 / 最新价并打印，查询失败时保留上次采样且只记录日志
+func (s *Strategy) updateBasis() {
+	if s.basis == nil || len(s.klines) == 0 {
+		return
+	}
+	mark, index, err := fetchPremiumIndex(s.basis.client, s.config.Symbol)
+	if err != nil {
+		log.Printf("查询指数价格失败: %v", err)
+		return
+	}
+
+	last := s.klines[len(s.klines)-1].Close
+	if s.client != nil {
+		if ticker, err := s.client.FutureTicker(s.config.Symbol); err == nil && ticker.Price > 0 {
+			last = ticker.Price
+		}
+	}
+	s.basis.last = BasisSample{Time: time.Now().Unix(), Index: index, Mark: mark, Last: last}
+	log.Printf("指数 %.2f | 标记 %.2f | 最新 %.2f | 基差 %.1f bps", index, mark, last, s.basis.last.Bps())
+
+	if s.influx != nil {
+		s.influx.Point("basis", map[string]float64{
+			"index":     index,
+			"mark":      mark,
+			"last":      last,
+			"basis_bps": s.basis.last.Bps(),
+		}, s.basis.last.Time)
+	}
+}
+
+// basisBps 最近一次采样的基差，未监控或尚无数据时为 0
+func (s *Strategy) basisBps() float64 {
+	if s.basis == nil || s.basis.last.Time == 0 {
+		return 0
+	}
+	return s.basis.last.Bps()
+}
+
+// basisStretched 基差朝入场方向过度拉伸时返回 true：永续溢价超过 max_basis_bps 时不开多、
+// 折价超过时不开空，避免在基差回归前追入
+func (s *Strategy) basisStretched(signal Signal) bool {
+	if s.config.MaxBasisBps <= 0 || s.basis == nil || s.basis.last.Time == 0 {
+		return false
+	}
+	bps := s.basis.last.Bps()
+	if (signal == SignalLong && bps > s.config.MaxBasisBps) || (signal == SignalShort && bps < -s.config.MaxBasisBps) {
+		log.Printf("基差 %.1f bps 超过 %.1f bps，跳过信号: %v", bps, s.config.MaxBasisBps, signal)
+		return true
+	}
+	return false
+}
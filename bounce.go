@@ -23,6 +23,7 @@ type BounceConfig struct {
 	OtherBatchSize  float64 // 其他份仓位（15%）
 	BatchInterval   int64   // 加仓间隔（秒）
 	MaxBatches      int     // 最大批次（7份）
+	Pyramid         PyramidConfig // 分批建仓，未配置 sizes / interval 时按上面四项
 	// 出场
 	BounceTarget    float64 // 反弹目标比例（0.25 = 25%）
 	ProfitThreshold float64 // 分批止盈触发（0.70 = 70%）
@@ -31,6 +32,17 @@ type BounceConfig struct {
 	ExitPercent     float64 // 每次减仓比例（0.20 = 20%）
 	MaxHoldTime     int64   // 最大持仓时间（秒）
 	RSIExit         float64 // RSI 止损阈值
+	// 仓位
+	Sizing           SizingConfig // 自适应仓位
+	DailyCompounding bool         // 按当日开盘资金（UTC）计算仓位
+	Throttle         ThrottleConfig // 入场频率限制
+	Filter           SymbolFilter   // 交易所数量步长和最小名义价值，分批止盈按步长取整并清扫碎仓
+	Slippage         SlippageModel  // 成交滑点模型
+	Funding          []FundingRate  // 历史资金费率，nil 表示不模拟
+	RiskPct          float64        // 计算 R 倍数的止损距离，0 取 defaultRiskPct
+	// 输出
+	TradeLog   *TradeLog // 逐笔交易 CSV，nil 表示不写
+	StreamOnly bool      // 只写 TradeLog，不在内存中保留逐笔交易
 }
 
 // DefaultBounceConfig 默认配置（降低目标）
@@ -67,9 +79,33 @@ type BouncePosition struct {
 	totalAmt       float64
 	avgPrice       float64
 	lastBatchTime  int64    // 上次加仓时间
+	lastBatchPrice float64  // 上次加仓的成交价
 	batchCount     int      // 当前批次
 	startExitTime  int64    // 开始减仓时间
 	exitCount      int      // 减仓次数
+	sizeMult       float64  // 开仓时的仓位系数
+	realizedPnL    float64  // 已实现盈亏（含分批止盈）
+}
+
+// pyramid 反弹策略的分批建仓：Pyramid 未配置 sizes 时为 FirstBatchSize + OtherBatchSize × (MaxBatches-1)，
+// 未配置 interval 时为 BatchInterval
+func (c BounceConfig) pyramid() PyramidConfig {
+	p := c.Pyramid
+	if len(p.Sizes) == 0 {
+		p.Sizes = []float64{c.FirstBatchSize}
+		for i := 1; i < c.MaxBatches; i++ {
+			p.Sizes = append(p.Sizes, c.OtherBatchSize)
+		}
+	}
+	if p.Interval == 0 {
+		p.Interval = c.BatchInterval
+	}
+	return p
+}
+
+// view 分批建仓判断加仓用的持仓
+func (p *BouncePosition) view() pyramidPosition {
+	return pyramidPosition{side: p.side, entries: p.batchCount, lastTime: p.lastBatchTime, lastPrice: p.lastBatchPrice}
 }
 
 // BounceEntry 入场记录
@@ -91,6 +127,9 @@ type BounceTrade struct {
 	PnL        float64
 	Fee        float64
 	Reason     string
+	Batch      int     // 第几批入场
+	SizeMult   float64 // 入场时的仓位系数
+	R          float64 // R 倍数：盈亏 / 初始风险
 }
 
 // BounceResult 回测结果
@@ -103,20 +142,37 @@ type BounceResult struct {
 	WinRate      float64
 	ProfitFactor float64
 	MaxDrawdown  float64
+	Drawdown     DrawdownStats // 回撤持续时间与恢复时间
 	Trades       []BounceTrade
-	BalanceCurve []float64
+	BalanceCurve []float64 // 已实现余额
+	EquityCurve  []float64 // 按收盘价计入持仓浮动盈亏的权益，回撤和夏普基于此曲线
+	BalanceTimes []int64   // 与 BalanceCurve / EquityCurve 对应的时间戳
+	// 滚动指标（日频，窗口 RollingWindowDays）
+	RollingSharpe   []EquityPoint
+	RollingDrawdown []EquityPoint
+	ThrottledEntries int // 因频率限制跳过的入场次数
+	Exposure         ExposureStats // 资金占用与按占用资金计的收益
+	SlippageCost     float64       // 滑点模型造成的成本 (USDT)，已计入盈亏
+	AvgSlippageBps   float64       // 按成交名义价值加权的平均滑点 (bps)
+	Participation    ParticipationStats // 订单数量占成交所在 K 线成交量的比例
+	FundingPaid      float64       // 净支付的资金费 (USDT)，负数为净收取，已计入总盈亏
+	FundingEvents    int           // 持仓经历的资金费结算次数
+	RStats           RStats        // 按 R 倍数计的期望和 SQN
+	RiskPct          float64       // 1R 对应的止损距离
 }
 
 // RunBounceBacktest 执行反弹策略回测
 func RunBounceBacktest(klines []Kline, config BounceConfig) *BounceResult {
 	result := &BounceResult{
 		BalanceCurve: []float64{config.StartBalance},
+		EquityCurve:  []float64{config.StartBalance},
 	}
 
 	n := len(klines)
 	if n < config.DropLookback+20 {
 		return result
 	}
+	result.BalanceTimes = append(result.BalanceTimes, klines[config.DropLookback-1].Timestamp)
 
 	// 计算指标
 	rsi := CalculateRSI(klines, 14)
@@ -125,13 +181,42 @@ func RunBounceBacktest(klines []Kline, config BounceConfig) *BounceResult {
 
 	balance := config.StartBalance
 	var position *BouncePosition
-	maxBalance := balance
+	maxEquity := balance
+	sizer := NewAdaptiveSizer(config.Sizing)
+	sizingBalance := NewSizingBalance(config.DailyCompounding)
+	throttle := NewEntryThrottle(config.Throttle)
+	pyramid := config.pyramid()
+	var grossWin, grossLoss float64
+	var exposure exposureTracker
+	var slippage slippageTracker
+	funding := fundingCursor{rates: config.Funding}
+	var rs rTracker
+	// tryEntry 入场信号触发时检查频率限制，允许则计数
+	tryEntry := func(ts int64) bool {
+		if !throttle.Allow(ts) {
+			result.ThrottledEntries++
+			return false
+		}
+		throttle.Record(ts)
+		return true
+	}
 
 	for i := config.DropLookback; i < n; i++ {
 		k := klines[i]
+		sizingBalance.Base(k.Timestamp, balance) // 按日复利时记下当日开盘资金
 		currentRSI := rsi[i]
 		prevRSI := rsi[i-1]
 
+		// 资金费结算：按结算所在 K 线开盘价计持仓名义价值
+		if rate, n := funding.due(k.Timestamp); n > 0 && position != nil {
+			paid := fundingPayment(position.side, position.totalAmt*k.Open, rate)
+			balance -= paid
+			position.realizedPnL -= paid
+			result.TotalPnL -= paid
+			result.FundingPaid += paid
+			result.FundingEvents += n
+		}
+
 		// ========== 检测下跌 ==========
 		// 找最近 config.DropLookback 根 K 线的最高价和最低价
 		highPrice := klines[i-1].High
@@ -183,7 +268,8 @@ func RunBounceBacktest(klines []Kline, config BounceConfig) *BounceResult {
 				if expectedExitCount > position.exitCount {
 					// 执行减仓
 					closePercent := config.ExitPercent
-					closeAmt := position.totalAmt * closePercent
+					closeAmt := config.Filter.CloseQty(position.totalAmt, closePercent, k.Close)
+					exitPrice := slippage.fill(config.Slippage, k.Close, false, closeAmt, k)
 					
 					// 从最早的仓位开始平
 					var newEntries []BounceEntry
@@ -209,33 +295,44 @@ func RunBounceBacktest(klines []Kline, config BounceConfig) *BounceResult {
 								ExitTime:   k.Timestamp,
 								Side:       position.side,
 								EntryPrice: entry.entryPrice,
-								ExitPrice:  k.Close,
+								ExitPrice:  exitPrice,
 								Amount:     closeThis,
-								Fee:        (entry.entryPrice + k.Close) * closeThis * config.FeeRate,
+								Batch:      entry.batch,
+								SizeMult:   position.sizeMult,
+								Fee:        (entry.entryPrice + exitPrice) * closeThis * config.FeeRate,
 								Reason:     fmt.Sprintf("分批止盈#%d(%.1f%%)", position.exitCount+1, currentBounce*100),
 							}
 							if position.side == "LONG" {
-								trade.PnL = (k.Close - entry.entryPrice) * closeThis
+								trade.PnL = (exitPrice - entry.entryPrice) * closeThis
 							} else {
-								trade.PnL = (entry.entryPrice - k.Close) * closeThis
+								trade.PnL = (entry.entryPrice - exitPrice) * closeThis
 							}
 							trade.PnL -= trade.Fee
+							trade.R = RMultiple(trade.PnL, trade.EntryPrice, trade.Amount, config.RiskPct)
+							rs.add(trade.R)
 
 							balance += trade.PnL
-							result.Trades = append(result.Trades, trade)
+							position.realizedPnL += trade.PnL
+							if !config.StreamOnly {
+								result.Trades = append(result.Trades, trade)
+							}
+							config.TradeLog.WriteBounceTrade(trade)
 							result.TotalPnL += trade.PnL
 							result.TotalFees += trade.Fee
 							result.TotalTrades++
 							if trade.PnL > 0 {
 								result.WinTrades++
+								grossWin += trade.PnL
 							} else {
 								result.LoseTrades++
+								grossLoss += -trade.PnL
 							}
 						} else {
 							newEntries = append(newEntries, entry)
 						}
 					}
 
+					config.TradeLog.Flush()
 					position.entries = newEntries
 					position.totalAmt = 0
 					for _, e := range newEntries {
@@ -243,8 +340,8 @@ func RunBounceBacktest(klines []Kline, config BounceConfig) *BounceResult {
 					}
 					position.exitCount++
 
-					// 如果仓位已空，清空持仓
-					if position.totalAmt < 0.0001 {
+					// 如果仓位已空（或只剩碎仓），清空持仓
+					if position.totalAmt < 0.0001 || config.Filter.IsDust(position.totalAmt, k.Close) {
 						shouldClose = true
 						closeReason = "分批止盈完成"
 					}
@@ -253,6 +350,7 @@ func RunBounceBacktest(klines []Kline, config BounceConfig) *BounceResult {
 
 			// 执行全平
 			if shouldClose && len(position.entries) > 0 {
+				exitPrice := slippage.fill(config.Slippage, k.Close, position.side == "SHORT", position.totalAmt, k)
 				for _, entry := range position.entries {
 					if entry.amount <= 0 {
 						continue
@@ -262,29 +360,41 @@ func RunBounceBacktest(klines []Kline, config BounceConfig) *BounceResult {
 						ExitTime:   k.Timestamp,
 						Side:       position.side,
 						EntryPrice: entry.entryPrice,
-						ExitPrice:  k.Close,
+						ExitPrice:  exitPrice,
 						Amount:     entry.amount,
-						Fee:        (entry.entryPrice + k.Close) * entry.amount * config.FeeRate,
+						Batch:      entry.batch,
+						SizeMult:   position.sizeMult,
+						Fee:        (entry.entryPrice + exitPrice) * entry.amount * config.FeeRate,
 						Reason:     closeReason,
 					}
 					if position.side == "LONG" {
-						trade.PnL = (k.Close - entry.entryPrice) * entry.amount
+						trade.PnL = (exitPrice - entry.entryPrice) * entry.amount
 					} else {
-						trade.PnL = (entry.entryPrice - k.Close) * entry.amount
+						trade.PnL = (entry.entryPrice - exitPrice) * entry.amount
 					}
 					trade.PnL -= trade.Fee
+					trade.R = RMultiple(trade.PnL, trade.EntryPrice, trade.Amount, config.RiskPct)
+					rs.add(trade.R)
 
 					balance += trade.PnL
-					result.Trades = append(result.Trades, trade)
+					position.realizedPnL += trade.PnL
+					if !config.StreamOnly {
+						result.Trades = append(result.Trades, trade)
+					}
+					config.TradeLog.WriteBounceTrade(trade)
 					result.TotalPnL += trade.PnL
 					result.TotalFees += trade.Fee
 					result.TotalTrades++
 					if trade.PnL > 0 {
 						result.WinTrades++
+						grossWin += trade.PnL
 					} else {
 						result.LoseTrades++
+						grossLoss += -trade.PnL
 					}
 				}
+				config.TradeLog.Flush()
+				sizer.Record(position.realizedPnL)
 				position = nil
 			}
 		}
@@ -297,13 +407,14 @@ func RunBounceBacktest(klines []Kline, config BounceConfig) *BounceResult {
 			// 3. 当前价格已经从低点反弹 > 1%（确认趋势）
 			priceBounce := (k.Close - lowPrice) / lowPrice
 			
-			if hasDrop && prevRSI < config.RSIOversold && currentRSI >= config.RSIEntry && uptrend && priceBounce >= 0.01 {
+			if hasDrop && prevRSI < config.RSIOversold && currentRSI >= config.RSIEntry && uptrend && priceBounce >= 0.01 && tryEntry(k.Timestamp) {
 				// 计算目标价
 				targetPrice := lowPrice + (highPrice-lowPrice)*config.BounceTarget
 
 				// 第1份入场
-				notional := balance * config.FirstBatchSize
-				amount := notional / k.Close
+				sizeMult := sizer.Multiplier()
+				amount := config.Sizing.EntryAmount(sizingBalance.Base(k.Timestamp, balance), pyramid.Sizes[0], sizeMult, pyramid.Sizes[0], k.Close)
+				entryPrice := slippage.fill(config.Slippage, k.Close, true, amount, k)
 
 				position = &BouncePosition{
 					side:          "LONG",
@@ -313,53 +424,69 @@ func RunBounceBacktest(klines []Kline, config BounceConfig) *BounceResult {
 					targetPrice:   targetPrice,
 					entries: []BounceEntry{{
 						entryTime:  k.Timestamp,
-						entryPrice: k.Close,
+						entryPrice: entryPrice,
 						amount:     amount,
 						batch:      1,
 					}},
-					totalAmt:      amount,
-					avgPrice:      k.Close,
-					lastBatchTime: k.Timestamp,
-					batchCount:    1,
+					totalAmt:       amount,
+					avgPrice:       entryPrice,
+					lastBatchTime:  k.Timestamp,
+					lastBatchPrice: entryPrice,
+					batchCount:     1,
+					sizeMult:       sizeMult,
 				}
-				balance -= k.Close * amount * config.FeeRate
+				balance -= entryPrice * amount * config.FeeRate
 			}
 		} else {
 			// ========== 加仓逻辑 ==========
-			if position.batchCount < config.MaxBatches {
-				timeSinceLastBatch := k.Timestamp - position.lastBatchTime
-				
-				// 每 3 分钟检查一次加仓
-				if timeSinceLastBatch >= config.BatchInterval {
-					// 检查加仓条件：RSI > 入场阈值 且 EMA 上升
-					if currentRSI >= config.RSIEntry && uptrend {
-						notional := balance * config.OtherBatchSize
-						amount := notional / k.Close
-
-						position.entries = append(position.entries, BounceEntry{
-							entryTime:  k.Timestamp,
-							entryPrice: k.Close,
-							amount:     amount,
-							batch:      position.batchCount + 1,
-						})
-						position.totalAmt += amount
-						position.avgPrice = (position.avgPrice*(position.totalAmt-amount) + k.Close*amount) / position.totalAmt
-						position.lastBatchTime = k.Timestamp
-						position.batchCount++
-						balance -= k.Close * amount * config.FeeRate
-					}
+			// 批数、间隔（默认每 3 分钟检查一次）和仓位上限按分批建仓配置
+			if size, ok := pyramid.admit(position.view(), config.OtherBatchSize, k.Timestamp); ok {
+				// 检查加仓条件：RSI > 入场阈值 且 EMA 上升；按价格触发时为相对上次加仓价移动 step
+				triggered := currentRSI >= config.RSIEntry && uptrend
+				if !pyramid.signalAddOn() {
+					triggered = pyramid.priceTriggered(position.view(), k.Close)
+				}
+				if triggered && tryEntry(k.Timestamp) {
+					amount := config.Sizing.EntryAmount(sizingBalance.Base(k.Timestamp, balance), size, position.sizeMult, pyramid.Sizes[0], k.Close)
+					entryPrice := slippage.fill(config.Slippage, k.Close, true, amount, k)
+
+					position.entries = append(position.entries, BounceEntry{
+						entryTime:  k.Timestamp,
+						entryPrice: entryPrice,
+						amount:     amount,
+						batch:      position.batchCount + 1,
+					})
+					position.totalAmt += amount
+					position.avgPrice = (position.avgPrice*(position.totalAmt-amount) + entryPrice*amount) / position.totalAmt
+					position.lastBatchTime = k.Timestamp
+					position.lastBatchPrice = entryPrice
+					position.batchCount++
+					balance -= entryPrice * amount * config.FeeRate
 				}
 			}
 		}
 
-		// 更新资金曲线
+		// 资金占用
+		notional := 0.0
+		if position != nil {
+			notional = position.totalAmt * k.Close
+		}
+		exposure.add(notional, balance)
+
+		// 更新资金曲线：余额只含已实现盈亏，权益按收盘价计入持仓浮动盈亏
+		equity := balance
+		if position != nil {
+			equity += unrealizedPnL(position.side, position.totalAmt, position.avgPrice, k.Close)
+		}
 		result.BalanceCurve = append(result.BalanceCurve, balance)
+		result.EquityCurve = append(result.EquityCurve, equity)
+		result.BalanceTimes = append(result.BalanceTimes, k.Timestamp)
 
-		// 计算最大回撤
-		if balance > maxBalance {
-			maxBalance = balance
+		// 计算最大回撤（按权益，持仓期间的浮亏同样计入）
+		if equity > maxEquity {
+			maxEquity = equity
 		}
-		drawdown := (maxBalance - balance) / maxBalance
+		drawdown := (maxEquity - equity) / maxEquity
 		if drawdown > result.MaxDrawdown {
 			result.MaxDrawdown = drawdown
 		}
@@ -370,21 +497,39 @@ func RunBounceBacktest(klines []Kline, config BounceConfig) *BounceResult {
 		result.WinRate = float64(result.WinTrades) / float64(result.TotalTrades)
 	}
 
-	var totalWin, totalLose float64
-	for _, t := range result.Trades {
-		if t.PnL > 0 {
-			totalWin += t.PnL
-		} else {
-			totalLose += -t.PnL
-		}
-	}
-	if totalLose > 0 {
-		result.ProfitFactor = totalWin / totalLose
+	if grossLoss > 0 {
+		result.ProfitFactor = grossWin / grossLoss
 	}
+	result.Exposure = exposure.stats(result.TotalPnL, config.StartBalance)
+	result.SlippageCost = slippage.cost
+	result.AvgSlippageBps = slippage.avgBps()
+	result.Participation = slippage.summary()
+	result.Drawdown = DrawdownDurations(result.BalanceTimes, result.EquityCurve)
+	result.RStats = rs.stats()
+	result.RiskPct = config.RiskPct
+
+	// 滚动夏普与滚动回撤
+	daily := DailyEquity(result.BalanceTimes, result.EquityCurve)
+	result.RollingSharpe = RollingSharpe(daily, RollingWindowDays)
+	result.RollingDrawdown = RollingMaxDrawdown(daily, RollingWindowDays)
 
 	return result
 }
 
+// frictionless 关闭手续费、滑点模型和资金费的回测配置
+func (c BounceConfig) frictionless() BounceConfig {
+	c.FeeRate = 0
+	c.Slippage = SlippageModel{}
+	c.Funding = nil
+	c.TradeLog = nil
+	return c
+}
+
+// summary 成本对比摘要
+func (r *BounceResult) summary() costSummary {
+	return costSummary{r.TotalPnL, r.WinRate, r.TotalTrades, r.MaxDrawdown, r.TotalFees, r.SlippageCost, r.FundingPaid}
+}
+
 // PrintBounceResult 打印反弹策略结果
 func PrintBounceResult(result *BounceResult) {
 	fmt.Println("\n========== 反弹策略回测结果 ==========")
@@ -396,17 +541,46 @@ func PrintBounceResult(result *BounceResult) {
 	fmt.Printf("总手续费: $%.2f\n", result.TotalFees)
 	fmt.Printf("盈亏比: %.2f\n", result.ProfitFactor)
 	fmt.Printf("最大回撤: %.2f%%\n", result.MaxDrawdown*100)
+	fmt.Printf("持仓时间占比: %.2f%%\n", result.Exposure.TimeInMarket*100)
+	if result.ThrottledEntries > 0 {
+		fmt.Printf("限频跳过入场: %d 次\n", result.ThrottledEntries)
+	}
+	if result.SlippageCost > 0 {
+		fmt.Printf("滑点成本: $%.2f, 平均滑点 %.1f bps\n", result.SlippageCost, result.AvgSlippageBps)
+		printParticipation(result.Participation)
+	}
+	if result.FundingEvents > 0 {
+		fmt.Printf("资金费: 净支付 $%.2f (%d 次结算)\n", result.FundingPaid, result.FundingEvents)
+	}
+	printDrawdownStats(result.Drawdown)
+	printRStats(result.RStats, result.RiskPct, 0)
+	printExposureStats(result.Exposure)
+	printRollingStats(result.RollingSharpe, result.RollingDrawdown)
+
+	pnls := make([]float64, len(result.Trades))
+	returns := make([]float64, len(result.Trades))
+	for i, t := range result.Trades {
+		pnls[i] = t.PnL
+		returns[i] = t.PnL / (t.EntryPrice * t.Amount)
+	}
+	printTradeDistribution(pnls, returns)
+
+	batches := make([]int, len(result.Trades))
+	for i, t := range result.Trades {
+		batches[i] = t.Batch
+	}
+	printBatchBreakdown(batches, pnls, returns, result.TotalPnL)
 	fmt.Println("================================")
 }
 
 // runBounceBacktestCmd 执行反弹策略回测命令
-func runBounceBacktestCmd(dbPath, symbol string, startTime, endTime int64) {
+func runBounceBacktestCmd(dbPath, symbol string, startTime, endTime int64, opts BacktestOptions) {
 	log.Printf("加载 K 线数据: %s", symbol)
-	klines, err := loadKlinesFromDB(dbPath, symbol, startTime, endTime)
+	klines, err := loadIntervalKlines(dbPath, symbol, startTime, endTime, opts.Interval)
 	if err != nil {
 		log.Fatalf("加载数据失败: %v", err)
 	}
-	log.Printf("加载 %d 根 1m K 线（反弹策略）", len(klines))
+	log.Printf("加载 %d 根 %ds K 线（反弹策略）", len(klines), opts.Interval)
 
 	if len(klines) < 100 {
 		log.Fatalf("数据不足")
@@ -414,20 +588,74 @@ func runBounceBacktestCmd(dbPath, symbol string, startTime, endTime int64) {
 
 	config := DefaultBounceConfig
 	config.Symbol = symbol
+	config.Sizing = opts.Sizing
+	config.DailyCompounding = opts.DailyCompounding
+	config.Throttle = opts.Throttle
+	config.Filter = knownSymbolFilters[symbol]
+	config.Slippage = opts.Slippage
+	config.Pyramid = opts.Pyramid
+	config.Funding = loadBacktestFunding(opts, dbPath, symbol, startTime, endTime)
+	config.RiskPct = opts.StopLossPct
+	config.StreamOnly = opts.StreamOnly
+	config.TradeLog = openTradeLog(opts)
+	if opts.Params != nil {
+		opts.Params.applyBounce(&config)
+		log.Printf("策略参数: %s", opts.Params)
+	}
 
 	result := RunBounceBacktest(klines, config)
+	closeTradeLog(config.TradeLog, opts)
+	exportTrades(bounceTradeRecords(result.Trades), opts)
 	PrintBounceResult(result)
+	recordExperiment(opts.ExperimentsPath, Experiment{
+		Mode:      "bounce",
+		Symbol:    symbol,
+		Engine:    "bounce",
+		StartTime: startTime,
+		EndTime:   endTime,
+		Interval:  opts.Interval,
+		Params:    bounceParamValues(config),
+		Metrics:   bounceMetrics(result),
+	})
+
+	if opts.CostCompare {
+		printCostComparison(result.summary(), RunBounceBacktest(klines, config.frictionless()).summary())
+	}
 
 	// 打印最近的交易
 	fmt.Println("\n最近 10 笔交易:")
 	for i := len(result.Trades) - 1; i >= 0 && i >= len(result.Trades)-10; i-- {
 		t := result.Trades[i]
-		fmt.Printf("%s | 入场: %.2f | 出场: %.2f | 盈亏: $%.2f | %s\n",
+		fmt.Printf("%s | 入场: %.2f | 出场: %.2f | 盈亏: $%.2f | %s",
 			time.Unix(t.EntryTime, 0).Format("2006-01-02 15:04"),
 			t.EntryPrice,
 			t.ExitPrice,
 			t.PnL,
 			t.Reason,
 		)
+		if opts.Sizing.Mode != SizingFixed {
+			fmt.Printf(" | 仓位系数: %.2f", t.SizeMult)
+		}
+		fmt.Println()
+	}
+
+	if opts.CurvePath != "" {
+		if err := WriteEquityCSV(opts.CurvePath, result.BalanceTimes, result.EquityCurve, result.RollingSharpe, result.RollingDrawdown, opts.ExportFormat); err != nil {
+			log.Fatalf("导出资金曲线失败: %v", err)
+		}
+		log.Printf("资金曲线已导出: %s", opts.CurvePath)
+	}
+
+	if opts.InfluxURL != "" {
+		exporter := NewInfluxExporter(opts.InfluxURL, backtestTags("bounce", symbol))
+		indicators := map[string][]float64{
+			"rsi":   CalculateRSI(klines, 14),
+			"ema5":  CalculateEMA(klines, 5),
+			"ema13": CalculateEMA(klines, 13),
+		}
+		if err := ExportBacktestSeries(exporter, klines, result.BalanceTimes, result.BalanceCurve, result.EquityCurve, indicators); err != nil {
+			log.Fatalf("导出到 InfluxDB 失败: %v", err)
+		}
+		log.Printf("已导出到 InfluxDB")
 	}
 }
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+)
+
+// CapacityOptions 容量估算选项
+type CapacityOptions struct {
+	MinCapital       float64 // 扫描的最小初始资金
+	MaxCapital       float64 // 扫描的最大初始资金
+	Steps            int     // 每 10 倍资金扫描的档数
+	MaxParticipation float64 // 平均成交量占比上限，超过时视为无法成交
+}
+
+// DefaultCapacityOptions 从 1000 USDT 扫描到 10 亿 USDT，每 10 倍 2 档，平均成交量占比不超过 10%
+var DefaultCapacityOptions = CapacityOptions{MinCapital: 1e3, MaxCapital: 1e9, Steps: 2, MaxParticipation: 0.1}
+
+// capacityBisections 找到盈亏翻转的区间后，在对数资金上二分的次数
+const capacityBisections = 8
+
+// CapacityPoint 某个初始资金下的回测结果
+type CapacityPoint struct {
+	Capital       float64
+	PnL           float64
+	Return        float64 // 总盈亏 / 初始资金
+	Trades        int
+	EdgeBps       float64 // 滑点前的净收益（已扣手续费和资金费）占成交额的比例 (bps)
+	SlippageBps   float64 // 按成交名义价值加权的平均滑点 (bps)
+	Participation ParticipationStats
+}
+
+// fillable 订单规模是否仍可成交：没有超过整根 K 线成交量的订单，且平均成交量占比不超过上限
+func (p CapacityPoint) fillable(maxParticipation float64) bool {
+	return p.Participation.OverVolume == 0 && p.Participation.Avg <= maxParticipation
+}
+
+// viable 盈利且可成交
+func (p CapacityPoint) viable(maxParticipation float64) bool {
+	return p.PnL > 0 && p.fillable(maxParticipation)
+}
+
+// CapacityResult 单个交易对的容量估算
+type CapacityResult struct {
+	Symbol           string
+	Model            SlippageModel
+	MaxParticipation float64
+	Points           []CapacityPoint // 按资金升序，含二分的点
+	// Capacity 净盈亏为正且可成交的最大初始资金；最小资金已不满足时为 0，最大资金仍满足时为最大资金
+	Capacity    float64
+	Unbounded   bool // 最大资金仍然满足，容量超出扫描范围
+	VolumeBound bool // 容量受成交量占比限制（超过上限时仍盈利）
+}
+
+// capacityPoint 用 capital 作为初始资金回测一次
+func capacityPoint(klines []Kline, config BacktestConfig, newEngine func() StrategyEngine, capital float64) CapacityPoint {
+	config.StartBalance = capital
+	res := RunEngineBacktest(klines, config, newEngine())
+	p := CapacityPoint{
+		Capital:       capital,
+		PnL:           res.TotalPnL,
+		Return:        res.TotalPnL / capital,
+		Trades:        res.TotalTrades,
+		SlippageBps:   res.AvgSlippageBps,
+		Participation: res.Participation,
+	}
+	if res.Fees.Volume > 0 {
+		p.EdgeBps = (res.TotalPnL + res.SlippageCost) / res.Fees.Volume * 1e4
+	}
+	return p
+}
+
+// RunCapacity 按资金从小到大回测，平方根冲击滑点随订单占 K 线成交量的比例增长，
+// 找出滑点吞掉全部收益（净盈亏不再为正）前的最大初始资金。滑点模型不是 impact 时按 impact 计算，
+// 基础 bps 和冲击系数沿用配置；仓位按余额比例计算时才有意义，名义价值上限会让订单规模在上限处停止增长
+func RunCapacity(symbol string, klines []Kline, config BacktestConfig, newEngine func() StrategyEngine, opts CapacityOptions) (*CapacityResult, error) {
+	if opts.MinCapital <= 0 || opts.MaxCapital < opts.MinCapital {
+		return nil, fmt.Errorf("capacity range must satisfy 0 < min <= max")
+	}
+	if opts.Steps <= 0 {
+		return nil, fmt.Errorf("capacity steps must be positive")
+	}
+	if opts.MaxParticipation <= 0 {
+		return nil, fmt.Errorf("capacity max participation must be positive")
+	}
+	if config.Sizing.Unit != UnitFraction && config.Sizing.Unit != UnitATR {
+		return nil, fmt.Errorf("capacity needs balance-proportional sizing, got unit %q", config.Sizing.Unit)
+	}
+	if len(klines) == 0 {
+		return nil, fmt.Errorf("no klines")
+	}
+
+	config.TradeLog = nil
+	config.StreamOnly = true
+	config.Explain = false
+	config.Slippage.Mode = SlipImpact
+	result := &CapacityResult{Symbol: symbol, Model: config.Slippage, MaxParticipation: opts.MaxParticipation}
+
+	ratio := math.Pow(10, 1/float64(opts.Steps))
+	prev := -1
+	for capital := opts.MinCapital; capital <= opts.MaxCapital*(1+1e-9); capital *= ratio {
+		p := capacityPoint(klines, config, newEngine, capital)
+		result.Points = append(result.Points, p)
+		if !p.viable(opts.MaxParticipation) {
+			break
+		}
+		prev = len(result.Points) - 1
+	}
+	last := result.Points[len(result.Points)-1]
+	result.VolumeBound = last.PnL > 0 && !last.fillable(opts.MaxParticipation)
+	switch {
+	case prev < 0:
+		return result, nil
+	case last.viable(opts.MaxParticipation):
+		result.Capacity = last.Capital
+		result.Unbounded = true
+		return result, nil
+	}
+
+	// 在最后一个满足的点和第一个不满足的点之间按对数资金二分
+	lo, hi := result.Points[prev].Capital, last.Capital
+	var extra []CapacityPoint
+	for i := 0; i < capacityBisections; i++ {
+		mid := math.Sqrt(lo * hi)
+		p := capacityPoint(klines, config, newEngine, mid)
+		extra = append(extra, p)
+		if p.viable(opts.MaxParticipation) {
+			lo = mid
+		} else {
+			hi = mid
+			result.VolumeBound = p.PnL > 0 && !p.fillable(opts.MaxParticipation)
+		}
+	}
+	result.Capacity = lo
+	result.Points = insertCapacityPoints(result.Points[:prev+1], extra, last)
+	return result, nil
+}
+
+// insertCapacityPoints 将二分得到的点按资金升序合并到扫描结果中
+func insertCapacityPoints(sweep, extra []CapacityPoint, last CapacityPoint) []CapacityPoint {
+	points := append([]CapacityPoint(nil), sweep...)
+	points = append(points, extra...)
+	points = append(points, last)
+	for i := len(sweep); i < len(points); i++ {
+		for j := i; j > 0 && points[j].Capital < points[j-1].Capital; j-- {
+			points[j], points[j-1] = points[j-1], points[j]
+		}
+	}
+	return points
+}
+
+// PrintCapacityResult 打印各资金规模下的收益、滑点和成交量占比，以及估算的容量
+func PrintCapacityResult(r *CapacityResult) {
+	fmt.Printf("\n========== 容量估算: %s ==========\n", r.Symbol)
+	fmt.Printf("滑点模型: impact, 基础 %.2f bps, 冲击系数 %g; 平均成交量占比上限 %.1f%%\n", r.Model.Bps, r.Model.Factor, r.MaxParticipation*100)
+	fmt.Printf("%14s %12s %9s %7s %10s %10s %10s %10s %8s\n", "初始资金", "总盈亏", "收益%", "交易数", "滑点前bps", "滑点bps", "平均占比%", "最大占比%", "超量笔数")
+	for _, p := range r.Points {
+		fmt.Printf("%14.0f %12.2f %9.2f %7d %10.2f %10.2f %10.3f %10.3f %8d\n", p.Capital, p.PnL, p.Return*100, p.Trades,
+			p.EdgeBps, p.SlippageBps, p.Participation.Avg*100, p.Participation.Max*100, p.Participation.OverVolume)
+	}
+
+	switch {
+	case r.Capacity <= 0 && r.VolumeBound:
+		fmt.Printf("\n最小资金 $%.0f 的订单已超出 K 线成交量或平均占比上限，无法成交\n", r.Points[0].Capital)
+	case r.Capacity <= 0:
+		fmt.Printf("\n最小资金 $%.0f 已不盈利，滑点前收益不足以覆盖成本\n", r.Points[0].Capital)
+	case r.Unbounded:
+		fmt.Printf("\n容量: 超过 $%.0f（扫描上限仍盈利且可成交）\n", r.Capacity)
+	case r.VolumeBound:
+		fmt.Printf("\n容量: 约 $%.0f，资金再大时订单超出 K 线成交量或平均占比超过 %.1f%%\n", r.Capacity, r.MaxParticipation*100)
+	default:
+		fmt.Printf("\n容量: 约 $%.0f，资金再大时滑点将吞掉全部收益\n", r.Capacity)
+	}
+	// 只在可成交的点中找总盈亏最大的资金
+	best := CapacityPoint{}
+	for _, p := range r.Points {
+		if p.fillable(r.MaxParticipation) && p.PnL > best.PnL {
+			best = p
+		}
+	}
+	if best.PnL > 0 && !r.Unbounded {
+		fmt.Printf("总盈亏最大的资金: $%.0f (盈亏 $%.2f, 收益 %.2f%%)\n", best.Capital, best.PnL, best.Return*100)
+	}
+	fmt.Println("==================================")
+}
+
+// runCapacityCmd 执行容量估算命令，symbols 为逗号分隔的交易对
+func runCapacityCmd(dbPath, symbols string, startTime, endTime int64, opts CapacityOptions, backtestOpts BacktestOptions) {
+	if opts.MinCapital <= 0 || opts.MaxCapital < opts.MinCapital {
+		log.Fatalf("需要 0 < -capacity-min <= -capacity-max")
+	}
+	if opts.MaxParticipation <= 0 {
+		log.Fatalf("-capacity-max-participation 必须大于 0")
+	}
+
+	var results []*CapacityResult
+	for _, symbol := range strings.Split(symbols, ",") {
+		symbol = strings.TrimSpace(symbol)
+		if symbol == "" {
+			continue
+		}
+		log.Printf("加载 K 线数据: %s", symbol)
+		klines, err := loadIntervalKlines(dbPath, symbol, startTime, endTime, backtestOpts.Interval)
+		if err != nil {
+			log.Fatalf("加载数据失败: %v", err)
+		}
+		log.Printf("加载 %d 根 %ds K 线", len(klines), backtestOpts.Interval)
+		if len(klines) < 100 {
+			log.Fatalf("%s 数据不足，至少需要 100 根 K 线", symbol)
+		}
+
+		config, newEngine := engineBacktestSetup(symbol, backtestOpts)
+		result, err := RunCapacity(symbol, klines, config, newEngine, opts)
+		if err != nil {
+			log.Fatalf("容量估算失败: %v", err)
+		}
+		PrintCapacityResult(result)
+		results = append(results, result)
+	}
+	if len(results) < 2 {
+		return
+	}
+
+	fmt.Println("\n========== 容量汇总 ==========")
+	for _, r := range results {
+		switch {
+		case r.Capacity <= 0 && r.VolumeBound:
+			fmt.Printf("%-12s 无法成交\n", r.Symbol)
+		case r.Capacity <= 0:
+			fmt.Printf("%-12s 不盈利\n", r.Symbol)
+		case r.Unbounded:
+			fmt.Printf("%-12s > $%.0f\n", r.Symbol, r.Capacity)
+		default:
+			fmt.Printf("%-12s ≈ $%.0f\n", r.Symbol, r.Capacity)
+		}
+	}
+	fmt.Println("==============================")
+}
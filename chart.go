@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"time"
+)
+
+// chartTrade 图表中的一笔交易，入场 / 出场时间为秒
+type chartTrade struct {
+	EntryTime   int64   `json:"entry_time"`
+	ExitTime    int64   `json:"exit_time"`
+	Side        string  `json:"side"`
+	Batch       int     `json:"batch"`
+	EntryPrice  float64 `json:"entry_price"`
+	ExitPrice   float64 `json:"exit_price"`
+	PnL         float64 `json:"pnl"`
+	EntryReason string  `json:"entry_reason"`
+	ExitReason  string  `json:"exit_reason"`
+}
+
+// chartData 嵌入图表页面的数据，序列与 Times 一一对应
+type chartData struct {
+	Times     []int64      `json:"t"`
+	Open      []float64    `json:"o"`
+	High      []float64    `json:"h"`
+	Low       []float64    `json:"l"`
+	Close     []float64    `json:"c"`
+	RSI       []float64    `json:"rsi"`
+	EMAFast   []float64    `json:"ema_fast"`
+	EMASlow   []float64    `json:"ema_slow"`
+	RSILevels []float64    `json:"rsi_levels"` // 做多超卖 / 做空超买阈值
+	Trades    []chartTrade `json:"trades"`
+}
+
+// TradeChart K 线图（含买卖点和 RSI、EMA）页面
+type TradeChart struct {
+	Symbol   string
+	Engine   string
+	Interval string
+	Fast     int // EMA 快线周期
+	Slow     int // EMA 慢线周期
+	Data     chartData
+}
+
+// NewTradeChart 生成 [from, to] 区间的 K 线图，from / to 为 0 表示不限；
+// 指标按完整 K 线计算后再截取，区间开头不受预热影响。入场或出场在区间内的交易都会标出
+func NewTradeChart(klines []Kline, trades []Trade, sc StrategyConfig, symbol, engine string, interval, from, to int64) *TradeChart {
+	c := &TradeChart{Symbol: symbol, Engine: engine, Interval: intervalName(interval), Fast: sc.EMA_FAST, Slow: sc.EMA_SLOW}
+	rsi := CalculateRSI(klines, sc.RSI_PERIOD)
+	fast := CalculateEMA(klines, sc.EMA_FAST)
+	slow := CalculateEMA(klines, sc.EMA_SLOW)
+
+	d := &c.Data
+	d.RSILevels = []float64{sc.RSI_OVERSOLD_LONG, sc.RSI_OVERBOUGHT_SHORT}
+	for i, k := range klines {
+		if (from > 0 && k.Timestamp < from) || (to > 0 && k.Timestamp > to) {
+			continue
+		}
+		d.Times = append(d.Times, k.Timestamp)
+		d.Open = append(d.Open, k.Open)
+		d.High = append(d.High, k.High)
+		d.Low = append(d.Low, k.Low)
+		d.Close = append(d.Close, k.Close)
+		d.RSI = append(d.RSI, rsi[i])
+		d.EMAFast = append(d.EMAFast, fast[i])
+		d.EMASlow = append(d.EMASlow, slow[i])
+	}
+	if len(d.Times) == 0 {
+		return c
+	}
+
+	first, last := d.Times[0], d.Times[len(d.Times)-1]
+	for _, t := range trades {
+		if t.ExitTime < first || t.EntryTime > last {
+			continue
+		}
+		d.Trades = append(d.Trades, chartTrade{
+			EntryTime: t.EntryTime, ExitTime: t.ExitTime, Side: t.Side, Batch: t.Batch,
+			EntryPrice: t.EntryPrice, ExitPrice: t.ExitPrice, PnL: t.PnL,
+			EntryReason: t.EntryReason, ExitReason: t.ExitReason,
+		})
+	}
+	return c
+}
+
+// tradeChartHTML K 线图模板：内嵌脚本在 canvas 上绘制，滚轮缩放、拖动平移，悬停显示 K 线和指标，
+// 点击交易列表跳转到该笔交易
+var tradeChartHTML = template.Must(template.New("chart").Funcs(template.FuncMap{
+	"time":  func(ts int64) string { return time.Unix(ts, 0).UTC().Format("2006-01-02 15:04") },
+	"money": func(v float64) string { return fmt.Sprintf("%.2f", v) },
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Symbol}} {{.Interval}} 买卖点</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+canvas { border: 1px solid #ddd; cursor: crosshair; }
+#tip { font: 12px monospace; height: 3em; white-space: pre; }
+table { border-collapse: collapse; margin-top: 1em; font-size: 13px; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; text-align: right; }
+td:nth-child(n+7) { text-align: left; }
+tbody tr { cursor: pointer; } tbody tr:hover { background: #f3f3f3; }
+.pos { color: #1a7f37; } .neg { color: #cf222e; }
+</style></head><body>
+<h1>{{.Symbol}} {{.Interval}} ({{.Engine}})</h1>
+<p>▲ 开多 / ▼ 开空，× 出场，虚线连接入场与出场（绿色盈利、红色亏损）；价格面板为 EMA{{.Fast}}（橙）/ EMA{{.Slow}}（紫）。滚轮缩放，拖动平移，点击下方交易跳转。</p>
+<div id="tip"></div>
+<canvas id="chart" width="1200" height="600"></canvas>
+<table><thead><tr><th>入场 (UTC)</th><th>出场 (UTC)</th><th>方向</th><th>批次</th><th>入场价</th><th>出场价</th><th>盈亏</th><th>入场原因</th><th>出场原因</th></tr></thead><tbody>
+{{range $i, $t := .Data.Trades}}<tr data-i="{{$i}}"><td>{{time .EntryTime}}</td><td>{{time .ExitTime}}</td><td>{{.Side}}</td><td>{{.Batch}}</td><td>{{money .EntryPrice}}</td><td>{{money .ExitPrice}}</td><td class="{{if lt .PnL 0.0}}neg{{else}}pos{{end}}">{{money .PnL}}</td><td>{{.EntryReason}}</td><td>{{.ExitReason}}</td></tr>
+{{end}}</tbody></table>
+<script>
+var d = {{.Data}};
+var cv = document.getElementById("chart"), g = cv.getContext("2d"), tip = document.getElementById("tip");
+var n = d.t.length, L = 60, R = 10, W = cv.width - L - R, PH = 400, RT = 430, RH = 150;
+var view = {start: Math.max(0, n - 300), count: Math.min(n, 300)};
+var index = {}; d.t.forEach(function(t, i) { index[t] = i; });
+// 交易时间按所在 K 线定位（不在图表区间内时为 -1）
+function bar(ts) { return index[ts] === undefined ? -1 : index[ts]; }
+function clamp() {
+  view.count = Math.max(20, Math.min(n, Math.round(view.count)));
+  view.start = Math.max(0, Math.min(n - view.count, Math.round(view.start)));
+}
+function x(i) { return L + (i - view.start + 0.5) * W / view.count; }
+function draw() {
+  g.clearRect(0, 0, cv.width, cv.height);
+  if (!n) return;
+  var s = view.start, e = s + view.count, lo = Infinity, hi = -Infinity;
+  for (var i = s; i < e; i++) {
+    lo = Math.min(lo, d.l[i]); hi = Math.max(hi, d.h[i]);
+    [d.ema_fast[i], d.ema_slow[i]].forEach(function(v) { if (v > 0) { lo = Math.min(lo, v); hi = Math.max(hi, v); } });
+  }
+  var pad = (hi - lo) * 0.05 || 1; lo -= pad; hi += pad;
+  function py(v) { return 10 + (PH - 10) * (hi - v) / (hi - lo); }
+  function ry(v) { return RT + RH * (100 - v) / 100; }
+  g.font = "11px sans-serif"; g.strokeStyle = "#eee"; g.fillStyle = "#555";
+  for (var k = 0; k <= 4; k++) {
+    var v = lo + (hi - lo) * k / 4;
+    g.beginPath(); g.moveTo(L, py(v)); g.lineTo(L + W, py(v)); g.stroke();
+    g.fillText(v.toFixed(2), 2, py(v) + 4);
+  }
+  var bw = Math.max(1, W / view.count * 0.7);
+  for (var i = s; i < e; i++) {
+    var up = d.c[i] >= d.o[i];
+    g.strokeStyle = g.fillStyle = up ? "#1a7f37" : "#cf222e";
+    g.beginPath(); g.moveTo(x(i), py(d.h[i])); g.lineTo(x(i), py(d.l[i])); g.stroke();
+    var top = py(Math.max(d.o[i], d.c[i])), h = Math.max(1, Math.abs(py(d.o[i]) - py(d.c[i])));
+    g.fillRect(x(i) - bw / 2, top, bw, h);
+  }
+  function series(values, y, color) {
+    g.strokeStyle = color; g.beginPath();
+    var on = false;
+    for (var i = s; i < e; i++) {
+      if (!(values[i] > 0)) { on = false; continue; }
+      on ? g.lineTo(x(i), y(values[i])) : g.moveTo(x(i), y(values[i])); on = true;
+    }
+    g.stroke();
+  }
+  series(d.ema_fast, py, "#e8890c"); series(d.ema_slow, py, "#8250df");
+  // RSI 面板与阈值线
+  g.strokeStyle = "#ccc"; g.strokeRect(L, RT, W, RH);
+  g.fillStyle = "#555"; g.fillText("RSI", 2, RT + 12);
+  g.setLineDash([4, 4]);
+  d.rsi_levels.forEach(function(v) { g.beginPath(); g.moveTo(L, ry(v)); g.lineTo(L + W, ry(v)); g.stroke(); g.fillText(v, 30, ry(v) + 4); });
+  g.setLineDash([]);
+  series(d.rsi, ry, "#0969da");
+  // 买卖点
+  d.trades.forEach(function(t) {
+    var a = bar(t.entry_time), b = bar(t.exit_time);
+    if ((a < s && b < s && a >= 0) || a >= e) return;
+    g.strokeStyle = t.pnl >= 0 ? "#1a7f37" : "#cf222e";
+    if (a >= 0 && b >= 0) {
+      g.setLineDash([3, 3]); g.beginPath(); g.moveTo(x(a), py(t.entry_price)); g.lineTo(x(b), py(t.exit_price)); g.stroke(); g.setLineDash([]);
+    }
+    if (a >= s && a < e) {
+      var long = t.side === "LONG", y0 = long ? py(d.l[a]) + 4 : py(d.h[a]) - 4;
+      g.fillStyle = long ? "#1a7f37" : "#cf222e";
+      g.beginPath(); g.moveTo(x(a), y0); g.lineTo(x(a) - 5, y0 + (long ? 10 : -10)); g.lineTo(x(a) + 5, y0 + (long ? 10 : -10)); g.fill();
+    }
+    if (b >= s && b < e) {
+      var px = x(b), y1 = py(t.exit_price);
+      g.beginPath(); g.moveTo(px - 4, y1 - 4); g.lineTo(px + 4, y1 + 4); g.moveTo(px + 4, y1 - 4); g.lineTo(px - 4, y1 + 4); g.stroke();
+    }
+  });
+}
+function fmt(ts) { return new Date(ts * 1000).toISOString().slice(0, 16).replace("T", " "); }
+cv.addEventListener("mousemove", function(ev) {
+  if (drag) {
+    view.start = drag.start - (ev.offsetX - drag.x) * view.count / W; clamp(); draw(); return;
+  }
+  var i = Math.floor(view.start + (ev.offsetX - L) * view.count / W);
+  if (i < 0 || i >= n) return;
+  var lines = [fmt(d.t[i]) + "  O " + d.o[i] + "  H " + d.h[i] + "  L " + d.l[i] + "  C " + d.c[i] +
+    "  RSI " + d.rsi[i].toFixed(1) + "  EMA{{.Fast}} " + d.ema_fast[i].toFixed(2) + "  EMA{{.Slow}} " + d.ema_slow[i].toFixed(2)];
+  d.trades.forEach(function(t) {
+    if (t.entry_time === d.t[i]) lines.push("入场 " + t.side + " #" + t.batch + " @ " + t.entry_price.toFixed(2) + " " + t.entry_reason);
+    if (t.exit_time === d.t[i]) lines.push("出场 " + t.side + " @ " + t.exit_price.toFixed(2) + " 盈亏 " + t.pnl.toFixed(2) + " " + t.exit_reason);
+  });
+  tip.textContent = lines.join("\n");
+});
+var drag = null;
+cv.addEventListener("mousedown", function(ev) { drag = {x: ev.offsetX, start: view.start}; });
+window.addEventListener("mouseup", function() { drag = null; });
+cv.addEventListener("wheel", function(ev) {
+  ev.preventDefault();
+  var f = (ev.offsetX - L) / W, at = view.start + f * view.count;
+  view.count *= ev.deltaY > 0 ? 1.2 : 1 / 1.2; clamp();
+  view.start = at - f * view.count; clamp(); draw();
+});
+document.querySelectorAll("tbody tr").forEach(function(row) {
+  row.addEventListener("click", function() {
+    var t = d.trades[+row.dataset.i], a = Math.max(0, bar(t.entry_time)), b = bar(t.exit_time);
+    if (b < 0) b = n - 1;
+    view.count = Math.max(60, (b - a) * 3); clamp();
+    view.start = (a + b) / 2 - view.count / 2; clamp(); draw();
+    window.scrollTo(0, 0);
+  });
+});
+draw();
+</script>
+</body></html>
+`))
+
+// WriteTradeChart 将 K 线图写为单个 HTML 文件
+func WriteTradeChart(path string, c *TradeChart) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := tradeChartHTML.Execute(f, c); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// OptimizeJob 分发给 worker 的一组参数
+type OptimizeJob struct {
+	ID             int
+	Symbol         string
+	StartTime      int64
+	EndTime        int64
+	Backtest       BacktestConfig
+	StrategyConfig StrategyConfig
+}
+
+// OptimizeJobResult worker 回传的结果
+type OptimizeJobResult struct {
+	ID     int
+	Result OptimizeResult
+}
+
+// jobLeaseTimeout 任务租约超时，超时未回传的任务重新入队
+const jobLeaseTimeout = 10 * time.Minute
+
+// 队列暂时为空（其他 worker 的任务尚未回传，超时后可能重新入队）时 worker 的等待间隔；
+// 全部完成后协调端继续服务 jobFinishGrace，让等待中的 worker 收到完成通知后退出
+const (
+	jobWaitInterval = 10 * time.Second
+	jobFinishGrace  = 3 * jobWaitInterval
+)
+
+// Coordinator 通过 HTTP 任务队列分发参数组合
+type Coordinator struct {
+	mu      sync.Mutex
+	jobs    []OptimizeJob
+	pending []int             // 待分发的任务 ID
+	leased  map[int]time.Time // 已分发未完成的任务及分发时间
+	results map[int]OptimizeResult
+	done    chan struct{}
+}
+
+// NewCoordinator 为参数网格创建任务队列
+func NewCoordinator(symbol string, startTime, endTime int64, config BacktestConfig, grid []StrategyConfig) *Coordinator {
+	c := &Coordinator{
+		leased:  make(map[int]time.Time),
+		results: make(map[int]OptimizeResult),
+		done:    make(chan struct{}),
+	}
+	for i, sc := range grid {
+		c.jobs = append(c.jobs, OptimizeJob{
+			ID:             i,
+			Symbol:         symbol,
+			StartTime:      startTime,
+			EndTime:        endTime,
+			Backtest:       config,
+			StrategyConfig: sc,
+		})
+		c.pending = append(c.pending, i)
+	}
+	// 没有任务时立即完成，Serve 不会等待永远不会到来的结果
+	if len(grid) == 0 {
+		close(c.done)
+	}
+	return c
+}
+
+// requeueExpired 将租约超时的任务放回队列（需持有锁）
+func (c *Coordinator) requeueExpired() {
+	now := time.Now()
+	for id, leasedAt := range c.leased {
+		if now.Sub(leasedAt) > jobLeaseTimeout {
+			delete(c.leased, id)
+			c.pending = append(c.pending, id)
+			log.Printf("任务 %d 超时，重新入队", id)
+		}
+	}
+}
+
+// handleJob GET /job：领取下一个任务；待分发队列为空但仍有任务未回传时返回 202（等待后重试），全部完成时返回 204
+func (c *Coordinator) handleJob(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	c.requeueExpired()
+	// 跳过超时重发后已由原 worker 完成的任务
+	for len(c.pending) > 0 {
+		if _, ok := c.results[c.pending[0]]; !ok {
+			break
+		}
+		c.pending = c.pending[1:]
+	}
+	if len(c.pending) == 0 {
+		finished := len(c.results) == len(c.jobs)
+		c.mu.Unlock()
+		if finished {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.Header().Set("Retry-After", fmt.Sprint(int(jobWaitInterval.Seconds())))
+			w.WriteHeader(http.StatusAccepted)
+		}
+		return
+	}
+	id := c.pending[0]
+	c.pending = c.pending[1:]
+	c.leased[id] = time.Now()
+	job := c.jobs[id]
+	c.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// handleResult POST /result：回传任务结果
+func (c *Coordinator) handleResult(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var res OptimizeJobResult
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if res.ID < 0 || res.ID >= len(c.jobs) {
+		http.Error(w, "unknown job", http.StatusBadRequest)
+		return
+	}
+	if _, ok := c.results[res.ID]; !ok {
+		c.results[res.ID] = res.Result
+		delete(c.leased, res.ID)
+		if len(c.results)%200 == 0 {
+			fmt.Printf("进度: %d/%d\n", len(c.results), len(c.jobs))
+		}
+		if len(c.results) == len(c.jobs) {
+			close(c.done)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Serve 在 addr 上提供任务队列，所有结果回传后返回
+func (c *Coordinator) Serve(addr string) []OptimizeResult {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/job", c.handleJob)
+	mux.HandleFunc("/result", c.handleResult)
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("任务队列服务失败: %v", err)
+		}
+	}()
+	log.Printf("任务队列: http://%s (共 %d 个参数组合)", addr, len(c.jobs))
+
+	<-c.done
+	time.Sleep(jobFinishGrace)
+	server.Close()
+
+	results := make([]OptimizeResult, 0, len(c.results))
+	for id := range c.jobs {
+		results = append(results, c.results[id])
+	}
+	return results
+}
+
+// runCoordinatorCmd 执行分布式优化的协调端
+func runCoordinatorCmd(addr, symbol string, startTime, endTime int64, opts OptimizeOptions) {
+	config := DefaultBacktestConfig
+	config.Symbol = symbol
+	config.Interval = opts.Interval
+
+	grid, err := optimizeGrid(opts.Spec)
+	if err != nil {
+		log.Fatalf("生成参数网格失败: %v", err)
+	}
+	if len(grid) == 0 {
+		log.Fatalf("参数网格为空，没有可分发的任务")
+	}
+
+	fmt.Println("\n========== 分布式参数优化 ==========")
+	c := NewCoordinator(symbol, startTime, endTime, config, grid)
+	results := c.Serve(addr)
+	reportOptimizeResults(results, opts)
+	writeOptArchive(opts.ArchivePath, OptArchiveHeader{
+		Symbol:    symbol,
+		StartTime: startTime,
+		EndTime:   endTime,
+		Interval:  opts.Interval,
+		Optimizer: OptimizerGrid,
+	}, results)
+	recordOptimizeExperiment(opts.ExperimentsPath, Experiment{
+		Mode:      "optimize",
+		Symbol:    symbol,
+		Engine:    DefaultEngine,
+		StartTime: startTime,
+		EndTime:   endTime,
+		Interval:  opts.Interval,
+	}, config, results, opts.Objective)
+}
+
+// runWorkerCmd 执行分布式优化的 worker：循环领取任务，暂时没有任务时等待，直到协调端通知全部完成
+func runWorkerCmd(coordinatorURL, dbPath string) {
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	// 按交易对、时间范围和周期缓存 K 线，同一轮优化只加载一次
+	type dataKey struct {
+		symbol               string
+		start, end, interval int64
+	}
+	cache := make(map[dataKey]*IndicatorCache)
+
+	done := 0
+	for {
+		resp, err := client.Get(coordinatorURL + "/job")
+		if err != nil {
+			log.Printf("领取任务失败: %v，10 秒后重试", err)
+			time.Sleep(10 * time.Second)
+			continue
+		}
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusNoContent:
+			resp.Body.Close()
+			log.Printf("全部任务已完成，本 worker 共完成 %d 个任务", done)
+			return
+		case http.StatusAccepted:
+			// 其他 worker 的任务尚未回传，超时后会重新入队
+			resp.Body.Close()
+			time.Sleep(jobWaitInterval)
+			continue
+		default:
+			resp.Body.Close()
+			log.Printf("领取任务失败: HTTP %d，10 秒后重试", resp.StatusCode)
+			time.Sleep(10 * time.Second)
+			continue
+		}
+
+		var job OptimizeJob
+		err = json.NewDecoder(resp.Body).Decode(&job)
+		resp.Body.Close()
+		if err != nil {
+			log.Fatalf("解析任务失败: %v", err)
+		}
+
+		key := dataKey{job.Symbol, job.StartTime, job.EndTime, job.Backtest.Interval}
+		indicators, ok := cache[key]
+		if !ok {
+			log.Printf("加载 K 线数据: %s", job.Symbol)
+			klines, err := loadIntervalKlines(dbPath, job.Symbol, job.StartTime, job.EndTime, job.Backtest.Interval)
+			if err != nil {
+				log.Fatalf("加载数据失败: %v", err)
+			}
+			indicators = NewIndicatorCache(klines)
+			cache[key] = indicators
+		}
+
+		res := OptimizeJobResult{ID: job.ID, Result: evaluateConfig(indicators, job.Backtest, job.StrategyConfig)}
+		body, err := json.Marshal(res)
+		if err != nil {
+			log.Printf("编码任务 %d 的结果失败: %v", job.ID, err)
+			continue
+		}
+		resp, err = client.Post(coordinatorURL+"/result", "application/json", bytes.NewReader(body))
+		if err != nil {
+			// 结果丢失由协调端租约超时重新分发
+			log.Printf("回传结果失败: %v", err)
+			continue
+		}
+		if resp.StatusCode/100 != 2 {
+			msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			resp.Body.Close()
+			log.Printf("回传任务 %d 的结果被拒绝: HTTP %d %s", job.ID, resp.StatusCode, bytes.TrimSpace(msg))
+			continue
+		}
+		resp.Body.Close()
+		done++
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// klineEndpoint 币安历史 K 线接口及单次最多返回条数
+type klineEndpoint struct {
+	URL   string
+	Limit int
+}
+
+// klineEndpoints 各市场（-market）的历史 K 线接口
+var klineEndpoints = map[string]klineEndpoint{
+	"futures": {"https://fapi.binance.com/fapi/v1/klines", 1500},
+	"spot":    {"https://api.binance.com/api/v3/klines", 1000},
+}
+
+// downloadPause 相邻两页请求的间隔，避免触发接口限频
+const downloadPause = 200 * time.Millisecond
+
+// createKlineTable 新建 K 线表：交易对为文本，开盘时间为毫秒，价格和成交量为 1e8 定点整数，
+// 与原始数据库的 klines_futures 布局相同，可被 loadKlinesFromDB 自动识别
+func createKlineTable(db *sql.DB, table string) error {
+	_, err := db.Exec(fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			symbol TEXT    NOT NULL,
+			ts     INTEGER NOT NULL,
+			o      INTEGER NOT NULL,
+			h      INTEGER NOT NULL,
+			l      INTEGER NOT NULL,
+			c      INTEGER NOT NULL,
+			v      INTEGER NOT NULL,
+			PRIMARY KEY (symbol, ts)
+		)
+	`, quoteIdent(table)))
+	return err
+}
+
+// downloadSchema 确定写入的表结构：表不存在时按 createKlineTable 新建；已有表按 detectKlineSchema 识别列，
+// 表为空且 -db-schema 未指定缩放时按新建表的缩放（1e8 定点、毫秒）写入
+func downloadSchema(store KlineStore, override KlineSchema) (KlineSchema, error) {
+	table := override.Table
+	if table == "" {
+		table = defaultKlineTable
+	}
+	if _, err := store.Columns(table); err != nil {
+		if err := createKlineTable(store.DB(), table); err != nil {
+			return override, err
+		}
+	}
+
+	var rows int
+	if err := store.QueryRow("SELECT COUNT(*) FROM (SELECT 1 FROM " + quoteIdent(table) + " LIMIT 1)").Scan(&rows); err != nil {
+		return override, err
+	}
+	if rows == 0 {
+		if override.PriceScale == 0 {
+			override.PriceScale = 1e8
+		}
+		if override.TimeScale == 0 {
+			override.TimeScale = 1000
+		}
+	}
+	return detectKlineSchema(store, override)
+}
+
+// symbolValue 交易对列中写入的值：整数 ID 列按 symbolID 映射，否则为交易对名称
+func (schema KlineSchema) symbolValue(store KlineStore, symbol string) (any, error) {
+	var declared string
+	err := store.QueryRow("SELECT type FROM pragma_table_info(?) WHERE name = ?", schema.Table, schema.Symbol).Scan(&declared)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(strings.ToUpper(declared), "INT") {
+		return symbol, nil
+	}
+	id, err := schema.symbolID(store, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("table %s stores integer symbol ids: %w", schema.Table, err)
+	}
+	return id, nil
+}
+
+// fetchKlinePage 下载 [from, to]（毫秒）内最多 limit 根 K 线；限频（429 / 418）时按 Retry-After 等待后重试
+func fetchKlinePage(client *http.Client, endpoint klineEndpoint, symbol, interval string, from, to int64) ([]Kline, error) {
+	q := url.Values{}
+	q.Set("symbol", symbol)
+	q.Set("interval", interval)
+	q.Set("startTime", strconv.FormatInt(from, 10))
+	q.Set("endTime", strconv.FormatInt(to, 10))
+	q.Set("limit", strconv.Itoa(endpoint.Limit))
+
+	for {
+		resp, err := client.Get(endpoint.URL + "?" + q.Encode())
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot {
+			resp.Body.Close()
+			wait, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+			wait = max(wait, 1)
+			log.Printf("K 线接口限频 (http %d)，等待 %d 秒", resp.StatusCode, wait)
+			time.Sleep(time.Duration(wait) * time.Second)
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("klines: http %d", resp.StatusCode)
+		}
+
+		// 每根 K 线为数组：开盘时间、开、高、低、收、成交量、收盘时间……
+		var page [][]any
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("klines: %w", err)
+		}
+		klines := make([]Kline, 0, len(page))
+		for _, row := range page {
+			if len(row) < 6 {
+				return nil, fmt.Errorf("klines: short row %v", row)
+			}
+			openTime, ok := row[0].(float64)
+			if !ok {
+				return nil, fmt.Errorf("klines: invalid open time %v", row[0])
+			}
+			var values [5]float64
+			for i := range values {
+				s, _ := row[i+1].(string)
+				if values[i], err = strconv.ParseFloat(s, 64); err != nil {
+					return nil, fmt.Errorf("klines: invalid value %v", row[i+1])
+				}
+			}
+			klines = append(klines, Kline{
+				Timestamp: int64(openTime) / 1000,
+				Open:      values[0],
+				High:      values[1],
+				Low:       values[2],
+				Close:     values[3],
+				Volume:    values[4],
+			})
+		}
+		return klines, nil
+	}
+}
+
+// insertKlines 在一个事务内写入一页 K 线，已存在的同一时间 K 线被替换
+func insertKlines(db *sql.DB, schema KlineSchema, symbol any, klines []Kline) error {
+	columns := []string{schema.Time, schema.Open, schema.High, schema.Low, schema.Close, schema.Volume}
+	placeholders := "?, ?, ?, ?, ?, ?"
+	if schema.Symbol != "" {
+		columns = append(columns, schema.Symbol)
+		placeholders += ", ?"
+	}
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quoteIdent(c)
+	}
+	query := fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) VALUES (%s)", quoteIdent(schema.Table), strings.Join(quoted, ", "), placeholders)
+
+	// 定点整数列按四舍五入写入
+	scale := func(v, s float64) any {
+		if s == 1 {
+			return v
+		}
+		return int64(v*s + 0.5)
+	}
+	price := func(v float64) any { return scale(v, schema.PriceScale) }
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, k := range klines {
+		args := []any{k.Timestamp * schema.TimeScale, price(k.Open), price(k.High), price(k.Low), price(k.Close), scale(k.Volume, schema.VolumeScale)}
+		if schema.Symbol != "" {
+			args = append(args, symbol)
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// lastKlineTime 表中该交易对最后一根 K 线的开盘时间（秒），没有时为 0
+func lastKlineTime(store KlineStore, schema KlineSchema, symbol string) (int64, error) {
+	where, args, err := schema.symbolFilter(store, symbol)
+	if err != nil {
+		return 0, err
+	}
+	var last sql.NullInt64
+	err = store.QueryRow(fmt.Sprintf("SELECT MAX(%s) FROM %s WHERE %s",
+		quoteIdent(schema.Time), quoteIdent(schema.Table), where), args...).Scan(&last)
+	if err != nil {
+		return 0, err
+	}
+	return last.Int64 / schema.TimeScale, nil
+}
+
+// DownloadKlines 从币安下载 [startTime, endTime)（秒）内已收盘的 K 线写入 dbPath，每页一个事务；
+// 表中已有该交易对的数据时从最后一根之后续传，中断后重新运行即可接着下载
+func DownloadKlines(dbPath, market, symbol, interval string, startTime, endTime int64) (int, error) {
+	endpoint, ok := klineEndpoints[market]
+	if !ok {
+		return 0, fmt.Errorf("unknown market %q", market)
+	}
+	sec, err := ParseInterval(interval)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := requireSQLite(dbPath, "downloading klines"); err != nil {
+		return 0, err
+	}
+	store, err := OpenKlineStore(dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer store.Close()
+
+	schema, err := downloadSchema(store, klineSchemaConfig)
+	if err != nil {
+		return 0, err
+	}
+	symbolValue := any(nil)
+	if schema.Symbol != "" {
+		if symbolValue, err = schema.symbolValue(store, symbol); err != nil {
+			return 0, err
+		}
+	}
+	last, err := lastKlineTime(store, schema, symbol)
+	if err != nil {
+		return 0, err
+	}
+	if last >= startTime {
+		startTime = last + sec
+		log.Printf("表 %s 中已有 %s 到 %s 的数据，从此处续传", schema.Table, symbol, time.Unix(last, 0).UTC().Format("2006-01-02 15:04"))
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	return downloadRange(client, endpoint, store.DB(), schema, symbolValue, symbol, interval, sec, startTime, endTime)
+}
+
+// downloadRange 分页下载 [startTime, endTime)（秒）内已收盘的 K 线并写入，返回写入条数
+func downloadRange(client *http.Client, endpoint klineEndpoint, db *sql.DB, schema KlineSchema, symbolValue any,
+	symbol, interval string, sec, startTime, endTime int64) (int, error) {
+	// 只保存已收盘的 K 线
+	endTime = min(endTime, time.Now().Unix()-sec+1)
+
+	total := 0
+	for from := startTime - startTime%sec; from < endTime; {
+		page, err := fetchKlinePage(client, endpoint, symbol, interval, from*1000, (endTime-1)*1000)
+		if err != nil {
+			return total, err
+		}
+		if len(page) == 0 {
+			break
+		}
+		if err := insertKlines(db, schema, symbolValue, page); err != nil {
+			return total, err
+		}
+		total += len(page)
+		from = page[len(page)-1].Timestamp + sec
+		log.Printf("已下载 %d 根 K 线，至 %s", total, time.Unix(from, 0).UTC().Format("2006-01-02 15:04"))
+		time.Sleep(downloadPause)
+	}
+	return total, nil
+}
+
+// runDownloadCmd 执行 K 线下载命令
+func runDownloadCmd(dbPath, market, symbol, interval string, startTime, endTime int64) {
+	if market == "" {
+		market = "futures"
+	}
+	log.Printf("下载 %s %s %s K 线: %s ~ %s (UTC) -> %s", market, symbol, interval,
+		time.Unix(startTime, 0).UTC().Format("2006-01-02"), time.Unix(endTime, 0).UTC().Format("2006-01-02"), dbPath)
+	n, err := DownloadKlines(dbPath, market, symbol, interval, startTime, endTime)
+	if err != nil {
+		log.Fatalf("下载 K 线失败（已写入 %d 根，重新运行可续传）: %v", n, err)
+	}
+	log.Printf("下载完成，共写入 %d 根 K 线", n)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+)
+
+// liveFeeRate 实盘预期手续费率（taker），与回测默认费率一致
+const liveFeeRate = 0.0004
+
+// DriftMonitor 比较实际权益变化与交易日志中的预期盈亏
+type DriftMonitor struct {
+	baseEquity float64 // 启动时的账户权益
+	since      int64   // 启动时间，只统计此后的日志
+	threshold  float64 // 告警阈值 (USDT)
+	alerted    bool    // 已告警，回到阈值内后重新布防
+}
+
+// accountEquity 查询账户 USDT 权益（含未实现盈亏）
+func (s *Strategy) accountEquity() (float64, error) {
+	account, err := s.client.FutureGetAccount()
+	if err != nil {
+		return 0, wrapExchangeError("account", err)
+	}
+	asset, err := account.GetAsset("USDT")
+	if err != nil {
+		return 0, err
+	}
+	if asset == nil {
+		return 0, fmt.Errorf("USDT asset not found")
+	}
+	return strconv.ParseFloat(asset.MarginBalance, 64)
+}
+
+// startDriftMonitor 记录基准权益，开始资金漂移监控
+func (s *Strategy) startDriftMonitor() error {
+	if s.client == nil || s.journal == nil {
+		return fmt.Errorf("drift monitor requires api keys and journal_path")
+	}
+
+	equity, err := s.accountEquity()
+	if err != nil {
+		return err
+	}
+	s.drift = &DriftMonitor{
+		baseEquity: equity,
+		since:      time.Now().Unix(),
+		threshold:  s.config.DriftAlertUSDT,
+	}
+	log.Printf("资金漂移监控: 基准权益 %.2f USDT, 阈值 %.2f USDT", equity, s.drift.threshold)
+	return nil
+}
+
+// expectedEquity 基准权益 + 日志中已实现盈亏 - 手续费 + 当前持仓浮动盈亏
+func (s *Strategy) expectedEquity(price float64) (float64, error) {
+	entries, err := s.journal.Entries(s.drift.since, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	expected := s.drift.baseEquity
+	for _, e := range entries {
+		if e.Symbol != s.config.Symbol {
+			continue
+		}
+		expected += e.PnL - e.Fee
+	}
+
+	if s.entryPrice > 0 {
+		unrealized := (price - s.entryPrice) * s.entryAmount
+		if s.entrySide == "SHORT" {
+			unrealized = -unrealized
+		}
+		expected += unrealized
+	}
+
+	return expected, nil
+}
+
+// checkBalanceDrift 实际权益偏离预期超过阈值时告警（手动交易、强平、手续费异常等）
+func (s *Strategy) checkBalanceDrift() {
+	if s.drift == nil || len(s.klines) == 0 {
+		return
+	}
+
+	actual, err := s.accountEquity()
+	if err != nil {
+		s.handleExchangeError(err)
+		return
+	}
+	expected, err := s.expectedEquity(s.klines[len(s.klines)-1].Close)
+	if err != nil {
+		log.Printf("计算预期权益失败: %v", err)
+		return
+	}
+
+	diff := actual - expected
+	if math.Abs(diff) <= s.drift.threshold {
+		s.drift.alerted = false
+		return
+	}
+	if s.drift.alerted {
+		return
+	}
+
+	s.drift.alerted = true
+	msg := fmt.Sprintf("账户权益 %.2f 与预期 %.2f 相差 %.2f USDT（阈值 %.2f），可能存在手动交易、强平或手续费异常",
+		actual, expected, diff, s.drift.threshold)
+	log.Printf("[告警] %s", msg)
+	s.webhook.Alert("balance_drift", s.config.Symbol, msg)
+}
@@ -0,0 +1,705 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Order 策略引擎输出的交易指令
+type Order struct {
+	Signal Signal  // SignalLong / SignalShort / SignalCloseLong / SignalCloseShort
+	Size   float64 // 仓位比例 (0-1)，0 表示使用配置的 position_size
+	// Batch 开仓批次；>= 2 时只有持仓恰好已有 Batch-1 批才执行（加仓不重复），0 表示不限制
+	Batch  int
+	Reason string
+}
+
+// PositionView 引擎决策时看到的当前持仓，由执行方（实盘 / 回测）提供
+type PositionView struct {
+	Side      string  // "LONG" / "SHORT"，"" 表示空仓
+	Entries   int     // 已入场批次数
+	EntryTime int64   // 第一批入场时间
+	AvgPrice  float64 // 持仓均价
+	Exposure  float64 // 按当前价格计的持仓名义价值 / 账户资金
+	// 对冲模式：多空可同时持仓，上面的字段为先开的一侧，Counter 为另一侧（没有时为 nil），用 Leg 按方向取
+	Hedge   bool
+	Counter *PositionView
+}
+
+// StrategyEngine 可插拔的交易策略，实盘和回测都逐根 K 线驱动，同一套代码做决策
+//
+// OnKline 收到与上一根时间戳相同的 K 线时表示该 K 线仍在走（实盘最新一根），
+// 引擎应替换而不是追加；持仓由执行方维护，引擎根据 pos 决定开仓、加仓或平仓
+type StrategyEngine interface {
+	Name() string
+	WarmupBars() int // 生成信号前需要的连续 K 线数
+	OnKline(k Kline, pos PositionView) []Order
+}
+
+// SignalExplainer 可选接口：返回最近一次判定的条件明细（explain_signals / -explain）
+// 每个方向的第一个条件视为触发条件，回测详细模式只在其成立时打印
+type SignalExplainer interface {
+	Explanation() SignalExplanation
+}
+
+// EngineFactory 按运行配置创建策略引擎
+type EngineFactory func(config *Config) StrategyEngine
+
+// engines 已注册的策略引擎
+var engines = map[string]EngineFactory{}
+
+// RegisterEngine 注册策略引擎，在 init 中调用
+func RegisterEngine(name string, factory EngineFactory) {
+	if _, ok := engines[name]; ok {
+		panic("duplicate strategy engine: " + name)
+	}
+	engines[name] = factory
+}
+
+// DefaultEngine 未配置 engine 时使用的策略
+const DefaultEngine = "rsi"
+
+// NewEngine 按名称创建策略引擎，name 为空时使用 DefaultEngine
+func NewEngine(name string, config *Config) (StrategyEngine, error) {
+	if name == "" {
+		name = DefaultEngine
+	}
+	factory, ok := engines[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy engine %q (available: %s)", name, strings.Join(EngineNames(), ", "))
+	}
+	return factory(config), nil
+}
+
+// EngineNames 已注册的引擎名称（排序）
+func EngineNames() []string {
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// klineBuffer 引擎内部的 K 线历史，同一时间戳替换最后一根，超过 limit 丢弃最早的
+type klineBuffer struct {
+	klines []Kline
+	limit  int
+	total  int // 累计收到的 K 线数（不含替换）
+}
+
+// push 追加或替换最后一根 K 线，返回是否为新的一根
+func (b *klineBuffer) push(k Kline) bool {
+	if n := len(b.klines); n > 0 && b.klines[n-1].Timestamp == k.Timestamp {
+		b.klines[n-1] = k
+		return false
+	}
+	b.klines = append(b.klines, k)
+	b.total++
+	if b.limit > 0 && len(b.klines) > 2*b.limit {
+		b.klines = append(b.klines[:0], b.klines[len(b.klines)-b.limit:]...)
+	}
+	return true
+}
+
+// BreakoutEngine 唐奇安通道突破：收盘突破前 N 根最高价做多、跌破最低价做空，反向突破 N/2 通道平仓
+type BreakoutEngine struct {
+	period int
+	buf    klineBuffer
+}
+
+// NewBreakoutEngine 创建突破策略引擎
+func NewBreakoutEngine(period int) *BreakoutEngine {
+	return &BreakoutEngine{period: period, buf: klineBuffer{limit: period + 1}}
+}
+
+func (e *BreakoutEngine) Name() string    { return "breakout" }
+func (e *BreakoutEngine) WarmupBars() int { return e.period + 1 }
+
+// channel 最近 n 根（不含最新一根）的最高价和最低价
+func (e *BreakoutEngine) channel(n int) (high, low float64) {
+	klines := e.buf.klines[len(e.buf.klines)-1-n : len(e.buf.klines)-1]
+	high, low = klines[0].High, klines[0].Low
+	for _, k := range klines[1:] {
+		high = max(high, k.High)
+		low = min(low, k.Low)
+	}
+	return high, low
+}
+
+func (e *BreakoutEngine) OnKline(k Kline, pos PositionView) []Order {
+	e.buf.push(k)
+	if len(e.buf.klines) < e.period+1 {
+		return nil
+	}
+
+	switch pos.Side {
+	case "LONG":
+		if _, exitLow := e.channel(e.period / 2); k.Close < exitLow {
+			return []Order{{Signal: SignalCloseLong, Reason: "跌破出场通道"}}
+		}
+	case "SHORT":
+		if exitHigh, _ := e.channel(e.period / 2); k.Close > exitHigh {
+			return []Order{{Signal: SignalCloseShort, Reason: "突破出场通道"}}
+		}
+	default:
+		high, low := e.channel(e.period)
+		if k.Close > high {
+			return []Order{{Signal: SignalLong, Reason: "突破通道上沿"}}
+		}
+		if k.Close < low {
+			return []Order{{Signal: SignalShort, Reason: "跌破通道下沿"}}
+		}
+	}
+	return nil
+}
+
+// breakoutPeriod 突破策略的通道周期（5m K 线，约 4 小时）
+const breakoutPeriod = 48
+
+func init() {
+	RegisterEngine("rsi", func(config *Config) StrategyEngine {
+		return NewRSIEngine(config.strategyConfig(), config.PositionSize).withPyramid(config.Pyramid)
+	})
+	RegisterEngine("breakout", func(config *Config) StrategyEngine {
+		return NewBreakoutEngine(breakoutPeriod)
+	})
+}
+
+// view 按当前价格和账户资金生成引擎看到的持仓，nil 表示空仓
+func (p *Position) view(price, balance float64) PositionView {
+	if p == nil || len(p.entries) == 0 {
+		return PositionView{}
+	}
+	v := PositionView{
+		Side:      p.side,
+		Entries:   len(p.entries),
+		EntryTime: p.entries[0].entryTime,
+		AvgPrice:  p.avgPrice,
+	}
+	if balance > 0 {
+		v.Exposure = p.totalAmt * price / balance
+	}
+	return v
+}
+
+// pyramidView 分批建仓判断加仓用的持仓
+func (p *Position) pyramidView() pyramidPosition {
+	if p == nil || len(p.entries) == 0 {
+		return pyramidPosition{}
+	}
+	last := p.entries[len(p.entries)-1]
+	return pyramidPosition{side: p.side, entries: len(p.entries), lastTime: last.entryTime, lastPrice: last.entryPrice}
+}
+
+// RunEngineBacktest 用策略引擎逐根 K 线回测，与实盘走同一套 OnKline 逻辑
+//
+// 同向开仓指令加仓（批数、间隔和仓位上限按 Pyramid），反向开仓指令在持仓期间忽略（对冲模式下另开一侧持仓），平仓指令一次平掉该方向全部批次；
+// 盘中模式（IntrabarFraction > 0）先用未走完的 K 线决策并成交，再补入完整 K 线（该次指令丢弃）
+func RunEngineBacktest(klines []Kline, config BacktestConfig, engine StrategyEngine) *BacktestResult {
+	if config.Interval <= 0 {
+		config.Interval = sourceInterval(klines)
+	}
+	result, _ := RunEngineBacktestSource(SliceKlines(klines), config, engine)
+	return result
+}
+
+// RunEngineBacktestSource 从 KlineSource 逐根读取 K 线回测，只保留当前和下一根 K 线
+// （以及波动率目标需要的回看窗口），逻辑同 RunEngineBacktest；返回读取 K 线时的错误
+func RunEngineBacktestSource(src KlineSource, config BacktestConfig, engine StrategyEngine) (*BacktestResult, error) {
+	result := &BacktestResult{
+		BalanceCurve: []float64{config.StartBalance},
+		EquityCurve:  []float64{config.StartBalance},
+	}
+	closed, ok := src.Next()
+	if !ok {
+		return result, src.Err()
+	}
+	result.BalanceTimes = append(result.BalanceTimes, closed.Timestamp)
+	// 下一根 K 线：延迟成交和限价入场按它判断
+	next, hasNext := src.Next()
+	advance := func() (Kline, bool) {
+		k, ok := next, hasNext
+		if ok {
+			next, hasNext = src.Next()
+		}
+		return k, ok
+	}
+	explainer, _ := engine.(SignalExplainer)
+
+	balance := config.StartBalance
+	var book positionBook
+	maxEquity := balance
+	sizer := NewAdaptiveSizer(config.Sizing)
+	sizingBalance := NewSizingBalance(config.DailyCompounding)
+	throttle := NewEntryThrottle(config.Throttle)
+	stopSlippageBps := 0.0
+	var grossWin, grossLoss float64
+	var exposure exposureTracker
+	var slippage slippageTracker
+	funding := fundingCursor{rates: config.Funding}
+	var rs rTracker
+	var holds []int64 // 每个持仓从第一批入场到平仓的时间（秒）
+	fees := newFeeTracker(config)
+	filter := NewEquityFilter(config.EquityFilter)
+	result.EquityFilter.MATrades = config.EquityFilter.MATrades
+	// 波动率目标：按 1 倍杠杆的名义价值比例估计，回测的名义价值按 生效杠杆 / 基准杠杆 缩放
+	baseLeverage := max(config.Leverage, 1)
+	interval := config.Interval
+	if interval <= 0 && hasNext {
+		interval = next.Timestamp - closed.Timestamp
+	}
+	volTarget := NewVolTarget(config.VolTarget, int(baseLeverage), config.PositionSize/baseLeverage, interval)
+	result.VolTarget.Target = config.VolTarget.Target
+	window := klineBuffer{limit: max(volTarget.Lookback(), 1)}
+	// ATR 仓位：按已收盘的 K 线计算（盘中模式下不含当前 K 线）
+	atr := config.Sizing.NewATRTracker()
+	trailATR := config.Trailing.NewATRTracker()
+
+	// closeEntries 按 exitPrice（再按滑点模型调整）平掉 closing 为 true 的批次，reason 记入每笔交易；
+	// 全部批次平完时持仓结束，否则按剩余批次重算均价和保证金。
+	// 虚拟持仓（净值曲线过滤暂停期间）只按固定费率计算盈亏，用于净值曲线，不影响余额和统计
+	closeEntries := func(position *Position, k Kline, exitPrice float64, reason string, closing func(e PositionEntry) bool) {
+		ts := k.Timestamp
+		amount := 0.0
+		for _, entry := range position.entries {
+			if closing(entry) {
+				amount += entry.amount
+			}
+		}
+		if !position.shadow {
+			exitPrice = slippage.fill(config.Slippage, exitPrice, position.side == "SHORT", amount, k)
+		}
+		var remaining []PositionEntry
+		for _, entry := range position.entries {
+			if !closing(entry) {
+				remaining = append(remaining, entry)
+				continue
+			}
+			if position.shadow {
+				position.realized += unrealizedPnL(position.side, entry.amount, entry.entryPrice, exitPrice) -
+					(entry.entryPrice+exitPrice)*entry.amount*config.FeeRate
+				continue
+			}
+			trade := Trade{
+				EntryTime:   entry.entryTime,
+				ExitTime:    ts,
+				Side:        position.side,
+				EntryPrice:  entry.entryPrice,
+				ExitPrice:   exitPrice,
+				Amount:      entry.amount,
+				Batch:       entry.batch,
+				SizeMult:    entry.sizeMult,
+				EntryReason: entry.reason,
+				ExitReason:  reason,
+			}
+			if position.side == "LONG" {
+				trade.PnL = (exitPrice - entry.entryPrice) * entry.amount
+			} else {
+				trade.PnL = (entry.entryPrice - exitPrice) * entry.amount
+			}
+			trade.Fee = entry.fee + fees.charge(exitPrice*entry.amount, false, ts)
+			if reason == ExitLiquidation {
+				liqFee := exitPrice * entry.amount * liquidationFeeRate
+				trade.Fee += liqFee
+				result.LiquidationFees += liqFee
+			}
+			trade.PnL -= trade.Fee
+			trade.R = RMultiple(trade.PnL, trade.EntryPrice, trade.Amount, position.stopLoss(config.StopLossPct))
+			rs.add(trade.R)
+
+			balance += trade.PnL
+			position.realized += trade.PnL
+			if !config.StreamOnly {
+				result.Trades = append(result.Trades, trade)
+			}
+			config.TradeLog.WriteTrade(trade)
+			result.TotalPnL += trade.PnL
+			result.TotalFees += trade.Fee
+			result.TotalTrades++
+			if trade.PnL > 0 {
+				result.WinTrades++
+				grossWin += trade.PnL
+			} else {
+				result.LoseTrades++
+				grossLoss += -trade.PnL
+			}
+		}
+		config.TradeLog.Flush()
+		if len(remaining) > 0 {
+			position.entries = remaining
+			position.recompute(position.leverage)
+			return
+		}
+		ret := position.realized / position.cost
+		filter.Record(ret)
+		if position.shadow {
+			result.EquityFilter.Shadow++
+			result.EquityFilter.ShadowReturn += ret
+		} else {
+			holds = append(holds, ts-position.openedAt)
+			sizer.Record(position.realized + position.funding)
+		}
+		book.set(position.side, nil)
+	}
+	// closeAll 平掉持仓的全部批次
+	closeAll := func(position *Position, k Kline, exitPrice float64, reason string) {
+		closeEntries(position, k, exitPrice, reason, func(PositionEntry) bool { return true })
+	}
+
+	for ; ok; closed, ok = advance() {
+		// 当前 K 线：默认为已收盘 K 线，盘中模式下为未走完的 K 线
+		k := closed
+		if config.IntrabarFraction > 0 {
+			k = partialBar(closed, config.IntrabarFraction)
+		}
+
+		// 资金费结算：按结算所在 K 线开盘价计持仓名义价值，对冲模式下多空各自结算
+		if rate, n := funding.due(k.Timestamp); n > 0 {
+			for _, position := range book.open() {
+				if position.shadow {
+					continue
+				}
+				paid := fundingPayment(position.side, position.totalAmt*k.Open, rate)
+				balance -= paid
+				position.funding -= paid
+				result.TotalPnL -= paid
+				result.FundingPaid += paid
+				result.FundingEvents += n
+			}
+		}
+
+		for _, position := range book.open() {
+			alive := func() bool { return book.get(position.side) == position }
+
+			// 逐批止损：每批按自己的入场价止损，只平掉触发的批次；开盘已越过强平价时整体强平
+			stopPct := position.stopLoss(config.StopLossPct)
+			if config.StopMode == StopPerBatch {
+				batchPct := stopPct
+				stopPct = 0
+				if !gapLiquidation(config, position, k) {
+					penalty := stopPenaltyFor(config.IntrabarFill, config.StopPenalty)
+					for _, entry := range position.entries {
+						price, slipBps, hit := stopLossFill(position.side, entry.entryPrice, k, batchPct, penalty)
+						if !hit {
+							continue
+						}
+						if !position.shadow {
+							result.StopExits++
+							stopSlippageBps += slipBps
+						}
+						closeEntries(position, k, price, ExitStopLoss, func(e PositionEntry) bool {
+							return e.entryTime == entry.entryTime && e.batch == entry.batch
+						})
+						if !alive() {
+							break
+						}
+					}
+				}
+			}
+			if !alive() {
+				continue
+			}
+
+			// 止损 / 止盈在盘中按最高 / 最低价触发，先于引擎的收盘判定
+			// 逐仓强平：K 线极值越过强平价时强制平仓；止损价在强平价之前且开盘未越过强平价时先止损
+			liqPrice, liqHit := 0.0, false
+			if config.Leverage > 0 {
+				tier := maintenanceTier(config.Symbol, position.totalAmt*position.avgPrice)
+				liqPrice, liqHit = liquidationFill(position.side, liquidationPrice(position.side, position.totalAmt, position.avgPrice, position.margin, tier), k)
+			}
+			penalty := stopPenaltyFor(config.IntrabarFill, config.StopPenalty)
+			stopPrice, slipBps, stopHit := stopLossFill(position.side, position.avgPrice, k, stopPct, penalty)
+			// 移动止损按之前 K 线的最有利价格计算，比固定止损更近时先于固定止损触发
+			stopLevel, _ := protectivePrices(position.side, position.avgPrice, stopPct, 0)
+			stopReason := ExitStopLoss
+			trail := tighterStop(position.side, position.trailStop, config.Trailing.StopPrice(position.side, position.avgPrice, position.extreme, trailATR.Value()))
+			position.trailStop = trail
+			if price, bps, hit := stopPriceFill(position.side, trail, k, penalty); hit && tighterStop(position.side, trail, stopLevel) == trail {
+				stopPrice, slipBps, stopHit, stopLevel, stopReason = price, bps, true, trail, ExitTrailingStop
+			}
+			tpPrice, tpHit := takeProfitFill(position.side, position.avgPrice, k, config.TakeProfitPct)
+			if stopHit && tpHit {
+				_, tpLevel := protectivePrices(position.side, position.avgPrice, 0, config.TakeProfitPct)
+				stopHit = stopPriceFirst(position.side, stopLevel, tpLevel, k, config.IntrabarFill)
+				tpHit = !stopHit
+			}
+			if liqHit && stopHit && liqPrice != k.Open {
+				liqHit = false
+			}
+			shadow := position.shadow
+			switch {
+			case liqHit:
+				if !shadow {
+					result.Liquidations++
+				}
+				closeAll(position, k, liqPrice, ExitLiquidation)
+			case stopHit:
+				if !shadow {
+					result.StopExits++
+					stopSlippageBps += slipBps
+					if stopReason == ExitTrailingStop {
+						result.TrailingExits++
+					}
+				}
+				closeAll(position, k, stopPrice, stopReason)
+			case tpHit:
+				if !shadow {
+					result.TakeProfitExits++
+				}
+				closeAll(position, k, tpPrice, ExitTakeProfit)
+			}
+			if alive() {
+				position.extreme = favorable(position.side, position.extreme, k)
+			}
+		}
+
+		// 波动率目标：按已知的 K 线估计（盘中模式下不含当前 K 线），新杠杆在空仓时生效
+		if volTarget != nil {
+			if config.IntrabarFraction == 0 {
+				window.push(closed)
+			}
+			if volTarget.Update(window.klines, k.Timestamp) {
+				result.VolTarget.observe(volTarget)
+			}
+			if config.IntrabarFraction > 0 {
+				window.push(closed)
+			}
+		}
+		if book.empty() {
+			volTarget.Commit()
+		}
+		if config.IntrabarFraction == 0 {
+			atr.Update(closed)
+			trailATR.Update(closed)
+		}
+
+		entered := false
+		orders := engine.OnKline(k, book.view(k.Close, balance, config.Hedge))
+		if config.Maintenance.Active(k.Timestamp) {
+			// 维护时段：平掉持仓，忽略引擎指令（引擎仍逐根更新指标）
+			for _, position := range book.open() {
+				if !position.shadow {
+					result.MaintenanceExits++
+				}
+				closeAll(position, k, k.Close, ExitMaintenance)
+			}
+			orders = nil
+		}
+		orders = config.Pyramid.filterOrders(orders, k.Close, book.long.pyramidView(), book.short.pyramidView())
+		for _, order := range orders {
+			switch order.Signal {
+			case SignalCloseLong, SignalCloseShort:
+				side := "LONG"
+				if order.Signal == SignalCloseShort {
+					side = "SHORT"
+				}
+				if position := book.get(side); position != nil {
+					closeAll(position, k, k.Close, order.Reason)
+				}
+
+			case SignalLong, SignalShort:
+				side := "LONG"
+				if order.Signal == SignalShort {
+					side = "SHORT"
+				}
+				if !config.Hedge && book.get(oppositeSide(side)) != nil {
+					continue
+				}
+				position := book.get(side)
+				if order.Batch >= 2 && (position == nil || len(position.entries) != order.Batch-1) {
+					continue
+				}
+				size := order.Size
+				if size <= 0 {
+					size = config.PositionSize
+				}
+				if config.Pyramid.Enabled() {
+					var ok bool
+					if size, ok = config.Pyramid.admit(position.pyramidView(), size, k.Timestamp); !ok {
+						continue
+					}
+				}
+				// 延迟成交：信号 K 线走完后才下单，按下一根开盘价成交；价格已跑远则放弃，不追价
+				fillPrice := k.Close
+				maker := false
+				if config.EntryFill == EntryLimit {
+					// 限价入场：在信号收盘价挂单，下一根 K 线穿过挂单价才成交，否则撤单
+					if !hasNext || !limitFill(side, fillPrice, next) {
+						result.LimitMisses++
+						continue
+					}
+					maker = true
+				} else if config.MaxChaseBps > 0 {
+					if !hasNext {
+						continue
+					}
+					fillPrice = next.Open
+					if chaseBps(side, k.Close, fillPrice) > config.MaxChaseBps {
+						result.ChaseSkips++
+						continue
+					}
+				}
+				if !throttle.Allow(k.Timestamp) {
+					result.ThrottledEntries++
+					continue
+				}
+				throttle.Record(k.Timestamp)
+
+				// 仓位资金基数：未按日复利时为当前余额（已扣除之前批次的开仓手续费），按日复利时为当日第一次取值时的余额
+				sizingBase := sizingBalance.Base(k.Timestamp, balance)
+				sizeMult := sizer.Multiplier()
+				leverage := config.Leverage
+				if lev := volTarget.Leverage(); lev > 0 {
+					leverage = float64(lev)
+				}
+				if position != nil {
+					leverage = position.leverage
+				}
+				amount := config.Sizing.EntryAmount(sizingBase, size, sizeMult*max(leverage, 1)/baseLeverage, config.PositionSize, fillPrice)
+				if config.Sizing.Unit == UnitATR {
+					// 风险按余额计，与杠杆无关
+					amount = config.Sizing.ATRAmount(sizingBase, size*sizeMult, config.PositionSize, atr.Value(), fillPrice)
+				}
+				if amount <= 0 {
+					continue
+				}
+				// 净值曲线低于均线时新开的持仓只虚拟跟踪，已有持仓的加仓沿用持仓的状态
+				shadow := !filter.Allow()
+				if position != nil {
+					shadow = position.shadow
+				}
+				entryFee := 0.0
+				if !shadow {
+					if !maker {
+						fillPrice = slippage.fill(config.Slippage, fillPrice, side == "LONG", amount, k)
+					}
+					entryFee = fees.charge(fillPrice*amount, maker, k.Timestamp)
+				}
+				if position == nil {
+					position = &Position{side: side, openedAt: k.Timestamp, shadow: shadow, leverage: leverage,
+						stopPct: config.Sizing.ATRStopPct(atr.Value(), fillPrice), extreme: fillPrice}
+					book.set(side, position)
+				}
+				batch := order.Batch
+				if batch <= 0 {
+					batch = len(position.entries) + 1
+				}
+				position.entries = append(position.entries, PositionEntry{
+					entryTime:  k.Timestamp,
+					entryPrice: fillPrice,
+					amount:     amount,
+					batch:      batch,
+					sizeMult:   sizeMult,
+					reason:     order.Reason,
+					fee:        entryFee,
+				})
+				position.totalAmt += amount
+				position.cost += fillPrice * amount
+				position.avgPrice = (position.avgPrice*(position.totalAmt-amount) + fillPrice*amount) / position.totalAmt
+				if config.Leverage > 0 {
+					position.margin += fillPrice * amount / position.leverage
+				}
+				if shadow {
+					result.EquityFilter.PausedEntries++
+					continue
+				}
+				balance -= entryFee
+				entered = true
+			}
+		}
+
+		// 当天没有开仓时也在第一根 K 线结束时记下当日开盘资金
+		sizingBalance.Base(k.Timestamp, balance)
+
+		// 详细模式：触发条件成立时输出各条件，便于排查为什么没有入场
+		if config.Explain && explainer != nil {
+			e := explainer.Explanation()
+			if len(e.Long) > 0 && e.Long[0].Pass {
+				printBacktestExplanation(k.Timestamp, "做多", e.Long, entered)
+			}
+			if len(e.Short) > 0 && e.Short[0].Pass {
+				printBacktestExplanation(k.Timestamp, "做空", e.Short, entered)
+			}
+		}
+
+		// 盘中模式：K 线走完后补入完整 K 线，供后续 K 线计算指标
+		if config.IntrabarFraction > 0 {
+			engine.OnKline(closed, book.view(closed.Close, balance, config.Hedge))
+			atr.Update(closed)
+			trailATR.Update(closed)
+		}
+
+		// 虚拟持仓不占用资金，也不计入权益；对冲模式下名义价值和保证金按多空两侧相加
+		// 回撤按含浮动盈亏的权益计算，持仓期间的浮亏同样计入
+		notional, margin := 0.0, 0.0
+		equity := balance
+		held := false
+		for _, position := range book.open() {
+			if position.shadow {
+				continue
+			}
+			if volTarget != nil && !held {
+				result.VolTarget.hold(int(position.leverage))
+			}
+			held = true
+			notional += position.totalAmt * closed.Close
+			margin += position.margin
+			equity += unrealizedPnL(position.side, position.totalAmt, position.avgPrice, closed.Close)
+		}
+		if held && balance > 0 {
+			result.MaxMarginUsage = max(result.MaxMarginUsage, margin/balance)
+		}
+		exposure.add(notional, balance)
+		result.BalanceCurve = append(result.BalanceCurve, balance)
+		result.EquityCurve = append(result.EquityCurve, equity)
+		result.BalanceTimes = append(result.BalanceTimes, k.Timestamp)
+
+		if equity > maxEquity {
+			maxEquity = equity
+		}
+		drawdown := (maxEquity - equity) / maxEquity
+		if drawdown > result.MaxDrawdown {
+			result.MaxDrawdown = drawdown
+		}
+	}
+
+	if result.TotalTrades > 0 {
+		result.WinRate = float64(result.WinTrades) / float64(result.TotalTrades)
+	}
+	if grossLoss > 0 {
+		result.ProfitFactor = grossWin / grossLoss
+	}
+	if result.StopExits > 0 {
+		result.AvgStopSlippageBps = stopSlippageBps / float64(result.StopExits)
+	}
+	result.Exposure = exposure.stats(result.TotalPnL, config.StartBalance)
+	result.SlippageCost = slippage.cost
+	result.AvgSlippageBps = slippage.avgBps()
+	result.Participation = slippage.summary()
+	result.Fees = fees.result()
+	if filter != nil {
+		result.EquityFilter.Pauses = filter.pauses
+	}
+	if volTarget != nil {
+		result.VolTarget.Changes = volTarget.changes
+	}
+	result.Drawdown = DrawdownDurations(result.BalanceTimes, result.EquityCurve)
+	result.RStats = rs.stats()
+	result.RiskPct = config.StopLossPct
+	if config.Sizing.Unit == UnitATR {
+		result.RiskATRMult = config.Sizing.atrMult()
+	}
+	result.Performance = ComputePerformance(result.BalanceTimes, result.EquityCurve, result.MaxDrawdown, holds)
+	result.SharpeRatio = result.Performance.Sharpe
+
+	daily := DailyEquity(result.BalanceTimes, result.EquityCurve)
+	if volTarget != nil {
+		result.VolTarget.RealizedVol = annualizedVol(daily)
+	}
+	result.RollingSharpe = RollingSharpe(daily, RollingWindowDays)
+	result.RollingDrawdown = RollingMaxDrawdown(daily, RollingWindowDays)
+
+	return result, src.Err()
+}
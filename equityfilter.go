@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// EquityFilterConfig 净值曲线过滤：策略自身的净值（每个持仓平仓收益率的累加，含暂停期间的虚拟持仓）
+// 低于最近 MATrades 笔平仓后净值的均线时暂停入场，期间的信号只虚拟跟踪，回到均线之上后恢复；0 表示不启用
+type EquityFilterConfig struct {
+	MATrades int `json:"ma_trades"`
+}
+
+// Validate 检查配置
+func (c EquityFilterConfig) Validate() error {
+	if c.MATrades < 0 {
+		return fmt.Errorf("equity_filter.ma_trades must be non-negative")
+	}
+	return nil
+}
+
+// EquityFilterState 净值曲线过滤的状态，实盘写入运行状态文件，重启后恢复
+type EquityFilterState struct {
+	Equity float64   `json:"equity"`
+	Curve  []float64 `json:"curve"` // 最近 MATrades 笔平仓后的净值
+	Paused bool      `json:"paused,omitempty"`
+}
+
+// EquityFilter 按策略净值与其均线决定是否允许实盘（回测中为计入结果的）入场，nil 表示不启用
+type EquityFilter struct {
+	period int
+	state  EquityFilterState
+	pauses int // 从允许切换到暂停的次数
+}
+
+// NewEquityFilter 创建净值曲线过滤，未启用时返回 nil
+func NewEquityFilter(c EquityFilterConfig) *EquityFilter {
+	if c.MATrades <= 0 {
+		return nil
+	}
+	return &EquityFilter{period: c.MATrades}
+}
+
+// Allow 当前是否允许入场：平仓不足 MATrades 笔时始终允许
+func (f *EquityFilter) Allow() bool {
+	return f == nil || !f.state.Paused
+}
+
+// Record 记录一个持仓的平仓收益率（小数），按新的净值和均线更新暂停状态，状态切换时返回 true
+func (f *EquityFilter) Record(ret float64) bool {
+	if f == nil {
+		return false
+	}
+	f.state.Equity += ret
+	f.state.Curve = append(f.state.Curve, f.state.Equity)
+	if len(f.state.Curve) > f.period {
+		f.state.Curve = f.state.Curve[len(f.state.Curve)-f.period:]
+	}
+	paused := len(f.state.Curve) == f.period && f.state.Equity < f.MA()
+	if paused == f.state.Paused {
+		return false
+	}
+	f.state.Paused = paused
+	if paused {
+		f.pauses++
+	}
+	return true
+}
+
+// MA 最近 MATrades 笔平仓后净值的均值
+func (f *EquityFilter) MA() float64 {
+	if f == nil || len(f.state.Curve) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range f.state.Curve {
+		sum += v
+	}
+	return sum / float64(len(f.state.Curve))
+}
+
+// State 当前状态（用于持久化），未启用时返回 nil
+func (f *EquityFilter) State() *EquityFilterState {
+	if f == nil {
+		return nil
+	}
+	state := f.state
+	state.Curve = append([]float64(nil), f.state.Curve...)
+	return &state
+}
+
+// Restore 恢复持久化的状态，均线周期变化时只保留最近 MATrades 笔
+func (f *EquityFilter) Restore(state *EquityFilterState) {
+	if f == nil || state == nil {
+		return
+	}
+	f.state = *state
+	if len(f.state.Curve) > f.period {
+		f.state.Curve = f.state.Curve[len(f.state.Curve)-f.period:]
+	}
+	f.state.Paused = len(f.state.Curve) == f.period && f.state.Equity < f.MA()
+}
+
+// EquityFilterStats 回测中净值曲线过滤的统计
+type EquityFilterStats struct {
+	MATrades      int
+	Pauses        int     // 暂停次数
+	Shadow        int     // 暂停期间只虚拟跟踪、未计入结果的持仓数
+	ShadowReturn  float64 // 虚拟持仓的收益率合计（负数为过滤避开的亏损）
+	PausedEntries int     // 虚拟持仓的入场批次数
+}
+
+// printEquityFilterStats 打印净值曲线过滤的统计
+func printEquityFilterStats(s EquityFilterStats) {
+	if s.MATrades <= 0 {
+		return
+	}
+	fmt.Printf("净值曲线过滤 (%d 笔均线): 暂停 %d 次, 虚拟跟踪 %d 个持仓 (%d 批入场), 虚拟收益合计 %.2f%%\n",
+		s.MATrades, s.Pauses, s.Shadow, s.PausedEntries, s.ShadowReturn*100)
+}
+
+// printEquityFilterComparison 并排打印启用与不启用净值曲线过滤的回测结果
+func printEquityFilterComparison(filtered, unfiltered *BacktestResult) {
+	fmt.Println("\n--- 净值曲线过滤对比 ---")
+	fmt.Printf("%-10s %14s %14s\n", "", "过滤", "不过滤")
+	fmt.Printf("%-10s %14.2f %14.2f\n", "总盈亏", filtered.TotalPnL, unfiltered.TotalPnL)
+	fmt.Printf("%-10s %13.2f%% %13.2f%%\n", "胜率", filtered.WinRate*100, unfiltered.WinRate*100)
+	fmt.Printf("%-10s %14d %14d\n", "交易次数", filtered.TotalTrades, unfiltered.TotalTrades)
+	fmt.Printf("%-10s %13.2f%% %13.2f%%\n", "最大回撤", filtered.MaxDrawdown*100, unfiltered.MaxDrawdown*100)
+	fmt.Printf("%-10s %14.2f %14.2f\n", "夏普", filtered.Performance.Sharpe, unfiltered.Performance.Sharpe)
+}
+
+// recordEquityFilter 实盘平仓后将持仓收益率（扣除开平仓手续费）计入净值曲线过滤，状态切换时记录日志并告警
+func (s *Strategy) recordEquityFilter(ret float64) {
+	if !s.equityFilter.Record(ret) {
+		return
+	}
+	f := s.equityFilter
+	msg := fmt.Sprintf("策略净值 %.2f%% 低于 %d 笔均线 %.2f%%，暂停实盘入场，信号只虚拟跟踪",
+		f.state.Equity*100, f.period, f.MA()*100)
+	if f.Allow() {
+		msg = fmt.Sprintf("策略净值 %.2f%% 回到 %d 笔均线 %.2f%% 之上，恢复实盘入场",
+			f.state.Equity*100, f.period, f.MA()*100)
+	}
+	log.Printf("[净值过滤] %s", msg)
+	s.webhook.Alert("equity_filter", s.config.Symbol, msg)
+}
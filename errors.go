@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// ErrorKind 交易所错误分类
+type ErrorKind int
+
+const (
+	ErrKindUnknown            ErrorKind = iota
+	ErrKindAuth                         // API Key 无效、签名错误、权限不足
+	ErrKindRateLimit                    // 请求频率超限 / IP 被封
+	ErrKindInsufficientMargin           // 保证金不足
+	ErrKindInvalidSymbol                // 交易对不存在
+	ErrKindNetwork                      // 连接失败、超时
+	ErrKindMinQty                       // 数量低于步长或名义价值低于下限
+)
+
+var errorKindNames = map[ErrorKind]string{
+	ErrKindUnknown:            "unknown",
+	ErrKindAuth:               "auth",
+	ErrKindRateLimit:          "rate_limit",
+	ErrKindInsufficientMargin: "insufficient_margin",
+	ErrKindInvalidSymbol:      "invalid_symbol",
+	ErrKindNetwork:            "network",
+	ErrKindMinQty:             "min_qty",
+}
+
+func (k ErrorKind) String() string {
+	return errorKindNames[k]
+}
+
+// ExchangeError 带分类的交易所错误
+type ExchangeError struct {
+	Kind ErrorKind
+	Op   string // 出错的操作，如 "ticker"、"open long"
+	Err  error
+}
+
+func (e *ExchangeError) Error() string {
+	return fmt.Sprintf("%s: %s: %v", e.Op, e.Kind, e.Err)
+}
+
+func (e *ExchangeError) Unwrap() error {
+	return e.Err
+}
+
+// errorPatterns Binance 错误码 / 错误信息到分类的映射，按顺序匹配
+var errorPatterns = []struct {
+	kind     ErrorKind
+	patterns []string
+}{
+	{ErrKindAuth, []string{"-2014", "-2015", "-1022", "-2008", "api-key", "signature", "http 401"}},
+	{ErrKindRateLimit, []string{"-1003", "-1015", "http 429", "http 418", "too many", "rate limit"}},
+	{ErrKindInsufficientMargin, []string{"-2018", "-2019", "-2027", "insufficient", "margin is"}},
+	{ErrKindInvalidSymbol, []string{"-1121", "invalid symbol"}},
+	{ErrKindMinQty, []string{"-4003", "-4164", "-1013", "lot_size", "min_notional", "notional must be"}},
+	{ErrKindNetwork, []string{"timeout", "connection refused", "connection reset", "no such host", "broken pipe"}},
+}
+
+// classifyError 根据错误类型和错误信息判断分类
+func classifyError(err error) ErrorKind {
+	var exErr *ExchangeError
+	if errors.As(err, &exErr) {
+		return exErr.Kind
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, p := range errorPatterns {
+		for _, pattern := range p.patterns {
+			if strings.Contains(msg, pattern) {
+				return p.kind
+			}
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrKindNetwork
+	}
+	return ErrKindUnknown
+}
+
+// wrapExchangeError 将交易所客户端返回的错误包装为 ExchangeError，nil 原样返回
+func wrapExchangeError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var exErr *ExchangeError
+	if errors.As(err, &exErr) {
+		return err
+	}
+	return &ExchangeError{Kind: classifyError(err), Op: op, Err: err}
+}
+
+// rateLimitBackoff 触发限频后暂停请求的时间
+const rateLimitBackoff = 2 * time.Minute
+
+// handleExchangeError 按错误分类处理：网络错误下周期重试，限频暂停一段时间，
+// 保证金不足告警后继续，数量过小时清除碎仓，认证失败和交易对无效告警并停止策略
+func (s *Strategy) handleExchangeError(err error) {
+	if err == nil {
+		return
+	}
+
+	kind := classifyError(err)
+	switch kind {
+	case ErrKindNetwork:
+		log.Printf("网络错误，下个周期重试: %v", err)
+	case ErrKindRateLimit:
+		s.pausedUntil = time.Now().Add(rateLimitBackoff)
+		log.Printf("请求频率超限，暂停至 %s: %v", s.pausedUntil.Format("15:04:05"), err)
+	case ErrKindInsufficientMargin:
+		log.Printf("保证金不足: %v", err)
+		s.webhook.Alert(kind.String(), s.config.Symbol, err.Error())
+	case ErrKindMinQty:
+		log.Printf("下单数量低于交易所最小值: %v", err)
+		s.dropDustPosition()
+	case ErrKindAuth, ErrKindInvalidSymbol:
+		log.Printf("[告警] 不可恢复的错误，停止策略: %v", err)
+		s.webhook.Alert(kind.String(), s.config.Symbol, err.Error())
+		s.haltErr = err
+		s.running = false
+	default:
+		log.Printf("交易所请求失败: %v", err)
+	}
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Experiment 一次回测 / 优化运行的记录
+type Experiment struct {
+	ID        int64
+	Time      int64
+	Mode      string // backtest, bounce, optimize
+	Symbol    string
+	Engine    string
+	StartTime int64
+	EndTime   int64
+	Interval  int64              // K 线周期（秒）
+	Params    map[string]float64 // 策略参数；优化为最优组合
+	Metrics   map[string]float64 // 结果指标
+	GitHash   string             // 构建时的代码版本，工作区有未提交修改时带 -dirty
+	Args      string             // 命令行参数，便于复现
+}
+
+// ExperimentStore 实验记录（SQLite）
+type ExperimentStore struct {
+	db *sql.DB
+}
+
+// experimentMigrations 实验记录库的表结构版本
+var experimentMigrations = []Migration{
+	{Version: 1, Name: "baseline", Up: execMigration(`
+		CREATE TABLE IF NOT EXISTS experiments (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			ts         INTEGER NOT NULL,
+			mode       TEXT NOT NULL,
+			symbol     TEXT NOT NULL,
+			engine     TEXT NOT NULL DEFAULT '',
+			start_time INTEGER NOT NULL,
+			end_time   INTEGER NOT NULL,
+			interval   INTEGER NOT NULL DEFAULT 0,
+			params     TEXT NOT NULL DEFAULT '{}',
+			metrics    TEXT NOT NULL DEFAULT '{}',
+			git_hash   TEXT NOT NULL DEFAULT '',
+			args       TEXT NOT NULL DEFAULT ''
+		)`,
+	)},
+}
+
+// OpenExperimentStore 打开或创建实验记录库
+func OpenExperimentStore(path string) (*ExperimentStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrateSQLite(db, path, "experiments", experimentMigrations); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &ExperimentStore{db: db}, nil
+}
+
+// Close 关闭数据库
+func (s *ExperimentStore) Close() error {
+	return s.db.Close()
+}
+
+// Record 写入一条运行记录，返回记录 ID
+func (s *ExperimentStore) Record(e Experiment) (int64, error) {
+	if e.Time == 0 {
+		e.Time = time.Now().Unix()
+	}
+	params, err := json.Marshal(e.Params)
+	if err != nil {
+		return 0, err
+	}
+	metrics, err := json.Marshal(e.Metrics)
+	if err != nil {
+		return 0, err
+	}
+	res, err := s.db.Exec(
+		`INSERT INTO experiments (ts, mode, symbol, engine, start_time, end_time, interval, params, metrics, git_hash, args)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Time, e.Mode, e.Symbol, e.Engine, e.StartTime, e.EndTime, e.Interval, string(params), string(metrics), e.GitHash, e.Args,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// ExperimentFilter 查询条件，空字段表示不限
+type ExperimentFilter struct {
+	IDs    []int64
+	Mode   string
+	Symbol string
+	Engine string
+	Limit  int // 0 表示不限
+}
+
+// ParseExperimentFilter 解析 "mode=backtest,symbol=ETHUSDT,engine=rsi" 形式的过滤条件
+func ParseExperimentFilter(spec string) (ExperimentFilter, error) {
+	var f ExperimentFilter
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return f, fmt.Errorf("invalid filter %q (want key=value)", part)
+		}
+		switch strings.TrimSpace(key) {
+		case "mode":
+			f.Mode = strings.TrimSpace(value)
+		case "symbol":
+			f.Symbol = strings.ToUpper(strings.TrimSpace(value))
+		case "engine":
+			f.Engine = strings.TrimSpace(value)
+		default:
+			return f, fmt.Errorf("unknown filter key %q (want mode, symbol or engine)", key)
+		}
+	}
+	return f, nil
+}
+
+// List 按 ID 倒序（最近的在前）查询运行记录
+func (s *ExperimentStore) List(f ExperimentFilter) ([]Experiment, error) {
+	query := `SELECT id, ts, mode, symbol, engine, start_time, end_time, interval, params, metrics, git_hash, args FROM experiments WHERE 1 = 1`
+	var args []any
+	if len(f.IDs) > 0 {
+		query += " AND id IN (" + strings.TrimSuffix(strings.Repeat("?,", len(f.IDs)), ",") + ")"
+		for _, id := range f.IDs {
+			args = append(args, id)
+		}
+	}
+	for _, c := range []struct{ column, value string }{{"mode", f.Mode}, {"symbol", f.Symbol}, {"engine", f.Engine}} {
+		if c.value != "" {
+			query += " AND " + c.column + " = ?"
+			args = append(args, c.value)
+		}
+	}
+	query += " ORDER BY id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Experiment
+	for rows.Next() {
+		var e Experiment
+		var params, metrics string
+		if err := rows.Scan(&e.ID, &e.Time, &e.Mode, &e.Symbol, &e.Engine, &e.StartTime, &e.EndTime, &e.Interval, &params, &metrics, &e.GitHash, &e.Args); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(params), &e.Params); err != nil {
+			return nil, fmt.Errorf("experiment %d params: %w", e.ID, err)
+		}
+		if err := json.Unmarshal([]byte(metrics), &e.Metrics); err != nil {
+			return nil, fmt.Errorf("experiment %d metrics: %w", e.ID, err)
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// buildRevision 构建时嵌入的 git 版本（go build 在 git 工作区内自动记录），取前 12 位
+func buildRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	var revision string
+	var dirty bool
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			dirty = s.Value == "true"
+		}
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if revision != "" && dirty {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// recordExperiment 将本次运行写入实验记录，path 为空时不记录；失败只记录日志，不影响运行结果
+func recordExperiment(path string, e Experiment) {
+	if path == "" {
+		return
+	}
+	store, err := OpenExperimentStore(path)
+	if err != nil {
+		log.Printf("打开实验记录失败: %v", err)
+		return
+	}
+	defer store.Close()
+
+	e.GitHash = buildRevision()
+	e.Args = strings.Join(os.Args[1:], " ")
+	id, err := store.Record(e)
+	if err != nil {
+		log.Printf("写入实验记录失败: %v", err)
+		return
+	}
+	log.Printf("实验记录 #%d 已写入 %s", id, path)
+}
+
+// strategyParamValues 策略参数的当前值，键名与 config.json 一致
+func strategyParamValues(sc StrategyConfig, positionSize float64) map[string]float64 {
+	values := map[string]float64{"position_size": positionSize}
+	for _, p := range strategyParams {
+		values[p.name] = p.get(sc)
+	}
+	return values
+}
+
+// backtestMetrics 回测结果中记录到实验的指标
+func backtestMetrics(r *BacktestResult) map[string]float64 {
+	return map[string]float64{
+		"pnl":            r.TotalPnL,
+		"fees":           r.TotalFees,
+		"trades":         float64(r.TotalTrades),
+		"win_rate":       r.WinRate,
+		"profit_factor":  r.ProfitFactor,
+		"max_drawdown":   r.MaxDrawdown,
+		"cagr":           r.Performance.CAGR,
+		"sharpe":         r.Performance.Sharpe,
+		"sortino":        r.Performance.Sortino,
+		"calmar":         r.Performance.Calmar,
+		"time_in_market": r.Exposure.TimeInMarket,
+	}
+}
+
+// optimizeMetrics 优化最优组合记录到实验的指标
+func optimizeMetrics(r OptimizeResult, evaluated int) map[string]float64 {
+	return map[string]float64{
+		"pnl":               r.TotalPnL,
+		"trades":            float64(r.Trades),
+		"win_rate":          r.WinRate,
+		"profit_factor":     r.ProfitFactor,
+		"max_drawdown":      r.MaxDrawdown,
+		"sharpe":            r.Sharpe,
+		"calmar":            r.Calmar,
+		"max_consec_losses": float64(r.MaxConsecLosses),
+		"evaluated":         float64(evaluated),
+	}
+}
+
+// bounceMetrics 反弹策略回测结果中记录到实验的指标
+func bounceMetrics(r *BounceResult) map[string]float64 {
+	perf := ComputePerformance(r.BalanceTimes, r.EquityCurve, r.MaxDrawdown, nil)
+	return map[string]float64{
+		"pnl":            r.TotalPnL,
+		"fees":           r.TotalFees,
+		"trades":         float64(r.TotalTrades),
+		"win_rate":       r.WinRate,
+		"profit_factor":  r.ProfitFactor,
+		"max_drawdown":   r.MaxDrawdown,
+		"cagr":           perf.CAGR,
+		"sharpe":         perf.Sharpe,
+		"sortino":        perf.Sortino,
+		"calmar":         perf.Calmar,
+		"time_in_market": r.Exposure.TimeInMarket,
+	}
+}
+
+// recordOptimizeExperiment 记录一次优化：参数和指标取按优化目标排名第一的组合
+func recordOptimizeExperiment(path string, e Experiment, config BacktestConfig, results []OptimizeResult, objective Objective) {
+	if path == "" || len(results) == 0 {
+		return
+	}
+	best := results[0]
+	for _, r := range results[1:] {
+		if objective.Score(r) > objective.Score(best) {
+			best = r
+		}
+	}
+	e.Params = strategyParamValues(best.Config, config.PositionSize)
+	e.Metrics = optimizeMetrics(best, len(results))
+	recordExperiment(path, e)
+}
+
+// experimentListMetrics 列表中显示的指标
+var experimentListMetrics = []string{"pnl", "max_drawdown", "sharpe", "trades", "win_rate"}
+
+// formatMetric 按指标类型格式化：比例类显示为百分比
+func formatMetric(name string, v float64) string {
+	switch name {
+	case "win_rate", "max_drawdown", "cagr", "time_in_market":
+		return fmt.Sprintf("%.2f%%", v*100)
+	case "trades", "evaluated", "max_consec_losses":
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// printExperiments 列出运行记录
+func printExperiments(list []Experiment) {
+	fmt.Println("\n========== 实验记录 ==========")
+	fmt.Println("ID | 时间 | 模式 | 交易对 | 引擎 | 数据区间 | 周期 | 盈亏 | 最大回撤 | 夏普 | 交易 | 胜率 | 版本")
+	for _, e := range list {
+		var metrics []string
+		for _, name := range experimentListMetrics {
+			if v, ok := e.Metrics[name]; ok {
+				metrics = append(metrics, formatMetric(name, v))
+			} else {
+				metrics = append(metrics, "-")
+			}
+		}
+		fmt.Printf("%d | %s | %s | %s | %s | %s ~ %s | %ds | %s | %s\n",
+			e.ID, time.Unix(e.Time, 0).Format("2006-01-02 15:04"), e.Mode, e.Symbol, e.Engine,
+			time.Unix(e.StartTime, 0).UTC().Format("2006-01-02"), time.Unix(e.EndTime, 0).UTC().Format("2006-01-02"),
+			e.Interval, strings.Join(metrics, " | "), e.GitHash)
+	}
+}
+
+// sortedKeys 多条记录中出现过的所有键，按名称排序
+func sortedKeys(list []Experiment, values func(e Experiment) map[string]float64) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, e := range list {
+		for k := range values(e) {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// printExperimentComparison 并排比较多条记录的参数和指标，取值不同的参数以 * 标出
+func printExperimentComparison(list []Experiment) {
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	header := []string{"%-22s"}
+	cells := []any{""}
+	for _, e := range list {
+		header = append(header, "%16s")
+		cells = append(cells, fmt.Sprintf("#%d %s", e.ID, e.Mode))
+	}
+	format := strings.Join(header, " ") + "\n"
+
+	fmt.Println("\n========== 实验对比 ==========")
+	fmt.Printf(format, cells...)
+	row := func(label string, value func(e Experiment) string) {
+		cells := []any{label}
+		for _, e := range list {
+			cells = append(cells, value(e))
+		}
+		fmt.Printf(format, cells...)
+	}
+	row("symbol", func(e Experiment) string { return e.Symbol })
+	row("engine", func(e Experiment) string { return e.Engine })
+	row("interval", func(e Experiment) string { return fmt.Sprintf("%ds", e.Interval) })
+	row("data", func(e Experiment) string {
+		return time.Unix(e.StartTime, 0).UTC().Format("060102") + "-" + time.Unix(e.EndTime, 0).UTC().Format("060102")
+	})
+	row("git", func(e Experiment) string { return e.GitHash })
+
+	fmt.Println("--- 参数 ---")
+	for _, name := range sortedKeys(list, func(e Experiment) map[string]float64 { return e.Params }) {
+		label := name
+		first, differs := list[0].Params[name], false
+		for _, e := range list[1:] {
+			if e.Params[name] != first {
+				differs = true
+			}
+		}
+		if differs {
+			label = "* " + name
+		}
+		row(label, func(e Experiment) string {
+			if v, ok := e.Params[name]; ok {
+				return strconv.FormatFloat(v, 'g', 6, 64)
+			}
+			return "-"
+		})
+	}
+
+	fmt.Println("--- 指标 ---")
+	for _, name := range sortedKeys(list, func(e Experiment) map[string]float64 { return e.Metrics }) {
+		row(name, func(e Experiment) string {
+			if v, ok := e.Metrics[name]; ok {
+				return formatMetric(name, v)
+			}
+			return "-"
+		})
+	}
+}
+
+// parseExperimentIDs 解析逗号分隔的记录 ID
+func parseExperimentIDs(list string) ([]int64, error) {
+	var ids []int64
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(strings.TrimPrefix(part, "#"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid experiment id %q", part)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// runExperimentsCmd 列出、过滤或对比实验记录
+func runExperimentsCmd(path, filterSpec, compare string, limit int) {
+	if path == "" {
+		log.Fatalf("实验模式需要 -experiments 参数")
+	}
+	store, err := OpenExperimentStore(path)
+	if err != nil {
+		log.Fatalf("打开实验记录失败: %v", err)
+	}
+	defer store.Close()
+
+	filter, err := ParseExperimentFilter(filterSpec)
+	if err != nil {
+		log.Fatalf("过滤条件错误: %v", err)
+	}
+	if compare != "" {
+		filter.IDs, err = parseExperimentIDs(compare)
+		if err != nil {
+			log.Fatalf("对比参数错误: %v", err)
+		}
+		if len(filter.IDs) < 2 {
+			log.Fatalf("-compare 至少需要两条记录")
+		}
+	} else {
+		filter.Limit = limit
+	}
+
+	list, err := store.List(filter)
+	if err != nil {
+		log.Fatalf("查询实验记录失败: %v", err)
+	}
+	if len(list) == 0 {
+		fmt.Println("没有符合条件的实验记录")
+		return
+	}
+	if compare != "" {
+		if len(list) != len(filter.IDs) {
+			log.Printf("部分记录不存在或不满足过滤条件，只对比找到的 %d 条", len(list))
+		}
+		printExperimentComparison(list)
+		return
+	}
+	printExperiments(list)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SignalCondition 单个信号条件的判定结果
+type SignalCondition struct {
+	Name   string
+	Pass   bool
+	Detail string // 判定所用的数值
+}
+
+// SignalExplanation 一次信号判定中多空各条件的明细
+type SignalExplanation struct {
+	Time   int64
+	Signal Signal
+	Warmup bool // 数据不足，指标尚未就绪
+	Long   []SignalCondition
+	Short  []SignalCondition
+}
+
+// formatConditions 格式化条件列表：name=true (数值)
+func formatConditions(conds []SignalCondition) string {
+	parts := make([]string, 0, len(conds))
+	for _, c := range conds {
+		part := fmt.Sprintf("%s=%v", c.Name, c.Pass)
+		if c.Detail != "" {
+			part += " (" + c.Detail + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+// String 单行格式：做多[...] 做空[...] → 信号
+func (e SignalExplanation) String() string {
+	if e.Warmup {
+		return "指标预热中，无信号"
+	}
+	name := signalNames[e.Signal]
+	if name == "" {
+		name = "NONE"
+	}
+	return fmt.Sprintf("[%s] 做多[%s] 做空[%s] → %s",
+		time.Unix(e.Time, 0).Format("01-02 15:04"),
+		formatConditions(e.Long),
+		formatConditions(e.Short),
+		name,
+	)
+}
+
+// printBacktestExplanation 回测详细模式：打印入场触发条件成立时各条件的判定
+func printBacktestExplanation(ts int64, side string, conds []SignalCondition, entered bool) {
+	result := "未入场"
+	if entered {
+		result = "入场"
+	}
+	fmt.Printf("[%s] %s %s → %s\n", time.Unix(ts, 0).Format("01-02 15:04"), side, formatConditions(conds), result)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WriteEquityCSV 按 format 导出日频资金曲线及滚动指标
+func WriteEquityCSV(path string, times []int64, curve []float64, sharpe, drawdown []EquityPoint, format ExportFormat) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	daily := DailyEquity(times, curve)
+
+	// 滚动序列从第 RollingWindowDays 天开始，按时间戳对齐
+	sharpeAt := make(map[int64]float64, len(sharpe))
+	for _, p := range sharpe {
+		sharpeAt[p.Timestamp] = p.Value
+	}
+	drawdownAt := make(map[int64]float64, len(drawdown))
+	for _, p := range drawdown {
+		drawdownAt[p.Timestamp] = p.Value
+	}
+
+	w, err := format.newWriter(f)
+	if err != nil {
+		return err
+	}
+	header := []string{"date", "equity", fmt.Sprintf("rolling_sharpe_%dd", RollingWindowDays), fmt.Sprintf("rolling_maxdd_%dd", RollingWindowDays)}
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	for _, p := range daily {
+		row := []string{
+			format.Date(p.Timestamp),
+			format.Float(p.Value, 2),
+			"",
+			"",
+		}
+		if v, ok := sharpeAt[p.Timestamp]; ok {
+			row[2] = format.Float(v, 4)
+		}
+		if v, ok := drawdownAt[p.Timestamp]; ok {
+			row[3] = format.Float(v, 4)
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+
+	return w.Error()
+}
+
+// WriteKlinesCSV 导出 K 线（时间戳为秒）
+func WriteKlinesCSV(path string, klines []Kline) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{"timestamp", "open", "high", "low", "close", "volume"}); err != nil {
+		return err
+	}
+	for _, k := range klines {
+		row := []string{
+			strconv.FormatInt(k.Timestamp, 10),
+			strconv.FormatFloat(k.Open, 'f', -1, 64),
+			strconv.FormatFloat(k.High, 'f', -1, 64),
+			strconv.FormatFloat(k.Low, 'f', -1, 64),
+			strconv.FormatFloat(k.Close, 'f', -1, 64),
+			strconv.FormatFloat(k.Volume, 'f', -1, 64),
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+
+	return w.Error()
+}
+
+// TradeLog 回测过程中逐笔写入交易 CSV，每次平仓后刷盘，中途崩溃也保留已完成的交易
+type TradeLog struct {
+	f      *os.File
+	w      *csv.Writer
+	format ExportFormat
+	err    error // 第一个写入错误，Close 时返回
+}
+
+// CreateTradeLog 按 format 创建交易 CSV 并写入表头
+func CreateTradeLog(path string, format ExportFormat) (*TradeLog, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := format.newWriter(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	t := &TradeLog{f: f, w: w, format: format}
+	t.write([]string{"entry_time", "exit_time", "side", "batch", "entry_price", "exit_price", "amount", "pnl", "fee", "size_mult", "r", "entry_reason", "exit_reason"})
+	return t, t.err
+}
+
+// write 写一行，出错后忽略后续写入
+func (t *TradeLog) write(row []string) {
+	if t == nil || t.err != nil {
+		return
+	}
+	t.err = t.w.Write(row)
+}
+
+// tradeRow 按导出格式格式化一笔交易
+func (t *TradeLog) tradeRow(entryTime, exitTime int64, side string, batch int, entryPrice, exitPrice, amount, pnl, fee, sizeMult, r float64, entryReason, exitReason string) []string {
+	f := func(v float64) string { return t.format.Float(v, -1) }
+	return []string{
+		t.format.Time(entryTime),
+		t.format.Time(exitTime),
+		side,
+		strconv.Itoa(batch),
+		f(entryPrice), f(exitPrice), f(amount), f(pnl), f(fee), f(sizeMult),
+		t.format.Float(r, 3),
+		entryReason,
+		exitReason,
+	}
+}
+
+// WriteTrade 写入 RSI 策略的一笔交易，未启用时为空操作
+func (t *TradeLog) WriteTrade(tr Trade) {
+	if t == nil {
+		return
+	}
+	t.write(t.tradeRow(tr.EntryTime, tr.ExitTime, tr.Side, tr.Batch, tr.EntryPrice, tr.ExitPrice, tr.Amount, tr.PnL, tr.Fee, tr.SizeMult, tr.R, tr.EntryReason, tr.ExitReason))
+}
+
+// WriteBounceTrade 写入反弹策略的一笔交易，未启用时为空操作
+func (t *TradeLog) WriteBounceTrade(tr BounceTrade) {
+	if t == nil {
+		return
+	}
+	t.write(t.tradeRow(tr.EntryTime, tr.ExitTime, tr.Side, tr.Batch, tr.EntryPrice, tr.ExitPrice, tr.Amount, tr.PnL, tr.Fee, tr.SizeMult, tr.R, "", tr.Reason))
+}
+
+// Flush 将缓冲写入文件
+func (t *TradeLog) Flush() {
+	if t == nil || t.err != nil {
+		return
+	}
+	t.w.Flush()
+	t.err = t.w.Error()
+}
+
+// Close 刷盘并关闭文件，返回写入过程中的第一个错误
+func (t *TradeLog) Close() error {
+	if t == nil {
+		return nil
+	}
+	t.Flush()
+	if err := t.f.Close(); err != nil && t.err == nil {
+		t.err = err
+	}
+	return t.err
+}
+
+// TradeRecord 导出的一笔交易，RSI 策略与反弹策略共用，字段与交易 CSV 的列一致
+type TradeRecord struct {
+	EntryTime   time.Time `json:"entry_time"`
+	ExitTime    time.Time `json:"exit_time"`
+	Side        string    `json:"side"`
+	Batch       int       `json:"batch"`
+	EntryPrice  float64   `json:"entry_price"`
+	ExitPrice   float64   `json:"exit_price"`
+	Amount      float64   `json:"amount"`
+	PnL         float64   `json:"pnl"`
+	Fee         float64   `json:"fee"`
+	SizeMult    float64   `json:"size_mult"`
+	R           float64   `json:"r"`
+	EntryReason string    `json:"entry_reason"`
+	ExitReason  string    `json:"exit_reason"`
+}
+
+// tradeRecords 转换 RSI 策略的交易列表
+func tradeRecords(trades []Trade) []TradeRecord {
+	records := make([]TradeRecord, len(trades))
+	for i, tr := range trades {
+		records[i] = TradeRecord{
+			EntryTime: time.Unix(tr.EntryTime, 0).UTC(), ExitTime: time.Unix(tr.ExitTime, 0).UTC(),
+			Side: tr.Side, Batch: tr.Batch, EntryPrice: tr.EntryPrice, ExitPrice: tr.ExitPrice,
+			Amount: tr.Amount, PnL: tr.PnL, Fee: tr.Fee, SizeMult: tr.SizeMult, R: tr.R,
+			EntryReason: tr.EntryReason, ExitReason: tr.ExitReason,
+		}
+	}
+	return records
+}
+
+// bounceTradeRecords 转换反弹策略的交易列表，反弹策略没有入场原因
+func bounceTradeRecords(trades []BounceTrade) []TradeRecord {
+	records := make([]TradeRecord, len(trades))
+	for i, tr := range trades {
+		records[i] = TradeRecord{
+			EntryTime: time.Unix(tr.EntryTime, 0).UTC(), ExitTime: time.Unix(tr.ExitTime, 0).UTC(),
+			Side: tr.Side, Batch: tr.Batch, EntryPrice: tr.EntryPrice, ExitPrice: tr.ExitPrice,
+			Amount: tr.Amount, PnL: tr.PnL, Fee: tr.Fee, SizeMult: tr.SizeMult, R: tr.R,
+			ExitReason: tr.Reason,
+		}
+	}
+	return records
+}
+
+// ExportTrades 导出交易列表，格式由扩展名决定：.csv 与 -trades 的列相同（按 format 的区域格式），
+// .json 为对象数组（时间为 RFC 3339 UTC）
+func ExportTrades(path string, trades []TradeRecord, format ExportFormat) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		t, err := CreateTradeLog(path, format)
+		if err != nil {
+			return err
+		}
+		for _, tr := range trades {
+			t.write(t.tradeRow(tr.EntryTime.Unix(), tr.ExitTime.Unix(), tr.Side, tr.Batch, tr.EntryPrice, tr.ExitPrice, tr.Amount, tr.PnL, tr.Fee, tr.SizeMult, tr.R, tr.EntryReason, tr.ExitReason))
+		}
+		return t.Close()
+	case ".json":
+		if trades == nil {
+			trades = []TradeRecord{}
+		}
+		data, err := json.MarshalIndent(trades, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, append(data, '\n'), 0644)
+	default:
+		return fmt.Errorf("unsupported export format %q (want .csv or .json)", filepath.Ext(path))
+	}
+}
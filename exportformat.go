@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ExportFormat CSV 导出的区域格式，零值为机器可读格式：逗号分隔、点作小数点、RFC 3339 UTC 时间
+type ExportFormat struct {
+	Delimiter  string `json:"delimiter,omitempty"`   // 列分隔符（单个字符），为空时为逗号
+	Decimal    string `json:"decimal,omitempty"`     // 小数点："." 或 ","，为空时为 "."
+	TimeFormat string `json:"time_format,omitempty"` // 时间列的 Go 时间格式，如 "02.01.2006 15:04:05"，为空时为 RFC 3339
+	DateFormat string `json:"date_format,omitempty"` // 日期列（资金曲线）的 Go 时间格式，为空时为 "2006-01-02"
+	Timezone   string `json:"timezone,omitempty"`    // 时间列的 IANA 时区，如 "Europe/Berlin"，为空时为 UTC；日期列始终按 UTC 日
+	BOM        bool   `json:"bom,omitempty"`         // 文件开头写 UTF-8 BOM，Excel 据此按 UTF-8 打开
+
+	loc *time.Location
+}
+
+// exportPresets -export-format 的预设
+var exportPresets = map[string]ExportFormat{
+	// 英文区 Excel：逗号分隔、点作小数点
+	"excel-us": {TimeFormat: "2006-01-02 15:04:05", BOM: true},
+	// 欧洲大陆 Excel（德、法、意等）：分号分隔、逗号作小数点
+	"excel-eu": {Delimiter: ";", Decimal: ",", TimeFormat: "02.01.2006 15:04:05", DateFormat: "02.01.2006", BOM: true},
+}
+
+// ExportPresetNames 预设名称（排序）
+func ExportPresetNames() []string {
+	names := make([]string, 0, len(exportPresets))
+	for name := range exportPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadExportFormat 解析 -export-format：为空时返回默认格式，预设名称返回预设，否则读取 ExportFormat 的 JSON 文件
+func LoadExportFormat(spec string) (ExportFormat, error) {
+	if spec == "" {
+		return ExportFormat{}, nil
+	}
+	format, ok := exportPresets[spec]
+	if !ok {
+		data, err := os.ReadFile(spec)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return ExportFormat{}, fmt.Errorf("unknown export format %q (presets: %s, or a JSON file)", spec, strings.Join(ExportPresetNames(), ", "))
+			}
+			return ExportFormat{}, err
+		}
+		if err := json.Unmarshal(data, &format); err != nil {
+			return ExportFormat{}, fmt.Errorf("parse %s: %w", spec, err)
+		}
+	}
+	if err := format.init(); err != nil {
+		return ExportFormat{}, fmt.Errorf("%s: %w", spec, err)
+	}
+	return format, nil
+}
+
+// init 校验各字段并加载时区
+func (f *ExportFormat) init() error {
+	if f.Delimiter != "" {
+		r, size := utf8.DecodeRuneInString(f.Delimiter)
+		if size != len(f.Delimiter) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+			return fmt.Errorf("invalid delimiter %q", f.Delimiter)
+		}
+	}
+	switch f.Decimal {
+	case "", ".":
+	case ",":
+		if f.comma() == ',' {
+			return fmt.Errorf("decimal comma requires a delimiter other than comma")
+		}
+	default:
+		return fmt.Errorf("invalid decimal separator %q (want \".\" or \",\")", f.Decimal)
+	}
+	if f.Timezone != "" {
+		loc, err := time.LoadLocation(f.Timezone)
+		if err != nil {
+			return err
+		}
+		f.loc = loc
+	}
+	return nil
+}
+
+// comma 列分隔符
+func (f ExportFormat) comma() rune {
+	if f.Delimiter == "" {
+		return ','
+	}
+	r, _ := utf8.DecodeRuneInString(f.Delimiter)
+	return r
+}
+
+// newWriter 按格式创建 CSV writer，需要时先写入 BOM
+func (f ExportFormat) newWriter(w io.Writer) (*csv.Writer, error) {
+	if f.BOM {
+		if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+			return nil, err
+		}
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = f.comma()
+	return cw, nil
+}
+
+// Float 格式化数值，prec 同 strconv.FormatFloat（-1 为最短精确表示）
+func (f ExportFormat) Float(v float64, prec int) string {
+	s := strconv.FormatFloat(v, 'f', prec, 64)
+	if f.Decimal == "," {
+		s = strings.Replace(s, ".", ",", 1)
+	}
+	return s
+}
+
+// Time 格式化时间列（秒）
+func (f ExportFormat) Time(ts int64) string {
+	t := time.Unix(ts, 0).UTC()
+	if f.loc != nil {
+		t = t.In(f.loc)
+	}
+	if f.TimeFormat == "" {
+		return t.Format(time.RFC3339)
+	}
+	return t.Format(f.TimeFormat)
+}
+
+// Date 格式化日期列（秒，按 UTC 日）
+func (f ExportFormat) Date(ts int64) string {
+	layout := f.DateFormat
+	if layout == "" {
+		layout = "2006-01-02"
+	}
+	return time.Unix(ts, 0).UTC().Format(layout)
+}
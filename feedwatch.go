@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/hstcscolor/wex/binance"
+)
+
+// defaultFeedStallIntervals 默认超过多少个 K 线周期没有新 K 线视为数据源停滞
+const defaultFeedStallIntervals = 2
+
+// FeedWatchdog 数据源看门狗：记录最新 K 线时间最近一次前进的时刻，超过 stall 没有新 K 线时判定停滞；
+// 停滞期间每隔 stall 触发一次恢复动作（重连、切换数据源）
+type FeedWatchdog struct {
+	stall    time.Duration
+	latest   int64     // 已收到的最新 K 线开盘时间
+	advanced time.Time // latest 最近一次前进的时刻
+	stalled  bool
+	acted    time.Time // 最近一次恢复动作的时刻
+	// Failover 当前使用备用数据源（公开 REST 接口），client 为其 HTTP 客户端
+	Failover bool
+	client   *http.Client
+}
+
+// NewFeedWatchdog 按 K 线周期（秒）和停滞倍数创建看门狗，intervals <= 0 时使用默认值
+func NewFeedWatchdog(interval int64, intervals float64, now time.Time) *FeedWatchdog {
+	if intervals <= 0 {
+		intervals = defaultFeedStallIntervals
+	}
+	return &FeedWatchdog{
+		stall:    time.Duration(float64(interval)*intervals) * time.Second,
+		advanced: now,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Observe 记录本周期拿到的最新 K 线时间，从停滞中恢复时返回 true
+func (w *FeedWatchdog) Observe(latest int64, now time.Time) bool {
+	if latest <= w.latest {
+		return false
+	}
+	w.latest, w.advanced = latest, now
+	if w.stalled {
+		w.stalled = false
+		return true
+	}
+	return false
+}
+
+// Check 判断是否停滞；act 为需要执行恢复动作（刚进入停滞，或上次动作后又过了 stall）
+func (w *FeedWatchdog) Check(now time.Time) (stalled, act bool) {
+	if now.Sub(w.advanced) <= w.stall {
+		return false, false
+	}
+	if !w.stalled || now.Sub(w.acted) >= w.stall {
+		w.stalled, w.acted = true, now
+		return true, true
+	}
+	return true, false
+}
+
+// Stalled 当前处于停滞中，nil 时为 false
+func (w *FeedWatchdog) Stalled() bool {
+	return w != nil && w.stalled
+}
+
+// Silence 距最新 K 线前进已过去的时间
+func (w *FeedWatchdog) Silence(now time.Time) time.Duration {
+	return now.Sub(w.advanced)
+}
+
+// feedSourceName 数据源名称
+func feedSourceName(failover bool) string {
+	if failover {
+		return "公开 REST 接口"
+	}
+	return "交易所客户端"
+}
+
+// checkFeed 检查数据源是否停滞：停滞时跳过本周期的信号判定，并按间隔告警、重连、切换数据源；
+// 返回 false 表示数据已过期
+func (s *Strategy) checkFeed() bool {
+	now := time.Now()
+	if n := len(s.klines); n > 0 && s.feed.Observe(s.klines[n-1].Timestamp, now) {
+		msg := fmt.Sprintf("数据源恢复，收到新 K 线 %s（当前数据源: %s）",
+			time.Unix(s.klines[n-1].Timestamp, 0).Format("01-02 15:04"), feedSourceName(s.feed.Failover))
+		log.Printf("%s", msg)
+		s.webhook.Alert("feed_recovered", s.config.Symbol, msg)
+	}
+
+	stalled, act := s.feed.Check(now)
+	if !stalled {
+		return true
+	}
+	if act {
+		s.recoverFeed(now)
+	}
+	log.Printf("数据源停滞中，跳过本周期")
+	return false
+}
+
+// recoverFeed 数据源停滞时告警，重建交易所客户端和连接，并切换到另一个数据源
+func (s *Strategy) recoverFeed(now time.Time) {
+	latest := "无"
+	if n := len(s.klines); n > 0 {
+		latest = time.Unix(s.klines[n-1].Timestamp, 0).Format("01-02 15:04")
+	}
+	next := feedSourceName(!s.feed.Failover)
+	msg := fmt.Sprintf("%s 没有新 K 线（最新 %s，数据源: %s），重连并切换到%s",
+		s.feed.Silence(now).Round(time.Second), latest, feedSourceName(s.feed.Failover), next)
+	log.Printf("[告警] %s", msg)
+	s.webhook.Alert("feed_stalled", s.config.Symbol, msg)
+
+	// 重连：丢弃可能已失效的长连接，重新创建交易所客户端
+	http.DefaultClient.CloseIdleConnections()
+	s.feed.client.CloseIdleConnections()
+	if s.config.ApiKey != "" && s.config.SecretKey != "" {
+		if client := binance.NewBinFutureFromKey(s.config.ApiKey, s.config.SecretKey); client != nil {
+			s.client = client
+		}
+	}
+	s.feed.Failover = !s.feed.Failover
+}
+
+// fetchFailoverKlines 从公开 REST 接口（feed_failover_url，默认币安合约 K 线接口）获取最近 limit 根 K 线
+func (s *Strategy) fetchFailoverKlines(limit int) ([]Kline, error) {
+	endpoint := klineEndpoints["futures"]
+	if s.config.FeedFailoverURL != "" {
+		endpoint.URL = s.config.FeedFailoverURL
+	}
+	endpoint.Limit = min(limit, endpoint.Limit)
+	to := time.Now().Unix()
+	from := to - int64(endpoint.Limit)*s.interval
+	klines, err := fetchKlinePage(s.feed.client, endpoint, s.config.Symbol, s.config.Interval, from*1000, to*1000)
+	if err != nil {
+		return nil, wrapExchangeError("klines", err)
+	}
+	return klines, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// 回测入场成交方式
+const (
+	EntryMarket = ""      // 市价单，按信号收盘价（或延迟成交价）以 taker 费率成交
+	EntryLimit  = "limit" // 在信号收盘价挂限价单，下一根 K 线价格穿过挂单价才成交，按 maker 费率、不计滑点
+)
+
+// FeeTier 手续费档位：累计成交额（USDT）达到 MinVolume 后适用，费率为负表示返佣
+type FeeTier struct {
+	MinVolume float64 `json:"min_volume"`
+	Maker     float64 `json:"maker"`
+	Taker     float64 `json:"taker"`
+}
+
+// LoadFeeTiers 读取手续费档位文件（FeeTier 的 JSON 数组），按 MinVolume 升序返回
+func LoadFeeTiers(path string) ([]FeeTier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tiers []FeeTier
+	if err := json.Unmarshal(data, &tiers); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinVolume < tiers[j].MinVolume })
+	if err := ValidateFeeTiers(tiers); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return tiers, nil
+}
+
+// ValidateFeeTiers 档位须按 MinVolume 严格升序且第一档从 0 开始；maker 可为负（返佣），taker 不可为负
+func ValidateFeeTiers(tiers []FeeTier) error {
+	if len(tiers) == 0 {
+		return fmt.Errorf("no fee tiers")
+	}
+	if tiers[0].MinVolume != 0 {
+		return fmt.Errorf("first fee tier must start at volume 0")
+	}
+	for i, t := range tiers {
+		if t.Taker < 0 {
+			return fmt.Errorf("fee tier %d: taker rate must be non-negative", i)
+		}
+		if i > 0 && t.MinVolume <= tiers[i-1].MinVolume {
+			return fmt.Errorf("fee tier %d: duplicate min_volume %g", i, t.MinVolume)
+		}
+	}
+	return nil
+}
+
+// FeeStats 回测手续费统计
+type FeeStats struct {
+	Volume      float64 // 累计成交额（开平仓名义价值）
+	MakerVolume float64 // 其中按 maker 成交的部分
+	Fees        float64 // 净手续费，已扣除返佣
+	Rebates     float64 // maker 返佣
+	BlendedRate float64 // 综合费率：净手续费 / 成交额
+	Tier        int     // 回测结束时所在档位（从 0 开始）
+	TierTimes   []int64 // 依次升到第 1、2、… 档时的 K 线时间
+	Tiered      bool    // 使用了手续费档位
+}
+
+// feeTracker 按累计成交额选择档位计算每笔成交的手续费；没有档位时 maker / taker 均按 rate
+type feeTracker struct {
+	tiers []FeeTier
+	rate  float64
+	stats FeeStats
+}
+
+// newFeeTracker 按回测配置创建
+func newFeeTracker(config BacktestConfig) *feeTracker {
+	return &feeTracker{
+		tiers: config.FeeTiers,
+		rate:  config.FeeRate,
+		stats: FeeStats{Tiered: len(config.FeeTiers) > 0},
+	}
+}
+
+// charge 按当前档位返回成交 notional 的手续费（负数为返佣），成交额计入累计后再判断升档
+func (t *feeTracker) charge(notional float64, maker bool, ts int64) float64 {
+	rate := t.rate
+	if len(t.tiers) > 0 {
+		rate = t.tiers[t.stats.Tier].Taker
+		if maker {
+			rate = t.tiers[t.stats.Tier].Maker
+		}
+	}
+	fee := notional * rate
+
+	t.stats.Volume += notional
+	if maker {
+		t.stats.MakerVolume += notional
+	}
+	t.stats.Fees += fee
+	if fee < 0 {
+		t.stats.Rebates -= fee
+	}
+	for t.stats.Tier+1 < len(t.tiers) && t.stats.Volume >= t.tiers[t.stats.Tier+1].MinVolume {
+		t.stats.Tier++
+		t.stats.TierTimes = append(t.stats.TierTimes, ts)
+	}
+	return fee
+}
+
+// result 回测结束时的统计
+func (t *feeTracker) result() FeeStats {
+	s := t.stats
+	if s.Volume > 0 {
+		s.BlendedRate = s.Fees / s.Volume
+	}
+	return s
+}
+
+// limitFill 在 price 挂的限价单能否被下一根 K 线成交：价格须穿过挂单价（触及不算，排队靠后时未必成交）
+func limitFill(side string, price float64, next Kline) bool {
+	if side == "LONG" {
+		return next.Low < price
+	}
+	return next.High > price
+}
+
+// printFeeStats 打印成交额、maker 占比、综合费率和档位变化
+func printFeeStats(s FeeStats) {
+	if s.Volume <= 0 {
+		return
+	}
+	fmt.Printf("成交额: $%.0f (maker %.1f%%), 综合费率 %.2f bps", s.Volume, s.MakerVolume/s.Volume*100, s.BlendedRate*1e4)
+	if s.Rebates > 0 {
+		fmt.Printf(", 返佣 $%.2f", s.Rebates)
+	}
+	fmt.Println()
+	if !s.Tiered {
+		return
+	}
+	fmt.Printf("手续费档位: 结束于第 %d 档", s.Tier+1)
+	for i, ts := range s.TierTimes {
+		fmt.Printf(", %s 升至第 %d 档", time.Unix(ts, 0).UTC().Format("2006-01-02"), i+2)
+	}
+	fmt.Println()
+}
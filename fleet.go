@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// FleetConfig 单进程运行多个独立策略实例的配置
+type FleetConfig struct {
+	// 控制接口监听地址，需设置环境变量 CONTROL_TOKEN；各实例自身的 control_addr 被忽略
+	ControlAddr string     `json:"control_addr,omitempty"`
+	Bots        []FleetBot `json:"bots"`
+}
+
+// FleetBot 一个策略实例：先加载 config_path（为空则用默认配置），再用 config 中的字段覆盖
+type FleetBot struct {
+	Name       string          `json:"name"`
+	ConfigPath string          `json:"config_path,omitempty"`
+	Config     json.RawMessage `json:"config,omitempty"`
+	Disabled   bool            `json:"disabled,omitempty"` // 启动时不运行，可通过控制接口启动
+}
+
+// 实例状态
+const (
+	BotStopped = "stopped"
+	BotRunning = "running"
+	BotHalted  = "halted" // 不可恢复的错误退出
+)
+
+// LoadFleetConfig 加载并校验编队配置
+func LoadFleetConfig(path string) (*FleetConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fc FleetConfig
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return nil, err
+	}
+	if len(fc.Bots) == 0 {
+		return nil, fmt.Errorf("fleet has no bots")
+	}
+	seen := make(map[string]bool)
+	for _, b := range fc.Bots {
+		if b.Name == "" {
+			return nil, fmt.Errorf("bot name is required")
+		}
+		if seen[b.Name] {
+			return nil, fmt.Errorf("duplicate bot name %q", b.Name)
+		}
+		seen[b.Name] = true
+	}
+	return &fc, nil
+}
+
+// botConfig 合并实例配置：配置文件 + 内联覆盖
+func (b FleetBot) botConfig() (*Config, error) {
+	config := defaultConfig
+	if b.ConfigPath != "" {
+		loaded, err := LoadConfig(b.ConfigPath)
+		if err != nil {
+			return nil, fmt.Errorf("bot %s: %w", b.Name, err)
+		}
+		config = *loaded
+	}
+	if len(b.Config) > 0 {
+		if err := json.Unmarshal(b.Config, &config); err != nil {
+			return nil, fmt.Errorf("bot %s: %w", b.Name, err)
+		}
+	}
+	// 控制接口由编队统一提供
+	config.ControlAddr = ""
+	return &config, nil
+}
+
+// fleetBot 运行中的实例
+type fleetBot struct {
+	spec     FleetBot
+	strategy *Strategy
+	state    string
+	err      error
+	started  time.Time
+	done     chan struct{}
+}
+
+// Fleet 管理多个策略实例的生命周期
+type Fleet struct {
+	mu    sync.Mutex
+	bots  map[string]*fleetBot
+	order []string
+}
+
+// NewFleet 按配置创建编队，实例均为停止状态
+func NewFleet(fc *FleetConfig) *Fleet {
+	f := &Fleet{bots: make(map[string]*fleetBot)}
+	for _, spec := range fc.Bots {
+		f.bots[spec.Name] = &fleetBot{spec: spec, state: BotStopped}
+		f.order = append(f.order, spec.Name)
+	}
+	return f
+}
+
+// Start 启动实例：每次启动重新加载配置并创建新的策略
+func (f *Fleet) Start(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bot, ok := f.bots[name]
+	if !ok {
+		return fmt.Errorf("unknown bot %q", name)
+	}
+	if bot.state == BotRunning {
+		return nil
+	}
+
+	config, err := bot.spec.botConfig()
+	if err != nil {
+		return err
+	}
+	strategy, err := NewStrategy(config)
+	if err != nil {
+		return fmt.Errorf("bot %s: %w", name, err)
+	}
+
+	bot.strategy = strategy
+	bot.state = BotRunning
+	bot.err = nil
+	bot.started = time.Now()
+	bot.done = make(chan struct{})
+	go f.run(bot, strategy, bot.done)
+	log.Printf("[%s] 启动实例: %s", name, config.Symbol)
+	return nil
+}
+
+// run 运行实例直到停止或出错
+func (f *Fleet) run(bot *fleetBot, strategy *Strategy, done chan struct{}) {
+	defer close(done)
+	err := strategy.Run()
+	strategy.journal.Close()
+	strategy.state.Close()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if bot.strategy != strategy {
+		return
+	}
+	if err != nil {
+		bot.state, bot.err = BotHalted, err
+		log.Printf("[%s] 实例退出: %v", bot.spec.Name, err)
+	} else {
+		bot.state = BotStopped
+	}
+}
+
+// Stop 停止实例并等待其退出
+func (f *Fleet) Stop(name string) error {
+	f.mu.Lock()
+	bot, ok := f.bots[name]
+	if !ok {
+		f.mu.Unlock()
+		return fmt.Errorf("unknown bot %q", name)
+	}
+	if bot.state != BotRunning {
+		f.mu.Unlock()
+		return nil
+	}
+	bot.strategy.Stop()
+	done := bot.done
+	f.mu.Unlock()
+
+	<-done
+	log.Printf("[%s] 实例已停止", name)
+	return nil
+}
+
+// StopAll 停止所有实例
+func (f *Fleet) StopAll() {
+	for _, name := range f.order {
+		f.Stop(name)
+	}
+}
+
+// Resume 实例退出资金保护模式
+func (f *Fleet) Resume(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	bot, ok := f.bots[name]
+	if !ok {
+		return fmt.Errorf("unknown bot %q", name)
+	}
+	if bot.state != BotRunning {
+		return fmt.Errorf("bot %s is not running", name)
+	}
+	bot.strategy.resume()
+	return nil
+}
+
+// ResetRisk 实例解除风控停止交易的状态（熔断状态写入状态文件，编队中的实例无法以 -reset-risk 重启解除）
+func (f *Fleet) ResetRisk(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	bot, ok := f.bots[name]
+	if !ok {
+		return fmt.Errorf("unknown bot %q", name)
+	}
+	if bot.state != BotRunning {
+		return fmt.Errorf("bot %s is not running", name)
+	}
+	bot.strategy.resetRisk()
+	return nil
+}
+
+// BotStatus 控制接口中一个实例的状态
+type BotStatus struct {
+	Name       string            `json:"name"`
+	State      string            `json:"state"`
+	Error      string            `json:"error,omitempty"`
+	Uptime     int64             `json:"uptime_secs,omitempty"`
+	RiskHalted string            `json:"risk_halted,omitempty"` // 风控停止交易的原因
+	Snapshot   *StrategySnapshot `json:"snapshot,omitempty"`
+}
+
+// FleetDashboard 编队汇总
+type FleetDashboard struct {
+	Bots          []BotStatus `json:"bots"`
+	Running       int         `json:"running"`
+	Halted        int         `json:"halted"`
+	RiskHalted    int         `json:"risk_halted"` // 运行中但被风控停止交易的实例数
+	OpenPositions int         `json:"open_positions"`
+	Notional      float64     `json:"notional"`       // 各实例持仓名义价值之和 (USDT)
+	Unrealized    float64     `json:"unrealized_pnl"` // 各实例浮动盈亏之和 (USDT)
+}
+
+// Dashboard 汇总各实例状态
+func (f *Fleet) Dashboard() FleetDashboard {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var d FleetDashboard
+	for _, name := range f.order {
+		bot := f.bots[name]
+		status := BotStatus{Name: name, State: bot.state}
+		if bot.err != nil {
+			status.Error = bot.err.Error()
+		}
+		if bot.state == BotRunning {
+			d.Running++
+			status.Uptime = int64(time.Since(bot.started).Seconds())
+			status.Snapshot = bot.strategy.Snapshot()
+			if status.RiskHalted = bot.strategy.risk.Halted(); status.RiskHalted != "" {
+				d.RiskHalted++
+			}
+		}
+		if bot.state == BotHalted {
+			d.Halted++
+		}
+		if snap := status.Snapshot; snap != nil && snap.Side != "" {
+			d.OpenPositions++
+			d.Notional += snap.Notional
+			d.Unrealized += snap.Unrealized
+		}
+		d.Bots = append(d.Bots, status)
+	}
+	return d
+}
+
+// serveControl 在 addr 上提供编队控制接口，请求需带 Authorization: Bearer $CONTROL_TOKEN
+//
+//	GET  /dashboard           各实例状态与持仓汇总
+//	POST /bots/{name}/start   启动实例
+//	POST /bots/{name}/stop    停止实例
+//	POST /bots/{name}/resume  实例退出资金保护模式
+//	POST /bots/{name}/risk/reset  实例解除风控停止交易的状态
+func (f *Fleet) serveControl(addr string) {
+	token := os.Getenv("CONTROL_TOKEN")
+	if token == "" {
+		log.Printf("未设置 CONTROL_TOKEN，控制接口未启动")
+		return
+	}
+
+	authorized := func(next func(w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer "+token {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next(w, r)
+		}
+	}
+	action := func(fn func(name string) error) http.HandlerFunc {
+		return authorized(func(w http.ResponseWriter, r *http.Request) {
+			if err := fn(r.PathValue("name")); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /dashboard", authorized(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(f.Dashboard())
+	}))
+	mux.HandleFunc("POST /bots/{name}/start", action(f.Start))
+	mux.HandleFunc("POST /bots/{name}/stop", action(f.Stop))
+	mux.HandleFunc("POST /bots/{name}/resume", action(f.Resume))
+	mux.HandleFunc("POST /bots/{name}/risk/reset", action(f.ResetRisk))
+
+	log.Printf("编队控制接口: http://%s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("编队控制接口退出: %v", err)
+	}
+}
+
+// RunFleet 启动编队中未禁用的实例和控制接口，阻塞直到收到退出信号
+func RunFleet(fc *FleetConfig, quit <-chan os.Signal) {
+	fleet := NewFleet(fc)
+
+	var names []string
+	for _, spec := range fc.Bots {
+		if spec.Disabled {
+			continue
+		}
+		if err := fleet.Start(spec.Name); err != nil {
+			log.Printf("启动实例失败: %v", err)
+			continue
+		}
+		names = append(names, spec.Name)
+	}
+	log.Printf("编队启动: %d/%d 个实例 %v", len(names), len(fc.Bots), names)
+
+	if fc.ControlAddr != "" {
+		go fleet.serveControl(fc.ControlAddr)
+	}
+
+	<-quit
+	log.Println("收到退出信号，停止所有实例...")
+	fleet.StopAll()
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// SignalMessage 广播给跟单实例的信号
+type SignalMessage struct {
+	Symbol       string  `json:"symbol"`
+	Signal       string  `json:"signal"` // LONG, SHORT, CLOSE_LONG, CLOSE_SHORT
+	Price        float64 `json:"price"`
+	PositionSize float64 `json:"position_size"` // 发布方仓位比例
+	Timestamp    int64   `json:"timestamp"`
+}
+
+// signalNames 信号与广播名称的映射
+var signalNames = map[Signal]string{
+	SignalLong:       "LONG",
+	SignalShort:      "SHORT",
+	SignalCloseLong:  "CLOSE_LONG",
+	SignalCloseShort: "CLOSE_SHORT",
+}
+
+// parseSignalName 将广播名称还原为信号
+func parseSignalName(name string) (Signal, bool) {
+	for sig, n := range signalNames {
+		if n == name {
+			return sig, true
+		}
+	}
+	return SignalNone, false
+}
+
+// SignalHub 通过 WebSocket 向跟单实例广播信号
+type SignalHub struct {
+	mu       sync.Mutex
+	clients  map[*websocket.Conn]bool
+	upgrader websocket.Upgrader
+	token    string // 跟单连接需带 Authorization: Bearer $FOLLOW_TOKEN
+}
+
+// NewSignalHub 创建信号广播器
+func NewSignalHub() *SignalHub {
+	return &SignalHub{
+		clients: make(map[*websocket.Conn]bool),
+	}
+}
+
+// ServeHTTP 接受跟单连接
+func (h *SignalHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.token == "" || r.Header.Get("Authorization") != "Bearer "+h.token {
+		log.Printf("拒绝未授权的跟单连接: %s", r.RemoteAddr)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("跟单连接失败: %v", err)
+		return
+	}
+
+	h.mu.Lock()
+	h.clients[conn] = true
+	h.mu.Unlock()
+	log.Printf("跟单实例已连接: %s", r.RemoteAddr)
+
+	// 读取直到断开，仅用于检测连接关闭
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				h.mu.Lock()
+				delete(h.clients, conn)
+				h.mu.Unlock()
+				conn.Close()
+				log.Printf("跟单实例已断开: %s", r.RemoteAddr)
+				return
+			}
+		}
+	}()
+}
+
+// Broadcast 向所有跟单实例发送信号
+func (h *SignalHub) Broadcast(msg SignalMessage) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for conn := range h.clients {
+		conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		if err := conn.WriteJSON(msg); err != nil {
+			log.Printf("广播信号失败: %v", err)
+			delete(h.clients, conn)
+			conn.Close()
+		}
+	}
+}
+
+// ListenAndServe 在 addr 上启动信号广播服务（路径 /signals），跟单连接需带 Authorization: Bearer $FOLLOW_TOKEN
+func (h *SignalHub) ListenAndServe(addr string) {
+	h.token = os.Getenv("FOLLOW_TOKEN")
+	if h.token == "" {
+		log.Printf("未设置 FOLLOW_TOKEN，信号广播未启动")
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/signals", h)
+	log.Printf("信号广播: ws://%s/signals", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("信号广播服务退出: %v", err)
+	}
+}
+
+// followPositionSize 按本地缩放和上限计算跟单仓位比例
+func followPositionSize(config *Config, leaderSize float64) float64 {
+	scale := config.FollowScale
+	if scale <= 0 {
+		scale = 1
+	}
+	size := leaderSize * scale
+	if config.FollowMaxPosition > 0 && size > config.FollowMaxPosition {
+		size = config.FollowMaxPosition
+	}
+	return size
+}
+
+// RunFollower 订阅 leaderURL 的信号流并在本地账户镜像交易，断线自动重连；连接时带 Authorization: Bearer $FOLLOW_TOKEN
+func RunFollower(s *Strategy, leaderURL string) error {
+	if leaderURL == "" {
+		return fmt.Errorf("leader url is empty")
+	}
+	token := os.Getenv("FOLLOW_TOKEN")
+	if token == "" {
+		return fmt.Errorf("FOLLOW_TOKEN is not set")
+	}
+	header := http.Header{"Authorization": {"Bearer " + token}}
+
+	if err := s.acquireLock(); err != nil {
+		return err
+	}
+	defer s.releaseLock()
+	defer s.webhook.Flush(webhookFlushTimeout)
+
+	s.running = true
+	for s.running {
+		conn, _, err := websocket.DefaultDialer.Dial(leaderURL, header)
+		if err != nil {
+			log.Printf("连接信号源失败: %v，10 秒后重试", err)
+			time.Sleep(10 * time.Second)
+			continue
+		}
+		log.Printf("已连接信号源: %s", leaderURL)
+
+		for s.running {
+			var msg SignalMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				log.Printf("信号流中断: %v", err)
+				break
+			}
+			s.guard("跟单", func() { s.mirrorSignal(msg) })
+		}
+		conn.Close()
+		time.Sleep(time.Second)
+	}
+
+	return s.haltErr
+}
+
+// mirrorSignal 在本地风控约束下执行收到的信号
+func (s *Strategy) mirrorSignal(msg SignalMessage) {
+	if msg.Symbol != s.config.Symbol {
+		log.Printf("忽略 %s 信号（本地交易对 %s）", msg.Symbol, s.config.Symbol)
+		return
+	}
+
+	signal, ok := parseSignalName(msg.Signal)
+	if !ok {
+		log.Printf("未知信号: %s", msg.Signal)
+		return
+	}
+
+	if time.Now().Before(s.pausedUntil) {
+		log.Printf("限频暂停中，忽略信号: %s", msg.Signal)
+		return
+	}
+
+	s.checkRisk(msg.Price)
+	size := followPositionSize(s.config, msg.PositionSize)
+	log.Printf("跟单信号: %s @ %.2f, 仓位 %.1f%% -> %.1f%%", msg.Signal, msg.Price, msg.PositionSize*100, size*100)
+	if _, _, err := s.executeSignalSized(signal, size); err != nil {
+		s.handleExchangeError(err)
+	}
+}
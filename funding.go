@@ -0,0 +1,188 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// FundingRate 一次资金费结算：持多仓支付 Rate × 名义价值（Rate 为负时收取），空仓相反
+type FundingRate struct {
+	Time int64 // 结算时间（秒）
+	Rate float64
+}
+
+// fundingRateURL 币安永续合约历史资金费率接口
+const fundingRateURL = "https://fapi.binance.com/fapi/v1/fundingRate"
+
+// fundingPageLimit 资金费率接口单次最多返回条数
+const fundingPageLimit = 1000
+
+// fundingMigrations K 线库中资金费率表的结构版本（K 线表按交易对和周期动态创建，不在版本管理内）
+var fundingMigrations = []Migration{
+	{Version: 1, Name: "baseline", Up: execMigration(`
+		CREATE TABLE IF NOT EXISTS funding_rates (
+			symbol TEXT    NOT NULL,
+			time   INTEGER NOT NULL,
+			rate   REAL    NOT NULL,
+			PRIMARY KEY (symbol, time)
+		)`,
+	)},
+}
+
+// createFundingTable 创建或升级资金费率表；K 线库是可重新下载的缓存且可能很大，升级前不备份
+func createFundingTable(db *sql.DB) error {
+	return migrateSQLite(db, "", "funding", fundingMigrations)
+}
+
+// queryFundingRates 读取 [startTime, endTime] 内的资金费率，按时间升序
+func queryFundingRates(db *sql.DB, symbol string, startTime, endTime int64) ([]FundingRate, error) {
+	rows, err := db.Query(
+		`SELECT time, rate FROM funding_rates WHERE symbol = ? AND time >= ? AND time <= ? ORDER BY time`,
+		symbol, startTime, endTime,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []FundingRate
+	for rows.Next() {
+		var r FundingRate
+		if err := rows.Scan(&r.Time, &r.Rate); err != nil {
+			return nil, err
+		}
+		rates = append(rates, r)
+	}
+	return rates, rows.Err()
+}
+
+// downloadFundingRates 从币安分页下载 [startTime, endTime] 内的历史资金费率
+func downloadFundingRates(symbol string, startTime, endTime int64) ([]FundingRate, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	var rates []FundingRate
+	from := startTime * 1000
+	for {
+		q := url.Values{}
+		q.Set("symbol", symbol)
+		q.Set("startTime", strconv.FormatInt(from, 10))
+		q.Set("endTime", strconv.FormatInt(endTime*1000, 10))
+		q.Set("limit", strconv.Itoa(fundingPageLimit))
+		resp, err := client.Get(fundingRateURL + "?" + q.Encode())
+		if err != nil {
+			return nil, err
+		}
+		var page []struct {
+			FundingTime int64  `json:"fundingTime"`
+			FundingRate string `json:"fundingRate"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("funding rate: http %d", resp.StatusCode)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("funding rate: %w", err)
+		}
+
+		for _, p := range page {
+			rate, err := strconv.ParseFloat(p.FundingRate, 64)
+			if err != nil {
+				return nil, fmt.Errorf("funding rate: invalid rate %q", p.FundingRate)
+			}
+			rates = append(rates, FundingRate{Time: p.FundingTime / 1000, Rate: rate})
+		}
+		if len(page) < fundingPageLimit {
+			return rates, nil
+		}
+		from = page[len(page)-1].FundingTime + 1
+	}
+}
+
+// LoadFundingRates 从 K 线数据库的 funding_rates 表读取资金费率；表中没有该区间的数据时
+// 从币安下载并写入，之后的回测直接读表；K 线库为 PostgreSQL 时不写库，每次从币安下载
+func LoadFundingRates(dbPath, symbol string, startTime, endTime int64) ([]FundingRate, error) {
+	if isPostgresDSN(dbPath) {
+		log.Printf("K 线库为 PostgreSQL，从币安下载 %s 的资金费率（不缓存）", symbol)
+		return downloadFundingRates(symbol, startTime, endTime)
+	}
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	if err := createFundingTable(db); err != nil {
+		return nil, err
+	}
+	rates, err := queryFundingRates(db, symbol, startTime, endTime)
+	if err != nil || len(rates) > 0 {
+		return rates, err
+	}
+
+	log.Printf("funding_rates 表中没有 %s 的资金费率，从币安下载", symbol)
+	rates, err = downloadFundingRates(symbol, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rates {
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO funding_rates (symbol, time, rate) VALUES (?, ?, ?)`,
+			symbol, r.Time, r.Rate); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	log.Printf("已下载 %d 条资金费率", len(rates))
+	return rates, nil
+}
+
+// fundingCursor 回测中按时间顺序消费资金费结算点
+type fundingCursor struct {
+	rates []FundingRate
+	next  int
+}
+
+// due 返回 ts 及之前尚未结算的资金费率之和（K 线缺口跨过多次结算时合并）
+func (c *fundingCursor) due(ts int64) (rate float64, n int) {
+	for c.next < len(c.rates) && c.rates[c.next].Time <= ts {
+		rate += c.rates[c.next].Rate
+		c.next++
+		n++
+	}
+	return rate, n
+}
+
+// fundingPayment 持仓在结算时支付的资金费（负数为收取）
+func fundingPayment(side string, notional, rate float64) float64 {
+	if side == "SHORT" {
+		return -notional * rate
+	}
+	return notional * rate
+}
+
+// loadBacktestFunding 按选项加载回测区间的资金费率，未启用时返回 nil
+func loadBacktestFunding(opts BacktestOptions, dbPath, symbol string, startTime, endTime int64) []FundingRate {
+	if !opts.Funding {
+		return nil
+	}
+	rates, err := LoadFundingRates(dbPath, symbol, startTime, endTime)
+	if err != nil {
+		log.Fatalf("加载资金费率失败: %v", err)
+	}
+	log.Printf("加载 %d 条资金费率", len(rates))
+	return rates
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GAOptions 遗传算法参数
+type GAOptions struct {
+	Population    int
+	Generations   int
+	Elite         int     // 每代直接保留的最优个体数
+	CrossoverRate float64 // 两个父代交叉的概率，否则复制第一个父代
+	MutationRate  float64 // 每个基因变异为其他候选值的概率
+	Seed          int64
+}
+
+// DefaultGAOptions 默认遗传算法参数
+var DefaultGAOptions = GAOptions{
+	Population:    40,
+	Generations:   20,
+	Elite:         2,
+	CrossoverRate: 0.8,
+	MutationRate:  0.1,
+	Seed:          1,
+}
+
+// 锦标赛选择规模；交叉 / 变异产生不满足约束的个体时的重试次数
+const (
+	gaTournament = 3
+	gaMaxRetries = 20
+)
+
+// Validate 校验遗传算法参数
+func (o GAOptions) Validate() error {
+	if o.Population < 2 || o.Generations < 1 {
+		return fmt.Errorf("ga: population must be at least 2 and generations at least 1")
+	}
+	if o.Elite < 0 || o.Elite >= o.Population {
+		return fmt.Errorf("ga: elite must be in [0, population)")
+	}
+	if o.CrossoverRate < 0 || o.CrossoverRate > 1 || o.MutationRate < 0 || o.MutationRate > 1 {
+		return fmt.Errorf("ga: crossover and mutation rates must be in [0, 1]")
+	}
+	return nil
+}
+
+// geneSpace 参数空间：每个基因是一个参数在其候选值中的下标
+type geneSpace struct {
+	params      []strategyParam
+	values      [][]float64
+	constraints []Constraint
+}
+
+// newGeneSpace 按优化配置建立参数空间
+func newGeneSpace(spec OptimizerSpec) (*geneSpace, error) {
+	params, constraints, err := parseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	g := &geneSpace{params: params, constraints: constraints}
+	for _, p := range params {
+		g.values = append(g.values, spec.Ranges[p.name])
+	}
+	return g, nil
+}
+
+// size 参数空间的组合总数（不计约束）
+func (g *geneSpace) size() int {
+	n := 1
+	for _, v := range g.values {
+		n *= len(v)
+	}
+	return n
+}
+
+// config 将基因解码为策略参数，未列出的参数取 DefaultConfig
+func (g *geneSpace) config(genes []int) StrategyConfig {
+	config := DefaultConfig
+	for i, p := range g.params {
+		p.set(&config, g.values[i][genes[i]])
+	}
+	return config
+}
+
+// valid 判断基因对应的参数是否满足约束
+func (g *geneSpace) valid(genes []int) bool {
+	config := g.config(genes)
+	for _, c := range g.constraints {
+		if !c.Satisfied(config) {
+			return false
+		}
+	}
+	return true
+}
+
+// random 随机生成一组基因
+func (g *geneSpace) random(rng *rand.Rand) []int {
+	genes := make([]int, len(g.values))
+	for i, v := range g.values {
+		genes[i] = rng.Intn(len(v))
+	}
+	return genes
+}
+
+// crossover 均匀交叉：每个基因随机取自一个父代
+func (g *geneSpace) crossover(a, b []int, rng *rand.Rand) []int {
+	child := make([]int, len(a))
+	for i := range a {
+		if rng.Intn(2) == 0 {
+			child[i] = a[i]
+		} else {
+			child[i] = b[i]
+		}
+	}
+	return child
+}
+
+// mutate 每个基因以 rate 的概率变为该参数的其他候选值
+func (g *geneSpace) mutate(genes []int, rate float64, rng *rand.Rand) {
+	for i, v := range g.values {
+		if len(v) > 1 && rng.Float64() < rate {
+			genes[i] = (genes[i] + 1 + rng.Intn(len(v)-1)) % len(v)
+		}
+	}
+}
+
+// individual 种群中的个体
+type individual struct {
+	genes   []int
+	fitness float64
+}
+
+// geneKey 基因的唯一标识，用于缓存已回测的参数组合
+func geneKey(genes []int) string {
+	var b strings.Builder
+	for _, g := range genes {
+		fmt.Fprintf(&b, "%d,", g)
+	}
+	return b.String()
+}
+
+// RunGeneticOptimize 用遗传算法在优化配置的参数空间中搜索：锦标赛选择、均匀交叉、
+// 按基因变异并保留精英，适应度为优化目标的取值；相同参数组合只回测一次，返回所有回测过的结果
+func RunGeneticOptimize(klines []Kline, config BacktestConfig, spec OptimizerSpec, opts GAOptions, objective Objective) ([]OptimizeResult, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	space, err := newGeneSpace(spec)
+	if err != nil {
+		return nil, err
+	}
+	rng := rand.New(rand.NewSource(opts.Seed))
+	cache := NewIndicatorCache(klines)
+
+	var results []OptimizeResult
+	evaluated := make(map[string]int) // 基因 -> results 下标
+
+	// evaluate 并行回测种群中尚未回测过的参数组合并计算适应度
+	evaluate := func(pop []individual) {
+		var pending []StrategyConfig
+		var keys []string
+		for _, ind := range pop {
+			key := geneKey(ind.genes)
+			if _, ok := evaluated[key]; ok {
+				continue
+			}
+			evaluated[key] = -1
+			keys = append(keys, key)
+			pending = append(pending, space.config(ind.genes))
+		}
+		for i, r := range evaluateConfigs(cache, config, pending, false) {
+			evaluated[keys[i]] = len(results)
+			results = append(results, r)
+		}
+		for i := range pop {
+			pop[i].fitness = objective.Score(results[evaluated[geneKey(pop[i].genes)]])
+		}
+	}
+
+	// breed 生成一个满足约束的个体，重试失败时返回 nil
+	breed := func(offspring func() []int) []int {
+		for attempt := 0; attempt < gaMaxRetries; attempt++ {
+			if genes := offspring(); space.valid(genes) {
+				return genes
+			}
+		}
+		return nil
+	}
+
+	pop := make([]individual, 0, opts.Population)
+	for attempts := 0; len(pop) < opts.Population && attempts < opts.Population*gaMaxRetries; attempts++ {
+		if genes := space.random(rng); space.valid(genes) {
+			pop = append(pop, individual{genes: genes})
+		}
+	}
+	if len(pop) < opts.Population {
+		return nil, fmt.Errorf("ga: could not sample %d parameter combinations satisfying constraints", opts.Population)
+	}
+
+	fmt.Printf("遗传算法: 参数空间 %d 组, 种群 %d, %d 代, 精英 %d, 交叉率 %.2f, 变异率 %.2f, 适应度 %s\n",
+		space.size(), opts.Population, opts.Generations, opts.Elite, opts.CrossoverRate, opts.MutationRate, objective.Label())
+
+	// tournament 锦标赛选择：随机抽取若干个体，取适应度最高者
+	tournament := func() individual {
+		best := pop[rng.Intn(len(pop))]
+		for i := 1; i < gaTournament; i++ {
+			if c := pop[rng.Intn(len(pop))]; c.fitness > best.fitness {
+				best = c
+			}
+		}
+		return best
+	}
+
+	start := time.Now()
+	for gen := 1; ; gen++ {
+		evaluate(pop)
+		sort.SliceStable(pop, func(i, j int) bool { return pop[i].fitness > pop[j].fitness })
+
+		// 平均值只计满足最低交易次数的个体（其余适应度为 -Inf）
+		var total float64
+		qualified := 0
+		for _, ind := range pop {
+			if !math.IsInf(ind.fitness, -1) {
+				total += ind.fitness
+				qualified++
+			}
+		}
+		avg := math.Inf(-1)
+		if qualified > 0 {
+			avg = total / float64(qualified)
+		}
+		fmt.Printf("第 %d/%d 代: 最优适应度 %.4f, 平均 %.4f (%d/%d 组合格), 已回测 %d 组 (已用 %s)\n",
+			gen, opts.Generations, pop[0].fitness, avg, qualified, len(pop), len(results), time.Since(start).Round(time.Second))
+		if gen == opts.Generations {
+			break
+		}
+
+		next := make([]individual, 0, opts.Population)
+		for _, elite := range pop[:opts.Elite] {
+			next = append(next, individual{genes: elite.genes})
+		}
+		for len(next) < opts.Population {
+			a, b := tournament(), tournament()
+			genes := breed(func() []int {
+				child := append([]int(nil), a.genes...)
+				if rng.Float64() < opts.CrossoverRate {
+					child = space.crossover(a.genes, b.genes, rng)
+				}
+				space.mutate(child, opts.MutationRate, rng)
+				return child
+			})
+			if genes == nil {
+				genes = a.genes
+			}
+			next = append(next, individual{genes: genes})
+		}
+		pop = next
+	}
+
+	best := results[evaluated[geneKey(pop[0].genes)]]
+	fmt.Printf("最优个体 (适应度 %.4f): 总盈亏 $%.2f, 最大回撤 %.2f%%, 交易 %d 笔, long: %.0f->%.0f short: %.0f->%.0f vol=%.1f ema=%d/%d%s\n",
+		pop[0].fitness, best.TotalPnL, best.MaxDrawdown*100, best.Trades,
+		best.Config.RSI_OVERSOLD_LONG, best.Config.RSI_ENTRY_LONG,
+		best.Config.RSI_OVERBOUGHT_SHORT, best.Config.RSI_ENTRY_SHORT,
+		best.Config.VOL_RATIO_THRESHOLD, best.Config.EMA_FAST, best.Config.EMA_SLOW, exitParams(best.Config))
+	return results, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+// positionBook 回测持仓：单向持仓模式下同一时间最多持有一个方向，反向开仓指令在持仓期间忽略；
+// 对冲模式（BacktestConfig.Hedge）下多空两个方向可同时持仓，各自分批、各自的逐仓保证金和强平价，余额共用
+type positionBook struct {
+	long, short *Position
+}
+
+// get 某一方向的持仓，没有时为 nil
+func (b *positionBook) get(side string) *Position {
+	if side == "SHORT" {
+		return b.short
+	}
+	return b.long
+}
+
+// set 设置某一方向的持仓，nil 表示已平仓
+func (b *positionBook) set(side string, p *Position) {
+	if side == "SHORT" {
+		b.short = p
+	} else {
+		b.long = p
+	}
+}
+
+// open 当前持仓，先开的在前（同时开仓时多头在前）
+func (b *positionBook) open() []*Position {
+	switch {
+	case b.long == nil && b.short == nil:
+		return nil
+	case b.short == nil:
+		return []*Position{b.long}
+	case b.long == nil:
+		return []*Position{b.short}
+	case b.short.openedAt < b.long.openedAt:
+		return []*Position{b.short, b.long}
+	}
+	return []*Position{b.long, b.short}
+}
+
+// empty 是否空仓
+func (b *positionBook) empty() bool {
+	return b.long == nil && b.short == nil
+}
+
+// view 引擎决策看到的持仓：先开的一侧为主视图，对冲模式下另一侧放在 Counter
+func (b *positionBook) view(price, balance float64, hedge bool) PositionView {
+	open := b.open()
+	if len(open) == 0 {
+		return PositionView{Hedge: hedge}
+	}
+	v := open[0].view(price, balance)
+	v.Hedge = hedge
+	if len(open) > 1 {
+		counter := open[1].view(price, balance)
+		counter.Hedge = hedge
+		v.Counter = &counter
+	}
+	return v
+}
+
+// Leg 某一方向的持仓视图，该方向没有持仓时返回空视图（保留 Hedge）
+func (v PositionView) Leg(side string) PositionView {
+	if v.Side == side {
+		v.Counter = nil
+		return v
+	}
+	if v.Counter != nil && v.Counter.Side == side {
+		return *v.Counter
+	}
+	return PositionView{Hedge: v.Hedge}
+}
+
+// oppositeSide 反方向
+func oppositeSide(side string) string {
+	if side == "SHORT" {
+		return "LONG"
+	}
+	return "SHORT"
+}
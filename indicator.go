@@ -154,6 +154,65 @@ func CalculateEMA(klines []Kline, period int) []float64 {
 	return ema
 }
 
+// CalculateATR 计算 ATR（Wilder 平滑的平均真实波幅）
+// 第一根 K 线的真实波幅取最高价 - 最低价，前 period-1 根为 0
+func CalculateATR(klines []Kline, period int) []float64 {
+	if period <= 0 || len(klines) < period {
+		return nil
+	}
+
+	atr := make([]float64, len(klines))
+	t := NewATRTracker(period)
+	for i, k := range klines {
+		t.Update(k)
+		atr[i] = t.Value()
+	}
+	return atr
+}
+
+// ATRTracker 逐根 K 线增量计算 ATR，结果与 CalculateATR 一致，nil 表示不计算
+type ATRTracker struct {
+	period    int
+	prevClose float64
+	n         int     // 已计入的 K 线数
+	sum       float64 // 前 period 根的真实波幅之和
+	value     float64
+}
+
+// NewATRTracker 创建 ATR 计算器
+func NewATRTracker(period int) *ATRTracker {
+	return &ATRTracker{period: period}
+}
+
+// Update 计入一根已收盘的 K 线
+func (t *ATRTracker) Update(k Kline) {
+	if t == nil {
+		return
+	}
+	tr := k.High - k.Low
+	if t.n > 0 {
+		tr = trueRange(k, t.prevClose)
+	}
+	t.prevClose = k.Close
+	t.n++
+	switch {
+	case t.n < t.period:
+		t.sum += tr
+	case t.n == t.period:
+		t.value = (t.sum + tr) / float64(t.period)
+	default:
+		t.value = (t.value*float64(t.period-1) + tr) / float64(t.period)
+	}
+}
+
+// Value 当前 ATR，不足 period 根时为 0
+func (t *ATRTracker) Value() float64 {
+	if t == nil {
+		return 0
+	}
+	return t.value
+}
+
 // Signal 表示交易信号
 type Signal int
 
@@ -178,6 +237,9 @@ type StrategyConfig struct {
 	EMA_FAST             int
 	EMA_SLOW             int
 	VOL_RATIO_THRESHOLD  float64
+	// 价格止损 / 止盈比例（相对持仓均价），0 表示沿用回测的 -stop-loss / -take-profit
+	StopLossPct          float64
+	TakeProfitPct        float64
 }
 
 // DefaultConfig 默认参数（超短线 1分钟，优化后）
@@ -200,47 +262,3 @@ const (
 	TrendUp         // 上升趋势
 	TrendDown       // 下降趋势
 )
-
-// GenerateSignal 生成交易信号（实盘用，回测用 RunBacktest 里的逻辑）
-func GenerateSignal(klines []Kline, config StrategyConfig) Signal {
-	n := len(klines)
-	if n < config.RSI_PERIOD+2 || n < config.EMA_SLOW+1 {
-		return SignalNone
-	}
-
-	rsi := CalculateRSI(klines, config.RSI_PERIOD)
-	emaFast := CalculateEMA(klines, config.EMA_FAST)
-	emaSlow := CalculateEMA(klines, config.EMA_SLOW)
-	volRatio := VolumeRatio(klines, config.RSI_PERIOD)
-
-	if rsi == nil || emaFast == nil || emaSlow == nil || volRatio == nil {
-		return SignalNone
-	}
-
-	currentRSI := rsi[n-1]
-	prevRSI := rsi[n-2]
-	currentEMAFast := emaFast[n-1]
-	currentEMASlow := emaSlow[n-1]
-	currentVolRatio := volRatio[n-1]
-
-	// 趋势判断
-	uptrend := currentEMAFast > currentEMASlow
-	downtrend := currentEMAFast < currentEMASlow
-
-	// 成交量放大
-	volumeOK := currentVolRatio >= config.VOL_RATIO_THRESHOLD
-
-	// === 做多信号 ===
-	rsiBull := prevRSI < config.RSI_OVERSOLD_LONG && currentRSI >= config.RSI_ENTRY_LONG
-	if rsiBull && uptrend && volumeOK {
-		return SignalLong
-	}
-
-	// === 做空信号 ===
-	rsiBear := prevRSI > config.RSI_OVERBOUGHT_SHORT && currentRSI <= config.RSI_ENTRY_SHORT
-	if rsiBear && downtrend && volumeOK {
-		return SignalShort
-	}
-
-	return SignalNone
-}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// DefaultInterval 默认 K 线周期，实盘、回测和优化一致
+const DefaultInterval = "5m"
+
+// klineIntervals 币安合约支持的 K 线周期（秒）
+var klineIntervals = map[string]int64{
+	"1m":  60,
+	"3m":  180,
+	"5m":  300,
+	"15m": 900,
+	"30m": 1800,
+	"1h":  3600,
+	"2h":  7200,
+	"4h":  14400,
+	"6h":  21600,
+	"8h":  28800,
+	"12h": 43200,
+	"1d":  86400,
+}
+
+// ParseInterval 解析 K 线周期（如 5m、1h），返回秒数；空值使用 DefaultInterval
+func ParseInterval(name string) (int64, error) {
+	if name == "" {
+		name = DefaultInterval
+	}
+	if sec, ok := klineIntervals[name]; ok {
+		return sec, nil
+	}
+	names := make([]string, 0, len(klineIntervals))
+	for n := range klineIntervals {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool { return klineIntervals[names[i]] < klineIntervals[names[j]] })
+	return 0, fmt.Errorf("unknown interval %q (want one of %s)", name, strings.Join(names, ", "))
+}
+
+// intervalName K 线周期的名称（如 5m），不是币安周期时按秒显示
+func intervalName(sec int64) string {
+	for name, s := range klineIntervals {
+		if s == sec {
+			return name
+		}
+	}
+	return fmt.Sprintf("%ds", sec)
+}
+
+// Resample 将 K 线合并为 interval 秒的周期，按整点边界对齐（Timestamp 为 interval 的整数倍，
+// 与交易所 K 线一致）；数据有缺口时按实际落入的 K 线合并，不补齐。首尾未走完的周期同样保留
+func Resample(klines []Kline, interval int64) []Kline {
+	var out []Kline
+	for _, k := range klines {
+		start := k.Timestamp - k.Timestamp%interval
+		if n := len(out); n > 0 && out[n-1].Timestamp == start {
+			bar := &out[n-1]
+			bar.High = max(bar.High, k.High)
+			bar.Low = min(bar.Low, k.Low)
+			bar.Close = k.Close
+			bar.Volume += k.Volume
+			continue
+		}
+		k.Timestamp = start
+		out = append(out, k)
+	}
+	return out
+}
+
+// sourceInterval 数据本身的 K 线周期：相邻 K 线的最小时间间隔
+func sourceInterval(klines []Kline) int64 {
+	var interval int64
+	for i := 1; i < len(klines); i++ {
+		if d := klines[i].Timestamp - klines[i-1].Timestamp; d > 0 && (interval == 0 || d < interval) {
+			interval = d
+		}
+	}
+	return interval
+}
+
+// loadIntervalKlines 从数据库加载 K 线并重采样到 interval 秒；数据周期比目标周期粗
+// 或不能整除时报错，避免回测在与实盘不同的周期上静默运行
+func loadIntervalKlines(dbPath, symbol string, startTime, endTime, interval int64) ([]Kline, error) {
+	klines, err := loadKlinesFromDB(dbPath, symbol, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	return resampleTo(klines, interval)
+}
+
+// resampleTo 按数据本身的周期检查后重采样到 interval 秒，周期相同时原样返回
+func resampleTo(klines []Kline, interval int64) ([]Kline, error) {
+	source := sourceInterval(klines)
+	if source == 0 || source == interval {
+		return klines, nil
+	}
+	if interval < source || interval%source != 0 {
+		return nil, fmt.Errorf("cannot resample %ds klines to %ds", source, interval)
+	}
+	resampled := Resample(klines, interval)
+	log.Printf("%d 根 %ds K 线重采样为 %d 根 %ds K 线", len(klines), source, len(resampled), interval)
+	return resampled, nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import "math"
+
+// partialBars 模拟盘中判定：把每根 K 线近似为只走完 frac 时的形态
+// 没有逐笔数据，价格按开盘到收盘线性推进，成交量按比例折算
+func partialBars(klines []Kline, frac float64) []Kline {
+	bars := make([]Kline, len(klines))
+	for i, k := range klines {
+		bars[i] = partialBar(k, frac)
+	}
+	return bars
+}
+
+// partialBar 单根 K 线只走完 frac 时的近似形态
+func partialBar(k Kline, frac float64) Kline {
+	c := k.Open + (k.Close-k.Open)*frac
+	return Kline{
+		Timestamp: k.Timestamp,
+		Open:      k.Open,
+		High:      math.Max(k.Open, c),
+		Low:       math.Min(k.Open, c),
+		Close:     c,
+		Volume:    k.Volume * frac,
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"database/sql"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// 日志事件类型
+const (
+	JournalOpen    = "open"
+	JournalClose   = "close"
+	JournalAborted = "aborted"
+)
+
+// JournalEntry 交易日志记录
+type JournalEntry struct {
+	Time     int64
+	Symbol   string
+	Event    string // open, close, aborted
+	Side     string // LONG, SHORT
+	Price    float64
+	Amount   float64
+	Notional float64
+	PnL      float64
+	Fee      float64
+	Reason   string
+	Strategy string  // 策略引擎名称，用于汇总报告按策略归因
+	Basis    float64 // 成交时的基差 (bps，最新价相对指数价格)，未监控基差时为 0
+	// 实盘成交的实测数据，供 dry-run 模拟成交延迟和价格偏离：信号 K 线收盘价和从信号到下单返回的耗时 (ms)，
+	// 未测量时为 0
+	SignalPrice float64
+	LatencyMs   int64
+}
+
+// Journal 实盘交易日志（SQLite）
+type Journal struct {
+	db *sql.DB
+}
+
+// journalMigrations 交易日志库的表结构版本
+var journalMigrations = []Migration{
+	{Version: 1, Name: "baseline", Up: func(tx *sql.Tx) error {
+		err := execMigration(`
+			CREATE TABLE IF NOT EXISTS journal (
+				id       INTEGER PRIMARY KEY AUTOINCREMENT,
+				ts       INTEGER NOT NULL,
+				symbol   TEXT NOT NULL,
+				event    TEXT NOT NULL,
+				side     TEXT NOT NULL,
+				price    REAL NOT NULL,
+				amount   REAL NOT NULL,
+				notional REAL NOT NULL,
+				pnl      REAL NOT NULL DEFAULT 0,
+				fee      REAL NOT NULL DEFAULT 0,
+				reason   TEXT NOT NULL DEFAULT '',
+				strategy TEXT NOT NULL DEFAULT '',
+				basis_bps REAL NOT NULL DEFAULT 0
+			)`, `
+			CREATE TABLE IF NOT EXISTS orders (
+				client_order_id TEXT PRIMARY KEY,
+				ts              INTEGER NOT NULL,
+				symbol          TEXT NOT NULL,
+				signal          TEXT NOT NULL,
+				price           REAL NOT NULL,
+				amount          REAL NOT NULL,
+				notional        REAL NOT NULL,
+				status          TEXT NOT NULL
+			)`, `
+			CREATE TABLE IF NOT EXISTS instance_locks (
+				key     TEXT PRIMARY KEY,
+				owner   TEXT NOT NULL,
+				expires INTEGER NOT NULL
+			)`,
+		)(tx)
+		if err != nil {
+			return err
+		}
+		// 引入版本记录之前的日志可能没有 strategy、basis_bps 列
+		if err := ensureColumn(tx, "journal", "strategy", `TEXT NOT NULL DEFAULT ''`); err != nil {
+			return err
+		}
+		return ensureColumn(tx, "journal", "basis_bps", `REAL NOT NULL DEFAULT 0`)
+	}},
+	{Version: 2, Name: "journal ts index", Up: execMigration(
+		`CREATE INDEX IF NOT EXISTS journal_ts ON journal (ts)`,
+	)},
+	{Version: 3, Name: "fill measurements", Up: execMigration(
+		`ALTER TABLE journal ADD COLUMN signal_price REAL NOT NULL DEFAULT 0`,
+		`ALTER TABLE journal ADD COLUMN latency_ms INTEGER NOT NULL DEFAULT 0`,
+	)},
+}
+
+// OpenJournal 打开或创建交易日志
+func OpenJournal(path string) (*Journal, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrateSQLite(db, path, "journal", journalMigrations); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Journal{db: db}, nil
+}
+
+// Record 写入一条记录，未启用日志时为空操作
+func (j *Journal) Record(e JournalEntry) error {
+	if j == nil {
+		return nil
+	}
+	if e.Time == 0 {
+		e.Time = time.Now().Unix()
+	}
+
+	_, err := j.db.Exec(
+		`INSERT INTO journal (ts, symbol, event, side, price, amount, notional, pnl, fee, reason, strategy, basis_bps, signal_price, latency_ms)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Time, e.Symbol, e.Event, e.Side, e.Price, e.Amount, e.Notional, e.PnL, e.Fee, e.Reason, e.Strategy, e.Basis, e.SignalPrice, e.LatencyMs,
+	)
+	return err
+}
+
+// Entries 按时间顺序读取 [from, to] 区间内的记录，to 为 0 表示不限
+func (j *Journal) Entries(from, to int64) ([]JournalEntry, error) {
+	query := `SELECT ts, symbol, event, side, price, amount, notional, pnl, fee, reason, strategy, basis_bps, signal_price, latency_ms FROM journal WHERE ts >= ?`
+	args := []any{from}
+	if to > 0 {
+		query += " AND ts <= ?"
+		args = append(args, to)
+	}
+	query += " ORDER BY ts, id"
+
+	rows, err := j.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []JournalEntry
+	for rows.Next() {
+		var e JournalEntry
+		if err := rows.Scan(&e.Time, &e.Symbol, &e.Event, &e.Side, &e.Price, &e.Amount, &e.Notional, &e.PnL, &e.Fee, &e.Reason, &e.Strategy, &e.Basis, &e.SignalPrice, &e.LatencyMs); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// CountOpens 统计 since 之后所有交易对的开仓次数
+func (j *Journal) CountOpens(since int64) (int, error) {
+	var n int
+	err := j.db.QueryRow(`SELECT COUNT(*) FROM journal WHERE event = ? AND ts >= ?`, JournalOpen, since).Scan(&n)
+	return n, err
+}
+
+// Close 关闭交易日志
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	return j.db.Close()
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// KlineGap 连续缺失的 K 线：From、To 为第一根和最后一根缺失 K 线的开盘时间（秒）
+type KlineGap struct {
+	From int64
+	To   int64
+	Bars int
+}
+
+// KlineIntegrity K 线数据完整性检查结果，时间均为开盘时间（秒）
+type KlineIntegrity struct {
+	Interval   int64 // K 线周期（秒）
+	Bars       int   // 实际 K 线数（含重复）
+	Expected   int   // 区间内应有的 K 线数
+	First      int64
+	Last       int64
+	Gaps       []KlineGap
+	Missing    int     // 缺失的 K 线总数
+	Duplicates []int64 // 出现多次的时间
+	Misaligned []int64 // 不在周期边界上的时间
+	ZeroVolume []int64 // 成交量为 0 的 K 线
+	ZeroPrice  []int64 // 开高低收任一价格不大于 0 的 K 线
+}
+
+// Clean 没有发现任何问题
+func (r KlineIntegrity) Clean() bool {
+	return len(r.Gaps) == 0 && len(r.Duplicates) == 0 && len(r.Misaligned) == 0 &&
+		len(r.ZeroVolume) == 0 && len(r.ZeroPrice) == 0
+}
+
+// ScanKlines 检查按时间排序的 K 线：缺口、重复、未对齐、零成交量和零价格；
+// startTime / endTime（秒）大于 0 时区间首尾缺少的 K 线也计为缺口
+func ScanKlines(klines []Kline, interval, startTime, endTime int64) KlineIntegrity {
+	r := KlineIntegrity{Interval: interval, Bars: len(klines)}
+	addGap := func(from, to int64) {
+		if to < from {
+			return
+		}
+		gap := KlineGap{From: from, To: to, Bars: int((to-from)/interval) + 1}
+		r.Gaps = append(r.Gaps, gap)
+		r.Missing += gap.Bars
+	}
+	if startTime > 0 {
+		startTime = (startTime + interval - 1) / interval * interval
+	}
+	if endTime > 0 {
+		endTime = endTime - endTime%interval
+	}
+
+	if len(klines) == 0 {
+		if startTime > 0 && endTime > startTime {
+			addGap(startTime, endTime-interval)
+			r.Expected = r.Missing
+		}
+		return r
+	}
+	r.First, r.Last = klines[0].Timestamp, klines[len(klines)-1].Timestamp
+	if startTime > 0 {
+		addGap(startTime, r.First-r.First%interval-interval)
+	}
+	for i, k := range klines {
+		if k.Timestamp%interval != 0 {
+			r.Misaligned = append(r.Misaligned, k.Timestamp)
+		}
+		if k.Open <= 0 || k.High <= 0 || k.Low <= 0 || k.Close <= 0 {
+			r.ZeroPrice = append(r.ZeroPrice, k.Timestamp)
+		} else if k.Volume == 0 {
+			r.ZeroVolume = append(r.ZeroVolume, k.Timestamp)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := klines[i-1].Timestamp
+		if k.Timestamp == prev {
+			if n := len(r.Duplicates); n == 0 || r.Duplicates[n-1] != prev {
+				r.Duplicates = append(r.Duplicates, prev)
+			}
+			continue
+		}
+		if k.Timestamp-prev > interval {
+			addGap(prev-prev%interval+interval, k.Timestamp-k.Timestamp%interval-interval)
+		}
+	}
+	if endTime > 0 {
+		addGap(r.Last-r.Last%interval+interval, endTime-interval)
+	}
+
+	first, last := r.First, r.Last
+	if len(r.Gaps) > 0 {
+		first, last = min(first, r.Gaps[0].From), max(last, r.Gaps[len(r.Gaps)-1].To)
+	}
+	r.Expected = int((last-last%interval-(first-first%interval))/interval) + 1
+	return r
+}
+
+// printKlineIntegrity 打印检查结果，缺口按缺失数量从大到小最多列出 limit 个
+func printKlineIntegrity(symbol string, r KlineIntegrity, limit int) {
+	format := func(ts int64) string { return time.Unix(ts, 0).UTC().Format("2006-01-02 15:04") }
+	fmt.Printf("\n========== K 线完整性 %s %s ==========\n", symbol, intervalName(r.Interval))
+	if r.Bars == 0 {
+		fmt.Println("没有 K 线数据")
+	} else {
+		fmt.Printf("区间: %s ~ %s (UTC)\n", format(r.First), format(r.Last))
+	}
+	coverage := 0.0
+	if r.Expected > 0 {
+		coverage = float64(r.Expected-r.Missing) / float64(r.Expected) * 100
+	}
+	fmt.Printf("K 线: %d 根, 应有 %d 根, 覆盖率 %.3f%%\n", r.Bars, r.Expected, coverage)
+	fmt.Printf("缺口: %d 处, 共缺 %d 根\n", len(r.Gaps), r.Missing)
+
+	gaps := append([]KlineGap(nil), r.Gaps...)
+	sort.SliceStable(gaps, func(i, j int) bool { return gaps[i].Bars > gaps[j].Bars })
+	for i, g := range gaps {
+		if i >= limit {
+			fmt.Printf("  ……另有 %d 处缺口\n", len(gaps)-limit)
+			break
+		}
+		fmt.Printf("  %s ~ %s  缺 %d 根\n", format(g.From), format(g.To), g.Bars)
+	}
+	printTimes := func(label string, times []int64) {
+		if len(times) == 0 {
+			return
+		}
+		fmt.Printf("%s: %d 根", label, len(times))
+		for i, ts := range times {
+			if i >= limit {
+				fmt.Print(" ……")
+				break
+			}
+			fmt.Printf(" %s", format(ts))
+		}
+		fmt.Println()
+	}
+	printTimes("重复", r.Duplicates)
+	printTimes("未对齐周期", r.Misaligned)
+	printTimes("零价格", r.ZeroPrice)
+	printTimes("零成交量", r.ZeroVolume)
+	if r.Clean() {
+		fmt.Println("未发现问题")
+	}
+}
+
+// backfillRanges 需要从交易所重新下载的区间：缺口和零价格 K 线（相邻的合并）
+func (r KlineIntegrity) backfillRanges() []KlineGap {
+	ranges := append([]KlineGap(nil), r.Gaps...)
+	for _, ts := range r.ZeroPrice {
+		ts -= ts % r.Interval
+		ranges = append(ranges, KlineGap{From: ts, To: ts, Bars: 1})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].From < ranges[j].From })
+	var merged []KlineGap
+	for _, g := range ranges {
+		if n := len(merged); n > 0 && g.From <= merged[n-1].To+r.Interval {
+			merged[n-1].To = max(merged[n-1].To, g.To)
+			merged[n-1].Bars = int((merged[n-1].To-merged[n-1].From)/r.Interval) + 1
+			continue
+		}
+		merged = append(merged, g)
+	}
+	return merged
+}
+
+// BackfillKlines 从币安重新下载 ranges 内的 K 线写入 dbPath（已有的同一时间 K 线被替换），返回写入条数；
+// 交易所本身也没有数据的区间（如停机维护）不会被补齐
+func BackfillKlines(dbPath, market, symbol string, interval int64, ranges []KlineGap) (int, error) {
+	endpoint, ok := klineEndpoints[market]
+	if !ok {
+		return 0, fmt.Errorf("unknown market %q", market)
+	}
+	name := intervalName(interval)
+	if _, err := ParseInterval(name); err != nil {
+		return 0, err
+	}
+
+	if err := requireSQLite(dbPath, "backfilling klines"); err != nil {
+		return 0, err
+	}
+	store, err := OpenKlineStore(dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer store.Close()
+
+	schema, err := downloadSchema(store, klineSchemaConfig)
+	if err != nil {
+		return 0, err
+	}
+	symbolValue := any(nil)
+	if schema.Symbol != "" {
+		if symbolValue, err = schema.symbolValue(store, symbol); err != nil {
+			return 0, err
+		}
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	total := 0
+	for _, g := range ranges {
+		n, err := downloadRange(client, endpoint, store.DB(), schema, symbolValue, symbol, name, interval, g.From, g.To+interval)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// runCheckCmd 执行 K 线完整性检查；interval 为空时按数据本身的周期，fix 为 true 时从交易所补齐缺口和零价格 K 线后再检查一遍
+func runCheckCmd(dbPath, market, symbol, interval string, startTime, endTime int64, fix bool) {
+	klines, err := loadKlinesFromDB(dbPath, symbol, startTime, endTime)
+	if err != nil {
+		log.Fatalf("加载数据失败: %v", err)
+	}
+	sec := sourceInterval(klines)
+	if interval != "" {
+		if sec, err = ParseInterval(interval); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+	if sec <= 0 {
+		log.Fatalf("无法确定 K 线周期，请用 -interval 指定")
+	}
+
+	// 区间末尾只检查已收盘的 K 线
+	if now := time.Now().Unix(); endTime > 0 {
+		endTime = min(endTime, now-now%sec)
+	}
+	r := ScanKlines(klines, sec, startTime, endTime)
+	printKlineIntegrity(symbol, r, 20)
+	if !fix {
+		return
+	}
+	ranges := r.backfillRanges()
+	if len(ranges) == 0 {
+		return
+	}
+	if market == "" {
+		market = "futures"
+	}
+	log.Printf("从 %s 补齐 %d 个区间", market, len(ranges))
+	n, err := BackfillKlines(dbPath, market, symbol, sec, ranges)
+	if err != nil {
+		log.Fatalf("补齐 K 线失败（已写入 %d 根）: %v", n, err)
+	}
+	log.Printf("补齐完成，共写入 %d 根 K 线", n)
+
+	if klines, err = loadKlinesFromDB(dbPath, symbol, startTime, endTime); err != nil {
+		log.Fatalf("加载数据失败: %v", err)
+	}
+	printKlineIntegrity(symbol, ScanKlines(klines, sec, startTime, endTime), 20)
+	if len(r.Duplicates) > 0 {
+		log.Printf("重复的 K 线无法通过补齐修复，请检查表是否缺少 (交易对, 时间) 主键")
+	}
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// KlineSchema K 线表的列映射，空字段在加载时自动检测
+type KlineSchema struct {
+	Table  string `json:"table"`
+	Time   string `json:"time"`
+	Open   string `json:"open"`
+	High   string `json:"high"`
+	Low    string `json:"low"`
+	Close  string `json:"close"`
+	Volume string `json:"volume"`
+	Symbol string `json:"symbol"` // 为空表示表中只有一个交易对
+	// SymbolIDs 交易对列为整数 ID 时的映射，优先于库中的交易对表和 defaultSymbolIDs
+	SymbolIDs map[string]int `json:"symbol_ids,omitempty"`
+	// SymbolTable 库中 ID 与交易对名称的对照表，为空时按 symbolTableNames 自动查找
+	SymbolTable string `json:"symbol_table,omitempty"`
+	// PriceScale 价格的缩放（存储值 / PriceScale），0 表示自动检测
+	PriceScale float64 `json:"price_scale"`
+	// VolumeScale 成交量的缩放，0 表示与 PriceScale 相同
+	VolumeScale float64 `json:"volume_scale,omitempty"`
+	// TimeScale 时间戳缩放（毫秒为 1000），0 表示自动检测
+	TimeScale int64 `json:"time_scale"`
+
+	// timestamp 时间列为 PostgreSQL 的 timestamp 类型，自动检测
+	timestamp bool
+}
+
+// defaultSymbolIDs 原始数据库（klines_futures）的交易对 ID，库中没有交易对表时使用
+var defaultSymbolIDs = map[string]int{
+	"BTCUSDT": 1, "ETHUSDT": 2, "BNBUSDT": 3, "SOLUSDT": 4,
+}
+
+// symbolTableNames 自动查找交易对表时尝试的表名（小写）
+var symbolTableNames = []string{"symbols", "symbol", "symbol_ids", "instruments", "pairs", "markets"}
+
+// symbolTableColumns 交易对表中 ID 列和名称列可能的列名（小写）
+var symbolTableColumns = map[string][]string{
+	"id":   {"id", "symbol_id"},
+	"name": {"symbol", "name", "pair", "instrument"},
+}
+
+// defaultKlineTable 未指定时使用的表
+const defaultKlineTable = "klines_futures"
+
+// marketTables 市场类型对应的 K 线表（-market）
+var marketTables = map[string]string{
+	"futures": "klines_futures",
+	"spot":    "klines_spot",
+}
+
+// klineColumnNames 自动检测时各字段可能的列名（小写）
+var klineColumnNames = map[string][]string{
+	"time":   {"ts", "timestamp", "time", "open_time", "opentime", "t"},
+	"open":   {"o", "open", "open_price"},
+	"high":   {"h", "high", "high_price"},
+	"low":    {"l", "low", "low_price"},
+	"close":  {"c", "close", "close_price"},
+	"volume": {"v", "volume", "vol", "amount", "base_volume"},
+	"symbol": {"symbol", "symbol_id", "pair", "instrument", "market"},
+}
+
+// klineSchemaConfig 命令行指定的列映射（-db-schema），未设置的字段自动检测
+var klineSchemaConfig KlineSchema
+
+// LoadKlineSchema 从 JSON 文件读取列映射
+func LoadKlineSchema(path string) (KlineSchema, error) {
+	var schema KlineSchema
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return schema, err
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return schema, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return schema, nil
+}
+
+// quoteIdent 引用 SQL 标识符
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// detectKlineSchema 以 override 为准，按列名和样本数据补全其余字段
+func detectKlineSchema(store KlineStore, override KlineSchema) (KlineSchema, error) {
+	schema := override
+	if schema.Table == "" {
+		schema.Table = defaultKlineTable
+	}
+
+	columns, err := store.Columns(schema.Table)
+	if err != nil {
+		return schema, err
+	}
+	find := func(field string) string {
+		for _, candidate := range klineColumnNames[field] {
+			for _, c := range columns {
+				if strings.EqualFold(c, candidate) {
+					return c
+				}
+			}
+		}
+		return ""
+	}
+
+	fields := []struct {
+		name   string
+		column *string
+	}{
+		{"time", &schema.Time}, {"open", &schema.Open}, {"high", &schema.High},
+		{"low", &schema.Low}, {"close", &schema.Close}, {"volume", &schema.Volume},
+	}
+	for _, f := range fields {
+		if *f.column == "" {
+			*f.column = find(f.name)
+		}
+		if *f.column == "" {
+			return schema, fmt.Errorf("table %s: no %s column (have %s)", schema.Table, f.name, strings.Join(columns, ", "))
+		}
+	}
+	if schema.Symbol == "" {
+		schema.Symbol = find("symbol")
+	}
+
+	// timestamp 类型的时间列按秒读取
+	timeType, err := store.ValueType(schema.Table, schema.Time)
+	if err != nil {
+		return schema, err
+	}
+	if timeType == valueTimestamp {
+		schema.timestamp = true
+		schema.TimeScale = 1
+	}
+
+	// 用样本判断价格缩放和时间戳单位；指定的缩放也按样本检查是否合理
+	closeType, err := store.ValueType(schema.Table, schema.Close)
+	if err != nil {
+		return schema, err
+	}
+	closes, firstTime, err := sampleKlineValues(store, schema)
+	if err != nil {
+		return schema, err
+	}
+	if schema.PriceScale == 0 {
+		schema.PriceScale = detectPriceScale(closeType, closes)
+	} else if len(closes) > 0 && !plausiblePrice(median(closes)/schema.PriceScale) {
+		log.Printf("[警告] 表 %s 收盘价中位数 %g 按 price_scale %g 换算为 %g，价格可能不正确",
+			schema.Table, median(closes), schema.PriceScale, median(closes)/schema.PriceScale)
+	}
+	if schema.VolumeScale == 0 {
+		schema.VolumeScale = schema.PriceScale
+	}
+	if schema.TimeScale == 0 {
+		schema.TimeScale = detectTimeScale(firstTime)
+	}
+
+	return schema, nil
+}
+
+// klineSampleRows 自动检测缩放时读取的样本行数
+const klineSampleRows = 100
+
+// fixedPointScale 定点整数价格的缩放
+const fixedPointScale = 1e8
+
+// sampleKlineValues 读取前 klineSampleRows 行的收盘价（存储值）和第一行的时间值，表为空时均为零值
+func sampleKlineValues(store KlineStore, schema KlineSchema) ([]float64, float64, error) {
+	rows, err := store.Query(fmt.Sprintf("SELECT %s, %s FROM %s LIMIT %d",
+		quoteIdent(schema.Close), schema.timeSelect(), quoteIdent(schema.Table), klineSampleRows))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var closes []float64
+	var firstTime float64
+	for rows.Next() {
+		var c, t sql.NullFloat64
+		if err := rows.Scan(&c, &t); err != nil {
+			return nil, 0, err
+		}
+		if c.Valid && c.Float64 > 0 {
+			closes = append(closes, c.Float64)
+		}
+		if firstTime == 0 && t.Valid {
+			firstTime = t.Float64
+		}
+	}
+	return closes, firstTime, rows.Err()
+}
+
+// plausiblePrice 换算后的价格是否在合理范围内（覆盖从极低价代币到 BTC 的量级）
+func plausiblePrice(price float64) bool {
+	return price >= 1e-6 && price <= 1e7
+}
+
+// median 中位数（不修改 values）
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return quantile(sorted, 0.5)
+}
+
+// detectPriceScale 按样本收盘价中位数判断价格缩放：整数存储且数值很大视为 1e8 定点数，否则为原始价格；
+// 换算后的价格明显不合理而换用另一种缩放合理时（如浮点列中存的是定点数），改用另一种
+func detectPriceScale(closeType string, closes []float64) float64 {
+	if len(closes) == 0 {
+		return 1
+	}
+	m := median(closes)
+	scale := 1.0
+	if closeType == valueInteger && m >= 1e6 {
+		scale = fixedPointScale
+	}
+	if !plausiblePrice(m / scale) {
+		other := fixedPointScale
+		if scale != 1 {
+			other = 1
+		}
+		if plausiblePrice(m / other) {
+			log.Printf("收盘价中位数 %g 按缩放 %g 换算不合理，改按 %g 换算（可在 -db-schema 中指定 price_scale）", m, scale, other)
+			scale = other
+		}
+	}
+	return scale
+}
+
+// detectTimeScale 按时间值的量级判断单位：秒、毫秒、微秒或纳秒
+func detectTimeScale(t float64) int64 {
+	switch {
+	case t >= 1e18:
+		return 1e9
+	case t >= 1e15:
+		return 1e6
+	case t >= 1e12:
+		return 1000
+	default:
+		return 1
+	}
+}
+
+// symbolFilter 返回按交易对过滤的 SQL 条件和参数
+func (schema KlineSchema) symbolFilter(store KlineStore, symbol string) (string, []any, error) {
+	if schema.Symbol == "" {
+		return "1 = 1", nil, nil
+	}
+
+	symbolType, err := store.ValueType(schema.Table, schema.Symbol)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if symbolType != valueInteger {
+		return quoteIdent(schema.Symbol) + " = ?", []any{symbol}, nil
+	}
+
+	id, err := schema.symbolID(store, symbol)
+	if err != nil {
+		return "", nil, err
+	}
+	return quoteIdent(schema.Symbol) + " = ?", []any{id}, nil
+}
+
+// symbolID 整数交易对列中 symbol 的 ID：依次查 SymbolIDs（含 -symbol-id）、库中的交易对表、defaultSymbolIDs
+func (schema KlineSchema) symbolID(store KlineStore, symbol string) (int, error) {
+	if id, ok := schema.SymbolIDs[symbol]; ok {
+		return id, nil
+	}
+	table, err := schema.findSymbolTable(store)
+	if err != nil {
+		return 0, err
+	}
+	if table != "" {
+		id, ok, err := lookupSymbolID(store, table, symbol)
+		if err != nil {
+			return 0, fmt.Errorf("symbol table %s: %w", table, err)
+		}
+		if ok {
+			return id, nil
+		}
+	}
+	if id, ok := defaultSymbolIDs[symbol]; ok && table == "" {
+		return id, nil
+	}
+	if table != "" {
+		return 0, fmt.Errorf("unknown symbol %s: not in symbol table %s (set -symbol-id or symbol_ids in -db-schema)", symbol, table)
+	}
+	return 0, fmt.Errorf("unknown symbol %s: no symbol table in database (set -symbol-id or symbol_ids in -db-schema)", symbol)
+}
+
+// findSymbolTable 交易对表名：SymbolTable 指定时直接使用，否则在库中按 symbolTableNames 查找，没有时返回空
+func (schema KlineSchema) findSymbolTable(store KlineStore) (string, error) {
+	if schema.SymbolTable != "" {
+		return schema.SymbolTable, nil
+	}
+	tables, err := store.Tables()
+	if err != nil {
+		return "", err
+	}
+	for _, candidate := range symbolTableNames {
+		for _, t := range tables {
+			if strings.EqualFold(t, candidate) && t != schema.Table {
+				return t, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// lookupSymbolID 在交易对表中按名称（不区分大小写）查找 ID
+func lookupSymbolID(store KlineStore, table, symbol string) (int, bool, error) {
+	columns, err := store.Columns(table)
+	if err != nil {
+		return 0, false, err
+	}
+	find := func(field string) string {
+		for _, candidate := range symbolTableColumns[field] {
+			for _, c := range columns {
+				if strings.EqualFold(c, candidate) {
+					return c
+				}
+			}
+		}
+		return ""
+	}
+	idCol, nameCol := find("id"), find("name")
+	if idCol == "" || nameCol == "" {
+		return 0, false, fmt.Errorf("no id / symbol columns (have %s)", strings.Join(columns, ", "))
+	}
+	var id int
+	err = store.QueryRow(fmt.Sprintf("SELECT %s FROM %s WHERE UPPER(%s) = ? LIMIT 1",
+		quoteIdent(idCol), quoteIdent(table), quoteIdent(nameCol)), strings.ToUpper(symbol)).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return id, true, nil
+}
+
+// loadKlinesFromDB 从 K 线库（SQLite 文件或 PostgreSQL 连接串）加载 K 线数据，列布局按 klineSchemaConfig 和自动检测确定
+func loadKlinesFromDB(dbPath, symbol string, startTime, endTime int64) ([]Kline, error) {
+	store, err := OpenKlineStore(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	schema, err := detectKlineSchema(store, klineSchemaConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := schema.klineQuery(store, symbol, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := store.Query(query+" ORDER BY "+quoteIdent(schema.Time), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var klines []Kline
+	for rows.Next() {
+		k, _, err := schema.scanKline(rows)
+		if err != nil {
+			return nil, err
+		}
+		klines = append(klines, k)
+	}
+
+	return klines, rows.Err()
+}
+
+// klineQuery 按交易对和时间范围（秒，0 表示不限）查询 K 线的 SQL（不含排序）及参数
+func (schema KlineSchema) klineQuery(store KlineStore, symbol string, startTime, endTime int64) (string, []any, error) {
+	where, args, err := schema.symbolFilter(store, symbol)
+	if err != nil {
+		return "", nil, err
+	}
+
+	timeCol := quoteIdent(schema.Time)
+	query := fmt.Sprintf("SELECT %s, %s, %s, %s, %s, %s FROM %s WHERE %s",
+		schema.timeSelect(), quoteIdent(schema.Open), quoteIdent(schema.High), quoteIdent(schema.Low),
+		quoteIdent(schema.Close), quoteIdent(schema.Volume), quoteIdent(schema.Table), where)
+
+	if startTime > 0 {
+		query += " AND " + timeCol + " >= ?"
+		args = append(args, schema.timeParam(startTime*schema.TimeScale))
+	}
+	if endTime > 0 {
+		query += " AND " + timeCol + " <= ?"
+		args = append(args, schema.timeParam(endTime*schema.TimeScale))
+	}
+	return query, args, nil
+}
+
+// timeSelect 查询时间列的表达式：timestamp 类型转换为秒
+func (schema KlineSchema) timeSelect() string {
+	if schema.timestamp {
+		return "CAST(EXTRACT(EPOCH FROM " + quoteIdent(schema.Time) + ") AS BIGINT)"
+	}
+	return quoteIdent(schema.Time)
+}
+
+// timeParam 与时间列比较的参数，raw 为库中的原始时间值（timestamp 类型为秒）
+func (schema KlineSchema) timeParam(raw int64) any {
+	if schema.timestamp {
+		return time.Unix(raw, 0).UTC()
+	}
+	return raw
+}
+
+// scanKline 读取 klineQuery 的一行并按 schema 换算，同时返回库中的原始时间值
+func (schema KlineSchema) scanKline(rows *sql.Rows) (Kline, int64, error) {
+	var ts int64
+	var o, h, l, c, v float64
+	if err := rows.Scan(&ts, &o, &h, &l, &c, &v); err != nil {
+		return Kline{}, 0, err
+	}
+	return Kline{
+		Timestamp: ts / schema.TimeScale,
+		Open:      o / schema.PriceScale,
+		High:      h / schema.PriceScale,
+		Low:       l / schema.PriceScale,
+		Close:     c / schema.PriceScale,
+		Volume:    v / schema.VolumeScale,
+	}, ts, nil
+}
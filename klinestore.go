@@ -0,0 +1,210 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	_ "github.com/lib/pq"
+)
+
+// K 线列的取值类型（KlineStore.ValueType）
+const (
+	valueInteger   = "integer"
+	valueReal      = "real"
+	valueText      = "text"
+	valueTimestamp = "timestamp"
+)
+
+// KlineStore K 线库：读取 K 线和识别表结构所需的方言相关操作，SQLite 文件与 PostgreSQL 各一种实现。
+// 查询统一使用 ? 占位符，由实现改写为驱动的格式
+type KlineStore interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	// Columns 表的列名，表不存在时返回错误
+	Columns(table string) ([]string, error)
+	// Tables 库中的表名
+	Tables() ([]string, error)
+	// ValueType 列的取值类型（valueInteger 等），表为空且无法判断时返回空
+	ValueType(table, column string) (string, error)
+	// DB 底层连接，写入（下载、补齐）时使用
+	DB() *sql.DB
+	Close() error
+}
+
+// isPostgresDSN -db 为 PostgreSQL 连接串（postgres:// 或 postgresql://）
+func isPostgresDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+// OpenKlineStore 按 -db 打开 K 线库：PostgreSQL 连接串或 SQLite 文件路径
+func OpenKlineStore(dsn string) (KlineStore, error) {
+	if isPostgresDSN(dsn) {
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.Ping(); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("connect postgres: %w", err)
+		}
+		return &postgresKlineStore{db: db}, nil
+	}
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteKlineStore{db: db}, nil
+}
+
+// requireSQLite 写入 K 线库的命令（下载、补齐）只支持 SQLite
+func requireSQLite(dsn, op string) error {
+	if isPostgresDSN(dsn) {
+		return fmt.Errorf("%s requires a SQLite database, not PostgreSQL", op)
+	}
+	return nil
+}
+
+// sqliteKlineStore SQLite 文件
+type sqliteKlineStore struct {
+	db *sql.DB
+}
+
+func (s *sqliteKlineStore) Query(query string, args ...any) (*sql.Rows, error) {
+	return s.db.Query(query, args...)
+}
+
+func (s *sqliteKlineStore) QueryRow(query string, args ...any) *sql.Row {
+	return s.db.QueryRow(query, args...)
+}
+
+func (s *sqliteKlineStore) Columns(table string) ([]string, error) {
+	rows, err := s.db.Query("PRAGMA table_info(" + quoteIdent(table) + ")")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var (
+			cid       int
+			name, typ string
+			notNull   int
+			dflt      sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s not found", table)
+	}
+	return columns, rows.Err()
+}
+
+func (s *sqliteKlineStore) Tables() ([]string, error) {
+	return queryStrings(s.db, "SELECT name FROM sqlite_master WHERE type = 'table'")
+}
+
+// ValueType SQLite 为动态类型，按第一行样本值的 typeof 判断
+func (s *sqliteKlineStore) ValueType(table, column string) (string, error) {
+	var typ string
+	err := s.db.QueryRow(fmt.Sprintf("SELECT typeof(%s) FROM %s LIMIT 1", quoteIdent(column), quoteIdent(table))).Scan(&typ)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return typ, err
+}
+
+func (s *sqliteKlineStore) DB() *sql.DB  { return s.db }
+func (s *sqliteKlineStore) Close() error { return s.db.Close() }
+
+// postgresKlineStore PostgreSQL，表在连接的 search_path 中查找（可在连接串中用 search_path 参数指定 schema）
+type postgresKlineStore struct {
+	db *sql.DB
+}
+
+// rebind 把 ? 占位符依次改写为 $1、$2……
+func (s *postgresKlineStore) rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (s *postgresKlineStore) Query(query string, args ...any) (*sql.Rows, error) {
+	return s.db.Query(s.rebind(query), args...)
+}
+
+func (s *postgresKlineStore) QueryRow(query string, args ...any) *sql.Row {
+	return s.db.QueryRow(s.rebind(query), args...)
+}
+
+func (s *postgresKlineStore) Columns(table string) ([]string, error) {
+	columns, err := queryStrings(s.db, `SELECT column_name FROM information_schema.columns
+		WHERE table_name = $1 AND table_schema = ANY(current_schemas(false)) ORDER BY ordinal_position`, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s not found", table)
+	}
+	return columns, nil
+}
+
+func (s *postgresKlineStore) Tables() ([]string, error) {
+	return queryStrings(s.db, `SELECT table_name FROM information_schema.tables
+		WHERE table_schema = ANY(current_schemas(false))`)
+}
+
+// ValueType PostgreSQL 为静态类型，按列的声明类型判断
+func (s *postgresKlineStore) ValueType(table, column string) (string, error) {
+	var typ string
+	err := s.db.QueryRow(`SELECT data_type FROM information_schema.columns
+		WHERE table_name = $1 AND column_name = $2 AND table_schema = ANY(current_schemas(false)) LIMIT 1`, table, column).Scan(&typ)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case typ == "smallint" || typ == "integer" || typ == "bigint":
+		return valueInteger, nil
+	case typ == "numeric" || typ == "real" || typ == "double precision":
+		return valueReal, nil
+	case strings.HasPrefix(typ, "timestamp"):
+		return valueTimestamp, nil
+	default:
+		return valueText, nil
+	}
+}
+
+func (s *postgresKlineStore) DB() *sql.DB  { return s.db }
+func (s *postgresKlineStore) Close() error { return s.db.Close() }
+
+// queryStrings 读取单列字符串结果
+func queryStrings(db *sql.DB, query string, args ...any) ([]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
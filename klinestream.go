@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// KlineSource 按时间顺序逐根提供 K 线，供回测引擎流式读取，内存占用与数据长度无关
+type KlineSource interface {
+	// Next 返回下一根 K 线，读完或出错时返回 false
+	Next() (Kline, bool)
+	// Err 读取过程中的错误
+	Err() error
+	Close() error
+}
+
+// sliceKlines 内存中的 K 线作为 KlineSource
+type sliceKlines struct {
+	klines []Kline
+	pos    int
+}
+
+// SliceKlines 把已加载的 K 线包装为 KlineSource
+func SliceKlines(klines []Kline) KlineSource {
+	return &sliceKlines{klines: klines}
+}
+
+func (s *sliceKlines) Next() (Kline, bool) {
+	if s.pos >= len(s.klines) {
+		return Kline{}, false
+	}
+	s.pos++
+	return s.klines[s.pos-1], true
+}
+
+func (s *sliceKlines) Err() error   { return nil }
+func (s *sliceKlines) Close() error { return nil }
+
+// defaultStreamChunk 流式读取时每次查询的 K 线根数
+const defaultStreamChunk = 50000
+
+// dbKlines 分块从 K 线库读取：按时间键分页（time > 上一块最后一根），每次最多 chunk 根；
+// 同一时间的重复 K 线若恰好跨块，只保留第一根
+type dbKlines struct {
+	store  KlineStore
+	schema KlineSchema
+	query  string
+	args   []any
+	chunk  int
+	buf    []Kline
+	pos    int
+	last   int64 // 已读取的最后一根 K 线在库中的原始时间值
+	read   bool  // 已读到过 K 线，之后的块从 last 之后开始
+	done   bool
+	err    error
+}
+
+// fill 读取下一块
+func (s *dbKlines) fill() {
+	s.buf, s.pos = s.buf[:0], 0
+	query, args := s.query, append([]any(nil), s.args...)
+	timeCol := quoteIdent(s.schema.Time)
+	if s.read {
+		query += " AND " + timeCol + " > ?"
+		args = append(args, s.schema.timeParam(s.last))
+	}
+	rows, err := s.store.Query(query+" ORDER BY "+timeCol+" LIMIT ?", append(args, s.chunk)...)
+	if err != nil {
+		s.err, s.done = err, true
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		k, raw, err := s.schema.scanKline(rows)
+		if err != nil {
+			s.err, s.done = err, true
+			return
+		}
+		s.buf = append(s.buf, k)
+		s.last, s.read = raw, true
+	}
+	if err := rows.Err(); err != nil {
+		s.err, s.done = err, true
+	}
+	if len(s.buf) < s.chunk {
+		s.done = true
+	}
+}
+
+func (s *dbKlines) Next() (Kline, bool) {
+	if s.pos >= len(s.buf) {
+		if s.done {
+			return Kline{}, false
+		}
+		s.fill()
+		if len(s.buf) == 0 {
+			return Kline{}, false
+		}
+	}
+	s.pos++
+	return s.buf[s.pos-1], true
+}
+
+func (s *dbKlines) Err() error   { return s.err }
+func (s *dbKlines) Close() error { return s.store.Close() }
+
+// resampledKlines 把源 K 线逐根合并为 interval 秒的 K 线，与 Resample 结果一致
+type resampledKlines struct {
+	src      KlineSource
+	interval int64
+	bar      Kline // 正在合并的 K 线
+	has      bool
+}
+
+func (r *resampledKlines) Next() (Kline, bool) {
+	for {
+		k, ok := r.src.Next()
+		if !ok {
+			if !r.has {
+				return Kline{}, false
+			}
+			r.has = false
+			return r.bar, true
+		}
+		start := k.Timestamp - k.Timestamp%r.interval
+		if r.has && r.bar.Timestamp == start {
+			r.bar.High = max(r.bar.High, k.High)
+			r.bar.Low = min(r.bar.Low, k.Low)
+			r.bar.Close = k.Close
+			r.bar.Volume += k.Volume
+			continue
+		}
+		done, had := r.bar, r.has
+		k.Timestamp = start
+		r.bar, r.has = k, true
+		if had {
+			return done, true
+		}
+	}
+}
+
+func (r *resampledKlines) Err() error   { return r.src.Err() }
+func (r *resampledKlines) Close() error { return r.src.Close() }
+
+// OpenKlineStream 分块流式读取 K 线库并重采样到 interval 秒（按第一块判断数据周期，规则同 loadIntervalKlines），
+// chunk 为每次查询的根数，0 表示默认值；调用方负责 Close
+func OpenKlineStream(dbPath, symbol string, startTime, endTime, interval int64, chunk int) (KlineSource, error) {
+	if chunk <= 0 {
+		chunk = defaultStreamChunk
+	}
+	store, err := OpenKlineStore(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := detectKlineSchema(store, klineSchemaConfig)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	query, args, err := schema.klineQuery(store, symbol, startTime, endTime)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	src := &dbKlines{store: store, schema: schema, query: query, args: args, chunk: chunk}
+	src.fill()
+	if src.err != nil {
+		store.Close()
+		return nil, src.err
+	}
+	source := sourceInterval(src.buf)
+	if source == 0 || source == interval || interval <= 0 {
+		return src, nil
+	}
+	if interval < source || interval%source != 0 {
+		store.Close()
+		return nil, fmt.Errorf("cannot resample %ds klines to %ds", source, interval)
+	}
+	log.Printf("%ds K 线流式重采样为 %ds K 线", source, interval)
+	return &resampledKlines{src: src, interval: interval}, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import "math"
+
+// liquidationFeeRate 强平清算费率（按强平时的名义价值）
+const liquidationFeeRate = 0.0125
+
+// MarginTier 维持保证金档位：持仓名义价值不超过 MaxNotional 时适用，
+// 维持保证金 = 名义价值 × Rate - Amount
+type MarginTier struct {
+	MaxNotional float64
+	Rate        float64
+	Amount      float64 // 速算额
+}
+
+// marginTiers 币安 U 本位合约维持保证金档位（近似值，以交易所当前档位为准）
+var marginTiers = map[string][]MarginTier{
+	"BTCUSDT": {
+		{50_000, 0.004, 0},
+		{600_000, 0.005, 50},
+		{3_000_000, 0.0065, 950},
+		{12_000_000, 0.01, 11_450},
+		{70_000_000, 0.025, 191_450},
+		{math.Inf(1), 0.05, 1_941_450},
+	},
+	"ETHUSDT": {
+		{50_000, 0.005, 0},
+		{600_000, 0.0065, 75},
+		{3_000_000, 0.01, 2_175},
+		{12_000_000, 0.02, 32_175},
+		{50_000_000, 0.05, 392_175},
+		{math.Inf(1), 0.1, 2_892_175},
+	},
+}
+
+// defaultMarginTiers 其他交易对使用的档位
+var defaultMarginTiers = []MarginTier{
+	{5_000, 0.01, 0},
+	{25_000, 0.025, 75},
+	{100_000, 0.05, 700},
+	{250_000, 0.1, 5_700},
+	{1_000_000, 0.125, 11_950},
+	{math.Inf(1), 0.25, 136_950},
+}
+
+// maintenanceTier 返回名义价值所在的维持保证金档位
+func maintenanceTier(symbol string, notional float64) MarginTier {
+	tiers, ok := marginTiers[symbol]
+	if !ok {
+		tiers = defaultMarginTiers
+	}
+	for _, t := range tiers {
+		if notional <= t.MaxNotional {
+			return t
+		}
+	}
+	return tiers[len(tiers)-1]
+}
+
+// liquidationPrice 逐仓强平价：保证金 + 未实现盈亏 = 维持保证金 时的价格
+func liquidationPrice(side string, amount, avgPrice, margin float64, tier MarginTier) float64 {
+	if amount <= 0 {
+		return 0
+	}
+	if side == "SHORT" {
+		return (margin + amount*avgPrice + tier.Amount) / (amount * (1 + tier.Rate))
+	}
+	return math.Max((amount*avgPrice-margin-tier.Amount)/(amount*(1-tier.Rate)), 0)
+}
+
+// liquidationFill 判断 K 线内是否触及强平价，返回成交价；跳空越过强平价时按开盘价
+func liquidationFill(side string, liqPrice float64, k Kline) (float64, bool) {
+	if liqPrice <= 0 {
+		return 0, false
+	}
+	if side == "SHORT" {
+		if k.High < liqPrice {
+			return 0, false
+		}
+		return math.Max(liqPrice, k.Open), true
+	}
+	if k.Low > liqPrice {
+		return 0, false
+	}
+	return math.Min(liqPrice, k.Open), true
+}
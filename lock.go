@@ -0,0 +1,246 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 实例锁类型
+const (
+	LockNone    = ""        // 不加锁
+	LockFile    = "file"    // 锁文件（同一主机或共享目录）
+	LockJournal = "journal" // 交易日志数据库中的锁表，需要 journal_path
+)
+
+// 锁租约：心跳间隔内续期，实例崩溃后租约过期即可被其他实例接管
+const (
+	lockTTL       = 90 * time.Second
+	lockHeartbeat = 30 * time.Second
+)
+
+// InstanceLock 防止两个实例同时交易同一交易对和账户
+type InstanceLock interface {
+	Acquire() error // 获取锁，已被其他实例持有且未过期时返回错误
+	Refresh() error // 续期，锁已被其他实例接管时返回错误
+	Release() error
+}
+
+// defaultInstanceID 主机名-进程号
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// lockKey 交易对 + 账户（API Key 摘要，不暴露 Key 本身）
+func lockKey(config *Config) string {
+	account := "nokey"
+	if config.ApiKey != "" {
+		sum := sha256.Sum256([]byte(config.ApiKey))
+		account = hex.EncodeToString(sum[:4])
+	}
+	return strings.ToLower(config.Symbol) + "-" + account
+}
+
+// NewInstanceLock 按配置创建实例锁，未配置时返回 nil
+func NewInstanceLock(config *Config, owner string, journal *Journal) (InstanceLock, error) {
+	key := lockKey(config)
+	switch config.Lock {
+	case LockNone:
+		return nil, nil
+	case LockFile:
+		dir := config.LockDir
+		if dir == "" {
+			dir = os.TempDir()
+		}
+		return &fileLock{path: filepath.Join(dir, "rsi-strat-"+key+".lock"), owner: owner}, nil
+	case LockJournal:
+		if journal == nil {
+			return nil, fmt.Errorf("journal lock requires journal_path")
+		}
+		return &journalLock{journal: journal, key: key, owner: owner}, nil
+	default:
+		return nil, fmt.Errorf("unknown lock type %q", config.Lock)
+	}
+}
+
+// lockRecord 锁文件内容
+type lockRecord struct {
+	Owner   string `json:"owner"`
+	Expires int64  `json:"expires"`
+}
+
+// fileLock 锁文件：以 O_EXCL 创建，过期的锁文件可被接管
+type fileLock struct {
+	path  string
+	owner string
+}
+
+func (l *fileLock) read() (*lockRecord, error) {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return nil, err
+	}
+	var rec lockRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("parse lock file %s: %w", l.path, err)
+	}
+	return &rec, nil
+}
+
+func (l *fileLock) write(flag int) error {
+	data, _ := json.Marshal(lockRecord{Owner: l.owner, Expires: time.Now().Add(lockTTL).Unix()})
+	f, err := os.OpenFile(l.path, flag, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (l *fileLock) Acquire() error {
+	err := l.write(os.O_WRONLY | os.O_CREATE | os.O_EXCL)
+	if !errors.Is(err, os.ErrExist) {
+		return err
+	}
+
+	rec, err := l.read()
+	if err != nil {
+		return err
+	}
+	if rec.Owner != l.owner && rec.Expires > time.Now().Unix() {
+		return fmt.Errorf("lock %s held by %s until %s", l.path, rec.Owner, time.Unix(rec.Expires, 0).Format("15:04:05"))
+	}
+	if rec.Owner != l.owner {
+		log.Printf("接管过期的实例锁 %s (原持有者 %s)", l.path, rec.Owner)
+	}
+	return l.write(os.O_WRONLY | os.O_CREATE | os.O_TRUNC)
+}
+
+func (l *fileLock) Refresh() error {
+	rec, err := l.read()
+	if err != nil {
+		return err
+	}
+	if rec.Owner != l.owner {
+		return fmt.Errorf("lock %s taken over by %s", l.path, rec.Owner)
+	}
+	return l.write(os.O_WRONLY | os.O_TRUNC)
+}
+
+func (l *fileLock) Release() error {
+	rec, err := l.read()
+	if err != nil || rec.Owner != l.owner {
+		return err
+	}
+	return os.Remove(l.path)
+}
+
+// journalLock 交易日志数据库中的锁：共享同一日志文件的实例互斥
+type journalLock struct {
+	journal *Journal
+	key     string
+	owner   string
+}
+
+func (l *journalLock) Acquire() error {
+	now := time.Now()
+	res, err := l.journal.db.Exec(
+		`INSERT INTO instance_locks (key, owner, expires) VALUES (?, ?, ?)
+		 ON CONFLICT(key) DO UPDATE SET owner = excluded.owner, expires = excluded.expires
+		 WHERE instance_locks.owner = excluded.owner OR instance_locks.expires < ?`,
+		l.key, l.owner, now.Add(lockTTL).Unix(), now.Unix(),
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 1 {
+		return err
+	}
+
+	var owner string
+	var expires int64
+	err = l.journal.db.QueryRow(`SELECT owner, expires FROM instance_locks WHERE key = ?`, l.key).Scan(&owner, &expires)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("lock %s held by %s until %s", l.key, owner, time.Unix(expires, 0).Format("15:04:05"))
+}
+
+func (l *journalLock) Refresh() error {
+	res, err := l.journal.db.Exec(
+		`UPDATE instance_locks SET expires = ? WHERE key = ? AND owner = ?`,
+		time.Now().Add(lockTTL).Unix(), l.key, l.owner,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 1 {
+		return err
+	}
+	return fmt.Errorf("lock %s taken over by another instance", l.key)
+}
+
+func (l *journalLock) Release() error {
+	_, err := l.journal.db.Exec(`DELETE FROM instance_locks WHERE key = ? AND owner = ?`, l.key, l.owner)
+	return err
+}
+
+// holdLock 定期续期实例锁；续期失败（锁被接管）时告警并停止策略
+func (s *Strategy) holdLock(done <-chan struct{}) {
+	ticker := time.NewTicker(lockHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.lock.Refresh(); err != nil {
+				msg := fmt.Sprintf("实例 %s 失去实例锁，停止策略: %v", s.instanceID, err)
+				log.Printf("[告警] %s", msg)
+				s.webhook.Alert("lock_lost", s.config.Symbol, msg)
+				s.haltErr = err
+				s.Stop()
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// acquireLock 获取实例锁并开始续期，未配置锁时直接返回
+func (s *Strategy) acquireLock() error {
+	if s.lock == nil {
+		return nil
+	}
+	if err := s.lock.Acquire(); err != nil {
+		return fmt.Errorf("acquire instance lock: %w", err)
+	}
+	log.Printf("实例 %s 已获取实例锁 (%s)", s.instanceID, lockKey(s.config))
+	s.lockDone = make(chan struct{})
+	go s.holdLock(s.lockDone)
+	return nil
+}
+
+// releaseLock 停止续期并释放实例锁
+func (s *Strategy) releaseLock() {
+	if s.lock == nil {
+		return
+	}
+	close(s.lockDone)
+	if err := s.lock.Release(); err != nil {
+		log.Printf("释放实例锁失败: %v", err)
+	}
+}
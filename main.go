@@ -2,11 +2,16 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -27,11 +32,96 @@ type Config struct {
 	EMA_FAST             int     `json:"ema_fast"`
 	EMA_SLOW             int     `json:"ema_slow"`
 	VOL_RATIO_THRESHOLD  float64 `json:"vol_ratio_threshold"`
+	// 策略引擎，为空使用 rsi
+	Engine string `json:"engine,omitempty"`
+	// K 线周期（如 1m、5m、1h），为空使用 5m；回测用 -interval 指定相同周期
+	Interval string `json:"interval,omitempty"`
 	// 交易参数
 	PositionSize float64 `json:"position_size"`
 	Leverage     int     `json:"leverage"`
+	// 交易所下单限制：数量步长、最小名义价值 (USDT)，0 时使用内置值（常用交易对）
+	QtyStep          float64 `json:"qty_step,omitempty"`
+	MinOrderNotional float64 `json:"min_order_notional,omitempty"`
+	// 遗留持仓处理：配置的交易对或引擎变更后，上次运行留下的持仓 manage（默认）继续管理到平仓 /
+	// flatten 启动时市价平掉
+	OrphanPolicy string `json:"orphan_policy,omitempty"`
+	// 周期内 panic 的处理：continue（默认）记录堆栈并告警后继续，连续 3 个周期 panic 时停止 / halt 立即停止
+	PanicPolicy string `json:"panic_policy,omitempty"`
+	// 启动时交易所上有未跟踪的持仓（手动开仓或状态丢失）：adopt（默认）按交易所数量和均价接管并按策略规则出场 /
+	// refuse 拒绝启动
+	ManualPosition string `json:"manual_position,omitempty"`
+	// 保证金预留：开仓前要求 可用余额 - 钱包余额 × margin_reserve 足以支付初始保证金，0 表示不预留
+	MarginReserve float64 `json:"margin_reserve,omitempty"`
+	// 交易所保护单：开仓 / 加仓后按持仓均价挂止损、止盈单（reduce-only 全平），0 表示不挂
+	StopLossPct   float64 `json:"stop_loss_pct,omitempty"`
+	TakeProfitPct float64 `json:"take_profit_pct,omitempty"`
+	// 移动止损和保本止损：止损价跟随持仓以来的最有利价格，收紧时重挂交易所止损单
+	Trailing TrailingConfig `json:"trailing,omitempty"`
+	// 分批建仓：各批仓位比例、加仓触发（策略信号 / 价格逆向或顺向移动）、批次间隔和总仓位上限，未配置时按策略默认
+	Pyramid PyramidConfig `json:"pyramid,omitempty"`
+	// 追价保护：下单时价格已朝信号方向偏离信号 K 线收盘价超过该值 (bps) 时放弃入场，0 表示不检查
+	MaxChaseBps float64 `json:"max_chase_bps,omitempty"`
+	// 维护时段（UTC）：如 ["22:00-06:00", "sun 00:00-02:00"]，时段内平仓并暂停交易
+	Maintenance []string `json:"maintenance,omitempty"`
+	// 数据源看门狗：超过 feed_stall_intervals 个 K 线周期（默认 2）没有新 K 线时暂停信号判定并告警，
+	// 重建交易所客户端并在交易所客户端和公开 REST 接口（feed_failover_url，默认币安合约 K 线接口）之间切换
+	FeedStallIntervals float64 `json:"feed_stall_intervals,omitempty"`
+	FeedFailoverURL    string  `json:"feed_failover_url,omitempty"`
+	// 数据核对：每周期将最新 K 线收盘价与第二数据源（index 指数价格 / spot 现货价格）比较，
+	// 偏离超过 max_divergence_bps 时暂停交易
+	ReferenceSource  string  `json:"reference_source,omitempty"`
+	MaxDivergenceBps float64 `json:"max_divergence_bps,omitempty"`
+	// 基差监控：每周期打印指数 / 标记 / 最新价并记录到交易日志；max_basis_bps 大于 0 时
+	// 永续溢价超过该值不开多、折价超过不开空（同时启用监控）
+	BasisMonitor bool    `json:"basis_monitor,omitempty"`
+	MaxBasisBps  float64 `json:"max_basis_bps,omitempty"`
 	// 运行参数
 	DryRun bool `json:"dry_run"`
+	// 自适应仓位（反马丁格尔），默认固定比例
+	Sizing SizingConfig `json:"sizing"`
+	// 滑点保护：预估滑点超过上限 (bps) 时放弃入场 (abort) 或改限价 (limit)，0 表示不检查
+	MaxSlippageBps float64 `json:"max_slippage_bps"`
+	SlippageAction string  `json:"slippage_action,omitempty"`
+	// 只用已收盘的 K 线生成信号（丢弃最新一根未走完的 K 线），默认包含未走完的 K 线
+	ClosedCandlesOnly bool `json:"closed_candles_only"`
+	// 每根 K 线输出信号各条件的判定明细
+	ExplainSignals bool `json:"explain_signals"`
+	// 入场频率限制（每小时 / 每天），防止震荡行情中反复开仓消耗手续费
+	Throttle ThrottleConfig `json:"throttle"`
+	// 行情异常检测：价格跳变、异常放量、零成交量 K 线告警，可选暂停交易
+	Anomaly AnomalyConfig `json:"anomaly,omitempty"`
+	// 资金保护：账户权益从峰值回撤超过该比例时切换为只发信号，0 表示不启用
+	PreserveDrawdown float64 `json:"preserve_drawdown"`
+	// 净值曲线过滤：策略净值低于最近 N 笔的均线时新开仓只虚拟跟踪，回到均线之上后恢复实盘
+	EquityFilter EquityFilterConfig `json:"equity_filter,omitempty"`
+	// 风控：当天亏损、持仓上限、连续亏损和回撤熔断，实盘和 dry-run 的入场都经过检查
+	Risk RiskConfig `json:"risk,omitempty"`
+	// dry-run 模拟成交：按实盘日志中实测的分布（或配置的分布）施加随机延迟和成交价偏离
+	PaperFill PaperFillConfig `json:"paper_fill,omitempty"`
+	// 波动率目标：按标的波动率定期调整交易所杠杆，使持仓年化波动率接近目标，空仓时生效
+	VolTarget VolTargetConfig `json:"vol_target,omitempty"`
+	// 控制接口监听地址（恢复实盘等），需设置环境变量 CONTROL_TOKEN
+	ControlAddr string `json:"control_addr,omitempty"`
+	// 资金漂移告警：实际权益与日志预期盈亏相差超过该值 (USDT) 时告警，0 表示不检查
+	DriftAlertUSDT float64 `json:"drift_alert_usdt"`
+	// 手续费预算：每天预计手续费上限 (USDT)，实盘启动前按最近行情预估，超出时拒绝启动，0 表示不检查
+	MaxDailyFees float64 `json:"max_daily_fees,omitempty"`
+	// 交易日志（SQLite），为空则不记录；同时保存 clientOrderId 用于重启后防重复下单
+	JournalPath string `json:"journal_path,omitempty"`
+	// 运行状态（SQLite）：持仓批次、入场时间、仓位系数、权益峰值等，每次变化后写入，重启后恢复
+	StatePath string `json:"state_path,omitempty"`
+	// 实例标识（为空时为 主机名-进程号）和实例锁：file 锁文件 / journal 交易日志数据库，防止两个实例交易同一交易对和账户
+	InstanceID string `json:"instance_id,omitempty"`
+	Lock       string `json:"lock,omitempty"`
+	LockDir    string `json:"lock_dir,omitempty"` // file 锁的目录，默认系统临时目录
+	// 通知
+	Webhooks []WebhookConfig `json:"webhooks,omitempty"`
+	// 时序数据库导出（InfluxDB 写入地址，Token 取自 INFLUX_TOKEN）
+	InfluxURL string `json:"influx_url,omitempty"`
+	// 跟单
+	PublishAddr       string  `json:"publish_addr,omitempty"`        // 信号广播监听地址，如 ":8686"
+	FollowScale       float64 `json:"follow_scale,omitempty"`        // 跟单仓位缩放系数
+	FollowMaxPosition float64 `json:"follow_max_position,omitempty"` // 跟单最大仓位比例
 }
 
 // DefaultConfig 默认配置（短线投机，5倍杠杆）
@@ -50,6 +140,21 @@ var defaultConfig = Config{
 	DryRun:               true,
 }
 
+// symbolFilter 当前交易对的下单限制，配置优先于内置值
+func (c *Config) symbolFilter() SymbolFilter {
+	filter, ok := knownSymbolFilters[c.Symbol]
+	if !ok {
+		filter.MinNotional = defaultMinNotional
+	}
+	if c.QtyStep > 0 {
+		filter.StepSize = c.QtyStep
+	}
+	if c.MinOrderNotional > 0 {
+		filter.MinNotional = c.MinOrderNotional
+	}
+	return filter
+}
+
 // LoadConfig 加载配置
 func LoadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
@@ -65,13 +170,28 @@ func LoadConfig(path string) (*Config, error) {
 	return &config, nil
 }
 
-// SaveConfig 保存配置
+// SaveConfig 保存配置；配置中含 API 密钥，文件只允许属主读写
 func SaveConfig(path string, config *Config) error {
 	data, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0644)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	// WriteFile 不改变已有文件的权限
+	return os.Chmod(path, 0600)
+}
+
+// flagPassed 命令行是否显式设置了该参数（未设置时以配置文件为准）
+func flagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
 }
 
 // Strategy 策略实例
@@ -80,12 +200,199 @@ type Strategy struct {
 	client  *binance.BinFuture
 	klines  []Kline
 	running bool
+	webhook *WebhookNotifier
+	hub      *SignalHub
+	recorder *SessionRecorder // 行情录制，nil 表示不录制
+	sizer    *AdaptiveSizer
+	influx   *InfluxExporter
+	journal  *Journal
+	throttle *EntryThrottle
+	engine   StrategyEngine
+	lastFed  int64 // 已送入引擎的最新 K 线时间戳
+	// 当前持仓（用于引擎决策、自适应仓位和预期盈亏）
+	entrySide     string
+	entryPrice    float64 // 持仓均价
+	entryAmount   float64
+	entryCount    int     // 已入场批次数
+	entryTime     int64   // 第一批入场时的 K 线时间
+	entryExposure float64 // 各批仓位比例之和
+	// 分批建仓：上一批入场时的 K 线时间和成交价，用于批次间隔和价格触发的加仓
+	batchTime  int64
+	batchPrice float64
+	stopPct     float64 // ATR 仓位模式下开仓时确定的止损距离，0 表示按 stop_loss_pct
+	// 移动止损：持仓以来的最有利价格和当前的移动止损价（0 表示未启动）
+	trailExtreme float64
+	trailStop    float64
+	drift       *DriftMonitor
+	// 错误处理：限频后暂停到该时间；不可恢复的错误使 Run 退出
+	pausedUntil time.Time
+	haltErr     error
+	panics      int // 连续发生 panic 的周期数
+	// 资金保护：回撤超限后只发信号，经控制接口确认后恢复
+	peakEquity float64
+	preserving atomic.Bool
+	// 净值曲线过滤（nil 表示不启用）；shadow 为当前持仓是过滤暂停期间开的虚拟持仓
+	equityFilter *EquityFilter
+	risk         *RiskManager // 风控，nil 表示不启用
+	paperFill    *PaperFill   // dry-run 模拟成交，nil 表示按收盘价立即成交
+	shadow       bool
+	paper        bool // 当前持仓开仓时处于资金保护模式，只模拟跟踪
+	// 波动率目标（nil 表示不启用），生效的杠杆用于仓位和保证金计算
+	volTarget *VolTarget
+	resetPeak  atomic.Bool
+	warmedUp   bool // 已有足够的连续 K 线
+	// 停止：Stop 关闭 quit 使 Run 立即返回；每周期结束发布状态快照供控制接口读取
+	quit     chan struct{}
+	stopOnce sync.Once
+	snapshot atomic.Pointer[StrategySnapshot]
+	// 实例标识与实例锁（nil 表示不加锁）
+	instanceID string
+	lock       InstanceLock
+	lockDone   chan struct{} // 关闭后停止续期
+	// 维护时段：时段内平仓并暂停交易
+	maintenance   MaintenanceSchedule
+	inMaintenance bool
+	// 数据核对：参考价格源（nil 表示不核对），diverged 为当前处于偏离暂停中
+	refPrice *ReferencePrice
+	diverged bool
+	// 行情异常：anomalyChecked 为最近检查过的 K 线时间，anomalyPausedUntil 之前暂停交易
+	anomalyChecked     int64
+	anomalyPausedUntil time.Time
+	feed *FeedWatchdog // 数据源看门狗
+	basis    *BasisMonitor // 基差监控，nil 表示不监控
+	state    *StateStore // 运行状态持久化，nil 表示不保存
+	// 交易对 / 引擎变更前遗留的持仓：manage 策略下每周期确认是否已平；priorEngine 为持仓所属的旧引擎
+	orphans     []orphanPosition
+	priorEngine string
+	interval    int64 // K 线周期（秒）
+	cycle       time.Duration // 轮询周期，0 表示 defaultCycle
 }
 
+// defaultCycle 实盘轮询周期
+const defaultCycle = 5 * time.Minute
+
 // NewStrategy 创建策略实例
 func NewStrategy(config *Config) (*Strategy, error) {
 	s := &Strategy{
-		config: config,
+		config:  config,
+		webhook: NewWebhookNotifier(config.Webhooks),
+		sizer:   NewAdaptiveSizer(config.Sizing),
+		throttle: NewEntryThrottle(config.Throttle),
+		equityFilter: NewEquityFilter(config.EquityFilter),
+		quit:     make(chan struct{}),
+		influx: NewInfluxExporter(config.InfluxURL, map[string]string{
+			"strategy": "rsi",
+			"symbol":   config.Symbol,
+			"mode":     "live",
+		}),
+	}
+
+	engine, err := NewEngine(config.Engine, config)
+	if err != nil {
+		return nil, err
+	}
+	s.engine = engine
+
+	if config.JournalPath != "" {
+		journal, err := OpenJournal(config.JournalPath)
+		if err != nil {
+			return nil, fmt.Errorf("open journal: %w", err)
+		}
+		s.journal = journal
+		if err := s.seedThrottle(); err != nil {
+			return nil, fmt.Errorf("load journal: %w", err)
+		}
+	}
+
+	s.maintenance, err = ParseMaintenanceSchedule(config.Maintenance)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidOrphanPolicy(config.OrphanPolicy); err != nil {
+		return nil, err
+	}
+	if err := ValidPanicPolicy(config.PanicPolicy); err != nil {
+		return nil, err
+	}
+	if err := ValidManualPosition(config.ManualPosition); err != nil {
+		return nil, err
+	}
+	s.interval, err = ParseInterval(config.Interval)
+	if err != nil {
+		return nil, err
+	}
+	if config.FeedStallIntervals < 0 {
+		return nil, fmt.Errorf("feed_stall_intervals must be non-negative")
+	}
+	s.feed = NewFeedWatchdog(s.interval, config.FeedStallIntervals, time.Now())
+	if config.Interval == "" {
+		config.Interval = DefaultInterval
+	}
+	if err := config.Sizing.ValidateUnit(); err != nil {
+		return nil, err
+	}
+	if err := config.Anomaly.Validate(); err != nil {
+		return nil, err
+	}
+	if err := config.EquityFilter.Validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Risk.Validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Trailing.Validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Pyramid.Validate(); err != nil {
+		return nil, err
+	}
+	s.risk = NewRiskManager(config.Risk)
+	if err := config.PaperFill.Validate(); err != nil {
+		return nil, err
+	}
+	s.paperFill, err = NewPaperFill(config.PaperFill, config.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	if n := s.paperFill.Samples(); n > 0 {
+		log.Printf("dry-run 模拟成交: 从 %s 读取 %d 个实测样本", config.PaperFill.Journal, n)
+	}
+	if err := config.VolTarget.Validate(); err != nil {
+		return nil, err
+	}
+	s.volTarget = NewVolTarget(config.VolTarget, max(config.Leverage, 1), config.PositionSize, s.interval)
+
+	if config.StatePath != "" {
+		s.state, err = OpenStateStore(config.StatePath)
+		if err != nil {
+			return nil, fmt.Errorf("open state store: %w", err)
+		}
+		if err := s.restoreState(); err != nil {
+			return nil, fmt.Errorf("restore state: %w", err)
+		}
+	}
+
+	if config.MaxDivergenceBps > 0 {
+		if config.ReferenceSource == "" {
+			return nil, fmt.Errorf("max_divergence_bps requires reference_source")
+		}
+		s.refPrice, err = NewReferencePrice(config.ReferenceSource)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.BasisMonitor || config.MaxBasisBps > 0 {
+		s.basis = NewBasisMonitor()
+	}
+
+	s.instanceID = config.InstanceID
+	if s.instanceID == "" {
+		s.instanceID = defaultInstanceID()
+	}
+	s.lock, err = NewInstanceLock(config, s.instanceID, s.journal)
+	if err != nil {
+		return nil, err
 	}
 
 	// 如果有 API Key，初始化客户端
@@ -105,233 +412,1409 @@ func (s *Strategy) fetchKlines() error {
 		return fmt.Errorf("client not initialized")
 	}
 
-	// 获取最近 100 根 5m K 线
-	klines, err := s.client.FutureKline(s.config.Symbol, "5m", 0, 0, 100)
-	if err != nil {
-		return err
+	// 获取最近 100 根 K 线（指标周期较长时多取，保证能完成预热）
+	limit := max(100, s.engine.WarmupBars(), s.volTarget.Lookback()+1)
+	var klines []Kline
+	if s.feed.Failover {
+		var err error
+		if klines, err = s.fetchFailoverKlines(limit); err != nil {
+			return err
+		}
+	} else {
+		raw, err := s.client.FutureKline(s.config.Symbol, s.config.Interval, 0, 0, limit)
+		if err != nil {
+			return wrapExchangeError("klines", err)
+		}
+		for _, k := range raw {
+			klines = append(klines, Kline{
+				Timestamp: k.Timestamp,
+				Open:      k.Open,
+				High:      k.High,
+				Low:       k.Low,
+				Close:     k.Close,
+				Volume:    k.Amount,
+			})
+		}
 	}
 
 	s.klines = nil
 	for _, k := range klines {
-		s.klines = append(s.klines, Kline{
-			Timestamp: k.Timestamp,
-			Open:      k.Open,
-			High:      k.High,
-			Low:       k.Low,
-			Close:     k.Close,
-			Volume:    k.Amount,
-		})
+		if s.config.ClosedCandlesOnly && k.Timestamp+s.interval > time.Now().Unix() {
+			continue
+		}
+		s.klines = append(s.klines, k)
 	}
+	s.recorder.Record(SessionRecord{Type: RecordKlines, Symbol: s.config.Symbol, Klines: s.klines})
 
 	return nil
 }
 
-// executeSignal 执行交易信号
-func (s *Strategy) executeSignal(signal Signal) error {
-	if s.client == nil || s.config.DryRun {
+// executeOrder 按引擎给出的仓位比例和自适应系数执行指令，返回值同 executeSignalSized
+func (s *Strategy) executeOrder(order Order, size float64) (bool, float64, error) {
+	return s.executeSignalSized(order.Signal, size*s.sizer.Multiplier())
+}
+
+// orderApplies 与回测相同的执行规则：持仓期间忽略反向开仓，加仓批次须与已有批次衔接
+func (s *Strategy) orderApplies(order Order) bool {
+	if order.Signal != SignalLong && order.Signal != SignalShort {
+		return true
+	}
+	side := "LONG"
+	if order.Signal == SignalShort {
+		side = "SHORT"
+	}
+	if s.entrySide != "" && s.entrySide != side {
+		return false
+	}
+	return order.Batch < 2 || s.entryCount == order.Batch-1
+}
+
+// pyramidView 分批建仓判断加仓用的当前持仓；旧版本状态文件没有上一批入场价时按持仓均价
+func (s *Strategy) pyramidView() pyramidPosition {
+	if s.entrySide == "" {
+		return pyramidPosition{}
+	}
+	p := pyramidPosition{side: s.entrySide, entries: s.entryCount, lastTime: s.batchTime, lastPrice: s.batchPrice}
+	if p.lastPrice <= 0 {
+		p.lastPrice = s.entryPrice
+	}
+	return p
+}
+
+// positionView 引擎决策用的当前持仓，仓位占比按当前价格折算
+func (s *Strategy) positionView(price float64) PositionView {
+	if s.entrySide == "" {
+		return PositionView{}
+	}
+	v := PositionView{
+		Side:      s.entrySide,
+		Entries:   s.entryCount,
+		EntryTime: s.entryTime,
+		AvgPrice:  s.entryPrice,
+		Exposure:  s.entryExposure,
+	}
+	if s.entryPrice > 0 {
+		v.Exposure *= price / s.entryPrice
+	}
+	return v
+}
+
+// feedEngine 将新 K 线送入策略引擎，只返回最新一根产生的指令（更早的是历史，不再执行）
+func (s *Strategy) feedEngine() []Order {
+	var orders []Order
+	for _, k := range s.klines {
+		if k.Timestamp < s.lastFed {
+			continue
+		}
+		orders = s.engine.OnKline(k, s.positionView(k.Close))
+		s.lastFed = k.Timestamp
+	}
+	return orders
+}
+
+// executeSignalSized 按指定仓位比例执行交易信号，返回持仓是否发生变化和成交价（dry-run 为模拟成交价）；
+// 被风控、限频、基差、追价或保证金检查跳过，以及下单失败时持仓不变
+func (s *Strategy) executeSignalSized(signal Signal, positionSize float64) (bool, float64, error) {
+	isEntry := signal == SignalLong || signal == SignalShort
+	if isEntry {
+		if reason := s.risk.Check(s.entryExposure, positionSize); reason != "" {
+			log.Printf("[风控] 跳过信号 %v: %s", signal, reason)
+			return false, 0, nil
+		}
+	}
+	if isEntry && !s.allowEntry() {
+		log.Printf("入场次数已达上限，跳过信号: %v", signal)
+		return false, 0, nil
+	}
+	if isEntry && s.basisStretched(signal) {
+		return false, 0, nil
+	}
+	// 净值曲线过滤：空仓时按当前净值决定新持仓走实盘还是只虚拟跟踪，加仓沿用持仓的状态
+	if isEntry && s.entrySide == "" {
+		s.shadow = !s.equityFilter.Allow()
+		if s.shadow {
+			log.Printf("[净值过滤] 策略净值低于 %d 笔均线，本次持仓只虚拟跟踪", s.config.EquityFilter.MATrades)
+		}
+		s.paper = s.preserving.Load()
+	}
+	// 资金保护只拦截新的实盘入场：实盘持仓不再加仓，平仓照常在交易所执行
+	if isEntry && s.entrySide != "" && !s.paperPosition() && s.preserving.Load() {
+		log.Printf("[资金保护] 只发信号期间不对实盘持仓加仓，跳过信号: %v", signal)
+		return false, 0, nil
+	}
+
+	if s.paperPosition() {
 		log.Printf("[DRY-RUN] Signal: %v", signal)
-		return nil
+		if isEntry {
+			s.throttle.Record(time.Now().Unix())
+		}
+		if len(s.klines) == 0 {
+			return false, 0, nil
+		}
+		// 按收盘价（启用模拟成交时为延迟后含偏离的价格）跟踪模拟持仓，引擎的加仓和出场判定与实盘一致
+		price := s.paperPrice(signal, s.klines[len(s.klines)-1].Close)
+		s.trackPosition(signal, price, 0, positionSize)
+		s.notifySignal(signal, price, 0, 0, true)
+		return true, price, nil
 	}
 
-	// 获取当前价格
+	// 获取当前价格；信号收盘价和下单耗时写入交易日志，供 dry-run 模拟成交使用
+	start := time.Now()
+	signalPrice := 0.0
+	if len(s.klines) > 0 {
+		signalPrice = s.klines[len(s.klines)-1].Close
+	}
 	ticker, err := s.client.FutureTicker(s.config.Symbol)
 	if err != nil {
-		return err
+		return false, 0, wrapExchangeError("ticker", err)
 	}
+	s.recorder.Record(SessionRecord{Type: RecordTicker, Symbol: s.config.Symbol, Price: ticker.Price})
 
-	// 获取账户余额
-	account, err := s.client.FutureGetAccount()
+	var amount, notional float64
+	fillPrice := ticker.Price
+	switch signal {
+	case SignalLong, SignalShort:
+		if s.missedEntry(signal, ticker.Price) {
+			return false, 0, nil
+		}
+		amount, notional, err = s.entrySize(ticker.Price, positionSize)
+		if err != nil {
+			return false, 0, err
+		}
+		if amount <= 0 {
+			log.Printf("跳过信号: %v", signal)
+			return false, 0, nil
+		}
+		// 限价单部分成交时按成交数量折算仓位比例
+		requested := amount
+		amount, fillPrice, err = s.openPosition(signal, ticker.Price, amount, notional)
+		if amount > 0 && amount < requested {
+			positionSize *= amount / requested
+		}
+		notional = amount * fillPrice
+	case SignalCloseLong, SignalCloseShort:
+		amount, err = s.closePosition(signal, ticker.Price)
+		notional = amount * ticker.Price
+		if err != nil && amount > 0 {
+			// 只平掉了一部分：剩余数量继续跟踪，下次平仓信号再平
+			s.entryAmount = max(s.entryAmount-amount, 0)
+			s.saveState()
+			s.notifyPartialClose(ticker.Price, amount)
+		}
+	default:
+		return false, 0, nil
+	}
+	if err == errEntryAborted {
+		return false, 0, nil
+	}
 	if err != nil {
-		return err
+		return false, 0, err
 	}
 
+	if isEntry {
+		s.throttle.Record(time.Now().Unix())
+	}
+	s.recordSignal(signal, fillPrice, amount, notional, signalPrice, time.Since(start))
+	s.trackPosition(signal, fillPrice, amount, positionSize)
+	s.notifySignal(signal, fillPrice, amount, notional, false)
+	// 持仓变化后按新均价重挂保护单，平仓后撤销
+	if isEntry {
+		s.placeProtection()
+	} else {
+		s.cancelProtection()
+	}
+	return true, fillPrice, nil
+}
+
+// entrySize 按账户余额计算开仓数量：余额 × 仓位比例 × 杠杆（固定数量模式按 sizing.quantity），数量按步长向下取整；
+// 取整后低于最小名义价值，或可用余额扣除预留后不足以支付初始保证金时返回 0
+func (s *Strategy) entrySize(price, positionSize float64) (amount, notional float64, err error) {
+	account, err := s.client.FutureGetAccount()
+	if err != nil {
+		return 0, 0, wrapExchangeError("account", err)
+	}
 	asset, err := account.GetAsset("USDT")
 	if err != nil {
-		return err
+		return 0, 0, wrapExchangeError("account", err)
+	}
+	if asset == nil {
+		return 0, 0, fmt.Errorf("USDT asset not found")
+	}
+	balance, err := strconv.ParseFloat(asset.WalletBalance, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("parse USDT balance %q: %w", asset.WalletBalance, err)
 	}
 
-	// 计算仓位大小
-	balance := 0.0
-	if asset != nil {
-		balance = float64(0)
-		// 解析余额字符串
+	leverage := s.leverage()
+	filter := s.config.symbolFilter()
+	if qty := s.config.Sizing.FixedAmount(positionSize, s.config.PositionSize); qty > 0 {
+		amount = filter.RoundQty(qty)
+	} else if s.config.Sizing.Unit == UnitATR {
+		atr := s.currentATR()
+		if atr <= 0 {
+			log.Printf("K 线不足 %d 根，ATR 未就绪，跳过入场", s.config.Sizing.atrPeriod())
+			return 0, 0, nil
+		}
+		amount = filter.RoundQty(s.config.Sizing.ATRAmount(balance, positionSize, s.config.PositionSize, atr, price))
+	} else {
+		notional = s.config.Sizing.ClampNotional(balance * positionSize * leverage)
+		amount = filter.RoundQty(notional / price)
+	}
+	notional = amount * price
+	if notional < filter.MinNotional {
+		log.Printf("下单金额 %.2f USDT 低于最小名义价值 %.2f USDT（余额 %.2f）",
+			notional, filter.MinNotional, balance)
+		return 0, 0, nil
 	}
 
-	notional := balance * s.config.PositionSize
-	amount := notional / ticker.Price
+	// 下单前核对保证金：初始保证金 + 开仓手续费 不超过 可用余额 - 预留，避免被交易所拒单
+	available, err := strconv.ParseFloat(asset.AvailableBalance, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("parse USDT available balance %q: %w", asset.AvailableBalance, err)
+	}
+	required := notional/leverage + notional*liveFeeRate
+	reserve := balance * s.config.MarginReserve
+	if required > available-reserve {
+		log.Printf("保证金不足，跳过入场: 名义价值 %.2f USDT 需要保证金 %.2f USDT（%.0fx，含手续费），可用 %.2f USDT，预留 %.2f USDT",
+			notional, required, leverage, available, reserve)
+		return 0, 0, nil
+	}
+	return amount, notional, nil
+}
 
+// recordSignal 将已执行的信号写入交易日志（平仓按跟踪的开仓价计算预期盈亏）
+func (s *Strategy) recordSignal(signal Signal, price, amount, notional, signalPrice float64, latency time.Duration) {
+	entry := JournalEntry{
+		Symbol:      s.config.Symbol,
+		Strategy:    s.engine.Name(),
+		Price:       price,
+		Amount:      amount,
+		Notional:    notional,
+		Fee:         notional * liveFeeRate,
+		Basis:       s.basisBps(),
+		SignalPrice: signalPrice,
+		LatencyMs:   latency.Milliseconds(),
+	}
 	switch signal {
 	case SignalLong:
-		log.Printf("开多仓: %.4f @ %.2f", amount, ticker.Price)
-		_, err = s.client.FutureOpenLongMarket(s.config.Symbol, notional)
+		entry.Event, entry.Side = JournalOpen, "LONG"
 	case SignalShort:
-		log.Printf("开空仓: %.4f @ %.2f", amount, ticker.Price)
-		_, err = s.client.FutureOpenShortMarket(s.config.Symbol, notional)
+		entry.Event, entry.Side = JournalOpen, "SHORT"
 	case SignalCloseLong:
-		log.Printf("平多仓")
-		// 需要查询当前持仓
+		entry.Event, entry.Side = JournalClose, "LONG"
 	case SignalCloseShort:
-		log.Printf("平空仓")
-		// 需要查询当前持仓
+		entry.Event, entry.Side = JournalClose, "SHORT"
+	default:
+		return
+	}
+	if entry.Event == JournalClose && s.entryPrice > 0 {
+		entry.Amount = s.entryAmount
+		entry.Notional = price * s.entryAmount
+		entry.Fee = entry.Notional * liveFeeRate
+		entry.PnL = (price - s.entryPrice) * s.entryAmount
+		if s.entrySide == "SHORT" {
+			entry.PnL = -entry.PnL
+		}
+	}
+	if err := s.journal.Record(entry); err != nil {
+		log.Printf("写入交易日志失败: %v", err)
+	}
+}
+
+// trackPosition 记录持仓均价、数量和批次，平仓时将盈亏方向反馈给自适应仓位
+func (s *Strategy) trackPosition(signal Signal, price, amount, size float64) {
+	switch signal {
+	case SignalLong, SignalShort:
+		side := "LONG"
+		if signal == SignalShort {
+			side = "SHORT"
+		}
+		if s.entrySide != side {
+			s.entrySide, s.entryPrice, s.entryAmount, s.entryCount, s.entryExposure = side, 0, 0, 0, 0
+			if len(s.klines) > 0 {
+				s.entryTime = s.klines[len(s.klines)-1].Timestamp
+			}
+			s.stopPct = s.config.Sizing.ATRStopPct(s.currentATR(), price)
+			s.trailExtreme, s.trailStop = price, 0
+		}
+		if total := s.entryAmount + amount; total > 0 {
+			s.entryPrice = (s.entryPrice*s.entryAmount + price*amount) / total
+		} else if s.entryPrice == 0 {
+			s.entryPrice = price
+		}
+		s.entryAmount += amount
+		s.entryCount++
+		s.entryExposure += size
+		s.batchPrice = price
+		if len(s.klines) > 0 {
+			s.batchTime = s.klines[len(s.klines)-1].Timestamp
+		}
+	case SignalCloseLong, SignalCloseShort:
+		if s.entryPrice > 0 {
+			pnl := price - s.entryPrice
+			if s.entrySide == "SHORT" {
+				pnl = -pnl
+			}
+			if !s.shadow {
+				s.sizer.Record(pnl)
+				log.Printf("仓位系数: %.2f", s.sizer.Multiplier())
+				s.recordRisk(s.positionReturn(price, true))
+			}
+			s.recordEquityFilter(pnl/s.entryPrice - 2*liveFeeRate)
+		}
+		s.resetPosition()
 	}
+	s.saveState()
+}
+
+// resetPosition 清除跟踪的持仓
+func (s *Strategy) resetPosition() {
+	s.entrySide, s.entryPrice, s.entryAmount, s.entryCount, s.entryExposure = "", 0, 0, 0, 0
+	s.batchTime, s.batchPrice = 0, 0
+	s.shadow, s.paper = false, false
+	s.stopPct = 0
+	s.trailExtreme, s.trailStop = 0, 0
+}
+
+// dropDustPosition 跟踪的持仓不足一个步长或低于最小名义价值时视为碎仓并清除，
+// 避免引擎一直认为有持仓而停止入场
+func (s *Strategy) dropDustPosition() {
+	if s.entrySide == "" || len(s.klines) == 0 {
+		return
+	}
+	price := s.klines[len(s.klines)-1].Close
+	if !s.config.symbolFilter().IsDust(s.entryAmount, price) {
+		return
+	}
+	log.Printf("清除碎仓: %s %.6f (%.2f USDT)", s.entrySide, s.entryAmount, s.entryAmount*price)
+	s.resetPosition()
+	s.saveState()
+	s.cancelProtection()
+}
 
-	return err
+// notifyPartialClose 持仓减少但未平完（平仓未全部成交、交易所上被部分平掉）时推送 position_partial_close，amount 为减少的数量
+func (s *Strategy) notifyPartialClose(price, amount float64) {
+	s.webhook.Notify(PositionEvent{
+		Event:    EventPositionPartialClose,
+		Symbol:   s.config.Symbol,
+		Side:     s.entrySide,
+		Price:    price,
+		Amount:   amount,
+		Notional: price * amount,
+		DryRun:   s.paperPosition(),
+	})
+}
+
+// notifySignal 将已执行的信号作为仓位事件推送给 webhook，paper 为模拟成交；平仓事件只在确认持仓归零后推送
+func (s *Strategy) notifySignal(signal Signal, price, amount, notional float64, paper bool) {
+	event := PositionEvent{
+		Symbol:   s.config.Symbol,
+		Price:    price,
+		Amount:   amount,
+		Notional: notional,
+		DryRun:   paper,
+	}
+	switch signal {
+	case SignalLong:
+		event.Event, event.Side = EventPositionOpen, "LONG"
+	case SignalShort:
+		event.Event, event.Side = EventPositionOpen, "SHORT"
+	case SignalCloseLong:
+		event.Event, event.Side = EventPositionClose, "LONG"
+	case SignalCloseShort:
+		event.Event, event.Side = EventPositionClose, "SHORT"
+	default:
+		return
+	}
+	s.webhook.Notify(event)
 }
 
 // Run 运行策略
 func (s *Strategy) Run() error {
+	if err := s.acquireLock(); err != nil {
+		return err
+	}
+	defer s.releaseLock()
+	defer s.webhook.Flush(webhookFlushTimeout)
+
 	s.running = true
-	ticker := time.NewTicker(5 * time.Minute)
+	cycle := s.cycle
+	if cycle <= 0 {
+		cycle = defaultCycle
+	}
+	ticker := time.NewTicker(cycle)
 	defer ticker.Stop()
 
+	// 确认上次运行中未确认的订单
+	if err := s.reconcilePendingOrders(); err != nil {
+		log.Printf("核对未确认订单失败: %v", err)
+	}
+
 	// 首次获取数据
 	if err := s.fetchKlines(); err != nil {
 		return err
 	}
 
+	// 恢复已有持仓，重启后继续由引擎判断出场；未跟踪的持仓按 manual_position 接管或拒绝启动
+	if err := s.syncPosition(); err != nil {
+		if errors.Is(err, errUntrackedPosition) {
+			return err
+		}
+		log.Printf("查询交易所持仓失败: %v", err)
+	}
+
+	// 处理交易对或引擎变更前遗留的持仓
+	s.reconcileOrphans()
+
+	if s.config.DriftAlertUSDT > 0 {
+		if err := s.startDriftMonitor(); err != nil {
+			log.Printf("启动资金漂移监控失败: %v", err)
+		}
+	}
+
 	log.Printf("策略启动，监控 %s", s.config.Symbol)
+	s.publishSnapshot()
+
+	if s.config.ControlAddr != "" {
+		go s.serveControl(s.config.ControlAddr)
+	}
+
+	if s.config.PublishAddr != "" {
+		s.hub = NewSignalHub()
+		go s.hub.ListenAndServe(s.config.PublishAddr)
+	}
 
-	for {
+	for s.running {
 		select {
 		case <-ticker.C:
-			if err := s.fetchKlines(); err != nil {
-				log.Printf("获取 K 线失败: %v", err)
+			s.guard("轮询周期", s.runCycle)
+		case <-s.quit:
+			// Stop 已将 running 置为 false
+		}
+	}
+
+	return s.haltErr
+}
+
+// runCycle 执行一个轮询周期：拉取 K 线、各项检查、生成并执行信号、保存状态
+func (s *Strategy) runCycle() {
+	if time.Now().Before(s.pausedUntil) {
+		log.Printf("限频暂停中，跳过本周期")
+		return
+	}
+	if err := s.fetchKlines(); err != nil {
+		s.handleExchangeError(err)
+		s.checkFeed()
+		return
+	}
+	if !s.checkFeed() {
+		s.publishSnapshot()
+		s.saveState()
+		return
+	}
+	s.checkOrphans()
+	s.updateBasis()
+	s.updateVolTarget()
+	if len(s.klines) > 0 {
+		s.checkRisk(s.klines[len(s.klines)-1].Close)
+		s.checkTrailing()
+	}
+	if s.checkMaintenance() || !s.checkReferencePrice() || !s.checkAnomalies() {
+		s.publishSnapshot()
+		s.saveState()
+		return
+	}
+
+	s.evaluate()
+	s.checkBalanceDrift()
+	s.checkDrawdown()
+	s.publishSnapshot()
+	s.saveState()
+}
+
+// strategyConfig 从运行配置提取策略参数
+func (c *Config) strategyConfig() StrategyConfig {
+	return StrategyConfig{
+		RSI_PERIOD:           c.RSI_PERIOD,
+		RSI_OVERSOLD_LONG:    c.RSI_OVERSOLD_LONG,
+		RSI_ENTRY_LONG:       c.RSI_ENTRY_LONG,
+		RSI_OVERBOUGHT_SHORT: c.RSI_OVERBOUGHT_SHORT,
+		RSI_ENTRY_SHORT:      c.RSI_ENTRY_SHORT,
+		EMA_FAST:             c.EMA_FAST,
+		EMA_SLOW:             c.EMA_SLOW,
+		VOL_RATIO_THRESHOLD:  c.VOL_RATIO_THRESHOLD,
+		StopLossPct:          c.StopLossPct,
+		TakeProfitPct:        c.TakeProfitPct,
+	}
+}
+
+// setStrategyConfig 将策略参数写回运行配置
+func (c *Config) setStrategyConfig(sc StrategyConfig) {
+	c.RSI_PERIOD = sc.RSI_PERIOD
+	c.RSI_OVERSOLD_LONG = sc.RSI_OVERSOLD_LONG
+	c.RSI_ENTRY_LONG = sc.RSI_ENTRY_LONG
+	c.RSI_OVERBOUGHT_SHORT = sc.RSI_OVERBOUGHT_SHORT
+	c.RSI_ENTRY_SHORT = sc.RSI_ENTRY_SHORT
+	c.EMA_FAST = sc.EMA_FAST
+	c.EMA_SLOW = sc.EMA_SLOW
+	c.VOL_RATIO_THRESHOLD = sc.VOL_RATIO_THRESHOLD
+	c.StopLossPct = sc.StopLossPct
+	c.TakeProfitPct = sc.TakeProfitPct
+}
+
+// evaluate 基于当前 K 线生成并执行信号，打印指标
+func (s *Strategy) evaluate() {
+	if !s.warmupReady() {
+		return
+	}
+
+	// 生成信号
+	strategyConfig := s.config.strategyConfig()
+
+	orders := s.feedEngine()
+	if s.config.ExplainSignals {
+		if explainer, ok := s.engine.(SignalExplainer); ok {
+			log.Printf("信号判定: %s", explainer.Explanation())
+		}
+	}
+
+	// 执行信号：按价格触发加仓时由分批建仓配置生成加仓指令
+	last := s.klines[len(s.klines)-1]
+	orders = s.config.Pyramid.filterOrders(orders, last.Close, s.pyramidView())
+	for _, order := range orders {
+		if !s.orderApplies(order) {
+			continue
+		}
+		size := order.Size
+		if size <= 0 {
+			size = s.config.PositionSize
+		}
+		if s.config.Pyramid.Enabled() && (order.Signal == SignalLong || order.Signal == SignalShort) {
+			var ok bool
+			if size, ok = s.config.Pyramid.admit(s.pyramidView(), size, last.Timestamp); !ok {
 				continue
 			}
+		}
+		log.Printf("信号: %v %s", order.Signal, order.Reason)
+		// 平仓会更新自适应系数，广播的仓位比例按执行前的系数计算
+		executedSize := size * s.sizer.Multiplier()
+		executed, price, err := s.executeOrder(order, size)
+		if err != nil {
+			s.handleExchangeError(err)
+		}
+		// 只广播实际改变了持仓的信号，仓位比例和价格按执行结果
+		if !executed {
+			continue
+		}
+		s.hub.Broadcast(SignalMessage{
+			Symbol:       s.config.Symbol,
+			Signal:       signalNames[order.Signal],
+			Price:        price,
+			PositionSize: executedSize,
+			Timestamp:    time.Now().Unix(),
+		})
+	}
 
-			// 生成信号
-			strategyConfig := StrategyConfig{
-				RSI_PERIOD:           s.config.RSI_PERIOD,
-				RSI_OVERSOLD_LONG:    s.config.RSI_OVERSOLD_LONG,
-				RSI_ENTRY_LONG:       s.config.RSI_ENTRY_LONG,
-				RSI_OVERBOUGHT_SHORT: s.config.RSI_OVERBOUGHT_SHORT,
-				RSI_ENTRY_SHORT:      s.config.RSI_ENTRY_SHORT,
-				EMA_FAST:             s.config.EMA_FAST,
-				EMA_SLOW:             s.config.EMA_SLOW,
-				VOL_RATIO_THRESHOLD:  s.config.VOL_RATIO_THRESHOLD,
-			}
+	// 打印当前指标
+	if len(s.klines) > 0 {
+		rsi := CalculateRSI(s.klines, strategyConfig.RSI_PERIOD)
+		vol := CalculateVolatility(s.klines, strategyConfig.RSI_PERIOD, false)
+		volRatio := VolumeRatio(s.klines, strategyConfig.RSI_PERIOD)
 
-			signal := GenerateSignal(s.klines, strategyConfig)
+		lastK := s.klines[len(s.klines)-1]
+		var currentRSI, currentVol, currentVolRatio float64
+		if rsi != nil {
+			currentRSI = rsi[len(rsi)-1]
+		}
+		if vol != nil {
+			currentVol = vol[len(vol)-1]
+		}
+		if volRatio != nil {
+			currentVolRatio = volRatio[len(volRatio)-1]
+		}
 
-			// 执行信号
-			if signal != SignalNone {
-				log.Printf("信号: %v", signal)
-				if err := s.executeSignal(signal); err != nil {
-					log.Printf("执行失败: %v", err)
-				}
-			}
+		log.Printf("[%s] Close: %.2f | RSI: %.1f | Vol: %.4f | VolRatio: %.2f",
+			time.Unix(lastK.Timestamp, 0).Format("15:04"),
+			lastK.Close,
+			currentRSI,
+			currentVol,
+			currentVolRatio,
+		)
 
-			// 打印当前指标
-			if len(s.klines) > 0 {
-				rsi := CalculateRSI(s.klines, strategyConfig.RSI_PERIOD)
-				vol := CalculateVolatility(s.klines, strategyConfig.RSI_PERIOD, false)
-				volRatio := VolumeRatio(s.klines, strategyConfig.RSI_PERIOD)
-
-				lastK := s.klines[len(s.klines)-1]
-				var currentRSI, currentVol, currentVolRatio float64
-				if rsi != nil {
-					currentRSI = rsi[len(rsi)-1]
-				}
-				if vol != nil {
-					currentVol = vol[len(vol)-1]
-				}
-				if volRatio != nil {
-					currentVolRatio = volRatio[len(volRatio)-1]
-				}
-
-				log.Printf("[%s] Close: %.2f | RSI: %.1f | Vol: %.4f | VolRatio: %.2f",
-					time.Unix(lastK.Timestamp, 0).Format("15:04"),
-					lastK.Close,
-					currentRSI,
-					currentVol,
-					currentVolRatio,
-				)
+		if s.influx != nil {
+			s.influx.Point("indicators", map[string]float64{
+				"close":      lastK.Close,
+				"volume":     lastK.Volume,
+				"rsi":        currentRSI,
+				"volatility": currentVol,
+				"vol_ratio":  currentVolRatio,
+			}, lastK.Timestamp)
+			s.exportEquity()
+			if err := s.influx.Flush(); err != nil {
+				log.Printf("写入 InfluxDB 失败: %v", err)
 			}
 		}
 	}
 }
 
-// Stop 停止策略
+// Stop 停止策略，Run 在当前周期结束后立即返回
 func (s *Strategy) Stop() {
 	s.running = false
+	if s.quit != nil {
+		s.stopOnce.Do(func() { close(s.quit) })
+	}
+}
+
+// StrategySnapshot 策略状态快照，每周期结束时更新
+type StrategySnapshot struct {
+	Symbol     string  `json:"symbol"`
+	Engine     string  `json:"engine"`
+	DryRun     bool    `json:"dry_run"`
+	Preserving bool    `json:"preserving"`
+	FeedStalled bool   `json:"feed_stalled,omitempty"` // 数据源停滞中
+	RiskHalted string  `json:"risk_halted,omitempty"`  // 风控停止交易的原因
+	Side       string  `json:"side,omitempty"`
+	Entries    int     `json:"entries,omitempty"`
+	Amount     float64 `json:"amount,omitempty"`
+	AvgPrice   float64 `json:"avg_price,omitempty"`
+	LastClose  float64 `json:"last_close"`
+	Notional   float64 `json:"notional"` // 持仓名义价值 (USDT)
+	Unrealized float64 `json:"unrealized_pnl"`
+	UpdatedAt  int64   `json:"updated_at"`
+}
+
+// publishSnapshot 发布当前状态快照（在 Run 所在的 goroutine 中调用）
+func (s *Strategy) publishSnapshot() {
+	snap := &StrategySnapshot{
+		Symbol:     s.config.Symbol,
+		Engine:     s.engine.Name(),
+		DryRun:     s.dryRun(),
+		Preserving: s.preserving.Load(),
+		FeedStalled: s.feed.Stalled(),
+		RiskHalted: s.risk.Halted(),
+		Side:       s.entrySide,
+		Entries:    s.entryCount,
+		Amount:     s.entryAmount,
+		AvgPrice:   s.entryPrice,
+		UpdatedAt:  time.Now().Unix(),
+	}
+	if len(s.klines) > 0 {
+		snap.LastClose = s.klines[len(s.klines)-1].Close
+		snap.Notional = s.entryAmount * snap.LastClose
+		snap.Unrealized = (snap.LastClose - s.entryPrice) * s.entryAmount
+		if s.entrySide == "SHORT" {
+			snap.Unrealized = -snap.Unrealized
+		}
+	}
+	s.snapshot.Store(snap)
+}
+
+// Snapshot 最近一次发布的状态快照，策略尚未启动完成时为 nil
+func (s *Strategy) Snapshot() *StrategySnapshot {
+	return s.snapshot.Load()
 }
 
 func main() {
 	// 命令行参数
-	mode := flag.String("mode", "run", "运行模式: run, backtest, bounce, optimize")
+	mode := flag.String("mode", "run", "运行模式: init, run, fleet, follow, replay, report, backtest, bounce, optimize, permute, rolling, capacity, stress, coordinator, worker, experiments, opt-query, download, check, preflight, soak, mock-exchange")
 	configPath := flag.String("config", "config.json", "配置文件路径")
-	dbPath := flag.String("db", "", "K线数据库路径 (回测模式)")
-	symbol := flag.String("symbol", "BTCUSDT", "交易对")
+	fleetPath := flag.String("fleet", "fleet.json", "编队配置文件路径 (fleet 模式)")
+	dbPath := flag.String("db", "", "K线数据库路径 (回测模式)：SQLite 文件，或 postgres:// 开头的 PostgreSQL 连接串（只读）")
+	market := flag.String("market", "", "K 线数据市场: futures (klines_futures) 或 spot (klines_spot)，默认 futures")
+	fixKlines := flag.Bool("fix", false, "check 模式：从交易所补齐缺口和零价格 K 线，之后重新检查")
+	table := flag.String("table", "", "K 线表名，优先于 -market")
+	dbSchemaPath := flag.String("db-schema", "", "K 线表列映射 JSON（表名、列名、价格缩放），未指定的字段自动检测")
+	symbolID := flag.Int("symbol-id", 0, "K 线表交易对列为整数 ID 时 -symbol 对应的 ID，0 表示从库中的交易对表查找")
+	symbol := flag.String("symbol", "BTCUSDT", "交易对；run / preflight / soak / follow 模式只在显式指定时覆盖配置中的 symbol")
+	days := flag.Int("days", 210, "使用最近多少天的 K 线 (backtest、bounce、optimize、permute、rolling、coordinator 模式)")
+	curvePath := flag.String("curve", "", "导出日频资金曲线及滚动指标 CSV (回测模式)")
+	sessionPath := flag.String("session", "", "行情会话文件：run 模式下录制，replay 模式下回放")
+	preflightDays := flag.Int("preflight-days", defaultPreflightDays, "上线前预估使用最近多少天的 K 线 (preflight 模式及 run 模式的手续费预算检查)，有 -db 时从库中读取，否则从币安下载")
+	preflightBalance := flag.Float64("preflight-balance", 0, "上线前预估的账户资金 (USDT)，0 表示查询交易所账户")
+	skipPreflight := flag.Bool("skip-preflight", false, "run 模式跳过启动前的手续费预算检查")
+	resetRisk := flag.Bool("reset-risk", false, "run 模式启动时解除风控停止交易的状态（连续亏损或回撤熔断后人工确认）")
+	soakDuration := flag.Duration("soak-duration", 4*time.Hour, "浸泡测试时长 (soak 模式)")
+	soakCycle := flag.Duration("soak-cycle", 10*time.Second, "浸泡测试中策略的轮询周期 (soak 模式)，实盘为 5 分钟")
+	soakReport := flag.Duration("soak-report", time.Minute, "浸泡测试打印运行状况的间隔 (soak 模式)")
+	mockAddr := flag.String("mock-addr", "127.0.0.1:8690", "模拟交易所监听地址 (mock-exchange 模式)")
+	mockLatency := flag.Duration("mock-latency", 0, "模拟交易所每个请求的固定延迟 (soak / mock-exchange 模式)")
+	mockJitter := flag.Duration("mock-jitter", 0, "模拟交易所每个请求的额外随机延迟上限")
+	mockErrors := flag.Float64("mock-errors", 0, "模拟交易所返回 500 内部错误的概率 (0-1)")
+	mockRateLimit := flag.Float64("mock-ratelimit", 0, "模拟交易所返回 429 限频的概率 (0-1)")
+	mockDrop := flag.Float64("mock-drop", 0, "模拟交易所直接断开连接的概率 (0-1)")
+	mockVol := flag.Float64("mock-vol", 0, "模拟行情每分钟收益率的标准差，默认 0.002")
+	mockSeed := flag.Int64("mock-seed", 0, "模拟行情的随机种子，0 表示按当前时间")
+	sizingMode := flag.String("sizing", "", "仓位模式 (回测模式): 留空为固定比例, anti-martingale 为盈利放大/亏损缩小")
+	dailyCompound := flag.Bool("daily-compound", false, "按当日开盘资金计算仓位，减少日内复利 (回测模式)")
+	explain := flag.Bool("explain", false, "RSI 触发时打印各入场条件的判定 (回测模式)")
+	intrabar := flag.Float64("intrabar", 0, "盘中判定 (回测模式)：按 K 线走完该比例（如 0.5）时生成信号并与收盘判定对比，0 表示只用已收盘 K 线")
+	tradesPath := flag.String("trades", "", "逐笔交易 CSV (回测模式)，回测过程中增量写入")
+	backtestReportPath := flag.String("report", "", "生成单文件 HTML 回测报告 (backtest 模式)：权益与回撤曲线、收益分布、月度盈亏和回测配置")
+	chartPath := flag.String("chart", "", "生成带买卖点、RSI 和 EMA 的交互式 K 线图 HTML (backtest 模式)，区间用 -from / -to 限定")
+	exportPath := flag.String("export", "", "回测结束后导出全部交易 (backtest、bounce 模式)，按扩展名输出 .csv 或 .json；opt-query 模式导出匹配的参数组合 CSV")
+	exportFormat := flag.String("export-format", "", "CSV 导出的区域格式 (-trades、-export、-curve)：预设 excel-us / excel-eu，或 JSON 文件（分隔符、小数点、时间格式、时区、BOM），默认逗号分隔、RFC 3339 UTC")
+	streamOnly := flag.Bool("stream-only", false, "逐笔交易只写 -trades 文件，不保留在内存中 (超长回测)")
+	streamKlines := flag.Bool("stream", false, "分块流式读取 K 线 (backtest 模式)，不一次性加载到内存 (超长回测)；不能与 -chart、-influx 同时使用")
+	stopLoss := flag.Float64("stop-loss", 0, "价格止损比例 (回测模式)，如 0.005 表示亏损 0.5% 止损，0 表示不设")
+	maintenance := flag.String("maintenance", "", "维护时段 (回测模式)，逗号分隔，如 \"22:00-06:00,sun 00:00-02:00\"（UTC），时段内平仓且不开仓")
+	feeTiersPath := flag.String("fee-tiers", "", "手续费档位 JSON (回测模式)：按累计模拟成交额升档，maker / taker 分别计费，maker 可为负（返佣）")
+	entryFill := flag.String("entry-fill", "", "入场成交方式 (回测模式): 留空为市价 (taker), limit 为在信号收盘价挂限价单，下一根 K 线穿过挂单价才成交 (maker)")
+	maxChase := flag.Float64("max-chase-bps", 0, "延迟成交 (回测模式)：入场按下一根 K 线开盘价成交，偏离信号收盘价超过该值 (bps) 时放弃，0 表示按信号收盘价成交")
+	slipMode := flag.String("slippage", "", "成交滑点模型 (回测模式): fixed 固定 bps, volume 按成交量占 K 线成交量比例, spread 按 K 线振幅估算价差, impact 按订单占 K 线成交量比例的平方根冲击，留空不计滑点")
+	slipBps := flag.Float64("slippage-bps", 1, "滑点 bps：fixed 为每笔滑点，volume 为基础滑点，spread 为最小半价差")
+	slipFactor := flag.Float64("slippage-factor", 0.1, "volume: 成交量每占 K 线成交量 1% 追加的 bps；spread: 半价差占 K 线振幅的比例；impact: 冲击系数 (冲击 = 系数 × K 线振幅 × sqrt(成交量占比))")
+	costCompare := flag.Bool("cost-compare", false, "成本诊断 (backtest、bounce 模式)：额外跑一遍无手续费、无滑点、无资金费的回测，并排对比成本消耗了多少毛利")
+	fundingSim := flag.Bool("funding", false, "模拟资金费 (backtest、bounce 模式)：从 -db 的 funding_rates 表读取历史资金费率，没有数据时从币安下载并写入该表")
+	takeProfit := flag.Float64("take-profit", 0, "价格止盈比例 (回测模式)，如 0.01 表示盈利 1% 止盈，按 K 线最高 / 最低价盘中触发，0 表示不设")
+	trail := flag.String("trail", TrailNone, "移动止损 (回测模式)：pct 止损价与持仓以来最有利价格保持 -trail-pct 的距离，atr 距离为 ATR(-atr-period) × -trail-atr-mult，留空不移动")
+	trailPct := flag.Float64("trail-pct", 0.01, "移动止损距离比例 (-trail pct)")
+	trailATRMult := flag.Float64("trail-atr-mult", defaultTrailATRMult, "移动止损距离的 ATR 倍数 (-trail atr)")
+	trailActivation := flag.Float64("trail-activation", 0, "浮盈达到该比例后才开始移动止损 (-trail)，0 表示开仓即移动")
+	breakEven := flag.Float64("break-even", 0, "保本止损 (回测模式)：浮盈达到该比例后止损至少移到持仓均价，0 表示不设")
+	pyramidSizes := flag.String("pyramid-sizes", "", "分批建仓 (回测模式)：逗号分隔的各批仓位比例，如 0.1,0.1,0.2，批数即个数；留空按策略默认")
+	pyramidTrigger := flag.String("pyramid-trigger", PyramidSignal, "加仓触发 (回测模式)：留空按策略的加仓信号，adverse 价格逆向移动 -pyramid-step 后加仓，favorable 顺向移动后加仓")
+	pyramidStep := flag.Float64("pyramid-step", 0, "adverse / favorable 加仓：相对上一批入场价的移动比例")
+	pyramidInterval := flag.Duration("pyramid-interval", 0, "相邻两批的最少间隔 (回测模式)，0 表示不限（反弹回测按 batch_interval）")
+	pyramidMaxExposure := flag.Float64("pyramid-max-exposure", 0, "各批仓位比例之和的上限 (回测模式)，0 表示不限")
+	hedge := flag.Bool("hedge", false, "对冲模式 (回测模式)：多空可同时持仓、各自逐仓保证金，反向开仓指令另开一侧而不是被忽略")
+	intrabarFill := flag.String("intrabar-fill", FillTrigger, "盘中止损成交假设 (回测模式)：留空按 -stop-penalty，worst 按 K 线极值（同时触及止损和止盈时先止损），mid 按触发价与极值的中点")
+	stopMode := flag.String("stop-mode", StopAverage, "分批持仓的止损方式 (回测模式)：留空按持仓均价止损并平掉全部批次，batch 为每批按自己的入场价止损、只平触发的批次")
+	stopCompare := flag.Bool("stop-compare", false, "止损方式对比 (回测模式)：额外用另一种 -stop-mode 跑一遍，并排对比盈亏、回撤和止损损失")
+	volTarget := flag.Float64("vol-target", 0, "波动率目标 (回测模式)：目标年化波动率，如 0.3；按标的波动率定期调整新持仓的杠杆（名义价值同比缩放），0 表示不启用")
+	volLookback := flag.Int("vol-lookback", defaultVolLookback, "估计标的波动率的 K 线根数 (-vol-target)")
+	volRebalance := flag.Duration("vol-rebalance", defaultVolRebalance*time.Second, "重新估计波动率的间隔 (-vol-target)")
+	volMaxLeverage := flag.Int("vol-max-leverage", defaultVolMaxLeverage, "波动率目标的杠杆上限 (-vol-target)，下限为 1 倍")
+	equityMA := flag.Int("equity-ma", 0, "净值曲线过滤 (回测模式)：策略净值低于最近 N 笔持仓的均线时新开仓只虚拟跟踪、不计入结果，并与不过滤的回测对比，0 表示不启用")
+	stopPenalty := flag.Float64("stop-penalty", 0, "止损成交惩罚 (回测模式)：按止损价到 K 线极值距离的该比例追加滑点，0-1")
+	minNotional := flag.Float64("min-notional", 0, "单笔最小名义价值 USDT (回测模式)，0 表示不限制")
+	maxNotional := flag.Float64("max-notional", 0, "单笔最大名义价值 USDT (回测模式)，0 表示不限制")
+	qtyUnit := flag.String("qty-unit", UnitFraction, "仓位单位 (回测模式): 留空按余额比例, coin 每批固定币数量, contract 每批固定合约张数, atr 按 ATR 计算数量并设 ATR 止损")
+	qty := flag.Float64("qty", 0, "每批入场的币数量或合约张数 (配合 -qty-unit)，多批入场按批次仓位比例缩放")
+	contractSize := flag.Float64("contract-size", 0, "每张合约的币数量 (-qty-unit contract)，默认 1")
+	riskPerTrade := flag.Float64("risk-per-trade", 0.01, "每批止损时亏损的余额比例 (-qty-unit atr)")
+	atrPeriod := flag.Int("atr-period", defaultATRPeriod, "ATR 周期 (-qty-unit atr、-trail atr)")
+	atrMult := flag.Float64("atr-mult", defaultATRMult, "止损距离的 ATR 倍数 (-qty-unit atr)，数量 = 余额 × 风险比例 / (ATR × 倍数)")
+	maxPerHour := flag.Int("max-trades-hour", 0, "每小时最多入场次数 (回测模式)，0 表示不限制")
+	maxPerDay := flag.Int("max-trades-day", 0, "每天最多入场次数 (回测模式)，0 表示不限制")
+	influxURL := flag.String("influx", "", "InfluxDB 写入地址 (回测模式)，如 http://localhost:8086/api/v2/write?org=me&bucket=rsi&precision=s，Token 取自 INFLUX_TOKEN")
+	optimizerPath := flag.String("optimizer-config", "", "优化配置 JSON（参数范围与约束），默认使用内置参数空间")
+	paretoPath := flag.String("pareto", "", "多目标优化：输出 Pareto 前沿并导出 CSV (优化模式)")
+	optArchive := flag.String("opt-archive", "", "全部参数组合结果的归档文件 (optimize、coordinator 模式写入，opt-query 模式读取)")
+	optimizer := flag.String("optimizer", OptimizerGrid, "参数搜索方式 (optimize 模式): grid 遍历参数网格, ga 遗传算法")
+	gaPopulation := flag.Int("ga-population", DefaultGAOptions.Population, "遗传算法种群大小")
+	gaGenerations := flag.Int("ga-generations", DefaultGAOptions.Generations, "遗传算法迭代代数")
+	gaElite := flag.Int("ga-elite", DefaultGAOptions.Elite, "遗传算法每代直接保留的最优个体数")
+	gaCrossover := flag.Float64("ga-crossover", DefaultGAOptions.CrossoverRate, "遗传算法交叉概率")
+	gaMutation := flag.Float64("ga-mutation", DefaultGAOptions.MutationRate, "遗传算法每个参数的变异概率")
+	objective := flag.String("objective", "", "优化目标 (optimize、coordinator、opt-query 模式，也是遗传算法的适应度)，默认 pnl: "+strings.Join(objectiveNames, ", "))
+	minTrades := flag.Int("min-trades", 0, "优化约束：参与排名的最低交易次数，0 表示取优化配置中的 min_trades")
+	maxDD := flag.Float64("max-dd", 0, "优化约束：最大回撤上限 (0-1)，0 表示取优化配置中的 max_drawdown")
+	minWinRate := flag.Float64("min-winrate", 0, "优化约束：最低胜率 (0-1)，0 表示取优化配置中的 min_win_rate")
+	maxConsecLosses := flag.Int("max-consec-losses", 0, "优化约束：最大连续亏损笔数，0 表示取优化配置中的 max_consec_losses")
+	paretoObjectives := flag.String("pareto-objectives", "", "多目标优化的目标，逗号分隔 (配合 -pareto)，默认 pnl,drawdown,trades")
+	listenAddr := flag.String("listen", ":9000", "任务队列监听地址 (coordinator 模式)")
+	coordinatorURL := flag.String("coordinator", "", "协调端地址 (worker 模式)，如 http://host:9000")
+	leaderURL := flag.String("leader", "", "信号源 WebSocket 地址 (跟单模式)，如 ws://host:8686/signals")
+	scenariosPath := flag.String("scenarios", "", "压力测试场景 JSON (stress 模式)，默认使用内置场景")
+	stressRuns := flag.Int("stress-runs", 20, "每个场景生成的行情条数 (stress 模式)")
+	stressSeed := flag.Int64("seed", 1, "随机种子 (stress、permute 模式，optimize 模式的遗传算法)")
+	syntheticOut := flag.String("synthetic-out", "", "导出第一个场景的合成 K 线 CSV (stress 模式)")
+	permutations := flag.Int("permutations", 100, "置换次数 (permute 模式)")
+	permuteMethod := flag.String("permute-method", PermuteBlock, "置换方式 (permute 模式): block 按块打乱, returns 逐根打乱")
+	blockSize := flag.Int("block-size", 60, "block 置换的块长度，K 线根数 (permute 模式)")
+	windowDays := flag.Int("window-days", 30, "每个回测窗口的天数 (rolling 模式)")
+	stepDays := flag.Int("step-days", 1, "相邻窗口起点间隔的天数 (rolling 模式)")
+	capacityMin := flag.Float64("capacity-min", DefaultCapacityOptions.MinCapital, "扫描的最小初始资金 USDT (capacity 模式)")
+	capacityMax := flag.Float64("capacity-max", DefaultCapacityOptions.MaxCapital, "扫描的最大初始资金 USDT (capacity 模式)")
+	capacitySteps := flag.Int("capacity-steps", DefaultCapacityOptions.Steps, "每 10 倍资金扫描的档数 (capacity 模式)")
+	capacityMaxPart := flag.Float64("capacity-max-participation", DefaultCapacityOptions.MaxParticipation, "平均成交量占比上限 (capacity 模式)，超过时或有订单超过整根 K 线成交量时视为无法成交")
+	journals := flag.String("journals", "", "交易日志列表 (report 模式)，逗号分隔，如 \"acct1=a.db,acct2=b.db\"，默认使用配置中的 journal_path")
+	reportFrom := flag.String("from", "", "报告开始日期 YYYY-MM-DD，UTC (report 模式)，默认 -to 前 30 天；backtest 模式下为 -chart 的开始日期")
+	reportTo := flag.String("to", "", "报告结束日期 YYYY-MM-DD，UTC，含当天 (report 模式)，默认当前时间；backtest 模式下为 -chart 的结束日期")
+	reportHTMLPath := flag.String("html", "", "导出 HTML 报告 (report 模式)")
+	engineName := flag.String("engine", "", "策略引擎: "+strings.Join(EngineNames(), ", ")+"；run 模式覆盖配置中的 engine，回测模式按引擎逐根 K 线回测")
+	intervalName := flag.String("interval", "", "K 线周期: 1m, 3m, 5m, 15m, 30m, 1h 等；run 模式覆盖配置中的 interval，回测 / 优化模式将数据重采样到该周期，默认 "+DefaultInterval+"（与实盘默认一致）")
+	experimentsPath := flag.String("experiments", "experiments.db", "实验记录库：backtest / bounce / optimize 运行后写入一条记录，为空时不记录；experiments 模式从中查询")
+	expFilter := flag.String("exp-filter", "", "实验记录过滤条件 (experiments 模式)，如 \"mode=backtest,symbol=ETHUSDT,engine=rsi\"")
+	expCompare := flag.String("compare", "", "并排对比的实验记录 ID (experiments 模式)，逗号分隔，如 \"3,7\"")
+	expLimit := flag.Int("limit", 20, "列出最近的实验记录条数 (experiments 模式) / 打印的参数组合数 (opt-query 模式)，0 表示全部")
+	paramsPath := flag.String("params", "", "策略参数 JSON 文件 (backtest / bounce / permute / rolling 模式)，键名同 config.json，可直接使用 config.json 或优化结果")
 	flag.Parse()
 
+	if *dbSchemaPath != "" {
+		schema, err := LoadKlineSchema(*dbSchemaPath)
+		if err != nil {
+			log.Fatalf("加载 K 线表映射失败: %v", err)
+		}
+		klineSchemaConfig = schema
+	}
+	if *market != "" {
+		name, ok := marketTables[*market]
+		if !ok {
+			log.Fatalf("未知市场类型: %s", *market)
+		}
+		klineSchemaConfig.Table = name
+	}
+	if *table != "" {
+		klineSchemaConfig.Table = *table
+	}
+	if *symbolID > 0 {
+		if klineSchemaConfig.SymbolIDs == nil {
+			klineSchemaConfig.SymbolIDs = make(map[string]int)
+		}
+		klineSchemaConfig.SymbolIDs[*symbol] = *symbolID
+	}
+
+	backtestOpts := BacktestOptions{
+		CurvePath:        *curvePath,
+		DailyCompounding: *dailyCompound,
+		InfluxURL:        *influxURL,
+		Throttle:         ThrottleConfig{MaxPerHour: *maxPerHour, MaxPerDay: *maxPerDay},
+		Explain:          *explain,
+		StopLossPct:      *stopLoss,
+		StopMode:         *stopMode,
+		StopCompare:      *stopCompare,
+		EquityMA:         *equityMA,
+		VolTarget:        VolTargetConfig{Target: *volTarget, Lookback: *volLookback, Rebalance: int64(volRebalance.Seconds()), MaxLeverage: *volMaxLeverage},
+		StopPenalty:      *stopPenalty,
+		TakeProfitPct:    *takeProfit,
+		Trailing:         TrailingConfig{Mode: *trail, Activation: *trailActivation, BreakEven: *breakEven},
+		IntrabarFill:     *intrabarFill,
+		TradesPath:       *tradesPath,
+		StreamOnly:       *streamOnly,
+		StreamKlines:     *streamKlines,
+		ExportPath:       *exportPath,
+		ReportPath:       *backtestReportPath,
+		ChartPath:        *chartPath,
+		IntrabarFraction: *intrabar,
+		MaxChaseBps:      *maxChase,
+		EntryFill:        *entryFill,
+		Maintenance:      *maintenance,
+		Funding:          *fundingSim,
+		CostCompare:      *costCompare,
+		Engine:           *engineName,
+		ExperimentsPath:  *experimentsPath,
+	}
+	if *intrabar < 0 || *intrabar > 1 {
+		log.Fatalf("盘中判定比例需在 0-1 之间: %v", *intrabar)
+	}
+	if *days <= 0 {
+		log.Fatalf("-days 需大于 0: %d", *days)
+	}
+	if *stopPenalty < 0 || *stopPenalty > 1 {
+		log.Fatalf("止损惩罚需在 0-1 之间: %v", *stopPenalty)
+	}
+	if *stopMode != StopAverage && *stopMode != StopPerBatch {
+		log.Fatalf("未知的止损方式: %s", *stopMode)
+	}
+	if *equityMA < 0 {
+		log.Fatalf("-equity-ma 不能为负数")
+	}
+	if err := backtestOpts.VolTarget.Validate(); err != nil || *volRebalance < time.Second {
+		log.Fatalf("波动率目标参数无效: -vol-target %v, -vol-lookback %d, -vol-rebalance %v, -vol-max-leverage %d",
+			*volTarget, *volLookback, *volRebalance, *volMaxLeverage)
+	}
+	if *trail == TrailPct {
+		backtestOpts.Trailing.Pct = *trailPct
+	}
+	if *trail == TrailATR {
+		backtestOpts.Trailing.ATRPeriod = *atrPeriod
+		backtestOpts.Trailing.ATRMult = *trailATRMult
+	}
+	if err := backtestOpts.Trailing.Validate(); err != nil {
+		log.Fatalf("移动止损参数错误: %v", err)
+	}
+	sizes, err := ParsePyramidSizes(*pyramidSizes)
+	if err != nil {
+		log.Fatalf("分批建仓参数错误: %v", err)
+	}
+	backtestOpts.Pyramid = PyramidConfig{Sizes: sizes, Trigger: *pyramidTrigger, Step: *pyramidStep,
+		Interval: int64(pyramidInterval.Seconds()), MaxExposure: *pyramidMaxExposure}
+	if err := backtestOpts.Pyramid.Validate(); err != nil {
+		log.Fatalf("分批建仓参数错误: %v", err)
+	}
+	backtestOpts.Hedge = *hedge
+	if (*stopMode == StopPerBatch || *stopCompare) && *stopLoss <= 0 && *qtyUnit != UnitATR {
+		log.Fatalf("-stop-mode batch / -stop-compare 需要同时设置 -stop-loss 或 -qty-unit atr")
+	}
+	if *intrabarFill != FillTrigger && *intrabarFill != FillWorst && *intrabarFill != FillMid {
+		log.Fatalf("未知的盘中成交假设: %s", *intrabarFill)
+	}
+	slippageModel, err := ParseSlippageModel(*slipMode, *slipBps, *slipFactor)
+	if err != nil {
+		log.Fatalf("滑点模型参数错误: %v", err)
+	}
+	backtestOpts.Slippage = slippageModel
+	if *entryFill != EntryMarket && *entryFill != EntryLimit {
+		log.Fatalf("未知的入场成交方式: %s", *entryFill)
+	}
+	if *entryFill == EntryLimit && *maxChase > 0 {
+		log.Fatalf("-entry-fill limit 不能与 -max-chase-bps 同时使用")
+	}
+	if *streamKlines && (*chartPath != "" || *influxURL != "") {
+		log.Fatalf("-stream 不保留 K 线，无法 -chart 或 -influx")
+	}
+	if *chartPath != "" {
+		if *streamOnly {
+			log.Fatalf("-stream-only 不保留逐笔交易，无法 -chart")
+		}
+		if *reportFrom != "" {
+			if backtestOpts.ChartFrom, err = parseReportDate(*reportFrom); err != nil {
+				log.Fatalf("解析 -from 失败: %v", err)
+			}
+		}
+		if *reportTo != "" {
+			if backtestOpts.ChartTo, err = parseReportDate(*reportTo); err != nil {
+				log.Fatalf("解析 -to 失败: %v", err)
+			}
+			backtestOpts.ChartTo += 86400 - 1 // 包含当天
+		}
+	}
+	if *feeTiersPath != "" {
+		backtestOpts.FeeTiers, err = LoadFeeTiers(*feeTiersPath)
+		if err != nil {
+			log.Fatalf("加载手续费档位失败: %v", err)
+		}
+	}
+	switch *sizingMode {
+	case SizingFixed:
+	case SizingAntiMartingale:
+		backtestOpts.Sizing = DefaultAntiMartingale
+	default:
+		log.Fatalf("未知仓位模式: %s", *sizingMode)
+	}
+	backtestOpts.ExportFormat, err = LoadExportFormat(*exportFormat)
+	if err != nil {
+		log.Fatalf("导出格式错误: %v", err)
+	}
+	if *paramsPath != "" {
+		params, err := LoadBacktestParams(*paramsPath)
+		if err != nil {
+			log.Fatalf("加载策略参数失败: %v", err)
+		}
+		backtestOpts.Params = params
+	}
+	backtestOpts.Interval, err = ParseInterval(*intervalName)
+	if err != nil {
+		log.Fatalf("K 线周期错误: %v", err)
+	}
+	backtestOpts.Sizing.MinNotional = *minNotional
+	backtestOpts.Sizing.MaxNotional = *maxNotional
+	backtestOpts.Sizing.Unit = *qtyUnit
+	backtestOpts.Sizing.Quantity = *qty
+	backtestOpts.Sizing.ContractSize = *contractSize
+	if *qtyUnit == UnitATR {
+		backtestOpts.Sizing.RiskPerTrade = *riskPerTrade
+		backtestOpts.Sizing.ATRPeriod = *atrPeriod
+		backtestOpts.Sizing.ATRMult = *atrMult
+	}
+	if err := backtestOpts.Sizing.ValidateUnit(); err != nil {
+		log.Fatalf("仓位单位错误: %v", err)
+	}
+
+	optimizeOpts := OptimizeOptions{
+		Spec:            DefaultOptimizerSpec,
+		ParetoPath:      *paretoPath,
+		Optimizer:       *optimizer,
+		Interval:        backtestOpts.Interval,
+		ExperimentsPath: *experimentsPath,
+		ArchivePath:     *optArchive,
+		GA: GAOptions{
+			Population:    *gaPopulation,
+			Generations:   *gaGenerations,
+			Elite:         *gaElite,
+			CrossoverRate: *gaCrossover,
+			MutationRate:  *gaMutation,
+			Seed:          *stressSeed,
+		},
+	}
+	switch *optimizer {
+	case OptimizerGrid:
+	case OptimizerGA:
+		if err := optimizeOpts.GA.Validate(); err != nil {
+			log.Fatalf("遗传算法参数错误: %v", err)
+		}
+	default:
+		log.Fatalf("未知参数搜索方式: %s", *optimizer)
+	}
+	if *optimizerPath != "" {
+		spec, err := LoadOptimizerSpec(*optimizerPath)
+		if err != nil {
+			log.Fatalf("加载优化配置失败: %v", err)
+		}
+		optimizeOpts.Spec = spec
+	}
+	objectiveName, filter := optimizeOpts.Spec.Objective, optimizeOpts.Spec.ResultFilter
+	if *objective != "" {
+		objectiveName = *objective
+	}
+	if *minTrades > 0 {
+		filter.MinTrades = *minTrades
+	}
+	if *maxDD > 0 {
+		filter.MaxDrawdown = *maxDD
+	}
+	if *minWinRate > 0 {
+		filter.MinWinRate = *minWinRate
+	}
+	if *maxConsecLosses > 0 {
+		filter.MaxConsecLosses = *maxConsecLosses
+	}
+	optimizeOpts.Objective, err = ParseObjective(objectiveName, filter)
+	if err != nil {
+		log.Fatalf("优化目标错误: %v", err)
+	}
+	paretoList := strings.Join(optimizeOpts.Spec.ParetoObjectives, ",")
+	if *paretoObjectives != "" {
+		paretoList = *paretoObjectives
+	}
+	optimizeOpts.Pareto, err = ParseParetoObjectives(paretoList)
+	if err != nil {
+		log.Fatalf("多目标优化目标错误: %v", err)
+	}
+
 	switch *mode {
+	case "init":
+		// 交互式生成配置
+		if err := RunInitWizard(*configPath, os.Stdin, os.Stdout); err != nil {
+			log.Fatalf("初始化配置失败: %v", err)
+		}
+
 	case "run":
 		// 加载配置
 		config, err := LoadConfig(*configPath)
 		if err != nil {
-			// 配置文件不存在，使用默认配置
-			config = &defaultConfig
-			if err := SaveConfig(*configPath, config); err != nil {
-				log.Printf("保存默认配置失败: %v", err)
+			if os.IsNotExist(err) {
+				log.Fatalf("配置文件 %s 不存在，请先运行 -mode init 生成", *configPath)
 			}
-			log.Printf("创建默认配置文件: %s", *configPath)
+			log.Fatalf("加载配置失败: %v", err)
 		}
 
-		config.Symbol = *symbol
+		if flagPassed("symbol") {
+			config.Symbol = *symbol
+		}
+		if *engineName != "" {
+			config.Engine = *engineName
+		}
+		if *intervalName != "" {
+			config.Interval = *intervalName
+		}
 		// 实盘运行
 		strategy, err := NewStrategy(config)
 		if err != nil {
 			log.Fatalf("创建策略失败: %v", err)
 		}
+		if *resetRisk {
+			strategy.resetRisk()
+			strategy.saveState()
+		}
+
+		// 手续费预算：实盘下单前按最近行情预估，超出预算拒绝启动
+		if !config.DryRun && config.MaxDailyFees > 0 && !*skipPreflight {
+			report, err := strategy.preflight(PreflightOptions{Days: *preflightDays, DBPath: *dbPath, Balance: *preflightBalance})
+			if err != nil {
+				log.Fatalf("上线前预估失败（-skip-preflight 跳过）: %v", err)
+			}
+			PrintPreflight(report, config)
+			if report.OverBudget() {
+				log.Fatalf("预计日均手续费 $%.2f 超出预算 $%.2f，拒绝启动（-skip-preflight 跳过）", report.FeesDay, report.Budget)
+			}
+		}
+
+		if *sessionPath != "" {
+			strategy.recorder, err = NewSessionRecorder(*sessionPath)
+			if err != nil {
+				log.Fatalf("打开会话文件失败: %v", err)
+			}
+			defer strategy.recorder.Close()
+			log.Printf("录制行情到: %s", *sessionPath)
+		}
 
 		// 信号处理
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+		// 停止后由 Run 正常返回，执行释放实例锁、推送剩余告警和关闭会话文件等收尾；再次收到信号时强制退出
 		go func() {
 			<-sigChan
 			log.Println("收到退出信号...")
 			strategy.Stop()
-			os.Exit(0)
+			<-sigChan
+			log.Println("再次收到退出信号，强制退出")
+			os.Exit(1)
 		}()
 
 		if err := strategy.Run(); err != nil {
 			log.Fatalf("运行失败: %v", err)
 		}
 
+	case "preflight":
+		// 上线前预估：按配置回测最近行情，估算每天交易笔数和手续费
+		config, err := LoadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("加载配置失败: %v", err)
+		}
+		if flagPassed("symbol") {
+			config.Symbol = *symbol
+		}
+		if *engineName != "" {
+			config.Engine = *engineName
+		}
+		if *intervalName != "" {
+			config.Interval = *intervalName
+		}
+		runPreflightCmd(config, PreflightOptions{Days: *preflightDays, DBPath: *dbPath, Balance: *preflightBalance})
+
+	case "soak":
+		// 浸泡测试：按实盘代码路径对模拟交易所运行数小时，检查稳定性
+		config, err := LoadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("加载配置失败: %v", err)
+		}
+		if flagPassed("symbol") {
+			config.Symbol = *symbol
+		}
+		if *engineName != "" {
+			config.Engine = *engineName
+		}
+		if *intervalName != "" {
+			config.Interval = *intervalName
+		}
+		runSoakCmd(config, SoakOptions{
+			Duration: *soakDuration,
+			Cycle:    *soakCycle,
+			Report:   *soakReport,
+			Mock:     mockExchangeConfig(*mockLatency, *mockJitter, *mockErrors, *mockRateLimit, *mockDrop, *mockVol, *mockSeed),
+		})
+
+	case "mock-exchange":
+		// 单独运行模拟交易所，供手动调试
+		mc := mockExchangeConfig(*mockLatency, *mockJitter, *mockErrors, *mockRateLimit, *mockDrop, *mockVol, *mockSeed)
+		mc.Symbol = *symbol
+		addr, err := ServeMockExchange(NewMockExchange(mc), *mockAddr)
+		if err != nil {
+			log.Fatalf("启动模拟交易所失败: %v", err)
+		}
+		log.Printf("模拟交易所 %s 监听 http://%s", *symbol, addr)
+		select {}
+
+	case "fleet":
+		// 单进程运行多个策略实例
+		fc, err := LoadFleetConfig(*fleetPath)
+		if err != nil {
+			log.Fatalf("加载编队配置失败: %v", err)
+		}
+
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		RunFleet(fc, sigChan)
+
+	case "follow":
+		// 跟单模式
+		config, err := LoadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("加载配置失败: %v", err)
+		}
+		if flagPassed("symbol") {
+			config.Symbol = *symbol
+		}
+
+		strategy, err := NewStrategy(config)
+		if err != nil {
+			log.Fatalf("创建策略失败: %v", err)
+		}
+
+		if err := RunFollower(strategy, *leaderURL); err != nil {
+			log.Fatalf("跟单失败: %v", err)
+		}
+
+	case "replay":
+		// 回放录制的实盘会话
+		if *sessionPath == "" {
+			log.Fatalf("回放模式需要 -session 参数")
+		}
+		config, err := LoadConfig(*configPath)
+		if err != nil {
+			log.Printf("加载配置失败，使用默认配置: %v", err)
+			config = &defaultConfig
+		}
+
+		if err := RunReplay(*sessionPath, config); err != nil {
+			log.Fatalf("回放失败: %v", err)
+		}
+
+	case "report":
+		// 多账户 / 多策略汇总报告
+		runReportCmd(*journals, *configPath, *reportFrom, *reportTo, *reportHTMLPath)
+
 	case "backtest":
-		// 回测模式 - 最近 7 个月
+		// 回测模式 - 最近 -days 天
 		if *dbPath == "" {
 			*dbPath = "../binance-klines/klines.db"
 		}
 
-		// 最近 7 个月
+		// 最近 -days 天，默认 210 天 ≈ 7 个月
 		endTime := time.Now().Unix()
-		startTime := endTime - 210*24*3600  // 210天 ≈ 7个月
+		startTime := endTime - int64(*days)*24*3600
 
-		runBacktestCmd(*dbPath, *symbol, startTime, endTime)
+		runBacktestCmd(*dbPath, *symbol, startTime, endTime, backtestOpts)
 
 	case "bounce":
-		// 反弹策略回测 - 最近 7 个月
+		// 反弹策略回测 - 最近 -days 天
+		if *qtyUnit == UnitATR {
+			log.Fatalf("反弹回测不支持 -qty-unit atr")
+		}
+		if backtestOpts.Trailing.Enabled() {
+			log.Fatalf("反弹回测不支持 -trail / -break-even")
+		}
+		if backtestOpts.Hedge {
+			log.Fatalf("反弹策略只做多，不支持 -hedge")
+		}
 		if *dbPath == "" {
 			*dbPath = "../binance-klines/klines.db"
 		}
 
 		endTime := time.Now().Unix()
-		startTime := endTime - 210*24*3600
+		startTime := endTime - int64(*days)*24*3600
 
-		runBounceBacktestCmd(*dbPath, *symbol, startTime, endTime)
+		runBounceBacktestCmd(*dbPath, *symbol, startTime, endTime, backtestOpts)
 
 	case "optimize":
-		// 参数优化 - 最近 7 个月
+		// 参数优化 - 最近 -days 天
+		if *dbPath == "" {
+			*dbPath = "../binance-klines/klines.db"
+		}
+
+		endTime := time.Now().Unix()
+		startTime := endTime - int64(*days)*24*3600
+
+		runOptimizeCmd(*dbPath, *symbol, startTime, endTime, optimizeOpts)
+
+	case "permute":
+		// 置换检验 - 最近 -days 天
 		if *dbPath == "" {
 			*dbPath = "../binance-klines/klines.db"
 		}
 
 		endTime := time.Now().Unix()
-		startTime := endTime - 210*24*3600
+		startTime := endTime - int64(*days)*24*3600
+
+		runPermutationCmd(*dbPath, *symbol, startTime, endTime, PermutationOptions{
+			Runs:      *permutations,
+			Method:    *permuteMethod,
+			BlockSize: *blockSize,
+			Seed:      *stressSeed,
+		}, backtestOpts)
+
+	case "rolling":
+		// 滚动窗口回测 - 最近 -days 天内按 -step-days 平移的 -window-days 天窗口
+		if *dbPath == "" {
+			*dbPath = "../binance-klines/klines.db"
+		}
+
+		endTime := time.Now().Unix()
+		startTime := endTime - int64(*days)*24*3600
+
+		runRollingCmd(*dbPath, *symbol, startTime, endTime, RollingOptions{
+			WindowDays: *windowDays,
+			StepDays:   *stepDays,
+		}, backtestOpts)
+
+	case "capacity":
+		// 容量估算 - 最近 -days 天，-symbol 可以用逗号分隔多个交易对
+		if *dbPath == "" {
+			*dbPath = "../binance-klines/klines.db"
+		}
+
+		endTime := time.Now().Unix()
+		startTime := endTime - int64(*days)*24*3600
+
+		runCapacityCmd(*dbPath, *symbol, startTime, endTime, CapacityOptions{
+			MinCapital:       *capacityMin,
+			MaxCapital:       *capacityMax,
+			Steps:            *capacitySteps,
+			MaxParticipation: *capacityMaxPart,
+		}, backtestOpts)
+
+	case "stress":
+		// 合成行情压力测试
+		runStressCmd(StressOptions{
+			ScenariosPath: *scenariosPath,
+			Runs:          *stressRuns,
+			Seed:          *stressSeed,
+			ExportPath:    *syntheticOut,
+		}, backtestOpts)
+
+	case "coordinator":
+		// 分布式参数优化：协调端 - 最近 -days 天
+		endTime := time.Now().Unix()
+		startTime := endTime - int64(*days)*24*3600
+
+		runCoordinatorCmd(*listenAddr, *symbol, startTime, endTime, optimizeOpts)
+
+	case "download":
+		// 下载历史 K 线到 -db，默认最近 -days 天的 1m K 线，-from / -to 指定日期区间
+		if *dbPath == "" {
+			*dbPath = "../binance-klines/klines.db"
+		}
+		interval := *intervalName
+		if interval == "" {
+			interval = "1m"
+		}
+		endTime := time.Now().Unix()
+		if *reportTo != "" {
+			day, err := parseReportDate(*reportTo)
+			if err != nil {
+				log.Fatalf("解析 -to 失败: %v", err)
+			}
+			endTime = day + 86400
+		}
+		startTime := endTime - int64(*days)*24*3600
+		if *reportFrom != "" {
+			if startTime, err = parseReportDate(*reportFrom); err != nil {
+				log.Fatalf("解析 -from 失败: %v", err)
+			}
+		}
+		runDownloadCmd(*dbPath, *market, *symbol, interval, startTime, endTime)
+
+	case "check":
+		// K 线完整性检查：缺口、重复、零成交量 / 零价格，默认检查整张表，-from / -to 限定日期区间，-fix 从交易所补齐
+		if *dbPath == "" {
+			*dbPath = "../binance-klines/klines.db"
+		}
+		var startTime, endTime int64
+		if *reportFrom != "" {
+			if startTime, err = parseReportDate(*reportFrom); err != nil {
+				log.Fatalf("解析 -from 失败: %v", err)
+			}
+		}
+		if *reportTo != "" {
+			day, err := parseReportDate(*reportTo)
+			if err != nil {
+				log.Fatalf("解析 -to 失败: %v", err)
+			}
+			endTime = day + 86400
+		}
+		runCheckCmd(*dbPath, *market, *symbol, *intervalName, startTime, endTime, *fixKlines)
+
+	case "experiments":
+		// 列出 / 过滤 / 对比历史回测与优化记录
+		runExperimentsCmd(*experimentsPath, *expFilter, *expCompare, *expLimit)
+
+	case "opt-query":
+		// 从优化结果归档中筛选参数组合，表达式为 -mode opt-query 之后的参数，如 "sharpe>1 and trades>100"
+		runOptQueryCmd(*optArchive, strings.Join(flag.Args(), " "), optimizeOpts.Objective, *expLimit, *exportPath)
+
+	case "worker":
+		// 分布式参数优化：worker
+		if *coordinatorURL == "" {
+			log.Fatalf("worker 模式需要 -coordinator 参数")
+		}
+		if *dbPath == "" {
+			*dbPath = "../binance-klines/klines.db"
+		}
 
-		runOptimizeCmd(*dbPath, *symbol, startTime, endTime)
+		runWorkerCmd(*coordinatorURL, *dbPath)
 
 	default:
 		log.Fatalf("未知模式: %s", *mode)
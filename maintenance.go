@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// MaintenanceWindow 维护时段（UTC）：每天或每周某天的 [Start, End)，End <= Start 表示跨零点
+type MaintenanceWindow struct {
+	Weekday int // -1 表示每天，否则为 time.Weekday
+	Start   int // 开始时刻，当日分钟数
+	End     int // 结束时刻，当日分钟数
+}
+
+// weekdayNames 维护时段中的星期缩写
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseMaintenanceWindow 解析 "22:00-06:00"（每天）或 "sun 00:00-02:00"（每周），时间为 UTC
+func ParseMaintenanceWindow(spec string) (MaintenanceWindow, error) {
+	w := MaintenanceWindow{Weekday: -1}
+	fields := strings.Fields(strings.ToLower(spec))
+	switch len(fields) {
+	case 1:
+	case 2:
+		day, ok := weekdayNames[fields[0]]
+		if !ok {
+			return w, fmt.Errorf("maintenance window %q: unknown weekday %q", spec, fields[0])
+		}
+		w.Weekday = int(day)
+		fields = fields[1:]
+	default:
+		return w, fmt.Errorf("maintenance window %q: expected [weekday] HH:MM-HH:MM", spec)
+	}
+
+	start, end, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return w, fmt.Errorf("maintenance window %q: expected HH:MM-HH:MM", spec)
+	}
+	var err error
+	if w.Start, err = parseClock(start); err != nil {
+		return w, fmt.Errorf("maintenance window %q: %w", spec, err)
+	}
+	if w.End, err = parseClock(end); err != nil {
+		return w, fmt.Errorf("maintenance window %q: %w", spec, err)
+	}
+	if w.Start == w.End {
+		return w, fmt.Errorf("maintenance window %q: empty window", spec)
+	}
+	return w, nil
+}
+
+// parseClock 解析 HH:MM 为当日分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Contains ts 是否在维护时段内；跨零点的每周时段从指定星期的开始时刻延续到次日结束时刻
+func (w MaintenanceWindow) Contains(ts int64) bool {
+	t := time.Unix(ts, 0).UTC()
+	minute := t.Hour()*60 + t.Minute()
+	day := int(t.Weekday())
+	onDay := func(d int) bool { return w.Weekday < 0 || w.Weekday == d }
+
+	if w.Start < w.End {
+		return onDay(day) && minute >= w.Start && minute < w.End
+	}
+	return (onDay(day) && minute >= w.Start) || (onDay((day+6)%7) && minute < w.End)
+}
+
+// MaintenanceSchedule 多个维护时段
+type MaintenanceSchedule []MaintenanceWindow
+
+// ParseMaintenanceSchedule 解析维护时段列表
+func ParseMaintenanceSchedule(specs []string) (MaintenanceSchedule, error) {
+	var schedule MaintenanceSchedule
+	for _, spec := range specs {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		w, err := ParseMaintenanceWindow(spec)
+		if err != nil {
+			return nil, err
+		}
+		schedule = append(schedule, w)
+	}
+	return schedule, nil
+}
+
+// Active ts 是否处于任一维护时段
+func (s MaintenanceSchedule) Active(ts int64) bool {
+	for _, w := range s {
+		if w.Contains(ts) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMaintenance 维护时段内平掉持仓并暂停交易，返回 true 表示本周期不再评估信号
+func (s *Strategy) checkMaintenance() bool {
+	active := s.maintenance.Active(time.Now().Unix())
+	if active != s.inMaintenance {
+		s.inMaintenance = active
+		if active {
+			log.Printf("进入维护时段，平仓并暂停交易")
+		} else {
+			log.Printf("维护时段结束，恢复交易")
+		}
+	}
+	if !active {
+		return false
+	}
+
+	if s.entrySide != "" {
+		signal := SignalCloseLong
+		if s.entrySide == "SHORT" {
+			signal = SignalCloseShort
+		}
+		if _, _, err := s.executeSignalSized(signal, 0); err != nil {
+			s.handleExchangeError(err)
+		}
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Migration 一次表结构变更，同一组件的 Version 从 1 起连续递增；已发布的迁移不再修改，结构变化只追加新版本
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx) error
+}
+
+// execMigration 依次执行 SQL 语句的迁移
+func execMigration(statements ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, stmt := range statements {
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// ensureColumn 表中没有该列时添加（旧版本无版本记录的文件可能缺少后加的列）
+func ensureColumn(tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.Query("PRAGMA table_info(" + quoteIdent(table) + ")")
+	if err != nil {
+		return err
+	}
+	found := false
+	for rows.Next() {
+		var (
+			cid       int
+			name, typ string
+			notNull   int
+			dflt      sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+		if name == column {
+			found = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || found {
+		return err
+	}
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", quoteIdent(table), quoteIdent(column), definition))
+	return err
+}
+
+// migrateSQLite 把 SQLite 文件中 component 的表结构升级到最新版本。各组件的版本分别记录在 schema_migrations 表中，
+// 多个组件可共用一个文件（如 journal_path 与 state_path 相同）；每个版本在单独的事务中执行并记录。
+// 已有数据的文件升级前先备份为 <path>.<component>-v<当前版本>.bak；文件的版本比本程序新时返回错误，避免旧版本程序写坏新结构
+func migrateSQLite(db *sql.DB, path, component string, migrations []Migration) error {
+	latest := len(migrations)
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return fmt.Errorf("%s migration %q has version %d, want %d", component, m.Name, m.Version, i+1)
+		}
+	}
+
+	var tables int
+	if err := db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name != 'schema_migrations'`).Scan(&tables); err != nil {
+		return err
+	}
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			component  TEXT PRIMARY KEY,
+			version    INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL
+		)
+	`); err != nil {
+		return err
+	}
+	current := 0
+	err := db.QueryRow(`SELECT version FROM schema_migrations WHERE component = ?`, component).Scan(&current)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if current > latest {
+		return fmt.Errorf("%s schema version %d is newer than this build supports (%d), upgrade the program", component, current, latest)
+	}
+	if current == latest {
+		return nil
+	}
+
+	if tables > 0 {
+		backup, err := backupSQLite(db, path, component, current)
+		if err != nil {
+			return fmt.Errorf("backup %s before migration: %w", path, err)
+		}
+		if backup != "" {
+			log.Printf("升级 %s 数据库结构前已备份: %s", component, backup)
+		}
+	}
+	for _, m := range migrations[current:] {
+		if err := applyMigration(db, component, m); err != nil {
+			return fmt.Errorf("%s migration %d (%s): %w", component, m.Version, m.Name, err)
+		}
+		if tables > 0 {
+			log.Printf("已升级 %s 数据库结构: v%d %s", component, m.Version, m.Name)
+		}
+	}
+	return nil
+}
+
+// applyMigration 在一个事务中执行迁移并记录版本
+func applyMigration(db *sql.DB, component string, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := m.Up(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO schema_migrations (component, version, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(component) DO UPDATE SET version = excluded.version, updated_at = excluded.updated_at
+	`, component, m.Version, time.Now().Unix()); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// backupSQLite 用 VACUUM INTO 把数据库完整复制到 <path>.<component>-v<version>.bak，已存在同名备份时保留原备份；
+// path 为空（可重新下载的缓存库）或内存数据库时不备份，返回空路径
+func backupSQLite(db *sql.DB, path, component string, version int) (string, error) {
+	if path == "" || path == ":memory:" {
+		return "", nil
+	}
+	backup := fmt.Sprintf("%s.%s-v%d.bak", path, component, version)
+	if _, err := os.Stat(backup); err == nil {
+		return backup, nil
+	}
+	if _, err := db.Exec(`VACUUM INTO ?`, backup); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
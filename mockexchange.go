@@ -0,0 +1,674 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MockExchangeConfig 模拟交易所：行情按随机游走生成，按配置注入延迟和故障
+type MockExchangeConfig struct {
+	Symbol        string
+	StartPrice    float64       // 初始价格，默认 50000
+	Volatility    float64       // 每分钟收益率的标准差，默认 0.002
+	Balance       float64       // 初始 USDT 余额，默认 10000
+	Leverage      float64       // 计算保证金的杠杆，默认 5
+	FeeRate       float64       // 成交手续费率，默认 liveFeeRate
+	Latency       time.Duration // 每个请求的固定延迟
+	Jitter        time.Duration // 额外的随机延迟 [0, Jitter)
+	ErrorRate     float64       // 返回 500 内部错误的概率
+	RateLimitRate float64       // 返回 429 限频的概率
+	DropRate      float64       // 不响应直接断开连接的概率
+	Seed          int64
+}
+
+// withDefaults 补全默认值
+func (c MockExchangeConfig) withDefaults() MockExchangeConfig {
+	if c.Symbol == "" {
+		c.Symbol = "BTCUSDT"
+	}
+	if c.StartPrice <= 0 {
+		c.StartPrice = 50000
+	}
+	if c.Volatility <= 0 {
+		c.Volatility = 0.002
+	}
+	if c.Balance <= 0 {
+		c.Balance = 10000
+	}
+	if c.Leverage <= 0 {
+		c.Leverage = 5
+	}
+	if c.FeeRate <= 0 {
+		c.FeeRate = liveFeeRate
+	}
+	return c
+}
+
+// mockExchangeConfig 由命令行参数构造模拟交易所配置，故障概率超出 [0, 1] 或合计超过 1 时退出
+func mockExchangeConfig(latency, jitter time.Duration, errorRate, rateLimitRate, dropRate, vol float64, seed int64) MockExchangeConfig {
+	for _, p := range []float64{errorRate, rateLimitRate, dropRate} {
+		if p < 0 || p > 1 {
+			log.Fatalf("-mock-errors / -mock-ratelimit / -mock-drop 须在 0 到 1 之间")
+		}
+	}
+	if errorRate+rateLimitRate+dropRate > 1 {
+		log.Fatalf("-mock-errors、-mock-ratelimit、-mock-drop 之和不能超过 1")
+	}
+	return MockExchangeConfig{
+		Volatility:    vol,
+		Latency:       latency,
+		Jitter:        jitter,
+		ErrorRate:     errorRate,
+		RateLimitRate: rateLimitRate,
+		DropRate:      dropRate,
+		Seed:          seed,
+	}
+}
+
+// mockHistory 启动时预先生成的 1m K 线数，保证引擎能完成预热
+const mockHistory = 3000
+
+// mockMaxBars 保留的 1m K 线上限，长时间运行内存恒定
+const mockMaxBars = 20000
+
+// mockOrder 模拟交易所中的订单
+type mockOrder struct {
+	ID            int64
+	ClientOrderID string
+	Side          string // BUY / SELL
+	Type          string // MARKET / LIMIT / STOP_MARKET / TAKE_PROFIT_MARKET
+	PositionSide  string
+	Qty           float64
+	Price         float64
+	StopPrice     float64
+	ClosePosition bool
+	Status        string // NEW / FILLED / CANCELED
+	ExecutedQty   float64
+	AvgPrice      float64
+	UpdateTime    int64
+}
+
+// MockExchangeStats 模拟交易所的请求和故障统计
+type MockExchangeStats struct {
+	Requests    int64
+	ByEndpoint  map[string]int64
+	Unknown     map[string]int64 // 未实现的接口
+	Errors      int64            // 注入的 500
+	RateLimited int64            // 注入的 429
+	Dropped     int64            // 注入的断开连接
+	Rejected    int64            // 业务拒绝（保证金不足、订单不存在等）
+	Fills       int64
+}
+
+// MockExchange 实现机器人用到的币安合约接口子集：K 线、最新价、深度、账户、持仓、下单 / 查单 / 撤单、
+// 标记价格和资金费率；单一净持仓，市价单按当前价成交，条件单在价格越过触发价时成交
+type MockExchange struct {
+	config MockExchangeConfig
+
+	mu       sync.Mutex
+	rng      *rand.Rand
+	bars     []Kline // 1m K 线，最后一根未走完
+	price    float64
+	clock    int64 // 行情已推进到的时间（秒）
+	balance  float64
+	position float64 // 净持仓，正数为多
+	entry    float64
+	orders   map[string]*mockOrder
+	nextID   int64
+	stats    MockExchangeStats
+}
+
+// NewMockExchange 创建模拟交易所，并生成截至当前时间的历史 K 线
+func NewMockExchange(c MockExchangeConfig) *MockExchange {
+	c = c.withDefaults()
+	seed := c.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	m := &MockExchange{
+		config:  c,
+		rng:     rand.New(rand.NewSource(seed)),
+		price:   c.StartPrice,
+		balance: c.Balance,
+		orders:  make(map[string]*mockOrder),
+		stats:   MockExchangeStats{ByEndpoint: make(map[string]int64), Unknown: make(map[string]int64)},
+	}
+	now := time.Now().Unix()
+	m.clock = now - now%60 - mockHistory*60
+	m.advance(now)
+	return m
+}
+
+// advance 按秒推进随机游走行情到 now，并检查条件单（调用方持有锁）
+func (m *MockExchange) advance(now int64) {
+	sigma := m.config.Volatility / math.Sqrt(60)
+	for ; m.clock < now; m.clock++ {
+		m.price *= math.Exp(m.rng.NormFloat64() * sigma)
+		open := m.clock - m.clock%60
+		volume := m.rng.ExpFloat64() * 0.5
+		if n := len(m.bars); n > 0 && m.bars[n-1].Timestamp == open {
+			bar := &m.bars[n-1]
+			bar.High = max(bar.High, m.price)
+			bar.Low = min(bar.Low, m.price)
+			bar.Close = m.price
+			bar.Volume += volume
+		} else {
+			m.bars = append(m.bars, Kline{Timestamp: open, Open: m.price, High: m.price, Low: m.price, Close: m.price, Volume: volume})
+			if len(m.bars) > mockMaxBars {
+				m.bars = append(m.bars[:0:0], m.bars[len(m.bars)-mockMaxBars:]...)
+			}
+		}
+		m.triggerOrders()
+	}
+}
+
+// triggerOrders 价格越过触发价的止损 / 止盈单按触发价成交（调用方持有锁）
+func (m *MockExchange) triggerOrders() {
+	for _, o := range m.orders {
+		if o.Status != "NEW" || o.StopPrice <= 0 {
+			continue
+		}
+		// 卖出的止损在价格跌破时触发、止盈在涨破时触发，买入相反
+		below := (o.Side == "SELL") == (o.Type == "STOP_MARKET")
+		if below && m.price > o.StopPrice || !below && m.price < o.StopPrice {
+			continue
+		}
+		qty := o.Qty
+		if o.ClosePosition {
+			qty = math.Abs(m.position)
+		}
+		if qty <= 0 || (o.Side == "SELL") != (m.position > 0) {
+			o.Status = "EXPIRED"
+			continue
+		}
+		m.fill(o, qty, o.StopPrice)
+	}
+}
+
+// fill 成交订单：更新净持仓、均价、已实现盈亏和手续费（调用方持有锁）
+func (m *MockExchange) fill(o *mockOrder, qty, price float64) {
+	signed := qty
+	if o.Side == "SELL" {
+		signed = -qty
+	}
+	switch {
+	case m.position == 0 || (m.position > 0) == (signed > 0):
+		// 开仓或加仓
+		total := math.Abs(m.position) + qty
+		m.entry = (m.entry*math.Abs(m.position) + price*qty) / total
+	default:
+		// 减仓，超出部分反向开仓
+		closed := min(qty, math.Abs(m.position))
+		pnl := (price - m.entry) * closed
+		if m.position < 0 {
+			pnl = -pnl
+		}
+		m.balance += pnl
+		if qty > closed {
+			m.entry = price
+		} else if math.Abs(m.position) == closed {
+			m.entry = 0
+		}
+	}
+	m.position += signed
+	if math.Abs(m.position) < 1e-12 {
+		m.position, m.entry = 0, 0
+	}
+	m.balance -= qty * price * m.config.FeeRate
+	o.Status, o.ExecutedQty, o.AvgPrice, o.UpdateTime = "FILLED", qty, price, m.clock*1000
+	m.stats.Fills++
+}
+
+// unrealized 按当前价计的浮动盈亏（调用方持有锁）
+func (m *MockExchange) unrealized() float64 {
+	return (m.price - m.entry) * m.position
+}
+
+// Position 当前净持仓和均价
+func (m *MockExchange) Position() (amount, entryPrice float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.position, m.entry
+}
+
+// Stats 请求和故障统计的副本
+func (m *MockExchange) Stats() MockExchangeStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.stats
+	s.ByEndpoint = make(map[string]int64, len(m.stats.ByEndpoint))
+	for k, v := range m.stats.ByEndpoint {
+		s.ByEndpoint[k] = v
+	}
+	s.Unknown = make(map[string]int64, len(m.stats.Unknown))
+	for k, v := range m.stats.Unknown {
+		s.Unknown[k] = v
+	}
+	return s
+}
+
+// mockEndpoint 按路径末段识别接口，忽略 /fapi/v1、/fapi/v2、/api/v3 等版本前缀
+func mockEndpoint(path string) string {
+	path = strings.TrimSuffix(path, "/")
+	for _, name := range []string{"ticker/price", "ticker/bookTicker", "ticker/24hr"} {
+		if strings.HasSuffix(path, "/"+name) {
+			return name
+		}
+	}
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// ServeHTTP 处理请求：先按配置注入延迟和故障，再按接口返回币安格式的 JSON
+func (m *MockExchange) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	roll := m.rng.Float64()
+	delay := m.config.Latency
+	if m.config.Jitter > 0 {
+		delay += time.Duration(m.rng.Int63n(int64(m.config.Jitter)))
+	}
+	m.stats.Requests++
+	m.mu.Unlock()
+	time.Sleep(delay)
+
+	switch {
+	case roll < m.config.DropRate:
+		m.count(&m.stats.Dropped)
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+		}
+		panic(http.ErrAbortHandler)
+	case roll < m.config.DropRate+m.config.RateLimitRate:
+		m.count(&m.stats.RateLimited)
+		w.Header().Set("Retry-After", "1")
+		mockError(w, http.StatusTooManyRequests, -1003, "Too many requests; current limit is 2400 request weight per 1 MINUTE.")
+		return
+	case roll < m.config.DropRate+m.config.RateLimitRate+m.config.ErrorRate:
+		m.count(&m.stats.Errors)
+		mockError(w, http.StatusInternalServerError, -1001, "Internal error; unable to process your request. Please try your request again.")
+		return
+	}
+
+	r.ParseForm()
+	endpoint := mockEndpoint(r.URL.Path)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.advance(time.Now().Unix())
+
+	var body any
+	var err *mockAPIError
+	switch endpoint {
+	case "time":
+		body = map[string]int64{"serverTime": time.Now().UnixMilli()}
+	case "leverage":
+		body, err = m.leverage(r)
+	case "ping", "marginType", "positionSide":
+		body = map[string]any{"code": 200, "msg": "success", "symbol": m.config.Symbol, "leverage": m.config.Leverage, "dualSidePosition": false}
+	case "klines":
+		body, err = m.klines(r.Form)
+	case "ticker/price":
+		body = map[string]any{"symbol": m.config.Symbol, "price": mockNum(m.price), "time": time.Now().UnixMilli()}
+	case "ticker/bookTicker":
+		bid, ask := m.quotes()
+		body = map[string]any{"symbol": m.config.Symbol, "bidPrice": mockNum(bid), "bidQty": "5", "askPrice": mockNum(ask), "askQty": "5"}
+	case "ticker/24hr":
+		body = map[string]any{"symbol": m.config.Symbol, "lastPrice": mockNum(m.price)}
+	case "depth":
+		body = m.depth()
+	case "premiumIndex":
+		body = map[string]any{"symbol": m.config.Symbol, "markPrice": mockNum(m.price), "indexPrice": mockNum(m.price),
+			"lastFundingRate": "0.0001", "nextFundingTime": (m.clock/28800 + 1) * 28800 * 1000, "time": time.Now().UnixMilli()}
+	case "fundingRate":
+		body = []any{}
+	case "account":
+		body = m.account()
+	case "balance":
+		body = []any{m.asset()}
+	case "positionRisk":
+		body = []any{m.positionRisk()}
+	case "order":
+		body, err = m.order(r.Method, r.Form)
+	case "openOrders":
+		var open []any
+		for _, o := range m.sortedOrders() {
+			if o.Status == "NEW" {
+				open = append(open, m.orderJSON(o))
+			}
+		}
+		body = open
+	case "allOpenOrders":
+		for _, o := range m.orders {
+			if o.Status == "NEW" {
+				o.Status = "CANCELED"
+			}
+		}
+		body = map[string]any{"code": 200, "msg": "The operation of cancel all open order is done."}
+	default:
+		m.stats.Unknown[r.Method+" "+r.URL.Path]++
+		mockError(w, http.StatusNotFound, -1000, "mock exchange: endpoint not implemented")
+		return
+	}
+	m.stats.ByEndpoint[endpoint]++
+	if err != nil {
+		m.stats.Rejected++
+		mockError(w, err.status, err.code, err.msg)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// count 加锁递增计数
+func (m *MockExchange) count(n *int64) {
+	m.mu.Lock()
+	*n++
+	m.mu.Unlock()
+}
+
+// leverage 调整杠杆：POST 时按 leverage 参数修改（1-125 倍），之后的保证金按新杠杆计算
+func (m *MockExchange) leverage(r *http.Request) (any, *mockAPIError) {
+	if r.Method == http.MethodPost {
+		leverage, convErr := strconv.Atoi(r.Form.Get("leverage"))
+		if convErr != nil || leverage < 1 || leverage > 125 {
+			return nil, &mockAPIError{http.StatusBadRequest, -4028, fmt.Sprintf("Leverage %s is not valid", r.Form.Get("leverage"))}
+		}
+		m.config.Leverage = float64(leverage)
+	}
+	return map[string]any{"symbol": m.config.Symbol, "leverage": int(m.config.Leverage), "maxNotionalValue": "1000000"}, nil
+}
+
+// mockAPIError 币安格式的错误响应
+type mockAPIError struct {
+	status int
+	code   int
+	msg    string
+}
+
+// mockError 写入币安格式的错误响应
+func mockError(w http.ResponseWriter, status, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"code": code, "msg": msg})
+}
+
+// mockNum 币安接口中的数值以字符串返回
+func mockNum(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// klines 按请求的周期由 1m K 线合并，支持 startTime / endTime / limit
+func (m *MockExchange) klines(form url.Values) (any, *mockAPIError) {
+	sec, err := ParseInterval(form.Get("interval"))
+	if err != nil {
+		return nil, &mockAPIError{http.StatusBadRequest, -1120, "Invalid interval."}
+	}
+	limit, _ := strconv.Atoi(form.Get("limit"))
+	if limit <= 0 || limit > 1500 {
+		limit = 500
+	}
+	start, _ := strconv.ParseInt(form.Get("startTime"), 10, 64)
+	end, _ := strconv.ParseInt(form.Get("endTime"), 10, 64)
+
+	bars := Resample(m.bars, sec)
+	var rows [][]any
+	for _, k := range bars {
+		if start > 0 && k.Timestamp*1000 < start || end > 0 && k.Timestamp*1000 > end {
+			continue
+		}
+		rows = append(rows, []any{k.Timestamp * 1000, mockNum(k.Open), mockNum(k.High), mockNum(k.Low), mockNum(k.Close),
+			mockNum(k.Volume), (k.Timestamp+sec)*1000 - 1, mockNum(k.Volume * k.Close), 100, "0", "0", "0"})
+	}
+	// 没有 startTime 时返回最近的 limit 根，否则从 startTime 起 limit 根
+	if len(rows) > limit {
+		if start > 0 {
+			rows = rows[:limit]
+		} else {
+			rows = rows[len(rows)-limit:]
+		}
+	}
+	return rows, nil
+}
+
+// quotes 以当前价为中心、1 bp 价差的买一 / 卖一（调用方持有锁）
+func (m *MockExchange) quotes() (bid, ask float64) {
+	return m.price * (1 - 0.00005), m.price * (1 + 0.00005)
+}
+
+// depth 盘口：买卖各 20 档，每档间隔 1 bp
+func (m *MockExchange) depth() any {
+	bid, ask := m.quotes()
+	var bids, asks [][]string
+	for i := 0; i < 20; i++ {
+		qty := mockNum(0.5 + float64(i)*0.25)
+		bids = append(bids, []string{mockNum(bid * (1 - float64(i)*0.0001)), qty})
+		asks = append(asks, []string{mockNum(ask * (1 + float64(i)*0.0001)), qty})
+	}
+	now := time.Now().UnixMilli()
+	return map[string]any{"lastUpdateId": now, "E": now, "T": now, "bids": bids, "asks": asks}
+}
+
+// margin 当前持仓占用的保证金（调用方持有锁）
+func (m *MockExchange) margin() float64 {
+	return math.Abs(m.position) * m.entry / m.config.Leverage
+}
+
+// asset USDT 资产
+func (m *MockExchange) asset() map[string]any {
+	upnl := m.unrealized()
+	return map[string]any{
+		"asset":            "USDT",
+		"walletBalance":    mockNum(m.balance),
+		"unrealizedProfit": mockNum(upnl),
+		"marginBalance":    mockNum(m.balance + upnl),
+		"availableBalance": mockNum(m.balance + min(upnl, 0) - m.margin()),
+		"balance":          mockNum(m.balance),
+	}
+}
+
+// account 合约账户信息
+func (m *MockExchange) account() any {
+	upnl := m.unrealized()
+	return map[string]any{
+		"totalWalletBalance":    mockNum(m.balance),
+		"totalUnrealizedProfit": mockNum(upnl),
+		"totalMarginBalance":    mockNum(m.balance + upnl),
+		"availableBalance":      mockNum(m.balance + min(upnl, 0) - m.margin()),
+		"assets":                []any{m.asset()},
+		"positions":             []any{m.positionRisk()},
+	}
+}
+
+// positionRisk 净持仓
+func (m *MockExchange) positionRisk() map[string]any {
+	return map[string]any{
+		"symbol":           m.config.Symbol,
+		"positionAmt":      mockNum(m.position),
+		"entryPrice":       mockNum(m.entry),
+		"markPrice":        mockNum(m.price),
+		"unRealizedProfit": mockNum(m.unrealized()),
+		"leverage":         mockNum(m.config.Leverage),
+		"positionSide":     "BOTH",
+		"marginType":       "isolated",
+	}
+}
+
+// sortedOrders 按订单号排序的订单，保证输出稳定
+func (m *MockExchange) sortedOrders() []*mockOrder {
+	orders := make([]*mockOrder, 0, len(m.orders))
+	for _, o := range m.orders {
+		orders = append(orders, o)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+	return orders
+}
+
+// orderJSON 订单的币安格式
+func (m *MockExchange) orderJSON(o *mockOrder) map[string]any {
+	return map[string]any{
+		"orderId":       o.ID,
+		"clientOrderId": o.ClientOrderID,
+		"symbol":        m.config.Symbol,
+		"side":          o.Side,
+		"positionSide":  o.PositionSide,
+		"type":          o.Type,
+		"origType":      o.Type,
+		"status":        o.Status,
+		"origQty":       mockNum(o.Qty),
+		"executedQty":   mockNum(o.ExecutedQty),
+		"cumQuote":      mockNum(o.ExecutedQty * o.AvgPrice),
+		"price":         mockNum(o.Price),
+		"avgPrice":      mockNum(o.AvgPrice),
+		"stopPrice":     mockNum(o.StopPrice),
+		"closePosition": o.ClosePosition,
+		"updateTime":    o.UpdateTime,
+	}
+}
+
+// findOrder 按 origClientOrderId 或 orderId 查找订单
+func (m *MockExchange) findOrder(form url.Values) *mockOrder {
+	if id := form.Get("origClientOrderId"); id != "" {
+		return m.orders[id]
+	}
+	if id, err := strconv.ParseInt(form.Get("orderId"), 10, 64); err == nil {
+		for _, o := range m.orders {
+			if o.ID == id {
+				return o
+			}
+		}
+	}
+	return nil
+}
+
+// order 下单（POST）、查单（GET）、撤单（DELETE）
+func (m *MockExchange) order(method string, form url.Values) (any, *mockAPIError) {
+	switch method {
+	case http.MethodGet, http.MethodDelete:
+		o := m.findOrder(form)
+		if o == nil {
+			return nil, &mockAPIError{http.StatusBadRequest, -2013, "Order does not exist."}
+		}
+		if method == http.MethodDelete {
+			if o.Status != "NEW" {
+				return nil, &mockAPIError{http.StatusBadRequest, -2011, "Unknown order sent."}
+			}
+			o.Status = "CANCELED"
+		}
+		return m.orderJSON(o), nil
+	case http.MethodPost:
+	default:
+		return nil, &mockAPIError{http.StatusMethodNotAllowed, -1000, "method not allowed"}
+	}
+
+	clientID := form.Get("newClientOrderId")
+	if clientID == "" {
+		clientID = fmt.Sprintf("mock-%d", m.nextID+1)
+	}
+	if _, ok := m.orders[clientID]; ok {
+		return nil, &mockAPIError{http.StatusBadRequest, -4015, "Client order id is not valid."}
+	}
+	qty, _ := strconv.ParseFloat(form.Get("quantity"), 64)
+	if qty <= 0 {
+		if quote, _ := strconv.ParseFloat(form.Get("quoteOrderQty"), 64); quote > 0 {
+			qty = quote / m.price
+		}
+	}
+	price, _ := strconv.ParseFloat(form.Get("price"), 64)
+	stop, _ := strconv.ParseFloat(form.Get("stopPrice"), 64)
+	m.nextID++
+	o := &mockOrder{
+		ID:            m.nextID,
+		ClientOrderID: clientID,
+		Side:          strings.ToUpper(form.Get("side")),
+		Type:          strings.ToUpper(form.Get("type")),
+		PositionSide:  form.Get("positionSide"),
+		Qty:           qty,
+		Price:         price,
+		StopPrice:     stop,
+		ClosePosition: form.Get("closePosition") == "true",
+		Status:        "NEW",
+		UpdateTime:    m.clock * 1000,
+	}
+	if o.Side != "BUY" && o.Side != "SELL" {
+		return nil, &mockAPIError{http.StatusBadRequest, -1102, "Mandatory parameter 'side' was not sent, was empty/null, or malformed."}
+	}
+	if o.Type == "" {
+		o.Type = "MARKET"
+	}
+	if o.Qty <= 0 && !o.ClosePosition {
+		return nil, &mockAPIError{http.StatusBadRequest, -4003, "Quantity less than or equal to zero."}
+	}
+
+	// 开仓方向的成交须有足够的可用保证金
+	increases := m.position == 0 || (m.position > 0) == (o.Side == "BUY")
+	if increases && o.StopPrice == 0 {
+		fillPrice := m.price
+		if o.Type == "LIMIT" && price > 0 {
+			fillPrice = price
+		}
+		available := m.balance + min(m.unrealized(), 0) - m.margin()
+		if o.Qty*fillPrice/m.config.Leverage > available {
+			m.nextID--
+			return nil, &mockAPIError{http.StatusBadRequest, -2019, "Margin is insufficient."}
+		}
+	}
+	m.orders[clientID] = o
+
+	bid, ask := m.quotes()
+	switch o.Type {
+	case "MARKET":
+		fillPrice := ask
+		if o.Side == "SELL" {
+			fillPrice = bid
+		}
+		m.fill(o, o.Qty, fillPrice)
+	case "LIMIT":
+		// 可立即成交的限价单按挂单价成交，否则挂单（模拟交易所不撮合挂单，由调用方撤单）
+		if o.Side == "BUY" && price >= ask || o.Side == "SELL" && price <= bid {
+			m.fill(o, o.Qty, price)
+		}
+	}
+	return m.orderJSON(o), nil
+}
+
+// mockTransport 将发往 *.binance.com 的请求改发到模拟交易所，其他请求照常发送
+type mockTransport struct {
+	target string // 模拟交易所地址 host:port
+	base   http.RoundTripper
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	if host != "binance.com" && !strings.HasSuffix(host, ".binance.com") {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.URL.Scheme = "http"
+	req.URL.Host = t.target
+	req.Host = t.target
+	return t.base.RoundTrip(req)
+}
+
+// ServeMockExchange 在 addr 上启动模拟交易所（addr 端口为 0 时随机分配），返回实际监听地址
+func ServeMockExchange(m *MockExchange, addr string) (string, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	go http.Serve(ln, m)
+	return ln.Addr().String(), nil
+}
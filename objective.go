@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// 优化目标，均按越大越好比较
+const (
+	ObjectivePnL          = "pnl"           // 总盈亏
+	ObjectivePnLDrawdown  = "pnl_dd"        // 总盈亏 / 最大回撤
+	ObjectiveSharpe       = "sharpe"        // 日收益夏普比率
+	ObjectiveCalmar       = "calmar"        // 年化收益 / 最大回撤
+	ObjectiveProfitFactor = "profit_factor" // 盈亏比
+	ObjectiveTrades       = "trades"        // 交易次数
+	ObjectiveDrawdown     = "drawdown"      // 最大回撤（取负，回撤越小越好）
+)
+
+// objectiveMetric 优化目标的取值和显示名称
+type objectiveMetric struct {
+	label string
+	value func(r OptimizeResult) float64
+}
+
+// objectiveMetrics 可用的优化目标
+var objectiveMetrics = map[string]objectiveMetric{
+	ObjectivePnL: {"总盈亏", func(r OptimizeResult) float64 { return r.TotalPnL }},
+	ObjectivePnLDrawdown: {"盈亏/回撤", func(r OptimizeResult) float64 {
+		if r.MaxDrawdown <= 0 {
+			return r.TotalPnL
+		}
+		return r.TotalPnL / r.MaxDrawdown
+	}},
+	ObjectiveSharpe:       {"夏普", func(r OptimizeResult) float64 { return r.Sharpe }},
+	ObjectiveCalmar:       {"卡玛", func(r OptimizeResult) float64 { return r.Calmar }},
+	ObjectiveProfitFactor: {"盈亏比", func(r OptimizeResult) float64 { return r.ProfitFactor }},
+	ObjectiveTrades:       {"交易次数", func(r OptimizeResult) float64 { return float64(r.Trades) }},
+	ObjectiveDrawdown:     {"最大回撤", func(r OptimizeResult) float64 { return -r.MaxDrawdown }},
+}
+
+// objectiveNames 按固定顺序列出优化目标，用于帮助信息
+var objectiveNames = []string{
+	ObjectivePnL, ObjectivePnLDrawdown, ObjectiveSharpe, ObjectiveCalmar,
+	ObjectiveProfitFactor, ObjectiveTrades, ObjectiveDrawdown,
+}
+
+// DefaultParetoObjectives 默认多目标：最大化盈亏、最小化回撤、最大化交易次数
+var DefaultParetoObjectives = []string{ObjectivePnL, ObjectiveDrawdown, ObjectiveTrades}
+
+// ResultFilter 参数组合参与排名的约束，0 表示不限制
+type ResultFilter struct {
+	MinTrades       int     `json:"min_trades,omitempty"`
+	MaxDrawdown     float64 `json:"max_drawdown,omitempty"` // 0-1
+	MinWinRate      float64 `json:"min_win_rate,omitempty"` // 0-1
+	MaxConsecLosses int     `json:"max_consec_losses,omitempty"`
+}
+
+// Validate 校验约束取值
+func (f ResultFilter) Validate() error {
+	if f.MinTrades < 0 || f.MaxConsecLosses < 0 {
+		return fmt.Errorf("min trades and max consecutive losses must be non-negative")
+	}
+	if f.MaxDrawdown < 0 || f.MaxDrawdown > 1 || f.MinWinRate < 0 || f.MinWinRate > 1 {
+		return fmt.Errorf("max drawdown and min win rate must be in [0, 1]")
+	}
+	return nil
+}
+
+// Allows 判断参数组合是否满足所有约束
+func (f ResultFilter) Allows(r OptimizeResult) bool {
+	if r.Trades < f.MinTrades {
+		return false
+	}
+	if f.MaxDrawdown > 0 && r.MaxDrawdown > f.MaxDrawdown {
+		return false
+	}
+	if f.MinWinRate > 0 && r.WinRate < f.MinWinRate {
+		return false
+	}
+	if f.MaxConsecLosses > 0 && r.MaxConsecLosses > f.MaxConsecLosses {
+		return false
+	}
+	return true
+}
+
+// String 约束描述，没有约束时为空
+func (f ResultFilter) String() string {
+	var parts []string
+	if f.MinTrades > 0 {
+		parts = append(parts, fmt.Sprintf("交易次数 >= %d", f.MinTrades))
+	}
+	if f.MaxDrawdown > 0 {
+		parts = append(parts, fmt.Sprintf("最大回撤 <= %.1f%%", f.MaxDrawdown*100))
+	}
+	if f.MinWinRate > 0 {
+		parts = append(parts, fmt.Sprintf("胜率 >= %.1f%%", f.MinWinRate*100))
+	}
+	if f.MaxConsecLosses > 0 {
+		parts = append(parts, fmt.Sprintf("最大连亏 <= %d", f.MaxConsecLosses))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Objective 单目标排名：按 Metric 降序，不满足 Filter 的组合不参与排名，
+// 避免只交易几笔或回撤过大的参数因偶然结果排在前面
+type Objective struct {
+	Metric string
+	Filter ResultFilter
+}
+
+// ParseObjective 校验优化目标和约束，metric 为空时使用 pnl
+func ParseObjective(metric string, filter ResultFilter) (Objective, error) {
+	if metric == "" {
+		metric = ObjectivePnL
+	}
+	if _, ok := objectiveMetrics[metric]; !ok {
+		return Objective{}, fmt.Errorf("unknown objective %q (want one of %s)", metric, strings.Join(objectiveNames, ", "))
+	}
+	if err := filter.Validate(); err != nil {
+		return Objective{}, err
+	}
+	return Objective{Metric: metric, Filter: filter}, nil
+}
+
+// ParseParetoObjectives 解析逗号分隔的多目标列表，至少两个，为空时使用默认目标
+func ParseParetoObjectives(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return DefaultParetoObjectives, nil
+	}
+	var metrics []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := objectiveMetrics[name]; !ok {
+			return nil, fmt.Errorf("unknown objective %q (want one of %s)", name, strings.Join(objectiveNames, ", "))
+		}
+		metrics = append(metrics, name)
+	}
+	if len(metrics) < 2 {
+		return nil, fmt.Errorf("pareto needs at least two objectives")
+	}
+	return metrics, nil
+}
+
+// Label 目标的显示名称
+func (o Objective) Label() string {
+	return objectiveMetrics[o.Metric].label
+}
+
+// Qualified 参数组合是否满足约束
+func (o Objective) Qualified(r OptimizeResult) bool {
+	return o.Filter.Allows(r)
+}
+
+// Score 目标值，不满足约束时为 -Inf
+func (o Objective) Score(r OptimizeResult) float64 {
+	if !o.Qualified(r) {
+		return math.Inf(-1)
+	}
+	return objectiveMetrics[o.Metric].value(r)
+}
+
+// Rank 按目标值降序排序
+func (o Objective) Rank(results []OptimizeResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return o.Score(results[i]) > o.Score(results[j])
+	})
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// optArchiveVersion 优化结果归档的格式版本
+const optArchiveVersion = 1
+
+// OptArchiveHeader 优化结果归档的文件头：产生结果的数据范围和搜索方式
+type OptArchiveHeader struct {
+	Version   int
+	Created   int64 // 写入时间（秒）
+	Symbol    string
+	StartTime int64
+	EndTime   int64
+	Interval  int64
+	Optimizer string
+	Count     int // 结果条数
+}
+
+// WriteOptArchive 把全部参数组合的结果写入归档：gzip 压缩的 gob 流，文件头之后逐条编码结果，
+// 百万级组合也只占几十 MB，opt-query 可在不重新回测的情况下事后筛选
+func WriteOptArchive(path string, header OptArchiveHeader, results []OptimizeResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	enc := gob.NewEncoder(zw)
+	header.Version = optArchiveVersion
+	header.Count = len(results)
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+	for i := range results {
+		if err := enc.Encode(&results[i]); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// ReadOptArchive 读取归档的文件头和全部结果
+func ReadOptArchive(path string) (OptArchiveHeader, []OptimizeResult, error) {
+	var header OptArchiveHeader
+	f, err := os.Open(path)
+	if err != nil {
+		return header, nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return header, nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	dec := gob.NewDecoder(zr)
+	if err := dec.Decode(&header); err != nil {
+		return header, nil, fmt.Errorf("read %s header: %w", path, err)
+	}
+	if header.Version != optArchiveVersion {
+		return header, nil, fmt.Errorf("unsupported archive version %d", header.Version)
+	}
+	results := make([]OptimizeResult, 0, header.Count)
+	for {
+		var r OptimizeResult
+		if err := dec.Decode(&r); err == io.EOF {
+			break
+		} else if err != nil {
+			return header, nil, fmt.Errorf("read %s result %d: %w", path, len(results), err)
+		}
+		results = append(results, r)
+	}
+	if len(results) != header.Count {
+		return header, nil, fmt.Errorf("archive %s truncated: %d of %d results", path, len(results), header.Count)
+	}
+	return header, results, nil
+}
+
+// writeOptArchive 优化结束后写入归档，path 为空时跳过；失败只记录日志，不影响优化结果
+func writeOptArchive(path string, header OptArchiveHeader, results []OptimizeResult) {
+	if path == "" {
+		return
+	}
+	header.Created = time.Now().Unix()
+	if err := WriteOptArchive(path, header, results); err != nil {
+		log.Printf("写入优化结果归档失败: %v", err)
+		return
+	}
+	log.Printf("优化结果归档已写入: %s (%d 组)", path, len(results))
+}
+
+// optQueryFields opt-query 表达式可用的字段：与 Pareto CSV 的列名一致（总盈亏为 pnl）
+var optQueryFields = map[string]func(r OptimizeResult) float64{
+	"pnl":                  func(r OptimizeResult) float64 { return r.TotalPnL },
+	"max_drawdown":         func(r OptimizeResult) float64 { return r.MaxDrawdown },
+	"trades":               func(r OptimizeResult) float64 { return float64(r.Trades) },
+	"win_rate":             func(r OptimizeResult) float64 { return r.WinRate },
+	"profit_factor":        func(r OptimizeResult) float64 { return r.ProfitFactor },
+	"sharpe":               func(r OptimizeResult) float64 { return r.Sharpe },
+	"calmar":               func(r OptimizeResult) float64 { return r.Calmar },
+	"max_consec_losses":    func(r OptimizeResult) float64 { return float64(r.MaxConsecLosses) },
+	"rsi_period":           func(r OptimizeResult) float64 { return float64(r.Config.RSI_PERIOD) },
+	"rsi_oversold_long":    func(r OptimizeResult) float64 { return r.Config.RSI_OVERSOLD_LONG },
+	"rsi_entry_long":       func(r OptimizeResult) float64 { return r.Config.RSI_ENTRY_LONG },
+	"rsi_overbought_short": func(r OptimizeResult) float64 { return r.Config.RSI_OVERBOUGHT_SHORT },
+	"rsi_entry_short":      func(r OptimizeResult) float64 { return r.Config.RSI_ENTRY_SHORT },
+	"ema_fast":             func(r OptimizeResult) float64 { return float64(r.Config.EMA_FAST) },
+	"ema_slow":             func(r OptimizeResult) float64 { return float64(r.Config.EMA_SLOW) },
+	"vol_ratio_threshold":  func(r OptimizeResult) float64 { return r.Config.VOL_RATIO_THRESHOLD },
+	"stop_loss_pct":        func(r OptimizeResult) float64 { return r.Config.StopLossPct },
+	"take_profit_pct":      func(r OptimizeResult) float64 { return r.Config.TakeProfitPct },
+}
+
+// optQueryFieldNames 排序后的字段名，用于错误信息
+func optQueryFieldNames() []string {
+	names := make([]string, 0, len(optQueryFields))
+	for name := range optQueryFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// optCondition 单个比较条件，如 sharpe>1
+type optCondition struct {
+	field string
+	op    string
+	value float64
+}
+
+func (c optCondition) match(r OptimizeResult) bool {
+	v := optQueryFields[c.field](r)
+	switch c.op {
+	case ">":
+		return v > c.value
+	case ">=":
+		return v >= c.value
+	case "<":
+		return v < c.value
+	case "<=":
+		return v <= c.value
+	case "=", "==":
+		return v == c.value
+	default: // "!="
+		return v != c.value
+	}
+}
+
+// OptQuery 解析后的查询表达式：or 连接的若干组 and 条件（and 优先），空表达式匹配全部
+type OptQuery [][]optCondition
+
+// optQueryToken 拆分运算符，使 "sharpe>1" 与 "sharpe > 1" 得到相同的词
+var optQueryToken = regexp.MustCompile(`(>=|<=|==|!=|&&|\|\||[<>=])`)
+
+// ParseOptQuery 解析 "sharpe>1 and trades>100 or pnl>=500" 形式的表达式，and 优先于 or，关键字不区分大小写
+func ParseOptQuery(expr string) (OptQuery, error) {
+	tokens := strings.Fields(optQueryToken.ReplaceAllString(expr, " $1 "))
+	var query OptQuery
+	var group []optCondition
+	for i := 0; i < len(tokens); i += 4 {
+		if i+3 > len(tokens) {
+			return nil, fmt.Errorf("incomplete condition %q (want field>value)", strings.Join(tokens[i:], " "))
+		}
+		c, err := parseOptCondition(tokens[i], tokens[i+1], tokens[i+2])
+		if err != nil {
+			return nil, err
+		}
+		group = append(group, c)
+		if i+3 == len(tokens) {
+			break
+		}
+		switch strings.ToLower(tokens[i+3]) {
+		case "and", "&&":
+		case "or", "||":
+			query = append(query, group)
+			group = nil
+		default:
+			return nil, fmt.Errorf("expected and/or before %q", tokens[i+3])
+		}
+		if i+4 == len(tokens) {
+			return nil, fmt.Errorf("dangling %q", tokens[i+3])
+		}
+	}
+	if len(group) > 0 {
+		query = append(query, group)
+	}
+	return query, nil
+}
+
+// parseOptCondition 解析单个比较条件
+func parseOptCondition(field, op, value string) (optCondition, error) {
+	field = strings.ToLower(field)
+	if _, ok := optQueryFields[field]; !ok {
+		return optCondition{}, fmt.Errorf("unknown field %q (want one of %s)", field, strings.Join(optQueryFieldNames(), ", "))
+	}
+	switch op {
+	case ">", ">=", "<", "<=", "=", "==", "!=":
+	default:
+		return optCondition{}, fmt.Errorf("invalid operator %q after %s", op, field)
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return optCondition{}, fmt.Errorf("invalid value %q for %s", value, field)
+	}
+	return optCondition{field: field, op: op, value: v}, nil
+}
+
+// Match 结果满足任意一组 and 条件
+func (q OptQuery) Match(r OptimizeResult) bool {
+	if len(q) == 0 {
+		return true
+	}
+	for _, group := range q {
+		matched := true
+		for _, c := range group {
+			if !c.match(r) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter 返回满足表达式的结果
+func (q OptQuery) Filter(results []OptimizeResult) []OptimizeResult {
+	var out []OptimizeResult
+	for _, r := range results {
+		if q.Match(r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// runOptQueryCmd 从归档中筛选参数组合，按优化目标排序打印前 limit 组（0 为全部），exportPath 非空时把全部匹配结果按排序导出为 CSV
+func runOptQueryCmd(archivePath, expr string, objective Objective, limit int, exportPath string) {
+	if archivePath == "" {
+		log.Fatalf("opt-query 模式需要 -opt-archive 参数")
+	}
+	query, err := ParseOptQuery(expr)
+	if err != nil {
+		log.Fatalf("查询表达式错误: %v", err)
+	}
+	header, results, err := ReadOptArchive(archivePath)
+	if err != nil {
+		log.Fatalf("读取优化结果归档失败: %v", err)
+	}
+	format := func(ts int64) string { return time.Unix(ts, 0).UTC().Format("2006-01-02") }
+	fmt.Printf("归档: %s %s ~ %s, %ds K 线, %s 搜索, %d 组 (写入于 %s)\n",
+		header.Symbol, format(header.StartTime), format(header.EndTime), header.Interval,
+		header.Optimizer, header.Count, time.Unix(header.Created, 0).Format("2006-01-02 15:04:05"))
+
+	matched := query.Filter(results)
+	fmt.Printf("满足 %q 的组合: %d/%d\n", expr, len(matched), len(results))
+	if len(matched) == 0 {
+		return
+	}
+	if limit <= 0 {
+		limit = len(matched)
+	}
+	printRankedResults(matched, objective, limit)
+	if exportPath != "" {
+		if err := WriteParetoCSV(exportPath, matched); err != nil {
+			log.Fatalf("导出查询结果失败: %v", err)
+		}
+		log.Printf("查询结果已导出: %s", exportPath)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 参数优化搜索方式
+const (
+	OptimizerGrid = "grid" // 遍历参数网格
+	OptimizerGA   = "ga"   // 遗传算法
+)
+
+// OptimizeOptions 参数优化选项
+type OptimizeOptions struct {
+	Spec       OptimizerSpec // 参数空间与约束
+	ParetoPath string        // 非空时输出 Pareto 前沿并导出到该 CSV
+	Optimizer  string        // grid（默认）或 ga
+	GA         GAOptions
+	Objective  Objective // 单目标排名（以及遗传算法的适应度）
+	Pareto     []string  // 多目标优化的目标列表
+	Interval   int64     // K 线周期（秒）
+	// 实验记录库，为空时不记录
+	ExperimentsPath string
+	// 全部组合结果的归档文件，为空时不写入，供 opt-query 事后筛选
+	ArchivePath string
+}
+
+// IndicatorCache 按周期缓存整段 K 线的指标序列，参数优化中相同周期的参数组合共享，并发安全
+type IndicatorCache struct {
+	klines []Kline
+	mu     sync.Mutex
+	rsi    map[int][]float64
+	ema    map[int][]float64
+	vol    map[int][]float64
+}
+
+// NewIndicatorCache 创建指标缓存，序列在首次使用时计算
+func NewIndicatorCache(klines []Kline) *IndicatorCache {
+	return &IndicatorCache{
+		klines: klines,
+		rsi:    make(map[int][]float64),
+		ema:    make(map[int][]float64),
+		vol:    make(map[int][]float64),
+	}
+}
+
+// cached 取出或计算 period 对应的序列（调用方持有锁）
+func (c *IndicatorCache) cached(m map[int][]float64, period int, calc func([]Kline, int) []float64) []float64 {
+	s, ok := m[period]
+	if !ok {
+		s = calc(c.klines, period)
+		m[period] = s
+	}
+	return s
+}
+
+// series 返回 RSIEngine 使用的指标序列；K 线不足以计算时返回 nil
+func (c *IndicatorCache) series(sc StrategyConfig) *rsiSeries {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := &rsiSeries{
+		rsi:      c.cached(c.rsi, sc.RSI_PERIOD, CalculateRSI),
+		emaFast:  c.cached(c.ema, sc.EMA_FAST, CalculateEMA),
+		emaSlow:  c.cached(c.ema, sc.EMA_SLOW, CalculateEMA),
+		volRatio: c.cached(c.vol, sc.RSI_PERIOD, VolumeRatio),
+	}
+	if s.rsi == nil || s.emaFast == nil || s.emaSlow == nil || s.volRatio == nil {
+		return nil
+	}
+	return s
+}
+
+// evaluateGrid 用 GOMAXPROCS 个 worker 并行回测参数组合，指标序列按周期预计算一次
+func evaluateGrid(klines []Kline, config BacktestConfig, grid []StrategyConfig) []OptimizeResult {
+	return evaluateConfigs(NewIndicatorCache(klines), config, grid, true)
+}
+
+// evaluateConfigs 用 GOMAXPROCS 个 worker 并行回测参数组合，结果顺序与 grid 一致；
+// progress 为 true 时由调用方 goroutine 汇总打印进度
+func evaluateConfigs(cache *IndicatorCache, config BacktestConfig, grid []StrategyConfig, progress bool) []OptimizeResult {
+	if len(grid) == 0 {
+		return nil
+	}
+	results := make([]OptimizeResult, len(grid))
+	jobs := make(chan int)
+	done := make(chan struct{}, len(grid))
+
+	workers := min(runtime.GOMAXPROCS(0), len(grid))
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				results[i] = evaluateConfig(cache, config, grid[i])
+				done <- struct{}{}
+			}
+		}()
+	}
+	go func() {
+		for i := range grid {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	start := time.Now()
+	step := max(len(grid)/20, 1)
+	for n := 1; n <= len(grid); n++ {
+		<-done
+		if progress && (n%step == 0 || n == len(grid)) {
+			elapsed := time.Since(start)
+			remaining := time.Duration(float64(elapsed) / float64(n) * float64(len(grid)-n))
+			fmt.Printf("进度: %d/%d (%d 个 worker, 已用 %s, 预计剩余 %s)\n",
+				n, len(grid), workers, elapsed.Round(time.Second), remaining.Round(time.Second))
+		}
+	}
+	return results
+}
+
+// reportOptimizeResults 按选项输出优化结果
+func reportOptimizeResults(results []OptimizeResult, opts OptimizeOptions) {
+	if opts.ParetoPath == "" {
+		printTopResults(results, opts.Objective)
+		return
+	}
+
+	front := ParetoFront(results, opts.Pareto, opts.Objective.Filter)
+	printParetoFront(front, opts.Pareto)
+	if err := WriteParetoCSV(opts.ParetoPath, front); err != nil {
+		log.Fatalf("导出 Pareto 前沿失败: %v", err)
+	}
+	log.Printf("Pareto 前沿已导出: %s", opts.ParetoPath)
+}
+
+// dominates 判断 a 是否支配 b：各目标都不差于 b，且至少一项严格更优
+func dominates(a, b OptimizeResult, objectives []string) bool {
+	better := false
+	for _, name := range objectives {
+		va, vb := objectiveMetrics[name].value(a), objectiveMetrics[name].value(b)
+		if va < vb {
+			return false
+		}
+		if va > vb {
+			better = true
+		}
+	}
+	return better
+}
+
+// ParetoFront 返回满足约束的组合中的非支配解集，按第一个目标降序
+func ParetoFront(results []OptimizeResult, objectives []string, filter ResultFilter) []OptimizeResult {
+	var candidates []OptimizeResult
+	for _, r := range results {
+		if filter.Allows(r) {
+			candidates = append(candidates, r)
+		}
+	}
+
+	var front []OptimizeResult
+	for i, r := range candidates {
+		dominated := false
+		for j, other := range candidates {
+			if i != j && dominates(other, r, objectives) {
+				dominated = true
+				break
+			}
+		}
+		if !dominated {
+			front = append(front, r)
+		}
+	}
+
+	first := objectiveMetrics[objectives[0]].value
+	sort.SliceStable(front, func(i, j int) bool {
+		return first(front[i]) > first(front[j])
+	})
+	return front
+}
+
+// printParetoFront 打印 Pareto 前沿
+func printParetoFront(front []OptimizeResult, objectives []string) {
+	labels := make([]string, len(objectives))
+	for i, name := range objectives {
+		labels[i] = objectiveMetrics[name].label
+	}
+	fmt.Printf("\n========== Pareto 前沿 (%d 组, 目标: %s) ==========\n", len(front), strings.Join(labels, " / "))
+	fmt.Println("总盈亏 | 最大回撤 | 交易次数 | 胜率 | 盈亏比 | 夏普 | 卡玛 | 参数")
+	fmt.Println("-------|----------|----------|------|--------|------|------|------")
+	for _, r := range front {
+		fmt.Printf("$%.2f | %.2f%% | %d | %.1f%% | %.2f | %.2f | %.2f | long: %.0f->%.0f short: %.0f->%.0f vol=%.1f ema=%d/%d%s\n",
+			r.TotalPnL, r.MaxDrawdown*100, r.Trades, r.WinRate*100, r.ProfitFactor, r.Sharpe, r.Calmar,
+			r.Config.RSI_OVERSOLD_LONG, r.Config.RSI_ENTRY_LONG,
+			r.Config.RSI_OVERBOUGHT_SHORT, r.Config.RSI_ENTRY_SHORT,
+			r.Config.VOL_RATIO_THRESHOLD, r.Config.EMA_FAST, r.Config.EMA_SLOW, exitParams(r.Config))
+	}
+}
+
+// WriteParetoCSV 导出 Pareto 前沿
+func WriteParetoCSV(path string, front []OptimizeResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := []string{
+		"total_pnl", "max_drawdown", "trades", "win_rate", "profit_factor", "sharpe", "calmar", "max_consec_losses",
+		"rsi_period", "rsi_oversold_long", "rsi_entry_long", "rsi_overbought_short", "rsi_entry_short",
+		"ema_fast", "ema_slow", "vol_ratio_threshold", "stop_loss_pct", "take_profit_pct",
+	}
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	for _, r := range front {
+		row := []string{
+			strconv.FormatFloat(r.TotalPnL, 'f', 2, 64),
+			strconv.FormatFloat(r.MaxDrawdown, 'f', 4, 64),
+			strconv.Itoa(r.Trades),
+			strconv.FormatFloat(r.WinRate, 'f', 4, 64),
+			strconv.FormatFloat(r.ProfitFactor, 'f', 4, 64),
+			strconv.FormatFloat(r.Sharpe, 'f', 4, 64),
+			strconv.FormatFloat(r.Calmar, 'f', 4, 64),
+			strconv.Itoa(r.MaxConsecLosses),
+			strconv.Itoa(r.Config.RSI_PERIOD),
+			strconv.FormatFloat(r.Config.RSI_OVERSOLD_LONG, 'f', -1, 64),
+			strconv.FormatFloat(r.Config.RSI_ENTRY_LONG, 'f', -1, 64),
+			strconv.FormatFloat(r.Config.RSI_OVERBOUGHT_SHORT, 'f', -1, 64),
+			strconv.FormatFloat(r.Config.RSI_ENTRY_SHORT, 'f', -1, 64),
+			strconv.Itoa(r.Config.EMA_FAST),
+			strconv.Itoa(r.Config.EMA_SLOW),
+			strconv.FormatFloat(r.Config.VOL_RATIO_THRESHOLD, 'f', -1, 64),
+			strconv.FormatFloat(r.Config.StopLossPct, 'f', -1, 64),
+			strconv.FormatFloat(r.Config.TakeProfitPct, 'f', -1, 64),
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+
+	return w.Error()
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// strategyParam 可优化的策略参数
+type strategyParam struct {
+	name string
+	get  func(c StrategyConfig) float64
+	set  func(c *StrategyConfig, v float64)
+}
+
+// strategyParams 参数名（与 config.json 一致）到 StrategyConfig 字段的映射
+// 新增策略参数时在此登记，即可在优化配置中使用
+var strategyParams = []strategyParam{
+	{"rsi_period", func(c StrategyConfig) float64 { return float64(c.RSI_PERIOD) }, func(c *StrategyConfig, v float64) { c.RSI_PERIOD = int(v) }},
+	{"rsi_oversold_long", func(c StrategyConfig) float64 { return c.RSI_OVERSOLD_LONG }, func(c *StrategyConfig, v float64) { c.RSI_OVERSOLD_LONG = v }},
+	{"rsi_entry_long", func(c StrategyConfig) float64 { return c.RSI_ENTRY_LONG }, func(c *StrategyConfig, v float64) { c.RSI_ENTRY_LONG = v }},
+	{"rsi_overbought_short", func(c StrategyConfig) float64 { return c.RSI_OVERBOUGHT_SHORT }, func(c *StrategyConfig, v float64) { c.RSI_OVERBOUGHT_SHORT = v }},
+	{"rsi_entry_short", func(c StrategyConfig) float64 { return c.RSI_ENTRY_SHORT }, func(c *StrategyConfig, v float64) { c.RSI_ENTRY_SHORT = v }},
+	{"ema_fast", func(c StrategyConfig) float64 { return float64(c.EMA_FAST) }, func(c *StrategyConfig, v float64) { c.EMA_FAST = int(v) }},
+	{"ema_slow", func(c StrategyConfig) float64 { return float64(c.EMA_SLOW) }, func(c *StrategyConfig, v float64) { c.EMA_SLOW = int(v) }},
+	{"vol_ratio_threshold", func(c StrategyConfig) float64 { return c.VOL_RATIO_THRESHOLD }, func(c *StrategyConfig, v float64) { c.VOL_RATIO_THRESHOLD = v }},
+	{"stop_loss_pct", func(c StrategyConfig) float64 { return c.StopLossPct }, func(c *StrategyConfig, v float64) { c.StopLossPct = v }},
+	{"take_profit_pct", func(c StrategyConfig) float64 { return c.TakeProfitPct }, func(c *StrategyConfig, v float64) { c.TakeProfitPct = v }},
+}
+
+// lookupParam 按名称查找策略参数
+func lookupParam(name string) (strategyParam, bool) {
+	for _, p := range strategyParams {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return strategyParam{}, false
+}
+
+// OptimizerSpec 优化配置：参数取值范围 + 参数间约束
+type OptimizerSpec struct {
+	// 参数名 -> 候选值，未列出的参数取 DefaultConfig
+	Ranges map[string][]float64 `json:"ranges"`
+	// 约束表达式，如 "ema_fast < ema_slow"、"rsi_entry_long >= 50"
+	Constraints []string `json:"constraints"`
+	// 优化目标（命令行 -objective 优先），多目标优化的目标列表（-pareto-objectives 优先）
+	Objective        string   `json:"objective,omitempty"`
+	ParetoObjectives []string `json:"pareto_objectives,omitempty"`
+	// 参与排名的约束：min_trades、max_drawdown、min_win_rate、max_consec_losses（对应命令行参数优先）
+	ResultFilter
+}
+
+// DefaultOptimizerSpec 默认参数空间（多空分开）
+var DefaultOptimizerSpec = OptimizerSpec{
+	Ranges: map[string][]float64{
+		"rsi_period":           {14},
+		"rsi_oversold_long":    {35, 40, 45},
+		"rsi_entry_long":       {45, 50, 55},
+		"rsi_overbought_short": {55, 60, 65},
+		"rsi_entry_short":      {45, 50, 55},
+		"vol_ratio_threshold":  {1.0, 1.5, 2.0},
+		"ema_fast":             {5, 7, 10},
+		"ema_slow":             {14, 20, 30},
+	},
+	Constraints: []string{
+		"rsi_oversold_long < rsi_entry_long",
+		"rsi_overbought_short > rsi_entry_short",
+		"ema_fast < ema_slow",
+	},
+}
+
+// LoadOptimizerSpec 加载优化配置
+func LoadOptimizerSpec(path string) (OptimizerSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return OptimizerSpec{}, err
+	}
+
+	var spec OptimizerSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return OptimizerSpec{}, err
+	}
+	return spec, nil
+}
+
+// operand 约束表达式的操作数：参数或常数
+type operand struct {
+	param *strategyParam
+	value float64
+}
+
+func (o operand) eval(c StrategyConfig) float64 {
+	if o.param != nil {
+		return o.param.get(c)
+	}
+	return o.value
+}
+
+func parseOperand(s string) (operand, error) {
+	if p, ok := lookupParam(s); ok {
+		return operand{param: &p}, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return operand{}, fmt.Errorf("unknown parameter or number: %q", s)
+	}
+	return operand{value: v}, nil
+}
+
+// Constraint 形如 "<lhs> <op> <rhs>" 的参数约束
+type Constraint struct {
+	expr     string
+	lhs, rhs operand
+	op       string
+}
+
+// constraintOps 支持的比较运算符（长的在前，避免 "<=" 被匹配成 "<"）
+var constraintOps = []string{"<=", ">=", "==", "!=", "<", ">"}
+
+// ParseConstraint 解析约束表达式
+func ParseConstraint(expr string) (Constraint, error) {
+	for _, op := range constraintOps {
+		idx := strings.Index(expr, op)
+		if idx < 0 {
+			continue
+		}
+		lhs, err := parseOperand(strings.TrimSpace(expr[:idx]))
+		if err != nil {
+			return Constraint{}, fmt.Errorf("constraint %q: %w", expr, err)
+		}
+		rhs, err := parseOperand(strings.TrimSpace(expr[idx+len(op):]))
+		if err != nil {
+			return Constraint{}, fmt.Errorf("constraint %q: %w", expr, err)
+		}
+		return Constraint{expr: expr, lhs: lhs, rhs: rhs, op: op}, nil
+	}
+	return Constraint{}, fmt.Errorf("constraint %q: missing comparison operator", expr)
+}
+
+// Satisfied 判断参数组合是否满足约束
+func (c Constraint) Satisfied(config StrategyConfig) bool {
+	l, r := c.lhs.eval(config), c.rhs.eval(config)
+	switch c.op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	case ">=":
+		return l >= r
+	case "==":
+		return l == r
+	case "!=":
+		return l != r
+	}
+	return false
+}
+
+// parseSpec 解析约束，并按 strategyParams 顺序返回有候选值的参数
+func parseSpec(spec OptimizerSpec) ([]strategyParam, []Constraint, error) {
+	var constraints []Constraint
+	for _, expr := range spec.Constraints {
+		c, err := ParseConstraint(expr)
+		if err != nil {
+			return nil, nil, err
+		}
+		constraints = append(constraints, c)
+	}
+
+	var params []strategyParam
+	for name := range spec.Ranges {
+		if _, ok := lookupParam(name); !ok {
+			return nil, nil, fmt.Errorf("unknown parameter in ranges: %q", name)
+		}
+	}
+	for _, p := range strategyParams {
+		if values := spec.Ranges[p.name]; len(values) > 0 {
+			params = append(params, p)
+		}
+	}
+	return params, constraints, nil
+}
+
+// optimizeGrid 按优化配置生成参数网格，跳过不满足约束的组合
+func optimizeGrid(spec OptimizerSpec) ([]StrategyConfig, error) {
+	// 按 strategyParams 顺序展开
+	params, constraints, err := parseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	var grid []StrategyConfig
+	var expand func(depth int, config StrategyConfig)
+	expand = func(depth int, config StrategyConfig) {
+		if depth == len(params) {
+			for _, c := range constraints {
+				if !c.Satisfied(config) {
+					return
+				}
+			}
+			grid = append(grid, config)
+			return
+		}
+		for _, v := range spec.Ranges[params[depth].name] {
+			params[depth].set(&config, v)
+			expand(depth+1, config)
+		}
+	}
+	expand(0, DefaultConfig)
+
+	return grid, nil
+}
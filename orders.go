@@ -0,0 +1,324 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+// 订单状态
+const (
+	OrderSubmitting = "submitting" // 已生成 clientOrderId，提交结果未知
+	OrderSubmitted  = "submitted"  // 交易所已确认
+	OrderFailed     = "failed"     // 提交失败或交易所查无此单
+)
+
+// OrderRecord 带信号上下文的订单记录
+type OrderRecord struct {
+	ClientOrderID string
+	Time          int64 // 信号 K 线时间
+	Symbol        string
+	Signal        string
+	Price         float64
+	Amount        float64
+	Notional      float64
+	Status        string
+}
+
+// orderSignalCodes clientOrderId 中的信号缩写（Binance 限制 ID 最长 36 字符）
+var orderSignalCodes = map[Signal]string{
+	SignalLong:       "l",
+	SignalShort:      "s",
+	SignalCloseLong:  "cl",
+	SignalCloseShort: "cs",
+}
+
+// clientOrderID 由交易对、信号 K 线时间和信号生成确定的 clientOrderId，
+// 同一根 K 线上的同一信号重启后得到相同 ID，可据此查询而不是重复下单
+func clientOrderID(symbol string, barTime int64, signal Signal) string {
+	return fmt.Sprintf("rsi-%s-%d-%s", strings.ToLower(symbol), barTime, orderSignalCodes[signal])
+}
+
+// SaveOrder 写入或更新订单记录
+func (j *Journal) SaveOrder(o OrderRecord) error {
+	if j == nil {
+		return nil
+	}
+	_, err := j.db.Exec(
+		`INSERT INTO orders (client_order_id, ts, symbol, signal, price, amount, notional, status)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(client_order_id) DO UPDATE SET status = excluded.status`,
+		o.ClientOrderID, o.Time, o.Symbol, o.Signal, o.Price, o.Amount, o.Notional, o.Status,
+	)
+	return err
+}
+
+// GetOrder 按 clientOrderId 查询订单记录，不存在时返回 nil
+func (j *Journal) GetOrder(id string) (*OrderRecord, error) {
+	if j == nil {
+		return nil, nil
+	}
+	var o OrderRecord
+	err := j.db.QueryRow(
+		`SELECT client_order_id, ts, symbol, signal, price, amount, notional, status FROM orders WHERE client_order_id = ?`, id,
+	).Scan(&o.ClientOrderID, &o.Time, &o.Symbol, &o.Signal, &o.Price, &o.Amount, &o.Notional, &o.Status)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// PendingOrders 返回提交结果未知的订单
+func (j *Journal) PendingOrders() ([]OrderRecord, error) {
+	if j == nil {
+		return nil, nil
+	}
+	rows, err := j.db.Query(
+		`SELECT client_order_id, ts, symbol, signal, price, amount, notional, status FROM orders WHERE status = ? ORDER BY ts`, OrderSubmitting,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []OrderRecord
+	for rows.Next() {
+		var o OrderRecord
+		if err := rows.Scan(&o.ClientOrderID, &o.Time, &o.Symbol, &o.Signal, &o.Price, &o.Amount, &o.Notional, &o.Status); err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+// reconcilePendingOrders 启动时查询上次崩溃前未确认的订单，确认是否已成交；
+// 只有交易所明确答复订单不存在时才标记为失败（允许重新提交），查询出错时保持未确认，下次启动再核对
+func (s *Strategy) reconcilePendingOrders() error {
+	if s.journal == nil || s.client == nil {
+		return nil
+	}
+
+	orders, err := s.journal.PendingOrders()
+	if err != nil {
+		return err
+	}
+
+	for _, o := range orders {
+		info, err := s.client.FutureGetOrderByClientID(o.Symbol, o.ClientOrderID)
+		switch {
+		case err != nil && isUnknownOrder(err):
+			log.Printf("订单 %s 未在交易所找到，标记为失败: %v", o.ClientOrderID, err)
+			o.Status = OrderFailed
+		case err != nil:
+			return wrapExchangeError("order", err)
+		case info == nil:
+			return fmt.Errorf("order %s: empty response", o.ClientOrderID)
+		default:
+			log.Printf("订单 %s 已提交 (状态 %s, 成交 %.4f @ %.2f)", o.ClientOrderID, info.Status, info.ExecutedQty, info.AvgPrice)
+			o.Status = OrderSubmitted
+			if signal, ok := parseSignalName(o.Signal); ok && o.Symbol == s.config.Symbol {
+				s.trackPosition(signal, info.AvgPrice, info.ExecutedQty, s.config.PositionSize)
+			}
+		}
+		if err := s.journal.SaveOrder(o); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// submitOnce 以确定的 clientOrderId 提交订单；该 ID 已提交过则跳过，避免重启后重复下单
+func (s *Strategy) submitOnce(signal Signal, price, amount, notional float64, submit func(clientOrderID string) error) error {
+	var barTime int64
+	if len(s.klines) > 0 {
+		barTime = s.klines[len(s.klines)-1].Timestamp
+	} else {
+		barTime = time.Now().Unix()
+	}
+
+	id := clientOrderID(s.config.Symbol, barTime, signal)
+	existing, err := s.journal.GetOrder(id)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Status != OrderFailed {
+		log.Printf("订单 %s 已存在 (状态 %s)，跳过重复提交", id, existing.Status)
+		return errEntryAborted
+	}
+
+	record := OrderRecord{
+		ClientOrderID: id,
+		Time:          barTime,
+		Symbol:        s.config.Symbol,
+		Signal:        signalNames[signal],
+		Price:         price,
+		Amount:        amount,
+		Notional:      notional,
+		Status:        OrderSubmitting,
+	}
+	if err := s.journal.SaveOrder(record); err != nil {
+		return err
+	}
+
+	err = submit(id)
+	if err != nil {
+		record.Status = OrderFailed
+	} else {
+		record.Status = OrderSubmitted
+	}
+	if saveErr := s.journal.SaveOrder(record); saveErr != nil {
+		log.Printf("更新订单记录失败: %v", saveErr)
+	}
+	return err
+}
+
+// 平仓后确认持仓归零的重试次数和间隔
+const (
+	closeVerifyAttempts = 3
+	closeVerifyDelay    = time.Second
+)
+
+// livePosition 查询交易所当前持仓数量（单向持仓模式：多头为正，空头为负）和开仓均价
+func (s *Strategy) livePosition() (amt, entryPrice float64, err error) {
+	return s.symbolPosition(s.config.Symbol)
+}
+
+// symbolPosition 查询指定交易对的持仓数量和开仓均价
+func (s *Strategy) symbolPosition(symbol string) (amt, entryPrice float64, err error) {
+	positions, err := s.client.FuturePosition(symbol)
+	if err != nil {
+		return 0, 0, wrapExchangeError("position", err)
+	}
+	for _, p := range positions {
+		if p.Symbol == symbol && p.PositionAmt != 0 {
+			amt += p.PositionAmt
+			entryPrice = p.EntryPrice
+		}
+	}
+	return amt, entryPrice, nil
+}
+
+// closePosition 查询交易所持仓，以 reduce-only 市价单全部平掉并确认持仓归零，返回平仓数量；
+// 交易所上没有对应方向的持仓时返回 0（只清除本地跟踪）
+func (s *Strategy) closePosition(signal Signal, price float64) (float64, error) {
+	side := "LONG"
+	if signal == SignalCloseShort {
+		side = "SHORT"
+	}
+	held := func(amt float64) float64 {
+		if side == "SHORT" {
+			return -amt
+		}
+		return amt
+	}
+
+	amt, _, err := s.livePosition()
+	if err != nil {
+		return 0, err
+	}
+	qty := held(amt)
+	if qty <= 0 {
+		log.Printf("交易所无 %s 持仓 (持仓数量 %.6f)，跳过平仓", side, amt)
+		return 0, nil
+	}
+
+	err = s.submitOnce(signal, price, qty, qty*price, func(clientOrderID string) error {
+		var err error
+		if side == "LONG" {
+			log.Printf("平多仓: %.6f @ %.2f (%s)", qty, price, clientOrderID)
+			_, err = s.client.FutureCloseLongMarketWithID(s.config.Symbol, qty, clientOrderID)
+		} else {
+			log.Printf("平空仓: %.6f @ %.2f (%s)", qty, price, clientOrderID)
+			_, err = s.client.FutureCloseShortMarketWithID(s.config.Symbol, qty, clientOrderID)
+		}
+		return wrapExchangeError("close market", err)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// 确认持仓已归零
+	remaining := qty
+	for attempt := 0; attempt < closeVerifyAttempts; attempt++ {
+		time.Sleep(closeVerifyDelay)
+		amt, _, err := s.livePosition()
+		if err != nil {
+			log.Printf("确认平仓失败: %v", err)
+			continue
+		}
+		if remaining = held(amt); remaining <= 0 {
+			return qty, nil
+		}
+	}
+
+	msg := fmt.Sprintf("平仓后 %s 持仓仍有 %.6f（平仓 %.6f），请人工检查", side, remaining, qty)
+	log.Printf("[告警] %s", msg)
+	s.webhook.Alert("close_incomplete", s.config.Symbol, msg)
+	return qty - remaining, fmt.Errorf("close %s: %.6f still open", side, remaining)
+}
+
+// syncPosition 启动时以交易所持仓为准核对跟踪的持仓，重启前开的仓位也由引擎判断出场：
+// 方向一致时保留状态文件中的批次和入场时间，只更新数量；交易所已无持仓时清除；
+// 没有可用的状态时按 manual_position 接管（adoptPosition）；方向相反时按 1 批、以启动时的 K 线作为入场时间
+func (s *Strategy) syncPosition() error {
+	if s.client == nil || s.config.DryRun {
+		return nil
+	}
+	amt, entryPrice, err := s.livePosition()
+	if err != nil {
+		return err
+	}
+
+	side := ""
+	switch {
+	case amt > 0:
+		side = "LONG"
+	case amt < 0:
+		side = "SHORT"
+	}
+
+	switch {
+	case side == "" && s.entrySide == "":
+		return nil
+	case side == "":
+		log.Printf("交易所已无持仓，清除跟踪的 %s 持仓（可能已被保护单或手动平仓）", s.entrySide)
+		s.resetPosition()
+		s.saveState()
+		s.cancelProtection()
+		return nil
+	case side == s.entrySide:
+		if reduced := s.entryAmount - math.Abs(amt); reduced > 0 && !s.config.symbolFilter().IsDust(reduced, entryPrice) {
+			price := entryPrice
+			if len(s.klines) > 0 {
+				price = s.klines[len(s.klines)-1].Close
+			}
+			log.Printf("交易所 %s 持仓比跟踪的少 %.6f（可能被部分平仓）", side, reduced)
+			s.notifyPartialClose(price, reduced)
+		}
+		s.entryAmount = math.Abs(amt)
+		s.saveState()
+		log.Printf("持仓与交易所一致: %s %.6f @ %.2f, %d 批", s.entrySide, s.entryAmount, s.entryPrice, s.entryCount)
+	case s.entrySide == "":
+		if err := s.adoptPosition(side, amt, entryPrice); err != nil {
+			return err
+		}
+	default:
+		signal := SignalLong
+		if side == "SHORT" {
+			signal = SignalShort
+		}
+		s.resetPosition()
+		s.trackPosition(signal, entryPrice, math.Abs(amt), s.config.PositionSize)
+		log.Printf("恢复交易所持仓: %s %.6f @ %.2f", s.entrySide, s.entryAmount, s.entryPrice)
+	}
+	s.placeProtection()
+	return nil
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+// 遗留持仓处理策略：配置的交易对或引擎变更后，上次运行留下的持仓
+const (
+	// OrphanManage 旧交易对的持仓按原均价挂止损 / 止盈单，每周期确认直到交易所持仓归零；
+	// 同一交易对换引擎时由新引擎接管出场判断
+	OrphanManage = "manage"
+	// OrphanFlatten 启动时市价平掉
+	OrphanFlatten = "flatten"
+)
+
+// ValidOrphanPolicy 校验 orphan_policy，空值使用 manage
+func ValidOrphanPolicy(policy string) error {
+	switch policy {
+	case "", OrphanManage, OrphanFlatten:
+		return nil
+	}
+	return fmt.Errorf("unknown orphan_policy %q (want %s or %s)", policy, OrphanManage, OrphanFlatten)
+}
+
+// orphanPosition 状态文件中同一账户其他交易对上的持仓（交易对变更前遗留）
+type orphanPosition struct {
+	key    string // 状态文件中的行
+	symbol string
+	engine string
+	side   string
+	amount float64
+	price  float64
+}
+
+// String 日志中的持仓描述
+func (o orphanPosition) String() string {
+	return fmt.Sprintf("%s %s %.6f @ %.2f", o.symbol, o.side, o.amount, o.price)
+}
+
+// stateKeySymbol 从状态 key（小写交易对-账户）还原交易对，早期状态未保存 symbol 时使用
+func stateKeySymbol(key string) string {
+	if i := strings.LastIndex(key, "-"); i > 0 {
+		return strings.ToUpper(key[:i])
+	}
+	return strings.ToUpper(key)
+}
+
+// findOrphans 在状态文件中查找上次运行在同一账户其他交易对上留下的持仓，以交易所持仓为准：
+// 交易所已无持仓的删除该行，仍有持仓的按交易所数量和均价返回。状态文件可由多个实例共用，
+// 其他实例（不同的非空 instance_id）写入的行和实例锁仍被持有的交易对跳过；未记录实例的行视为本实例的
+func (s *Strategy) findOrphans() ([]orphanPosition, error) {
+	states, err := s.state.List()
+	if err != nil {
+		return nil, err
+	}
+	current := lockKey(s.config)
+	account := current[strings.LastIndex(current, "-"):]
+
+	var orphans []orphanPosition
+	for key, state := range states {
+		if key == current || !strings.HasSuffix(key, account) || state.Side == "" {
+			continue
+		}
+		if state.Owner != "" && state.Owner != s.config.InstanceID {
+			continue
+		}
+		symbol := state.Symbol
+		if symbol == "" {
+			symbol = stateKeySymbol(key)
+		}
+		if !s.orphanLockFree(symbol) {
+			log.Printf("%s 的实例锁被其他实例持有，不处理其持仓", symbol)
+			continue
+		}
+		amt, entryPrice, err := s.symbolPosition(symbol)
+		if err != nil {
+			return nil, err
+		}
+		if amt == 0 {
+			log.Printf("状态文件中 %s 的 %s 持仓在交易所已平，删除遗留状态", symbol, state.Side)
+			if err := s.state.Delete(key); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		side := "LONG"
+		if amt < 0 {
+			side = "SHORT"
+		}
+		orphans = append(orphans, orphanPosition{
+			key:    key,
+			symbol: symbol,
+			engine: state.Engine,
+			side:   side,
+			amount: math.Abs(amt),
+			price:  entryPrice,
+		})
+	}
+	return orphans, nil
+}
+
+// orphanLockFree 配置了实例锁时确认没有运行中的实例持有该交易对的锁（获取后立即释放），未配置锁时返回 true
+func (s *Strategy) orphanLockFree(symbol string) bool {
+	config := *s.config
+	config.Symbol = symbol
+	lock, err := NewInstanceLock(&config, s.instanceID, s.journal)
+	if err != nil || lock == nil {
+		return err == nil
+	}
+	if err := lock.Acquire(); err != nil {
+		return false
+	}
+	if err := lock.Release(); err != nil {
+		log.Printf("释放 %s 的实例锁失败: %v", symbol, err)
+	}
+	return true
+}
+
+// reconcileOrphans 启动时按 orphan_policy 处理交易对或引擎变更前遗留的持仓，
+// 并告警，避免旧持仓无人管理
+func (s *Strategy) reconcileOrphans() {
+	if s.priorEngine != "" {
+		s.handleEngineChange()
+	}
+	if s.state == nil || s.client == nil || s.config.DryRun {
+		return
+	}
+
+	orphans, err := s.findOrphans()
+	if err != nil {
+		log.Printf("查找遗留持仓失败: %v", err)
+		return
+	}
+	for _, o := range orphans {
+		msg := fmt.Sprintf("配置的交易对已改为 %s，%s 仍有上次运行遗留的持仓 %s", s.config.Symbol, o.symbol, o)
+		log.Printf("[告警] %s", msg)
+		s.webhook.Alert("orphan_position", o.symbol, msg)
+
+		if s.config.OrphanPolicy == OrphanFlatten {
+			if err := s.flattenOrphan(o); err != nil {
+				log.Printf("平掉遗留持仓 %s 失败，改为继续管理: %v", o, err)
+				s.manageOrphan(o)
+			}
+			continue
+		}
+		s.manageOrphan(o)
+	}
+}
+
+// handleEngineChange 同一交易对上的持仓由旧引擎开出：flatten 时平掉，manage 时交给新引擎判断出场
+func (s *Strategy) handleEngineChange() {
+	prior := s.priorEngine
+	s.priorEngine = ""
+	if s.entrySide == "" {
+		return
+	}
+
+	msg := fmt.Sprintf("策略引擎已由 %s 改为 %s，%s 持仓 %.6f @ %.2f 由旧引擎开出",
+		prior, s.engine.Name(), s.entrySide, s.entryAmount, s.entryPrice)
+	if s.config.OrphanPolicy != OrphanFlatten {
+		msg += "，由新引擎接管出场判断"
+		log.Printf("[告警] %s", msg)
+		s.webhook.Alert("orphan_position", s.config.Symbol, msg)
+		return
+	}
+
+	msg += "，平仓"
+	log.Printf("[告警] %s", msg)
+	s.webhook.Alert("orphan_position", s.config.Symbol, msg)
+	signal := SignalCloseLong
+	if s.entrySide == "SHORT" {
+		signal = SignalCloseShort
+	}
+	if _, _, err := s.executeSignalSized(signal, 0); err != nil {
+		s.handleExchangeError(err)
+	}
+}
+
+// flattenOrphan 以 reduce-only 市价单平掉遗留持仓，撤销旧交易对的保护单并记录交易日志
+func (s *Strategy) flattenOrphan(o orphanPosition) error {
+	signal := SignalCloseLong
+	if o.side == "SHORT" {
+		signal = SignalCloseShort
+	}
+	ticker, err := s.client.FutureTicker(o.symbol)
+	if err != nil {
+		return wrapExchangeError("ticker", err)
+	}
+
+	id := clientOrderID(o.symbol, time.Now().Unix(), signal)
+	log.Printf("平掉遗留持仓: %s @ %.2f (%s)", o, ticker.Price, id)
+	if o.side == "LONG" {
+		_, err = s.client.FutureCloseLongMarketWithID(o.symbol, o.amount, id)
+	} else {
+		_, err = s.client.FutureCloseShortMarketWithID(o.symbol, o.amount, id)
+	}
+	if err != nil {
+		return wrapExchangeError("close market", err)
+	}
+
+	time.Sleep(closeVerifyDelay)
+	amt, _, err := s.symbolPosition(o.symbol)
+	if err != nil {
+		return err
+	}
+	if amt != 0 {
+		return fmt.Errorf("close %s: %.6f still open", o.symbol, math.Abs(amt))
+	}
+
+	s.cancelSymbolProtection(o.symbol)
+	pnl := (ticker.Price - o.price) * o.amount
+	if o.side == "SHORT" {
+		pnl = -pnl
+	}
+	notional := ticker.Price * o.amount
+	if err := s.journal.Record(JournalEntry{
+		Symbol:   o.symbol,
+		Event:    JournalClose,
+		Side:     o.side,
+		Price:    ticker.Price,
+		Amount:   o.amount,
+		Notional: notional,
+		PnL:      pnl,
+		Fee:      notional * liveFeeRate,
+		Reason:   "遗留持仓平仓",
+		Strategy: o.engine,
+	}); err != nil {
+		log.Printf("写入交易日志失败: %v", err)
+	}
+	if err := s.state.Delete(o.key); err != nil {
+		log.Printf("删除遗留状态失败: %v", err)
+	}
+	log.Printf("遗留持仓 %s 已平，预期盈亏 %.2f USDT", o.symbol, pnl)
+	return nil
+}
+
+// manageOrphan 为遗留持仓按原均价重挂止损 / 止盈单，之后每周期确认是否已平
+func (s *Strategy) manageOrphan(o orphanPosition) {
+	s.orphans = append(s.orphans, o)
+	if s.config.StopLossPct <= 0 && s.config.TakeProfitPct <= 0 {
+		log.Printf("未配置 stop_loss_pct / take_profit_pct，遗留持仓 %s 需人工平仓", o)
+		return
+	}
+
+	s.cancelSymbolProtection(o.symbol)
+	stop, takeProfit := protectivePrices(o.side, o.price, s.config.StopLossPct, s.config.TakeProfitPct)
+	if stop > 0 {
+		id := protectiveOrderID(o.symbol, protectStop)
+		if _, err := s.client.FutureStopMarketClose(o.symbol, o.side, stop, id); err != nil {
+			log.Printf("遗留持仓 %s 挂止损单失败: %v", o.symbol, err)
+		} else {
+			log.Printf("遗留持仓挂止损单: %s %s @ %.2f (%s)", o.symbol, o.side, stop, id)
+		}
+	}
+	if takeProfit > 0 {
+		id := protectiveOrderID(o.symbol, protectTakeProfit)
+		if _, err := s.client.FutureTakeProfitMarketClose(o.symbol, o.side, takeProfit, id); err != nil {
+			log.Printf("遗留持仓 %s 挂止盈单失败: %v", o.symbol, err)
+		} else {
+			log.Printf("遗留持仓挂止盈单: %s %s @ %.2f (%s)", o.symbol, o.side, takeProfit, id)
+		}
+	}
+}
+
+// checkOrphans 每周期确认管理中的遗留持仓是否已被保护单或手动平掉，已平的撤销剩余保护单并删除状态
+func (s *Strategy) checkOrphans() {
+	remaining := s.orphans[:0]
+	for _, o := range s.orphans {
+		amt, _, err := s.symbolPosition(o.symbol)
+		if err != nil {
+			log.Printf("查询遗留持仓 %s 失败: %v", o.symbol, err)
+			remaining = append(remaining, o)
+			continue
+		}
+		if amt != 0 {
+			remaining = append(remaining, o)
+			continue
+		}
+		log.Printf("遗留持仓 %s 已平", o)
+		s.cancelSymbolProtection(o.symbol)
+		if err := s.state.Delete(o.key); err != nil {
+			log.Printf("删除遗留状态失败: %v", err)
+		}
+	}
+	s.orphans = remaining
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// PaperFillConfig dry-run 模拟成交：每次模拟成交前等待随机延迟，成交价在信号收盘价上叠加随机的价格偏离，
+// 使模拟盘的表现接近实盘实际成交。journal 为实盘交易日志时从中随机抽取实测的 (延迟, 偏离) 样本，否则按正态分布生成
+type PaperFillConfig struct {
+	Journal         string  `json:"journal,omitempty"`           // 实盘交易日志，按交易对抽取实测样本，设置后忽略下面的分布参数
+	LatencyMs       float64 `json:"latency_ms,omitempty"`        // 平均延迟 (ms)
+	LatencyJitterMs float64 `json:"latency_jitter_ms,omitempty"` // 延迟的标准差 (ms)
+	SlipBps         float64 `json:"slip_bps,omitempty"`          // 平均不利偏离 (bps)
+	SlipJitterBps   float64 `json:"slip_jitter_bps,omitempty"`   // 偏离的标准差 (bps)
+	Seed            int64   `json:"seed,omitempty"`              // 随机种子，0 表示按启动时间
+}
+
+// enabled 是否启用模拟成交
+func (c PaperFillConfig) enabled() bool {
+	return c.Journal != "" || c.LatencyMs > 0 || c.LatencyJitterMs > 0 || c.SlipBps != 0 || c.SlipJitterBps > 0
+}
+
+// Validate 检查配置
+func (c PaperFillConfig) Validate() error {
+	if c.LatencyMs < 0 || c.LatencyJitterMs < 0 || c.SlipJitterBps < 0 {
+		return fmt.Errorf("paper_fill latency and jitter must be non-negative")
+	}
+	return nil
+}
+
+// fillSample 一次成交的延迟和相对信号价的不利偏离 (bps，有利为负)
+type fillSample struct {
+	latency time.Duration
+	slipBps float64
+}
+
+// fillSamples 从交易日志中提取 symbol 实盘成交的实测样本：买入（开多、平空）成交价高于信号价为不利，卖出相反
+func fillSamples(entries []JournalEntry, symbol string) []fillSample {
+	var samples []fillSample
+	for _, e := range entries {
+		if e.Symbol != symbol || e.SignalPrice <= 0 || e.LatencyMs <= 0 || e.Price <= 0 {
+			continue
+		}
+		if e.Event != JournalOpen && e.Event != JournalClose {
+			continue
+		}
+		buy := (e.Event == JournalOpen) == (e.Side == "LONG")
+		bps := (e.Price - e.SignalPrice) / e.SignalPrice * 1e4
+		if !buy {
+			bps = -bps
+		}
+		samples = append(samples, fillSample{latency: time.Duration(e.LatencyMs) * time.Millisecond, slipBps: bps})
+	}
+	return samples
+}
+
+// PaperFill dry-run 的模拟成交，nil 表示按信号收盘价立即成交
+type PaperFill struct {
+	config  PaperFillConfig
+	samples []fillSample // 实测样本，为空时按配置的分布生成
+	rng     *rand.Rand
+}
+
+// NewPaperFill 创建模拟成交，未启用时返回 nil；配置了 journal 时读取 symbol 的实测样本，没有样本时返回错误
+func NewPaperFill(c PaperFillConfig, symbol string) (*PaperFill, error) {
+	if !c.enabled() {
+		return nil, nil
+	}
+	seed := c.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	p := &PaperFill{config: c, rng: rand.New(rand.NewSource(seed))}
+	if c.Journal == "" {
+		return p, nil
+	}
+
+	journal, err := OpenJournal(c.Journal)
+	if err != nil {
+		return nil, fmt.Errorf("open paper_fill journal: %w", err)
+	}
+	defer journal.Close()
+	entries, err := journal.Entries(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("read paper_fill journal: %w", err)
+	}
+	p.samples = fillSamples(entries, symbol)
+	if len(p.samples) == 0 {
+		return nil, fmt.Errorf("paper_fill journal %s has no measured fills for %s", c.Journal, symbol)
+	}
+	return p, nil
+}
+
+// Samples 实测样本数，0 表示按配置的分布生成
+func (p *PaperFill) Samples() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.samples)
+}
+
+// draw 抽取一次成交的延迟和偏离：有实测样本时整对抽取，保留延迟与偏离之间的相关性
+func (p *PaperFill) draw() fillSample {
+	if len(p.samples) > 0 {
+		return p.samples[p.rng.Intn(len(p.samples))]
+	}
+	latency := max(p.config.LatencyMs+p.rng.NormFloat64()*p.config.LatencyJitterMs, 0)
+	return fillSample{
+		latency: time.Duration(latency * float64(time.Millisecond)),
+		slipBps: p.config.SlipBps + p.rng.NormFloat64()*p.config.SlipJitterBps,
+	}
+}
+
+// Fill 返回按 price 下单的模拟成交价和成交前的延迟：买入时不利偏离使成交价上移，卖出时下移
+func (p *PaperFill) Fill(price float64, buy bool) (float64, time.Duration) {
+	if p == nil {
+		return price, 0
+	}
+	sample := p.draw()
+	if buy {
+		return price * (1 + sample.slipBps/1e4), sample.latency
+	}
+	return price * (1 - sample.slipBps/1e4), sample.latency
+}
+
+// paperPrice dry-run 按 price 模拟成交：等待抽取的延迟（策略停止时提前返回）后返回含偏离的成交价
+func (s *Strategy) paperPrice(signal Signal, price float64) float64 {
+	if s.paperFill == nil {
+		return price
+	}
+	buy := signal == SignalLong || signal == SignalCloseShort
+	fill, latency := s.paperFill.Fill(price, buy)
+	select {
+	case <-time.After(latency):
+	case <-s.quit:
+	}
+	log.Printf("[DRY-RUN] 模拟成交: 延迟 %v, %.2f -> %.2f", latency.Round(time.Millisecond), price, fill)
+	return fill
+}
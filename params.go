@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// bounceParam 反弹策略可配置的参数
+type bounceParam struct {
+	name string
+	get  func(c BounceConfig) float64
+	set  func(c *BounceConfig, v float64)
+}
+
+// bounceParams 参数名到 BounceConfig 字段的映射，时间类参数单位为秒
+var bounceParams = []bounceParam{
+	{"drop_lookback", func(c BounceConfig) float64 { return float64(c.DropLookback) }, func(c *BounceConfig, v float64) { c.DropLookback = int(v) }},
+	{"drop_threshold", func(c BounceConfig) float64 { return c.DropThreshold }, func(c *BounceConfig, v float64) { c.DropThreshold = v }},
+	{"rsi_oversold", func(c BounceConfig) float64 { return c.RSIOversold }, func(c *BounceConfig, v float64) { c.RSIOversold = v }},
+	{"rsi_entry", func(c BounceConfig) float64 { return c.RSIEntry }, func(c *BounceConfig, v float64) { c.RSIEntry = v }},
+	{"first_batch_size", func(c BounceConfig) float64 { return c.FirstBatchSize }, func(c *BounceConfig, v float64) { c.FirstBatchSize = v }},
+	{"other_batch_size", func(c BounceConfig) float64 { return c.OtherBatchSize }, func(c *BounceConfig, v float64) { c.OtherBatchSize = v }},
+	{"batch_interval", func(c BounceConfig) float64 { return float64(c.BatchInterval) }, func(c *BounceConfig, v float64) { c.BatchInterval = int64(v) }},
+	{"max_batches", func(c BounceConfig) float64 { return float64(c.MaxBatches) }, func(c *BounceConfig, v float64) { c.MaxBatches = int(v) }},
+	{"bounce_target", func(c BounceConfig) float64 { return c.BounceTarget }, func(c *BounceConfig, v float64) { c.BounceTarget = v }},
+	{"profit_threshold", func(c BounceConfig) float64 { return c.ProfitThreshold }, func(c *BounceConfig, v float64) { c.ProfitThreshold = v }},
+	{"start_exit_time", func(c BounceConfig) float64 { return float64(c.StartExitTime) }, func(c *BounceConfig, v float64) { c.StartExitTime = int64(v) }},
+	{"exit_interval", func(c BounceConfig) float64 { return float64(c.ExitInterval) }, func(c *BounceConfig, v float64) { c.ExitInterval = int64(v) }},
+	{"exit_percent", func(c BounceConfig) float64 { return c.ExitPercent }, func(c *BounceConfig, v float64) { c.ExitPercent = v }},
+	{"max_hold_time", func(c BounceConfig) float64 { return float64(c.MaxHoldTime) }, func(c *BounceConfig, v float64) { c.MaxHoldTime = int64(v) }},
+	{"rsi_exit", func(c BounceConfig) float64 { return c.RSIExit }, func(c *BounceConfig, v float64) { c.RSIExit = v }},
+}
+
+// BacktestParams -params 文件中的策略参数：键名与 config.json 一致（rsi_period、ema_fast 等），
+// 反弹策略参数见 bounceParams，另支持 position_size；可直接使用实盘的 config.json
+type BacktestParams map[string]float64
+
+// LoadBacktestParams 读取参数文件，只保留数值字段；既不是策略参数也不是反弹参数的键
+// （如 config.json 中的 api_key）忽略并打印
+func LoadBacktestParams(path string) (BacktestParams, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	params := make(BacktestParams)
+	var ignored []string
+	for key, value := range raw {
+		v, ok := value.(float64)
+		if !ok || !knownBacktestParam(key) {
+			ignored = append(ignored, key)
+			continue
+		}
+		params[key] = v
+	}
+	if len(params) == 0 {
+		return nil, fmt.Errorf("%s: no strategy parameters found", path)
+	}
+	if len(ignored) > 0 {
+		sort.Strings(ignored)
+		log.Printf("参数文件中忽略的字段: %s", strings.Join(ignored, ", "))
+	}
+	return params, nil
+}
+
+// knownBacktestParam 判断键名是否为可回测的参数
+func knownBacktestParam(key string) bool {
+	if key == "position_size" {
+		return true
+	}
+	if _, ok := lookupParam(key); ok {
+		return true
+	}
+	for _, p := range bounceParams {
+		if p.name == key {
+			return true
+		}
+	}
+	return false
+}
+
+// applyStrategy 用参数文件覆盖 RSI 策略参数和仓位比例
+func (p BacktestParams) applyStrategy(sc *StrategyConfig, positionSize *float64) {
+	for _, param := range strategyParams {
+		if v, ok := p[param.name]; ok {
+			param.set(sc, v)
+		}
+	}
+	if v, ok := p["position_size"]; ok {
+		*positionSize = v
+	}
+}
+
+// bounceParamValues 反弹策略参数的当前值
+func bounceParamValues(c BounceConfig) map[string]float64 {
+	values := make(map[string]float64, len(bounceParams))
+	for _, p := range bounceParams {
+		values[p.name] = p.get(c)
+	}
+	return values
+}
+
+// applyBounce 用参数文件覆盖反弹策略参数
+func (p BacktestParams) applyBounce(c *BounceConfig) {
+	for _, param := range bounceParams {
+		if v, ok := p[param.name]; ok {
+			param.set(c, v)
+		}
+	}
+}
+
+// String 按键名排序的参数列表，用于日志
+func (p BacktestParams) String() string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%g", k, p[k])
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// 置换方式
+const (
+	PermuteBlock   = "block"   // 按固定长度的块打乱，保留块内的短期结构（波动聚集、日内形态）
+	PermuteReturns = "returns" // 逐根打乱，破坏所有时间结构，只保留收益分布
+)
+
+// barShape 一根 K 线相对上一根收盘价的对数形态，打乱后按新顺序重建价格
+type barShape struct {
+	open, high, low, close float64
+	volume                 float64
+}
+
+// PermuteKlines 打乱 K 线顺序后重建价格路径：首根不动，其余每根保持相对上一根收盘的
+// 开高低收比例和成交量，时间戳沿用原序列；总涨跌幅与原序列相同
+func PermuteKlines(klines []Kline, method string, blockSize int, rng *rand.Rand) ([]Kline, error) {
+	if len(klines) < 2 {
+		return klines, nil
+	}
+	switch method {
+	case PermuteReturns:
+		blockSize = 1
+	case PermuteBlock:
+		if blockSize <= 0 {
+			return nil, fmt.Errorf("block size must be positive")
+		}
+	default:
+		return nil, fmt.Errorf("unknown permutation method %q", method)
+	}
+
+	shapes := make([]barShape, len(klines)-1)
+	for i := 1; i < len(klines); i++ {
+		prev := klines[i-1].Close
+		k := klines[i]
+		shapes[i-1] = barShape{
+			open:   math.Log(k.Open / prev),
+			high:   math.Log(k.High / prev),
+			low:    math.Log(k.Low / prev),
+			close:  math.Log(k.Close / prev),
+			volume: k.Volume,
+		}
+	}
+
+	// 按块打乱：最后一块可能不足 blockSize
+	var blocks [][]barShape
+	for i := 0; i < len(shapes); i += blockSize {
+		blocks = append(blocks, shapes[i:min(i+blockSize, len(shapes))])
+	}
+	rng.Shuffle(len(blocks), func(i, j int) { blocks[i], blocks[j] = blocks[j], blocks[i] })
+
+	out := make([]Kline, len(klines))
+	out[0] = klines[0]
+	prev := klines[0].Close
+	i := 1
+	for _, block := range blocks {
+		for _, s := range block {
+			out[i] = Kline{
+				Timestamp: klines[i].Timestamp,
+				Open:      prev * math.Exp(s.open),
+				High:      prev * math.Exp(s.high),
+				Low:       prev * math.Exp(s.low),
+				Close:     prev * math.Exp(s.close),
+				Volume:    s.volume,
+			}
+			prev = out[i].Close
+			i++
+		}
+	}
+	return out, nil
+}
+
+// PermutationOptions 置换检验命令选项
+type PermutationOptions struct {
+	Runs      int    // 置换次数
+	Method    string // block / returns
+	BlockSize int    // 块长度（K 线根数）
+	Seed      int64
+}
+
+// PermutationResult 真实行情与置换分布的对比
+type PermutationResult struct {
+	Real       *BacktestResult
+	PnLs       []float64 // 各次置换的总盈亏（升序）
+	Drawdowns  []float64 // 各次置换的最大回撤（升序）
+	Trades     []float64 // 各次置换的交易数（升序）
+	PValue     float64   // 置换盈亏 >= 真实盈亏的比例（含真实行情本身）
+	Percentile float64   // 真实盈亏在置换分布中的分位
+	MeanPnL    float64
+	StdPnL     float64
+	ExcessPnL  float64 // 真实盈亏 - 置换平均盈亏，可归因于行情结构的部分
+}
+
+// RunPermutationTest 在真实行情和 runs 次置换行情上回测，newEngine 每次返回新的引擎
+func RunPermutationTest(klines []Kline, config BacktestConfig, newEngine func() StrategyEngine, opts PermutationOptions) (*PermutationResult, error) {
+	result := &PermutationResult{Real: RunEngineBacktest(klines, config, newEngine())}
+
+	// 置换只用于比较，不写逐笔交易
+	config.TradeLog = nil
+	config.StreamOnly = true
+	config.Explain = false
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	for r := 0; r < opts.Runs; r++ {
+		permuted, err := PermuteKlines(klines, opts.Method, opts.BlockSize, rng)
+		if err != nil {
+			return nil, err
+		}
+		res := RunEngineBacktest(permuted, config, newEngine())
+		result.PnLs = append(result.PnLs, res.TotalPnL)
+		result.Drawdowns = append(result.Drawdowns, res.MaxDrawdown)
+		result.Trades = append(result.Trades, float64(res.TotalTrades))
+	}
+	sort.Float64s(result.PnLs)
+	sort.Float64s(result.Drawdowns)
+	sort.Float64s(result.Trades)
+
+	realPnL := result.Real.TotalPnL
+	atLeast := 1 // 真实行情本身计入，避免 p = 0
+	below := 0
+	for _, pnl := range result.PnLs {
+		if pnl >= realPnL {
+			atLeast++
+		} else {
+			below++
+		}
+	}
+	result.PValue = float64(atLeast) / float64(len(result.PnLs)+1)
+	if len(result.PnLs) > 0 {
+		result.Percentile = float64(below) / float64(len(result.PnLs))
+	}
+	result.MeanPnL, result.StdPnL, _, _ = Moments(result.PnLs)
+	result.ExcessPnL = realPnL - result.MeanPnL
+	return result, nil
+}
+
+// quantile 已排序序列的 q 分位（最近秩）
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[min(int(q*float64(len(sorted))), len(sorted)-1)]
+}
+
+// PrintPermutationResult 打印置换检验结果
+func PrintPermutationResult(r *PermutationResult, opts PermutationOptions) {
+	fmt.Println("\n========== 置换检验 ==========")
+	method := opts.Method
+	if method == PermuteBlock {
+		method = fmt.Sprintf("%s (块长 %d)", method, opts.BlockSize)
+	}
+	fmt.Printf("置换方式: %s, 次数: %d, 种子: %d\n", method, len(r.PnLs), opts.Seed)
+	fmt.Printf("真实行情: 盈亏 $%.2f, 交易 %d 笔, 最大回撤 %.2f%%\n",
+		r.Real.TotalPnL, r.Real.TotalTrades, r.Real.MaxDrawdown*100)
+
+	fmt.Println("\n--- 置换分布 ---")
+	fmt.Printf("%-8s %12s %12s %12s %12s %12s\n", "", "5%", "25%", "50%", "75%", "95%")
+	row := func(name string, values []float64, format string) {
+		fmt.Printf("%-8s", name)
+		for _, q := range []float64{0.05, 0.25, 0.5, 0.75, 0.95} {
+			fmt.Printf(" %12s", fmt.Sprintf(format, quantile(values, q)))
+		}
+		fmt.Println()
+	}
+	row("盈亏", r.PnLs, "%.2f")
+	drawdowns := make([]float64, len(r.Drawdowns))
+	for i, d := range r.Drawdowns {
+		drawdowns[i] = d * 100
+	}
+	row("回撤%", drawdowns, "%.2f")
+	row("交易数", r.Trades, "%.0f")
+
+	fmt.Printf("\n置换平均盈亏: $%.2f (标准差 $%.2f)\n", r.MeanPnL, r.StdPnL)
+	fmt.Printf("真实盈亏分位: %.1f%%, p 值: %.3f\n", r.Percentile*100, r.PValue)
+	fmt.Printf("超出置换平均: $%.2f", r.ExcessPnL)
+	if r.Real.TotalPnL != 0 {
+		fmt.Printf("（占真实盈亏 %.0f%%）", r.ExcessPnL/math.Abs(r.Real.TotalPnL)*100)
+	}
+	fmt.Println()
+	if r.PValue < 0.05 {
+		fmt.Println("结论: 真实行情表现显著优于置换行情，盈亏主要来自行情结构")
+	} else {
+		fmt.Println("结论: 与置换行情无显著差异，盈亏可能主要来自运气")
+	}
+	fmt.Println("================================")
+}
+
+// runPermutationCmd 执行置换检验命令
+func runPermutationCmd(dbPath, symbol string, startTime, endTime int64, opts PermutationOptions, backtestOpts BacktestOptions) {
+	if opts.Runs <= 0 {
+		log.Fatalf("-permutations 需大于 0")
+	}
+
+	log.Printf("加载 K 线数据: %s", symbol)
+	klines, err := loadIntervalKlines(dbPath, symbol, startTime, endTime, backtestOpts.Interval)
+	if err != nil {
+		log.Fatalf("加载数据失败: %v", err)
+	}
+	log.Printf("加载 %d 根 %ds K 线", len(klines), backtestOpts.Interval)
+	if len(klines) < 100 {
+		log.Fatalf("数据不足，至少需要 100 根 K 线")
+	}
+
+	config, newEngine := engineBacktestSetup(symbol, backtestOpts)
+
+	log.Printf("置换检验: %d 次", opts.Runs)
+	result, err := RunPermutationTest(klines, config, newEngine, opts)
+	if err != nil {
+		log.Fatalf("置换检验失败: %v", err)
+	}
+	PrintPermutationResult(result, opts)
+}
+
+// engineBacktestSetup 按回测命令行选项构造逐根 K 线回测的配置和策略引擎工厂，引擎无法创建时退出
+func engineBacktestSetup(symbol string, backtestOpts BacktestOptions) (BacktestConfig, func() StrategyEngine) {
+	config := DefaultBacktestConfig
+	config.Symbol = symbol
+	config.Sizing = backtestOpts.Sizing
+	config.DailyCompounding = backtestOpts.DailyCompounding
+	config.Throttle = backtestOpts.Throttle
+	config.StopLossPct = backtestOpts.StopLossPct
+	config.StopMode = backtestOpts.StopMode
+	config.StopPenalty = backtestOpts.StopPenalty
+	config.TakeProfitPct = backtestOpts.TakeProfitPct
+	config.Trailing = backtestOpts.Trailing
+	config.Pyramid = backtestOpts.Pyramid
+	config.Hedge = backtestOpts.Hedge
+	config.IntrabarFill = backtestOpts.IntrabarFill
+	config.MaxChaseBps = backtestOpts.MaxChaseBps
+	config.FeeTiers = backtestOpts.FeeTiers
+	config.EntryFill = backtestOpts.EntryFill
+	config.EquityFilter = EquityFilterConfig{MATrades: backtestOpts.EquityMA}
+	config.VolTarget = backtestOpts.VolTarget
+	config.Maintenance = backtestOpts.maintenanceSchedule()
+	config.Slippage = backtestOpts.Slippage
+
+	strategyConfig := DefaultConfig
+	if backtestOpts.Params != nil {
+		backtestOpts.Params.applyStrategy(&strategyConfig, &config.PositionSize)
+		config.applyExits(strategyConfig)
+	}
+	engineConfig := defaultConfig
+	engineConfig.setStrategyConfig(strategyConfig)
+	engineConfig.PositionSize = config.PositionSize
+	engineConfig.Pyramid = config.Pyramid
+	if _, err := NewEngine(backtestOpts.Engine, &engineConfig); err != nil {
+		log.Fatalf("创建策略引擎失败: %v", err)
+	}
+	newEngine := func() StrategyEngine {
+		engine, _ := NewEngine(backtestOpts.Engine, &engineConfig)
+		return engine
+	}
+	return config, newEngine
+}
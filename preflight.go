@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// defaultPreflightDays 上线前预估默认使用的最近天数
+const defaultPreflightDays = 30
+
+// PreflightOptions 上线前预估选项
+type PreflightOptions struct {
+	Days    int     // 使用最近多少天的 K 线
+	DBPath  string  // K 线库，为空时从币安下载
+	Balance float64 // 账户资金 (USDT)，0 时查询交易所，无 API Key 时使用回测默认资金
+}
+
+// PreflightReport 按实盘配置回测最近行情得到的交易频率和手续费预估，金额按实盘名义价值（含杠杆）
+type PreflightReport struct {
+	Start, End   int64 // 数据范围（秒）
+	Days         float64
+	Bars         int
+	Balance      float64 // 账户资金
+	Trades       int     // 平仓笔数（每批一笔）
+	TradesPerDay float64
+	BusiestDay   int64 // 平仓最多的一天（UTC 零点）
+	BusiestCount int
+	ActiveDays   int     // 有交易的天数
+	TurnoverDay  float64 // 日均成交额（开仓 + 平仓）
+	FeesDay      float64 // 日均手续费
+	GrossDay     float64 // 日均毛利（不含手续费）
+	Budget       float64 // 每日手续费预算，0 表示不检查
+}
+
+// OverBudget 预计日均手续费超过预算
+func (r *PreflightReport) OverBudget() bool {
+	return r.Budget > 0 && r.FeesDay > r.Budget
+}
+
+// preflightBacktestConfig 按实盘配置构造回测参数：费率为实盘 taker 费率；按余额比例下单时
+// 初始资金取 账户资金 × 杠杆，使每批名义价值（及名义价值上下限）与实盘一致（ATR 仓位按余额计风险，不乘杠杆）
+func preflightBacktestConfig(config *Config, balance float64, interval int64) (BacktestConfig, error) {
+	maintenance, err := ParseMaintenanceSchedule(config.Maintenance)
+	if err != nil {
+		return BacktestConfig{}, err
+	}
+	leverage := float64(max(config.Leverage, 1))
+	bt := DefaultBacktestConfig
+	bt.Symbol = config.Symbol
+	bt.StartBalance = balance
+	if config.Sizing.Unit == UnitFraction {
+		bt.StartBalance = balance * leverage
+	}
+	bt.FeeRate = liveFeeRate
+	bt.Leverage = leverage
+	bt.PositionSize = config.PositionSize
+	bt.Sizing = config.Sizing
+	bt.Throttle = config.Throttle
+	bt.StopLossPct = config.StopLossPct
+	bt.TakeProfitPct = config.TakeProfitPct
+	bt.Trailing = config.Trailing
+	bt.Pyramid = config.Pyramid
+	bt.MaxChaseBps = config.MaxChaseBps
+	bt.Maintenance = maintenance
+	bt.EquityFilter = config.EquityFilter
+	bt.VolTarget = config.VolTarget
+	bt.Interval = interval
+	return bt, nil
+}
+
+// RunPreflight 用实盘配置的引擎和参数回测 klines，统计交易频率和手续费
+func RunPreflight(klines []Kline, config *Config, balance float64, interval int64) (*PreflightReport, error) {
+	if len(klines) < 100 {
+		return nil, fmt.Errorf("not enough klines: %d", len(klines))
+	}
+	engine, err := NewEngine(config.Engine, config)
+	if err != nil {
+		return nil, err
+	}
+	bt, err := preflightBacktestConfig(config, balance, interval)
+	if err != nil {
+		return nil, err
+	}
+	result := RunEngineBacktest(klines, bt, engine)
+
+	report := &PreflightReport{
+		Start:   klines[0].Timestamp,
+		End:     klines[len(klines)-1].Timestamp + interval,
+		Bars:    len(klines),
+		Balance: balance,
+		Trades:  len(result.Trades),
+		Budget:  config.MaxDailyFees,
+	}
+	report.Days = float64(report.End-report.Start) / 86400
+	daily := make(map[int64]int)
+	turnover := 0.0
+	for _, t := range result.Trades {
+		daily[t.ExitTime-t.ExitTime%86400]++
+		turnover += (t.EntryPrice + t.ExitPrice) * t.Amount
+	}
+	report.ActiveDays = len(daily)
+	days := make([]int64, 0, len(daily))
+	for day := range daily {
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+	for _, day := range days {
+		if daily[day] > report.BusiestCount {
+			report.BusiestDay, report.BusiestCount = day, daily[day]
+		}
+	}
+	report.TradesPerDay = float64(report.Trades) / report.Days
+	report.TurnoverDay = turnover / report.Days
+	report.FeesDay = result.TotalFees / report.Days
+	report.GrossDay = (result.TotalPnL + result.TotalFees) / report.Days
+	return report, nil
+}
+
+// PrintPreflight 打印预估结果和警告
+func PrintPreflight(r *PreflightReport, config *Config) {
+	format := func(ts int64) string { return time.Unix(ts, 0).UTC().Format("2006-01-02") }
+	engine := config.Engine
+	if engine == "" {
+		engine = DefaultEngine
+	}
+	fmt.Println("\n========== 上线前预估 ==========")
+	fmt.Printf("数据: %s ~ %s, %.1f 天, %d 根 %s K 线\n", format(r.Start), format(r.End), r.Days, r.Bars, config.Interval)
+	fmt.Printf("参数: 引擎 %s, 仓位 %.0f%%, 杠杆 %dx, 账户资金 $%.2f, 费率 %.2f%%\n",
+		engine, config.PositionSize*100, max(config.Leverage, 1), r.Balance, liveFeeRate*100)
+	if r.Trades == 0 {
+		fmt.Println("最近行情中没有交易，无法预估频率和手续费")
+		fmt.Println("================================")
+		return
+	}
+	fmt.Printf("交易: 共 %d 笔, 平均每天 %.1f 笔, %d 天有交易, 最多一天 %d 笔 (%s)\n",
+		r.Trades, r.TradesPerDay, r.ActiveDays, r.BusiestCount, format(r.BusiestDay))
+	fmt.Printf("成交额: 平均每天 $%.0f (资金的 %.1f 倍)\n", r.TurnoverDay, r.TurnoverDay/r.Balance)
+	fmt.Printf("手续费: 平均每天 $%.2f (资金的 %.3f%%), 每 30 天约 $%.2f\n",
+		r.FeesDay, r.FeesDay/r.Balance*100, r.FeesDay*30)
+	fmt.Printf("毛利: 平均每天 $%.2f (不含手续费)\n", r.GrossDay)
+	if r.Budget > 0 {
+		status := "未超出"
+		if r.OverBudget() {
+			status = "超出"
+		}
+		fmt.Printf("预算: 每天 $%.2f, 预计手续费为预算的 %.0f%% (%s)\n", r.Budget, r.FeesDay/r.Budget*100, status)
+	}
+
+	if r.OverBudget() {
+		fmt.Printf("[警告] 预计日均手续费 $%.2f 超出预算 $%.2f，考虑降低仓位、收紧入场频率限制或调整参数\n", r.FeesDay, r.Budget)
+	}
+	if r.GrossDay <= r.FeesDay {
+		fmt.Printf("[警告] 最近行情中毛利 ($%.2f/天) 不足以覆盖手续费 ($%.2f/天)\n", r.GrossDay, r.FeesDay)
+	}
+	fmt.Println("================================")
+}
+
+// fetchRecentKlines 从币安合约接口下载 [startTime, endTime)（秒）内已收盘的 K 线，不写入数据库
+func fetchRecentKlines(symbol, interval string, sec, startTime, endTime int64) ([]Kline, error) {
+	endpoint := klineEndpoints["futures"]
+	client := &http.Client{Timeout: 30 * time.Second}
+	endTime = min(endTime, time.Now().Unix()-sec+1)
+	var klines []Kline
+	for from := startTime - startTime%sec; from < endTime; {
+		page, err := fetchKlinePage(client, endpoint, symbol, interval, from*1000, (endTime-1)*1000)
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			break
+		}
+		klines = append(klines, page...)
+		from = page[len(page)-1].Timestamp + sec
+		time.Sleep(downloadPause)
+	}
+	return klines, nil
+}
+
+// preflight 按当前配置预估最近 opts.Days 天的交易频率和手续费
+func (s *Strategy) preflight(opts PreflightOptions) (*PreflightReport, error) {
+	if opts.Days <= 0 {
+		opts.Days = defaultPreflightDays
+	}
+	balance := opts.Balance
+	if balance <= 0 && s.client != nil {
+		equity, err := s.accountEquity()
+		if err != nil {
+			return nil, fmt.Errorf("query balance: %w", err)
+		}
+		balance = equity
+	}
+	if balance <= 0 {
+		balance = DefaultBacktestConfig.StartBalance
+		log.Printf("未配置 API Key，按 $%.0f 资金预估", balance)
+	}
+
+	end := time.Now().Unix()
+	start := end - int64(opts.Days)*86400
+	var klines []Kline
+	var err error
+	if opts.DBPath != "" {
+		log.Printf("从 %s 加载最近 %d 天 K 线", opts.DBPath, opts.Days)
+		klines, err = loadIntervalKlines(opts.DBPath, s.config.Symbol, start, end, s.interval)
+	} else {
+		log.Printf("从币安下载最近 %d 天 %s %s K 线", opts.Days, s.config.Symbol, s.config.Interval)
+		klines, err = fetchRecentKlines(s.config.Symbol, s.config.Interval, s.interval, start, end)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load klines: %w", err)
+	}
+	return RunPreflight(klines, s.config, balance, s.interval)
+}
+
+// runPreflightCmd 执行上线前预估命令，超出手续费预算时以非零状态退出
+func runPreflightCmd(config *Config, opts PreflightOptions) {
+	strategy, err := NewStrategy(config)
+	if err != nil {
+		log.Fatalf("创建策略失败: %v", err)
+	}
+	report, err := strategy.preflight(opts)
+	if err != nil {
+		log.Fatalf("上线前预估失败: %v", err)
+	}
+	PrintPreflight(report, config)
+	if report.OverBudget() {
+		log.Fatalf("预计手续费超出预算")
+	}
+}
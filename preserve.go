@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+)
+
+// dryRun 新开仓是否只发信号不下单：未配置 API、配置为 dry-run、处于资金保护模式，或当前为净值曲线过滤的虚拟持仓
+func (s *Strategy) dryRun() bool {
+	return s.client == nil || s.config.DryRun || s.preserving.Load() || s.shadow
+}
+
+// paperPosition 当前持仓是否为模拟持仓：按开仓时的状态判断，实盘开的持仓在资金保护期间照常在交易所平仓；
+// 空仓时与 dryRun 相同
+func (s *Strategy) paperPosition() bool {
+	if s.entrySide == "" {
+		return s.dryRun()
+	}
+	return s.client == nil || s.config.DryRun || s.paper || s.shadow
+}
+
+// checkDrawdown 账户权益从峰值回撤超过 preserve_drawdown 时切换到资金保护模式（只发信号），
+// 需要运维通过控制接口确认后才恢复实盘
+func (s *Strategy) checkDrawdown() {
+	if s.config.PreserveDrawdown <= 0 || s.client == nil || s.config.DryRun {
+		return
+	}
+
+	equity, err := s.accountEquity()
+	if err != nil {
+		s.handleExchangeError(err)
+		return
+	}
+
+	// 恢复实盘后以当前权益作为新的峰值
+	if s.resetPeak.Swap(false) || equity > s.peakEquity {
+		s.peakEquity = equity
+	}
+	if s.peakEquity <= 0 || s.preserving.Load() {
+		return
+	}
+
+	drawdown := (s.peakEquity - equity) / s.peakEquity
+	if drawdown < s.config.PreserveDrawdown {
+		return
+	}
+
+	s.preserving.Store(true)
+	msg := fmt.Sprintf("账户回撤 %.2f%%（峰值 %.2f, 当前 %.2f）超过 %.2f%%，已切换为只发信号，确认后调用 POST /resume 恢复实盘",
+		drawdown*100, s.peakEquity, equity, s.config.PreserveDrawdown*100)
+	log.Printf("[告警] %s", msg)
+	s.webhook.Alert("capital_preservation", s.config.Symbol, msg)
+}
+
+// resume 运维确认后退出资金保护模式，以当时的权益作为新的峰值
+func (s *Strategy) resume() {
+	if s.preserving.Swap(false) {
+		s.resetPeak.Store(true)
+		log.Printf("运维已确认，恢复实盘交易")
+		s.webhook.Alert("capital_preservation_resumed", s.config.Symbol, "运维已确认，恢复实盘交易")
+	}
+}
+
+// PreserveStatus 控制接口 GET /status 的返回
+type PreserveStatus struct {
+	Symbol     string `json:"symbol"`
+	Preserving bool   `json:"preserving"`
+	RiskHalted string `json:"risk_halted,omitempty"` // 风控停止交易的原因
+}
+
+// serveControl 在 addr 上提供控制接口，请求需带 Authorization: Bearer $CONTROL_TOKEN
+//
+//	GET  /status  查询是否处于资金保护模式
+//	POST /resume  确认后恢复实盘交易
+//	POST /risk/reset  确认后解除风控停止交易的状态
+func (s *Strategy) serveControl(addr string) {
+	token := os.Getenv("CONTROL_TOKEN")
+	if token == "" {
+		log.Printf("未设置 CONTROL_TOKEN，控制接口未启动")
+		return
+	}
+
+	authorized := func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Bearer "+token
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(PreserveStatus{
+			Symbol:     s.config.Symbol,
+			Preserving: s.preserving.Load(),
+			RiskHalted: s.risk.Halted(),
+		})
+	})
+	mux.HandleFunc("/resume", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !authorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		s.resume()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/risk/reset", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !authorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		s.resetRisk()
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	log.Printf("控制接口: http://%s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("控制接口退出: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// 保护单类型，用于 clientOrderId
+const (
+	protectStop       = "sl"
+	protectTakeProfit = "tp"
+)
+
+// protectiveOrderID 保护单的 clientOrderId：每个交易对每种保护单固定一个 ID，
+// 交易所只要求 ID 在未完成订单中唯一，重启后仍可按 ID 撤销上次挂的保护单
+func protectiveOrderID(symbol, kind string) string {
+	return fmt.Sprintf("rsi-%s-%s", strings.ToLower(symbol), kind)
+}
+
+// protectivePrices 按持仓均价计算止损价和止盈价，未配置的返回 0
+func protectivePrices(side string, avgPrice, stopPct, takeProfitPct float64) (stop, takeProfit float64) {
+	dir := 1.0
+	if side == "SHORT" {
+		dir = -1
+	}
+	if stopPct > 0 {
+		stop = avgPrice * (1 - dir*stopPct)
+	}
+	if takeProfitPct > 0 {
+		takeProfit = avgPrice * (1 + dir*takeProfitPct)
+	}
+	return stop, takeProfit
+}
+
+// protectionEnabled 是否挂交易所保护单：配置了止损 / 止盈比例，为 ATR 仓位模式（止损按 ATR），或启用了移动止损
+func (s *Strategy) protectionEnabled() bool {
+	return s.config.StopLossPct > 0 || s.config.TakeProfitPct > 0 || s.config.Sizing.Unit == UnitATR || s.config.Trailing.Enabled()
+}
+
+// stopLossPct 当前持仓的止损比例：ATR 仓位模式下为开仓时的 ATR 止损距离（恢复或接管的持仓按当前 ATR 补算），
+// 否则为 stop_loss_pct
+func (s *Strategy) stopLossPct() float64 {
+	if s.config.Sizing.Unit != UnitATR {
+		return s.config.StopLossPct
+	}
+	if s.stopPct <= 0 {
+		s.stopPct = s.config.Sizing.ATRStopPct(s.currentATR(), s.entryPrice)
+	}
+	return s.stopPct
+}
+
+// currentATR 按已有 K 线计算的最新 ATR，不足 atr_period 根时为 0
+func (s *Strategy) currentATR() float64 {
+	atr := CalculateATR(s.klines, s.config.Sizing.atrPeriod())
+	if len(atr) == 0 {
+		return 0
+	}
+	return atr[len(atr)-1]
+}
+
+// placeProtection 按当前跟踪的持仓挂交易所止损 / 止盈单（reduce-only 全平），
+// 先撤销旧的保护单；进程崩溃后持仓仍受保护
+func (s *Strategy) placeProtection() {
+	if s.client == nil || s.entrySide == "" || s.entryPrice <= 0 {
+		return
+	}
+	if !s.protectionEnabled() {
+		return
+	}
+	s.cancelProtection()
+
+	stop, takeProfit := protectivePrices(s.entrySide, s.entryPrice, s.stopLossPct(), s.config.TakeProfitPct)
+	stop = tighterStop(s.entrySide, stop, s.trailStop)
+	var failed []string
+	if stop > 0 {
+		id := protectiveOrderID(s.config.Symbol, protectStop)
+		if _, err := s.client.FutureStopMarketClose(s.config.Symbol, s.entrySide, stop, id); err != nil {
+			failed = append(failed, fmt.Sprintf("止损 %.2f: %v", stop, err))
+		} else {
+			log.Printf("挂止损单: %s @ %.2f (%s)", s.entrySide, stop, id)
+		}
+	}
+	if takeProfit > 0 {
+		id := protectiveOrderID(s.config.Symbol, protectTakeProfit)
+		if _, err := s.client.FutureTakeProfitMarketClose(s.config.Symbol, s.entrySide, takeProfit, id); err != nil {
+			failed = append(failed, fmt.Sprintf("止盈 %.2f: %v", takeProfit, err))
+		} else {
+			log.Printf("挂止盈单: %s @ %.2f (%s)", s.entrySide, takeProfit, id)
+		}
+	}
+
+	if len(failed) > 0 {
+		msg := fmt.Sprintf("%s 持仓保护单挂单失败: %s", s.entrySide, strings.Join(failed, "; "))
+		log.Printf("[告警] %s", msg)
+		s.webhook.Alert("protection_failed", s.config.Symbol, msg)
+	}
+}
+
+// cancelProtection 撤销本交易对的止损 / 止盈单，订单不存在（已触发或未挂）时忽略
+func (s *Strategy) cancelProtection() {
+	if s.client == nil || !s.protectionEnabled() {
+		return
+	}
+	s.cancelSymbolProtection(s.config.Symbol)
+}
+
+// cancelSymbolProtection 撤销指定交易对的止损 / 止盈单（含交易对变更前遗留持仓的保护单）
+func (s *Strategy) cancelSymbolProtection(symbol string) {
+	for _, kind := range []string{protectStop, protectTakeProfit} {
+		id := protectiveOrderID(symbol, kind)
+		err := s.client.FutureCancelOrderByClientID(symbol, id)
+		if err != nil && !isUnknownOrder(err) {
+			log.Printf("撤销保护单 %s 失败: %v", id, err)
+		}
+	}
+}
+
+// isUnknownOrder 交易所明确答复订单不存在：撤单（-2011）或查询（-2013）
+func isUnknownOrder(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "-2011") || strings.Contains(msg, "unknown order") ||
+		strings.Contains(msg, "-2013") || strings.Contains(msg, "order does not exist")
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 加仓触发方式
+const (
+	PyramidSignal    = ""          // 由策略的加仓信号触发（RSI: EMA 金叉 / 死叉；反弹: RSI 仍高于入场阈值且 EMA 向上）
+	PyramidAdverse   = "adverse"   // 价格相对上一批入场价朝不利方向移动 step 后加仓，摊低成本
+	PyramidFavorable = "favorable" // 价格相对上一批入场价朝有利方向移动 step 后加仓，顺势加码
+)
+
+// PyramidConfig 分批建仓：各批仓位比例、加仓触发条件和总仓位上限，RSI 等引擎的回测、反弹回测和实盘共用。
+// 未配置时各策略保持原有的批次规则（RSI 两批各 position_size，反弹按 first / other_batch_size 和 max_batches）
+type PyramidConfig struct {
+	Sizes       []float64 `json:"sizes,omitempty"`        // 各批仓位比例，批数即长度；为空时按策略默认
+	Trigger     string    `json:"trigger,omitempty"`      // 加仓触发：为空按策略信号，adverse / favorable 按价格移动
+	Step        float64   `json:"step,omitempty"`         // adverse / favorable: 相对上一批入场价的移动比例
+	Interval    int64     `json:"interval,omitempty"`     // 相邻两批的最少间隔（秒）
+	MaxExposure float64   `json:"max_exposure,omitempty"` // 各批仓位比例之和的上限，0 表示不限
+}
+
+// Enabled 是否配置了分批建仓
+func (c PyramidConfig) Enabled() bool {
+	return len(c.Sizes) > 0 || c.Trigger != PyramidSignal || c.Interval > 0 || c.MaxExposure > 0
+}
+
+// Validate 检查配置
+func (c PyramidConfig) Validate() error {
+	for i, size := range c.Sizes {
+		if size <= 0 || size > 1 {
+			return fmt.Errorf("pyramid size of batch %d must be in (0, 1]", i+1)
+		}
+	}
+	switch c.Trigger {
+	case PyramidSignal:
+	case PyramidAdverse, PyramidFavorable:
+		if c.Step <= 0 || c.Step >= 1 {
+			return fmt.Errorf("pyramid step must be in (0, 1) for trigger %q", c.Trigger)
+		}
+		if len(c.Sizes) == 0 && c.MaxExposure <= 0 {
+			return fmt.Errorf("pyramid trigger %q needs sizes or max_exposure to bound the number of batches", c.Trigger)
+		}
+	default:
+		return fmt.Errorf("unknown pyramid trigger %q (want adverse or favorable)", c.Trigger)
+	}
+	if c.Interval < 0 || c.MaxExposure < 0 {
+		return fmt.Errorf("pyramid interval and max_exposure must be non-negative")
+	}
+	return nil
+}
+
+// ParsePyramidSizes 解析逗号分隔的各批仓位比例，空串返回 nil
+func ParsePyramidSizes(s string) ([]float64, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var sizes []float64
+	for _, field := range strings.Split(s, ",") {
+		size, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse pyramid size %q: %w", field, err)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+// size 第 batch 批（从 1 开始）的仓位比例，未配置 Sizes 时为 def
+func (c PyramidConfig) size(batch int, def float64) float64 {
+	if batch >= 1 && batch <= len(c.Sizes) {
+		return c.Sizes[batch-1]
+	}
+	return def
+}
+
+// exposure 已入场 entries 批的仓位比例之和
+func (c PyramidConfig) exposure(entries int, def float64) float64 {
+	total := 0.0
+	for batch := 1; batch <= entries; batch++ {
+		total += c.size(batch, def)
+	}
+	return total
+}
+
+// pyramidPosition 执行方判断加仓时的持仓
+type pyramidPosition struct {
+	side      string  // "LONG" / "SHORT"，"" 表示空仓
+	entries   int     // 已入场批次数
+	lastTime  int64   // 上一批入场时间
+	lastPrice float64 // 上一批入场价
+}
+
+// admit 判断下一批能否入场并返回其仓位比例：超出配置的批数、距上一批不足 Interval、或累计仓位将超过 MaxExposure 时拒绝。
+// def 为引擎给出（或默认）的仓位比例，配置了 Sizes 时以 Sizes 为准
+func (c PyramidConfig) admit(p pyramidPosition, def float64, now int64) (float64, bool) {
+	batch := p.entries + 1
+	if len(c.Sizes) > 0 && batch > len(c.Sizes) {
+		return 0, false
+	}
+	if p.entries > 0 && c.Interval > 0 && now-p.lastTime < c.Interval {
+		return 0, false
+	}
+	size := c.size(batch, def)
+	if c.MaxExposure > 0 && c.exposure(p.entries, def)+size > c.MaxExposure+1e-9 {
+		return 0, false
+	}
+	return size, true
+}
+
+// signalAddOn 加仓是否由策略信号触发；按价格触发时执行方忽略引擎的加仓指令
+func (c PyramidConfig) signalAddOn() bool {
+	return c.Trigger == PyramidSignal
+}
+
+// priceTriggered 按价格触发时，price 相对上一批入场价是否已移动 Step
+func (c PyramidConfig) priceTriggered(p pyramidPosition, price float64) bool {
+	if p.entries == 0 || p.lastPrice <= 0 {
+		return false
+	}
+	move := price/p.lastPrice - 1
+	if p.side == "SHORT" {
+		move = -move
+	}
+	switch c.Trigger {
+	case PyramidAdverse:
+		return move <= -c.Step
+	case PyramidFavorable:
+		return move >= c.Step
+	}
+	return false
+}
+
+// addOnOrder 按价格触发的加仓指令，未触发时返回 false
+func (c PyramidConfig) addOnOrder(p pyramidPosition, price float64) (Order, bool) {
+	if !c.priceTriggered(p, price) {
+		return Order{}, false
+	}
+	order := Order{Signal: SignalLong, Batch: p.entries + 1}
+	if p.side == "SHORT" {
+		order.Signal = SignalShort
+	}
+	if c.Trigger == PyramidAdverse {
+		order.Reason = fmt.Sprintf("逆向 %.2f%% 加仓", c.Step*100)
+	} else {
+		order.Reason = fmt.Sprintf("顺势 %.2f%% 加仓", c.Step*100)
+	}
+	return order, true
+}
+
+// filterOrders 按价格触发加仓时只保留引擎的第一批开仓指令，去掉持仓期间（含同一根 K 线刚开的仓）的同向开仓指令，
+// 原持仓仍在且价格已移动 Step 时追加加仓指令；按策略信号加仓时原样返回。
+// legs 为各方向的持仓（对冲模式下多空各一个），空仓的方向忽略
+func (c PyramidConfig) filterOrders(orders []Order, price float64, legs ...pyramidPosition) []Order {
+	if c.signalAddOn() {
+		return orders
+	}
+	held := map[string]bool{}
+	for _, p := range legs {
+		if p.entries > 0 {
+			held[p.side] = true
+		}
+	}
+	closed := map[string]bool{}
+	var kept []Order
+	for _, order := range orders {
+		switch order.Signal {
+		case SignalCloseLong, SignalCloseShort:
+			side := "LONG"
+			if order.Signal == SignalCloseShort {
+				side = "SHORT"
+			}
+			if held[side] {
+				held[side], closed[side] = false, true
+			}
+		case SignalLong, SignalShort:
+			side := "LONG"
+			if order.Signal == SignalShort {
+				side = "SHORT"
+			}
+			if held[side] {
+				continue
+			}
+			held[side] = true
+		}
+		kept = append(kept, order)
+	}
+	for _, p := range legs {
+		if p.entries == 0 || closed[p.side] {
+			continue
+		}
+		if order, ok := c.addOnOrder(p, price); ok {
+			kept = append(kept, order)
+		}
+	}
+	return kept
+}
+
+// pyramidName 分批建仓配置的简短描述，用于回测报告
+func pyramidName(c PyramidConfig) string {
+	if !c.Enabled() {
+		return "策略默认"
+	}
+	var parts []string
+	if len(c.Sizes) > 0 {
+		sizes := make([]string, len(c.Sizes))
+		for i, size := range c.Sizes {
+			sizes[i] = strconv.FormatFloat(size, 'g', -1, 64)
+		}
+		parts = append(parts, fmt.Sprintf("%d 批 %s", len(c.Sizes), strings.Join(sizes, "+")))
+	}
+	switch c.Trigger {
+	case PyramidAdverse:
+		parts = append(parts, fmt.Sprintf("逆向 %g 加仓", c.Step))
+	case PyramidFavorable:
+		parts = append(parts, fmt.Sprintf("顺势 %g 加仓", c.Step))
+	}
+	if c.Interval > 0 {
+		parts = append(parts, fmt.Sprintf("间隔 %ds", c.Interval))
+	}
+	if c.MaxExposure > 0 {
+		parts = append(parts, fmt.Sprintf("上限 %g", c.MaxExposure))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// 周期内 panic 的处理策略（panic_policy）
+const (
+	// PanicContinue 记录堆栈并告警后继续下一周期，连续 maxConsecutivePanics 个周期 panic 时停止
+	PanicContinue = "continue"
+	// PanicHalt 记录堆栈并告警后停止策略，交易所上的持仓和保护单保持不变
+	PanicHalt = "halt"
+)
+
+// maxConsecutivePanics continue 策略下连续 panic 的周期数上限，超过后视为持续性故障并停止
+const maxConsecutivePanics = 3
+
+// webhookFlushTimeout 策略停止时等待告警推送完成的最长时间
+const webhookFlushTimeout = 5 * time.Second
+
+// ValidPanicPolicy 校验 panic_policy，空值使用 continue
+func ValidPanicPolicy(policy string) error {
+	switch policy {
+	case "", PanicContinue, PanicHalt:
+		return nil
+	}
+	return fmt.Errorf("unknown panic_policy %q (want %s or %s)", policy, PanicContinue, PanicHalt)
+}
+
+// guard 执行 fn 并恢复其中的 panic：记录完整堆栈、推送告警，按 panic_policy 继续或停止策略；
+// 返回 fn 是否正常完成
+func (s *Strategy) guard(stage string, fn func()) (ok bool) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		ok = false
+		stack := debug.Stack()
+		s.panics++
+		log.Printf("[告警] %s发生 panic（连续第 %d 次）: %v\n%s", stage, s.panics, r, stack)
+
+		message := fmt.Sprintf("%s panic: %v at %s", stage, r, panicLocation(stack))
+		halt := s.config.PanicPolicy == PanicHalt || s.panics >= maxConsecutivePanics
+		if halt {
+			message += "; strategy halted"
+		}
+		s.webhook.Alert("panic", s.config.Symbol, message)
+		if halt {
+			log.Printf("[告警] panic 后停止策略（panic_policy=%s），交易所持仓和保护单保持不变", s.panicPolicy())
+			s.haltErr = fmt.Errorf("%s panic: %v", stage, r)
+			s.running = false
+		}
+	}()
+	fn()
+	s.panics = 0
+	return true
+}
+
+// panicPolicy 生效的 panic 处理策略
+func (s *Strategy) panicPolicy() string {
+	if s.config.PanicPolicy == "" {
+		return PanicContinue
+	}
+	return s.config.PanicPolicy
+}
+
+// panicLocation 从 debug.Stack 的输出中取引发 panic 的第一个本程序调用位置（文件:行），用于告警摘要
+func panicLocation(stack []byte) string {
+	lines := strings.Split(string(stack), "\n")
+	// 跳过 runtime 和 guard 自身的帧：panic 之后的第一个非 runtime 函数即出错位置
+	afterPanic := false
+	for i := 0; i+1 < len(lines); i++ {
+		fn := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(fn, "panic(") {
+			afterPanic = true
+			continue
+		}
+		if !afterPanic || strings.HasPrefix(fn, "runtime.") || !strings.HasPrefix(lines[i+1], "\t") {
+			continue
+		}
+		file := strings.TrimSpace(lines[i+1])
+		if j := strings.LastIndex(file, " +0x"); j > 0 {
+			file = file[:j]
+		}
+		if j := strings.LastIndex(file, "/"); j >= 0 {
+			file = file[j+1:]
+		}
+		return fn[:strings.LastIndex(fn, "(")] + " (" + file + ")"
+	}
+	return "unknown"
+}
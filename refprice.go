@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// 参考价格来源
+const (
+	RefSourceIndex = "index" // 合约指数价格（多家现货加权）
+	RefSourceSpot  = "spot"  // 币安现货最新价
+)
+
+// refSources 参考价格接口地址和价格字段
+var refSources = map[string]struct {
+	url   string
+	field string
+}{
+	RefSourceIndex: {"https://fapi.binance.com/fapi/v1/premiumIndex", "indexPrice"},
+	RefSourceSpot:  {"https://api.binance.com/api/v3/ticker/price", "price"},
+}
+
+// ReferencePrice 从第二数据源获取价格，用于核对 K 线收盘价
+type ReferencePrice struct {
+	source string
+	client *http.Client
+}
+
+// NewReferencePrice 创建参考价格源，source 为空时返回 nil
+func NewReferencePrice(source string) (*ReferencePrice, error) {
+	if source == "" {
+		return nil, nil
+	}
+	if _, ok := refSources[source]; !ok {
+		return nil, fmt.Errorf("unknown reference source %q", source)
+	}
+	return &ReferencePrice{source: source, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// Price 查询 symbol 的参考价格
+func (r *ReferencePrice) Price(symbol string) (float64, error) {
+	src := refSources[r.source]
+	resp, err := r.client.Get(src.url + "?symbol=" + url.QueryEscape(symbol))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("reference %s: http %d", r.source, resp.StatusCode)
+	}
+
+	var body map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("reference %s: %w", r.source, err)
+	}
+	raw, _ := body[src.field].(string)
+	price, err := strconv.ParseFloat(raw, 64)
+	if err != nil || price <= 0 {
+		return 0, fmt.Errorf("reference %s: invalid %s %q", r.source, src.field, raw)
+	}
+	return price, nil
+}
+
+// checkReferencePrice 核对最新 K 线收盘价与参考价格，偏离超过 max_divergence_bps 时
+// 告警并返回 false（本周期暂停交易）；参考源查询失败时只记录日志，不影响交易
+func (s *Strategy) checkReferencePrice() bool {
+	if s.refPrice == nil || len(s.klines) == 0 {
+		return true
+	}
+	ref, err := s.refPrice.Price(s.config.Symbol)
+	if err != nil {
+		log.Printf("查询参考价格失败: %v", err)
+		return true
+	}
+
+	close := s.klines[len(s.klines)-1].Close
+	divergence := math.Abs(close-ref) / ref * 1e4
+	if divergence <= s.config.MaxDivergenceBps {
+		if s.diverged {
+			s.diverged = false
+			log.Printf("K 线收盘价与参考价格恢复一致，恢复交易")
+		}
+		return true
+	}
+
+	if !s.diverged {
+		s.diverged = true
+		msg := fmt.Sprintf("K 线收盘价 %.2f 与参考价格 (%s) %.2f 偏离 %.1f bps，超过 %.1f bps，暂停交易",
+			close, s.config.ReferenceSource, ref, divergence, s.config.MaxDivergenceBps)
+		log.Printf("[告警] %s", msg)
+		s.webhook.Alert("data_divergence", s.config.Symbol, msg)
+	}
+	return false
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReportSource 汇总报告的一个交易日志，Label 标识账户或实例
+type ReportSource struct {
+	Label string
+	Path  string
+}
+
+// ParseReportSources 解析 "label=path,path2"，省略 label 时使用文件名
+func ParseReportSources(spec string) ([]ReportSource, error) {
+	var sources []ReportSource
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		label, path, ok := strings.Cut(item, "=")
+		if !ok {
+			path = item
+			label = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		if path == "" {
+			return nil, fmt.Errorf("journal source %q: empty path", item)
+		}
+		sources = append(sources, ReportSource{Label: label, Path: path})
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no journal sources")
+	}
+	return sources, nil
+}
+
+// ReportRow 一个账户 / 策略 / 交易对（或其汇总）在报告期内的表现
+type ReportRow struct {
+	Account     string
+	Strategy    string
+	Symbol      string
+	Trades      int     // 平仓次数
+	Wins        int     // 盈利的平仓次数
+	Aborted     int     // 被放弃的入场次数
+	PnL         float64 // 已实现盈亏（未扣手续费）
+	Fees        float64
+	Turnover    float64 // 开仓名义价值之和
+	AvgExposure float64 // 时间加权平均持仓名义价值
+	Share       float64 // 净盈亏占合计净盈亏的比例
+}
+
+// Net 扣除手续费后的净盈亏
+func (r ReportRow) Net() float64 {
+	return r.PnL - r.Fees
+}
+
+// WinRate 胜率
+func (r ReportRow) WinRate() float64 {
+	if r.Trades == 0 {
+		return 0
+	}
+	return float64(r.Wins) / float64(r.Trades)
+}
+
+// add 累加另一行
+func (r *ReportRow) add(o ReportRow) {
+	r.Trades += o.Trades
+	r.Wins += o.Wins
+	r.Aborted += o.Aborted
+	r.PnL += o.PnL
+	r.Fees += o.Fees
+	r.Turnover += o.Turnover
+	r.AvgExposure += o.AvgExposure
+}
+
+// Report 多个交易日志的汇总报告
+type Report struct {
+	From, To   int64
+	Rows       []ReportRow // 按账户、策略、交易对
+	ByStrategy []ReportRow // 按策略归因
+	Total      ReportRow
+}
+
+// reportOpen 持仓中的一批，用于计算时间加权敞口
+type reportOpen struct {
+	ts       int64
+	notional float64
+	row      *ReportRow
+}
+
+// BuildReport 读取各交易日志 [from, to] 区间的记录并汇总；区间之前开的仓位按 from 起算敞口
+func BuildReport(sources []ReportSource, from, to int64) (*Report, error) {
+	report := &Report{From: from, To: to}
+	rows := make(map[string]*ReportRow)
+	exposure := make(map[*ReportRow]float64) // 名义价值 × 秒
+
+	for _, src := range sources {
+		journal, err := OpenJournal(src.Path)
+		if err != nil {
+			return nil, fmt.Errorf("open journal %s: %w", src.Path, err)
+		}
+		entries, err := journal.Entries(0, to)
+		journal.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read journal %s: %w", src.Path, err)
+		}
+
+		open := make(map[string][]reportOpen) // 按交易对
+		for _, e := range entries {
+			strategy := e.Strategy
+			if strategy == "" {
+				strategy = "-"
+			}
+			key := src.Label + "\x00" + strategy + "\x00" + e.Symbol
+			row, ok := rows[key]
+			if !ok {
+				row = &ReportRow{Account: src.Label, Strategy: strategy, Symbol: e.Symbol}
+				rows[key] = row
+			}
+			inRange := e.Time >= from
+
+			switch e.Event {
+			case JournalOpen:
+				open[e.Symbol] = append(open[e.Symbol], reportOpen{ts: max(e.Time, from), notional: e.Notional, row: row})
+				if inRange {
+					row.Turnover += e.Notional
+				}
+			case JournalClose:
+				for _, o := range open[e.Symbol] {
+					if e.Time > o.ts {
+						exposure[o.row] += o.notional * float64(e.Time-o.ts)
+					}
+				}
+				delete(open, e.Symbol)
+				if inRange {
+					row.Trades++
+					if e.PnL > 0 {
+						row.Wins++
+					}
+					row.PnL += e.PnL
+				}
+			case JournalAborted:
+				if inRange {
+					row.Aborted++
+				}
+			}
+			if inRange {
+				row.Fees += e.Fee
+			}
+		}
+
+		// 报告期末仍持仓的部分算到 to
+		for _, batches := range open {
+			for _, o := range batches {
+				if to > o.ts {
+					exposure[o.row] += o.notional * float64(to-o.ts)
+				}
+			}
+		}
+	}
+
+	period := float64(max(to-from, 1))
+	byStrategy := make(map[string]*ReportRow)
+	for _, row := range rows {
+		row.AvgExposure = exposure[row] / period
+		if row.Trades == 0 && row.Aborted == 0 && row.Turnover == 0 && row.AvgExposure == 0 {
+			continue
+		}
+		report.Rows = append(report.Rows, *row)
+		report.Total.add(*row)
+
+		s, ok := byStrategy[row.Strategy]
+		if !ok {
+			s = &ReportRow{Strategy: row.Strategy}
+			byStrategy[row.Strategy] = s
+		}
+		s.add(*row)
+	}
+	for _, s := range byStrategy {
+		report.ByStrategy = append(report.ByStrategy, *s)
+	}
+
+	if total := report.Total.Net(); total != 0 {
+		for i := range report.Rows {
+			report.Rows[i].Share = report.Rows[i].Net() / total
+		}
+		for i := range report.ByStrategy {
+			report.ByStrategy[i].Share = report.ByStrategy[i].Net() / total
+		}
+	}
+	sort.Slice(report.Rows, func(i, j int) bool { return report.Rows[i].Net() > report.Rows[j].Net() })
+	sort.Slice(report.ByStrategy, func(i, j int) bool { return report.ByStrategy[i].Net() > report.ByStrategy[j].Net() })
+	return report, nil
+}
+
+// PrintReport 打印汇总报告
+func PrintReport(r *Report) {
+	fmt.Printf("\n========== 汇总报告 %s ~ %s (UTC) ==========\n",
+		time.Unix(r.From, 0).UTC().Format("2006-01-02"), time.Unix(r.To, 0).UTC().Format("2006-01-02"))
+	t := r.Total
+	fmt.Printf("平仓次数: %d, 胜率: %.2f%%, 放弃入场: %d\n", t.Trades, t.WinRate()*100, t.Aborted)
+	fmt.Printf("已实现盈亏: $%.2f, 手续费: $%.2f, 净盈亏: $%.2f\n", t.PnL, t.Fees, t.Net())
+	fmt.Printf("开仓成交额: $%.2f, 平均持仓名义价值: $%.2f\n", t.Turnover, t.AvgExposure)
+
+	fmt.Println("\n--- 按策略归因 ---")
+	fmt.Printf("%-12s %6s %8s %12s %10s %12s %8s\n", "策略", "平仓", "胜率", "净盈亏", "手续费", "平均敞口", "占比")
+	for _, s := range r.ByStrategy {
+		fmt.Printf("%-12s %6d %7.1f%% %12.2f %10.2f %12.2f %7.1f%%\n",
+			s.Strategy, s.Trades, s.WinRate()*100, s.Net(), s.Fees, s.AvgExposure, s.Share*100)
+	}
+
+	fmt.Println("\n--- 按账户 / 策略 / 交易对 ---")
+	fmt.Printf("%-12s %-12s %-10s %6s %8s %12s %10s %12s %8s\n", "账户", "策略", "交易对", "平仓", "胜率", "净盈亏", "手续费", "平均敞口", "占比")
+	for _, row := range r.Rows {
+		fmt.Printf("%-12s %-12s %-10s %6d %7.1f%% %12.2f %10.2f %12.2f %7.1f%%\n",
+			row.Account, row.Strategy, row.Symbol, row.Trades, row.WinRate()*100, row.Net(), row.Fees, row.AvgExposure, row.Share*100)
+	}
+	fmt.Println("================================")
+}
+
+// reportHTML 汇总报告 HTML 模板
+var reportHTML = template.Must(template.New("report").Funcs(template.FuncMap{
+	"date":    func(ts int64) string { return time.Unix(ts, 0).UTC().Format("2006-01-02") },
+	"money":   func(v float64) string { return fmt.Sprintf("%.2f", v) },
+	"percent": func(v float64) string { return fmt.Sprintf("%.1f%%", v*100) },
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>汇总报告 {{date .From}} ~ {{date .To}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+</style></head><body>
+<h1>汇总报告 {{date .From}} ~ {{date .To}} (UTC)</h1>
+<p>平仓 {{.Total.Trades}} 次，胜率 {{percent .Total.WinRate}}，放弃入场 {{.Total.Aborted}} 次<br>
+已实现盈亏 ${{money .Total.PnL}}，手续费 ${{money .Total.Fees}}，净盈亏 ${{money .Total.Net}}<br>
+开仓成交额 ${{money .Total.Turnover}}，平均持仓名义价值 ${{money .Total.AvgExposure}}</p>
+<h2>按策略归因</h2>
+<table><tr><th>策略</th><th>平仓</th><th>胜率</th><th>净盈亏</th><th>手续费</th><th>平均敞口</th><th>占比</th></tr>
+{{range .ByStrategy}}<tr><td>{{.Strategy}}</td><td>{{.Trades}}</td><td>{{percent .WinRate}}</td><td>{{money .Net}}</td><td>{{money .Fees}}</td><td>{{money .AvgExposure}}</td><td>{{percent .Share}}</td></tr>
+{{end}}</table>
+<h2>按账户 / 策略 / 交易对</h2>
+<table><tr><th>账户</th><th>策略</th><th>交易对</th><th>平仓</th><th>胜率</th><th>净盈亏</th><th>手续费</th><th>开仓成交额</th><th>平均敞口</th><th>放弃入场</th><th>占比</th></tr>
+{{range .Rows}}<tr><td>{{.Account}}</td><td>{{.Strategy}}</td><td>{{.Symbol}}</td><td>{{.Trades}}</td><td>{{percent .WinRate}}</td><td>{{money .Net}}</td><td>{{money .Fees}}</td><td>{{money .Turnover}}</td><td>{{money .AvgExposure}}</td><td>{{.Aborted}}</td><td>{{percent .Share}}</td></tr>
+{{end}}</table>
+</body></html>
+`))
+
+// WriteReportHTML 将汇总报告写为 HTML
+func WriteReportHTML(path string, r *Report) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := reportHTML.Execute(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// parseReportDate 解析 YYYY-MM-DD（UTC）
+func parseReportDate(s string) (int64, error) {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", s)
+	}
+	return t.Unix(), nil
+}
+
+// runReportCmd 执行汇总报告命令；未指定 -journals 时使用配置文件中的 journal_path
+func runReportCmd(journals, configPath, fromDate, toDate, htmlPath string) {
+	if journals == "" {
+		config, err := LoadConfig(configPath)
+		if err != nil || config.JournalPath == "" {
+			log.Fatalf("请用 -journals 指定交易日志，或在 %s 中配置 journal_path", configPath)
+		}
+		journals = config.JournalPath
+	}
+	sources, err := ParseReportSources(journals)
+	if err != nil {
+		log.Fatalf("解析 -journals 失败: %v", err)
+	}
+
+	to := time.Now().Unix()
+	if toDate != "" {
+		day, err := parseReportDate(toDate)
+		if err != nil {
+			log.Fatalf("解析 -to 失败: %v", err)
+		}
+		to = day + 86400 - 1 // 包含当天
+	}
+	from := to - 30*86400
+	if fromDate != "" {
+		if from, err = parseReportDate(fromDate); err != nil {
+			log.Fatalf("解析 -from 失败: %v", err)
+		}
+	}
+	if from >= to {
+		log.Fatalf("-from 需早于 -to")
+	}
+
+	report, err := BuildReport(sources, from, to)
+	if err != nil {
+		log.Fatalf("生成报告失败: %v", err)
+	}
+	PrintReport(report)
+
+	if htmlPath != "" {
+		if err := WriteReportHTML(htmlPath, report); err != nil {
+			log.Fatalf("导出 HTML 报告失败: %v", err)
+		}
+		log.Printf("HTML 报告已导出: %s", htmlPath)
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// RiskConfig 风控限制，比例均相对账户权益（按持仓收益率 × 仓位比例 × 杠杆折算），0 表示不限制
+type RiskConfig struct {
+	MaxDailyLoss    float64 `json:"max_daily_loss,omitempty"`    // 当天（UTC）权益最多亏损的比例（含浮亏），达到后当天不再入场
+	MaxExposure     float64 `json:"max_exposure,omitempty"`      // 持仓各批仓位比例之和的上限（与 position_size 同单位），超出的入场被拒绝
+	MaxConsecLosses int     `json:"max_consec_losses,omitempty"` // 连续亏损笔数上限，达到后停止入场直到人工重置
+	MaxDrawdown     float64 `json:"max_drawdown,omitempty"`      // 权益从峰值回撤的比例上限（含浮亏），达到后平掉持仓并停止交易直到人工重置
+}
+
+// enabled 是否配置了任一限制
+func (c RiskConfig) enabled() bool {
+	return c.MaxDailyLoss > 0 || c.MaxExposure > 0 || c.MaxConsecLosses > 0 || c.MaxDrawdown > 0
+}
+
+// Validate 检查配置
+func (c RiskConfig) Validate() error {
+	if c.MaxExposure < 0 || c.MaxConsecLosses < 0 {
+		return fmt.Errorf("risk.max_exposure and risk.max_consec_losses must be non-negative")
+	}
+	if c.MaxDailyLoss < 0 || c.MaxDailyLoss >= 1 || c.MaxDrawdown < 0 || c.MaxDrawdown >= 1 {
+		return fmt.Errorf("risk.max_daily_loss and risk.max_drawdown must be in [0, 1)")
+	}
+	return nil
+}
+
+// RiskState 风控状态，实盘写入运行状态文件，重启后恢复（停止交易的状态重启后仍保持）
+type RiskState struct {
+	Equity       float64 `json:"equity"`        // 已平仓持仓累计的权益指数，起始为 1
+	Peak         float64 `json:"peak"`          // 权益指数（含浮盈）的峰值
+	Day          int64   `json:"day"`           // 当前 UTC 日（零点，秒）
+	DayStart     float64 `json:"day_start"`     // 当天开始时的权益指数
+	ConsecLosses int     `json:"consec_losses"` // 当前连续亏损笔数
+	DailyPaused  bool    `json:"daily_paused,omitempty"`
+	Halted       string  `json:"halted,omitempty"` // 停止交易的原因，为空表示未停止
+}
+
+// 风控检查的结果（RiskManager.Mark）
+const (
+	RiskOK        = iota
+	RiskDailyLoss // 刚达到当天亏损上限
+	RiskDrawdown  // 刚触发回撤熔断，需要平仓
+)
+
+// RiskManager 实盘（含 dry-run）所有入场前的风控检查和熔断，nil 表示不启用。
+// 控制接口在其他 goroutine 中查询和重置，方法均加锁
+type RiskManager struct {
+	config RiskConfig
+	mu     sync.Mutex
+	state  RiskState
+	marked float64 // 最近一次按浮盈亏折算的权益指数
+}
+
+// NewRiskManager 创建风控，未配置任何限制时返回 nil
+func NewRiskManager(c RiskConfig) *RiskManager {
+	if !c.enabled() {
+		return nil
+	}
+	return &RiskManager{config: c, state: RiskState{Equity: 1, Peak: 1, DayStart: 1}, marked: 1}
+}
+
+// rollDay 跨过 UTC 零点时以当前权益作为新一天的起点
+func (r *RiskManager) rollDay(now int64) {
+	day := now - now%86400
+	if day == r.state.Day {
+		return
+	}
+	r.state.Day = day
+	r.state.DayStart = r.marked
+	r.state.DailyPaused = false
+}
+
+// dailyLoss 当天权益的亏损比例
+func (r *RiskManager) dailyLoss() float64 {
+	if r.state.DayStart <= 0 {
+		return 0
+	}
+	return 1 - r.marked/r.state.DayStart
+}
+
+// drawdown 权益从峰值的回撤比例
+func (r *RiskManager) drawdown() float64 {
+	if r.state.Peak <= 0 {
+		return 0
+	}
+	return 1 - r.marked/r.state.Peak
+}
+
+// Mark 按当前持仓的浮盈亏（占权益的比例）更新权益，检查当天亏损和回撤熔断，只在刚触发时返回非 RiskOK
+func (r *RiskManager) Mark(unrealized float64, now int64) int {
+	if r == nil {
+		return RiskOK
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.marked = r.state.Equity * (1 + unrealized)
+	r.rollDay(now)
+	r.state.Peak = max(r.state.Peak, r.marked)
+
+	if r.state.Halted == "" && r.config.MaxDrawdown > 0 && r.drawdown() >= r.config.MaxDrawdown {
+		r.state.Halted = fmt.Sprintf("权益回撤 %.2f%% 达到熔断线 %.2f%%", r.drawdown()*100, r.config.MaxDrawdown*100)
+		return RiskDrawdown
+	}
+	if !r.state.DailyPaused && r.config.MaxDailyLoss > 0 && r.dailyLoss() >= r.config.MaxDailyLoss {
+		r.state.DailyPaused = true
+		return RiskDailyLoss
+	}
+	return RiskOK
+}
+
+// Check 入场前检查：exposure 为当前持仓的仓位比例，size 为本次入场的仓位比例；允许时返回空，否则返回原因
+func (r *RiskManager) Check(exposure, size float64) string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case r.state.Halted != "":
+		return "已停止交易: " + r.state.Halted
+	case r.state.DailyPaused:
+		return fmt.Sprintf("当天亏损 %.2f%% 已达上限 %.2f%%", r.dailyLoss()*100, r.config.MaxDailyLoss*100)
+	case r.config.MaxExposure > 0 && exposure+size > r.config.MaxExposure+1e-9:
+		return fmt.Sprintf("持仓 %.1f%% 加本次 %.1f%% 超过上限 %.1f%%", exposure*100, size*100, r.config.MaxExposure*100)
+	}
+	return ""
+}
+
+// RecordClose 记录一个持仓的平仓收益（占权益的比例，已扣手续费），连续亏损达到上限时停止交易并返回 true
+func (r *RiskManager) RecordClose(ret float64) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.state.Equity *= 1 + ret
+	r.marked = r.state.Equity
+	r.state.Peak = max(r.state.Peak, r.marked)
+	if ret < 0 {
+		r.state.ConsecLosses++
+	} else {
+		r.state.ConsecLosses = 0
+	}
+	if r.state.Halted == "" && r.config.MaxConsecLosses > 0 && r.state.ConsecLosses >= r.config.MaxConsecLosses {
+		r.state.Halted = fmt.Sprintf("连续亏损 %d 笔达到上限", r.state.ConsecLosses)
+		return true
+	}
+	return false
+}
+
+// Halted 停止交易的原因，为空表示未停止
+func (r *RiskManager) Halted() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state.Halted
+}
+
+// Reset 人工确认后恢复交易：清除停止状态和连续亏损计数，以当前权益作为新的峰值；原本未停止时返回 false
+func (r *RiskManager) Reset() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	halted := r.state.Halted != ""
+	r.state.Halted = ""
+	r.state.ConsecLosses = 0
+	r.state.Peak = r.marked
+	return halted
+}
+
+// State 当前状态（用于持久化），未启用时返回 nil
+func (r *RiskManager) State() *RiskState {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state := r.state
+	return &state
+}
+
+// Restore 恢复持久化的状态
+func (r *RiskManager) Restore(state *RiskState) {
+	if r == nil || state == nil || state.Equity <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state = *state
+	r.marked = state.Equity
+}
+
+// positionReturn 当前持仓按 price 计算的收益占账户权益的比例：收益率 × 仓位比例 × 杠杆，closing 为 true 时扣除开平仓手续费
+func (s *Strategy) positionReturn(price float64, closing bool) float64 {
+	if s.entrySide == "" || s.entryPrice <= 0 {
+		return 0
+	}
+	ret := price/s.entryPrice - 1
+	if s.entrySide == "SHORT" {
+		ret = -ret
+	}
+	if closing {
+		ret -= 2 * liveFeeRate
+	}
+	return ret * s.entryExposure * s.leverage()
+}
+
+// checkRisk 每周期按最新价格（实盘为最新收盘价，跟单为信号价格）检查风控：当天亏损达到上限时告警，回撤熔断时停止交易；
+// 停止交易期间仍有持仓（熔断平仓失败，或重启后接管的持仓）时每周期平仓
+func (s *Strategy) checkRisk(price float64) {
+	if s.risk == nil || price <= 0 {
+		return
+	}
+	unrealized := 0.0
+	if !s.shadow {
+		unrealized = s.positionReturn(price, false)
+	}
+	switch s.risk.Mark(unrealized, time.Now().Unix()) {
+	case RiskDailyLoss:
+		msg := fmt.Sprintf("当天亏损达到 %.2f%% 上限，UTC 零点前不再入场", s.config.Risk.MaxDailyLoss*100)
+		log.Printf("[风控] %s", msg)
+		s.webhook.Alert("risk_daily_loss", s.config.Symbol, msg)
+	case RiskDrawdown:
+		s.haltRisk()
+	}
+	if s.entrySide != "" && s.risk.Halted() != "" {
+		s.flattenRisk()
+	}
+}
+
+// flattenRisk 风控停止交易后平掉当前持仓（dry-run 只清除模拟持仓），失败时下个周期重试
+func (s *Strategy) flattenRisk() {
+	if s.entrySide == "" {
+		return
+	}
+	signal := SignalCloseLong
+	if s.entrySide == "SHORT" {
+		signal = SignalCloseShort
+	}
+	log.Printf("[风控] 熔断平仓: %s %.6f", s.entrySide, s.entryAmount)
+	if _, _, err := s.executeSignalSized(signal, 0); err != nil {
+		s.handleExchangeError(err)
+	}
+}
+
+// haltRisk 风控停止交易后记录日志并告警
+func (s *Strategy) haltRisk() {
+	msg := fmt.Sprintf("%s，停止交易，确认后调用 POST /risk/reset 或以 -reset-risk 启动恢复", s.risk.Halted())
+	log.Printf("[风控] %s", msg)
+	s.webhook.Alert("risk_halt", s.config.Symbol, msg)
+}
+
+// recordRisk 平仓后计入风控，连续亏损达到上限时停止交易
+func (s *Strategy) recordRisk(ret float64) {
+	if s.risk.RecordClose(ret) {
+		s.haltRisk()
+	}
+}
+
+// resetRisk 人工确认后恢复风控停止的交易
+func (s *Strategy) resetRisk() {
+	if s.risk.Reset() {
+		log.Printf("[风控] 运维已确认，恢复交易")
+		s.webhook.Alert("risk_reset", s.config.Symbol, "运维已确认，恢复交易")
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// RollingOptions 滚动窗口回测选项
+type RollingOptions struct {
+	WindowDays int // 窗口长度（天）
+	StepDays   int // 相邻窗口起点的间隔（天）
+}
+
+// WindowResult 单个窗口的回测结果
+type WindowResult struct {
+	Start       int64 // 窗口开始时间（秒）
+	End         int64 // 窗口结束时间（秒，不含）
+	Bars        int
+	PnL         float64
+	Return      float64 // 总盈亏 / 初始资金
+	MaxDrawdown float64
+	Sharpe      float64
+	Trades      int
+	WinRate     float64
+}
+
+// RollingResult 所有窗口的结果，Windows 按开始时间排序
+type RollingResult struct {
+	Windows    []WindowResult
+	Returns    []float64 // 各窗口收益率（升序）
+	Drawdowns  []float64 // 各窗口最大回撤（升序）
+	Sharpes    []float64 // 各窗口夏普（升序）
+	Trades     []float64 // 各窗口交易数（升序）
+	MeanReturn float64
+	StdReturn  float64
+	Profitable float64 // 盈利窗口的比例
+}
+
+// RunRollingWindows 用同一组参数在按 StepDays 平移的 WindowDays 天窗口上分别回测，
+// 每个窗口从初始资金和空仓开始，指标预热也在窗口内完成；不足一个完整窗口的尾部不计入
+func RunRollingWindows(klines []Kline, config BacktestConfig, newEngine func() StrategyEngine, opts RollingOptions) (*RollingResult, error) {
+	if opts.WindowDays <= 0 || opts.StepDays <= 0 {
+		return nil, fmt.Errorf("window and step days must be positive")
+	}
+	if len(klines) == 0 {
+		return nil, fmt.Errorf("no klines")
+	}
+
+	// 窗口只用于比较，不写逐笔交易
+	config.TradeLog = nil
+	config.StreamOnly = true
+	config.Explain = false
+
+	window := int64(opts.WindowDays) * 24 * 3600
+	step := int64(opts.StepDays) * 24 * 3600
+	last := klines[len(klines)-1].Timestamp
+	result := &RollingResult{}
+	for start := klines[0].Timestamp; start+window <= last+1; start += step {
+		end := start + window
+		from := sort.Search(len(klines), func(i int) bool { return klines[i].Timestamp >= start })
+		to := sort.Search(len(klines), func(i int) bool { return klines[i].Timestamp >= end })
+		if to-from < 100 {
+			continue
+		}
+		res := RunEngineBacktest(klines[from:to], config, newEngine())
+		w := WindowResult{
+			Start:       start,
+			End:         end,
+			Bars:        to - from,
+			PnL:         res.TotalPnL,
+			MaxDrawdown: res.MaxDrawdown,
+			Sharpe:      res.Performance.Sharpe,
+			Trades:      res.TotalTrades,
+			WinRate:     res.WinRate,
+		}
+		if config.StartBalance > 0 {
+			w.Return = res.TotalPnL / config.StartBalance
+		}
+		result.Windows = append(result.Windows, w)
+	}
+	if len(result.Windows) == 0 {
+		return nil, fmt.Errorf("data shorter than one %d-day window", opts.WindowDays)
+	}
+
+	profitable := 0
+	for _, w := range result.Windows {
+		result.Returns = append(result.Returns, w.Return)
+		result.Drawdowns = append(result.Drawdowns, w.MaxDrawdown)
+		result.Sharpes = append(result.Sharpes, w.Sharpe)
+		result.Trades = append(result.Trades, float64(w.Trades))
+		if w.PnL > 0 {
+			profitable++
+		}
+	}
+	sort.Float64s(result.Returns)
+	sort.Float64s(result.Drawdowns)
+	sort.Float64s(result.Sharpes)
+	sort.Float64s(result.Trades)
+	result.MeanReturn, result.StdReturn, _, _ = Moments(result.Returns)
+	result.Profitable = float64(profitable) / float64(len(result.Windows))
+	return result, nil
+}
+
+// PrintRollingResult 打印窗口结果的分布、最好 / 最差窗口和收益率直方图
+func PrintRollingResult(r *RollingResult, opts RollingOptions) {
+	format := func(ts int64) string { return time.Unix(ts, 0).UTC().Format("2006-01-02") }
+	fmt.Println("\n========== 滚动窗口回测 ==========")
+	fmt.Printf("窗口: %d 天, 步长: %d 天, 共 %d 个窗口 (%s ~ %s)\n", opts.WindowDays, opts.StepDays, len(r.Windows),
+		format(r.Windows[0].Start), format(r.Windows[len(r.Windows)-1].End))
+
+	fmt.Println("\n--- 窗口分布 ---")
+	fmt.Printf("%-8s %10s %10s %10s %10s %10s %10s %10s\n", "", "最小", "5%", "25%", "50%", "75%", "95%", "最大")
+	row := func(name string, values []float64, scale float64, format string) {
+		fmt.Printf("%-8s", name)
+		fmt.Printf(" %10s", fmt.Sprintf(format, values[0]*scale))
+		for _, q := range []float64{0.05, 0.25, 0.5, 0.75, 0.95} {
+			fmt.Printf(" %10s", fmt.Sprintf(format, quantile(values, q)*scale))
+		}
+		fmt.Printf(" %10s\n", fmt.Sprintf(format, values[len(values)-1]*scale))
+	}
+	row("收益%", r.Returns, 100, "%.2f")
+	row("回撤%", r.Drawdowns, 100, "%.2f")
+	row("夏普", r.Sharpes, 1, "%.2f")
+	row("交易数", r.Trades, 1, "%.0f")
+
+	fmt.Printf("\n平均收益: %.2f%% (标准差 %.2f%%), 盈利窗口占比: %.1f%%\n",
+		r.MeanReturn*100, r.StdReturn*100, r.Profitable*100)
+
+	byReturn := append([]WindowResult(nil), r.Windows...)
+	sort.SliceStable(byReturn, func(i, j int) bool { return byReturn[i].Return < byReturn[j].Return })
+	n := min(3, len(byReturn))
+	printWindow := func(w WindowResult) {
+		fmt.Printf("  %s ~ %s  收益 %7.2f%%  回撤 %6.2f%%  夏普 %5.2f  交易 %d 笔\n",
+			format(w.Start), format(w.End), w.Return*100, w.MaxDrawdown*100, w.Sharpe, w.Trades)
+	}
+	fmt.Println("\n最差窗口:")
+	for _, w := range byReturn[:n] {
+		printWindow(w)
+	}
+	fmt.Println("最好窗口:")
+	for i := len(byReturn) - 1; i >= len(byReturn)-n; i-- {
+		printWindow(byReturn[i])
+	}
+
+	returns := make([]float64, len(r.Returns))
+	for i, v := range r.Returns {
+		returns[i] = v * 100
+	}
+	fmt.Println()
+	printHistogram("窗口收益率 (%):", Histogram(returns, 10), "%7.2f")
+	fmt.Println("==================================")
+}
+
+// runRollingCmd 执行滚动窗口回测命令
+func runRollingCmd(dbPath, symbol string, startTime, endTime int64, opts RollingOptions, backtestOpts BacktestOptions) {
+	if opts.WindowDays <= 0 || opts.StepDays <= 0 {
+		log.Fatalf("-window-days 和 -step-days 需大于 0")
+	}
+
+	log.Printf("加载 K 线数据: %s", symbol)
+	klines, err := loadIntervalKlines(dbPath, symbol, startTime, endTime, backtestOpts.Interval)
+	if err != nil {
+		log.Fatalf("加载数据失败: %v", err)
+	}
+	log.Printf("加载 %d 根 %ds K 线", len(klines), backtestOpts.Interval)
+	if len(klines) < 100 {
+		log.Fatalf("数据不足，至少需要 100 根 K 线")
+	}
+
+	config, newEngine := engineBacktestSetup(symbol, backtestOpts)
+	result, err := RunRollingWindows(klines, config, newEngine, opts)
+	if err != nil {
+		log.Fatalf("滚动窗口回测失败: %v", err)
+	}
+	PrintRollingResult(result, opts)
+}
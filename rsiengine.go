@@ -0,0 +1,256 @@
+package main
+
+import "fmt"
+
+// emaStream 逐根计算 EMA：前 period 根用 SMA 初始化，与 CalculateEMA 一致；
+// 同一根 K 线重复输入（未走完）时基于上一根的值重算当前值
+type emaStream struct {
+	period int
+	closed int     // 已走完的 K 线数
+	sum    float64 // 初始化阶段已走完 K 线的收盘价之和
+	prev   float64 // 上一根的 EMA（closed >= period 时有效）
+	cur    float64 // 当前 K 线的 EMA（ready 时有效）
+	close  float64 // 当前 K 线的收盘价
+	has    bool
+}
+
+// update 输入当前 K 线收盘价，newBar 表示上一根已经走完
+func (e *emaStream) update(close float64, newBar bool) {
+	if newBar && e.has {
+		e.closed++
+		if e.closed <= e.period {
+			e.sum += e.close
+		}
+		e.prev = e.cur
+	}
+	e.has = true
+	e.close = close
+
+	multiplier := 2.0 / float64(e.period+1)
+	switch n := e.closed + 1; {
+	case n < e.period:
+		e.cur = 0
+	case n == e.period:
+		e.cur = (e.sum + close) / float64(e.period)
+	default:
+		e.cur = (close-e.prev)*multiplier + e.prev
+	}
+}
+
+// lastVolumeRatio 最后一根成交量 / 最近 period 根（含最后一根）平均成交量
+func lastVolumeRatio(klines []Kline, period int) float64 {
+	var volSum float64
+	for _, b := range klines[len(klines)-period:] {
+		volSum += b.Volume
+	}
+	if volSum <= 0 {
+		return 0
+	}
+	return klines[len(klines)-1].Volume / (volSum / float64(period))
+}
+
+// RSI 策略的出场阈值与持仓超时
+const (
+	rsiExitLong  = 40   // 多头 RSI 跌破即出场
+	rsiExitShort = 60   // 空头 RSI 突破即出场
+	rsiHoldMid   = 50   // 超时后 RSI 偏弱 / 偏强出场
+	maxHoldSecs  = 1800 // 持仓超时（30 分钟）
+)
+
+// RSIEngine 默认的 RSI + EMA + 成交量分批策略，实盘和回测共用
+//
+// 入场：第一批 RSI 超卖回升 / 超买回落 + 突破前 5 根高低点 + 放量 + 顺 EMA 趋势；
+// 之后每次 EMA 金叉 / 死叉确认后加仓一批（默认共两批，按 PyramidConfig.Sizes 的批数）。出场：EMA 反向交叉、RSI 跌破 40 / 突破 60，
+// 或持仓超过 30 分钟且 RSI 偏弱 / 偏强
+type RSIEngine struct {
+	config       StrategyConfig
+	positionSize float64       // 每批仓位比例，持仓超过 1 批时不再开第一批
+	pyramid      PyramidConfig // 分批建仓，决定加仓批数和持仓上限
+	buf          klineBuffer
+	emaFast      emaStream
+	emaSlow      emaStream
+	last         SignalExplanation
+	// series 预计算的指标序列（参数优化共享），下标为收到的第几根 K 线；nil 时逐根计算
+	series *rsiSeries
+}
+
+// rsiSeries 整段回测 K 线上的 RSIEngine 指标，与 OnKline 逐根计算的值一致
+type rsiSeries struct {
+	rsi      []float64
+	emaFast  []float64
+	emaSlow  []float64
+	volRatio []float64 // 当前成交量 / 最近 RSI_PERIOD 根（含当前）平均成交量
+}
+
+// NewRSIEngine 创建 RSI 策略引擎
+func NewRSIEngine(config StrategyConfig, positionSize float64) *RSIEngine {
+	return &RSIEngine{
+		config:       config,
+		positionSize: positionSize,
+		buf:          klineBuffer{limit: max(config.RSI_PERIOD+2, 6)},
+		emaFast:      emaStream{period: config.EMA_FAST},
+		emaSlow:      emaStream{period: config.EMA_SLOW},
+	}
+}
+
+// withPyramid 按分批建仓配置决定加仓批数
+func (e *RSIEngine) withPyramid(p PyramidConfig) *RSIEngine {
+	e.pyramid = p
+	return e
+}
+
+// batches 最多入场的批数
+func (e *RSIEngine) batches() int {
+	if n := len(e.pyramid.Sizes); n > 0 {
+		return n
+	}
+	return 2
+}
+
+// addOn 是否还能加仓：已入场批数未满且按当前价格计的仓位低于各批比例之和
+func (e *RSIEngine) addOn(pos PositionView) bool {
+	return pos.Entries < e.batches() && pos.Exposure < e.pyramid.exposure(e.batches(), e.positionSize)
+}
+
+// withSeries 使用预计算的指标序列，只适用于从第一根开始逐根输入已收盘 K 线的回测
+func (e *RSIEngine) withSeries(s *rsiSeries) *RSIEngine {
+	e.series = s
+	return e
+}
+
+func (e *RSIEngine) Name() string    { return "rsi" }
+func (e *RSIEngine) WarmupBars() int { return e.config.requiredBars() }
+
+// minBars 指标（含上一根的值）全部有效所需的 K 线数
+func (e *RSIEngine) minBars() int {
+	return max(e.config.RSI_PERIOD+2, e.config.EMA_FAST+1, e.config.EMA_SLOW+1, 6)
+}
+
+func (e *RSIEngine) Explanation() SignalExplanation {
+	return e.last
+}
+
+func (e *RSIEngine) OnKline(k Kline, pos PositionView) []Order {
+	newBar := e.buf.push(k)
+	if e.series == nil {
+		e.emaFast.update(k.Close, newBar)
+		e.emaSlow.update(k.Close, newBar)
+	}
+	if e.buf.total < e.minBars() {
+		e.last = SignalExplanation{Time: k.Timestamp, Warmup: true}
+		return nil
+	}
+
+	sc := e.config
+	klines := e.buf.klines
+	n := len(klines)
+
+	var currentRSI, prevRSI, currentFast, currentSlow, prevFast, prevSlow, volRatio float64
+	if s := e.series; s != nil {
+		i := e.buf.total - 1
+		currentRSI, prevRSI = s.rsi[i], s.rsi[i-1]
+		currentFast, currentSlow = s.emaFast[i], s.emaSlow[i]
+		prevFast, prevSlow = s.emaFast[i-1], s.emaSlow[i-1]
+		volRatio = s.volRatio[i]
+	} else {
+		rsi := CalculateRSI(klines[n-sc.RSI_PERIOD-2:], sc.RSI_PERIOD)
+		currentRSI, prevRSI = rsi[len(rsi)-1], rsi[len(rsi)-2]
+		currentFast, currentSlow = e.emaFast.cur, e.emaSlow.cur
+		prevFast, prevSlow = e.emaFast.prev, e.emaSlow.prev
+		volRatio = lastVolumeRatio(klines, sc.RSI_PERIOD)
+	}
+
+	// 前 5 根 K 线最高 / 最低价
+	high5, low5 := klines[n-2].High, klines[n-2].Low
+	for _, b := range klines[n-6 : n-2] {
+		high5 = max(high5, b.High)
+		low5 = min(low5, b.Low)
+	}
+
+	var orders []Order
+
+	// ========== 出场 ==========
+	// 对冲模式下多空两侧各自按同样的规则出场
+	long, short := pos.Leg("LONG"), pos.Leg("SHORT")
+	for _, leg := range []*PositionView{&long, &short} {
+		if leg.Side == "" {
+			continue
+		}
+		crossDown := prevFast > prevSlow && currentFast <= currentSlow
+		crossUp := prevFast < prevSlow && currentFast >= currentSlow
+		overdue := k.Timestamp-leg.EntryTime > maxHoldSecs
+
+		var reason string
+		switch {
+		case leg.Side == "LONG" && crossDown:
+			reason = "EMA 死叉"
+		case leg.Side == "LONG" && currentRSI < rsiExitLong:
+			reason = fmt.Sprintf("RSI 跌破 %d", rsiExitLong)
+		case leg.Side == "LONG" && overdue && currentRSI < rsiHoldMid:
+			reason = "持仓超时且 RSI 偏弱"
+		case leg.Side == "SHORT" && crossUp:
+			reason = "EMA 金叉"
+		case leg.Side == "SHORT" && currentRSI > rsiExitShort:
+			reason = fmt.Sprintf("RSI 突破 %d", rsiExitShort)
+		case leg.Side == "SHORT" && overdue && currentRSI > rsiHoldMid:
+			reason = "持仓超时且 RSI 偏强"
+		}
+		if reason != "" {
+			signal := SignalCloseLong
+			if leg.Side == "SHORT" {
+				signal = SignalCloseShort
+			}
+			orders = append(orders, Order{Signal: signal, Reason: reason})
+			*leg = PositionView{Hedge: pos.Hedge}
+		}
+	}
+
+	// ========== 入场 ==========
+	uptrend := currentFast > currentSlow
+	downtrend := currentFast < currentSlow
+	volumeOK := volRatio >= sc.VOL_RATIO_THRESHOLD
+	rsiBull := prevRSI < sc.RSI_OVERSOLD_LONG && currentRSI >= sc.RSI_ENTRY_LONG
+	rsiBear := prevRSI > sc.RSI_OVERBOUGHT_SHORT && currentRSI <= sc.RSI_ENTRY_SHORT
+	// 单向持仓时持有一侧就不开另一侧，对冲模式下两侧互不影响
+	longOK := short.Side == "" || pos.Hedge
+	shortOK := long.Side == "" || pos.Hedge
+
+	if longOK && uptrend {
+		if rsiBull && k.Close > high5 && volumeOK && long.Exposure < e.positionSize {
+			orders = append(orders, Order{Signal: SignalLong, Batch: 1, Reason: "RSI 超卖回升突破前高"})
+		}
+		if prevFast <= prevSlow && currentFast > currentSlow && e.addOn(long) {
+			orders = append(orders, Order{Signal: SignalLong, Batch: max(long.Entries+1, 2), Reason: "EMA 金叉加仓"})
+		}
+	}
+	if shortOK && downtrend {
+		if rsiBear && k.Close < low5 && volumeOK && short.Exposure < e.positionSize {
+			orders = append(orders, Order{Signal: SignalShort, Batch: 1, Reason: "RSI 超买回落跌破前低"})
+		}
+		if prevFast >= prevSlow && currentFast < currentSlow && e.addOn(short) {
+			orders = append(orders, Order{Signal: SignalShort, Batch: max(short.Entries+1, 2), Reason: "EMA 死叉加仓"})
+		}
+	}
+
+	e.last = SignalExplanation{
+		Time: k.Timestamp,
+		Long: []SignalCondition{
+			{"rsiBull", rsiBull, fmt.Sprintf("prevRSI %.1f < %.0f, RSI %.1f >= %.0f", prevRSI, sc.RSI_OVERSOLD_LONG, currentRSI, sc.RSI_ENTRY_LONG)},
+			{"uptrend", uptrend, fmt.Sprintf("EMA%d %.2f > EMA%d %.2f", sc.EMA_FAST, currentFast, sc.EMA_SLOW, currentSlow)},
+			{"breakout", k.Close > high5, fmt.Sprintf("close %.2f, high5 %.2f", k.Close, high5)},
+			{"volumeOK", volumeOK, fmt.Sprintf("volRatio %.2f >= %.2f", volRatio, sc.VOL_RATIO_THRESHOLD)},
+			{"positionOK", longOK && long.Exposure < e.positionSize, fmt.Sprintf("仓位 %.0f%%", long.Exposure*100)},
+		},
+		Short: []SignalCondition{
+			{"rsiBear", rsiBear, fmt.Sprintf("prevRSI %.1f > %.0f, RSI %.1f <= %.0f", prevRSI, sc.RSI_OVERBOUGHT_SHORT, currentRSI, sc.RSI_ENTRY_SHORT)},
+			{"downtrend", downtrend, fmt.Sprintf("EMA%d %.2f < EMA%d %.2f", sc.EMA_FAST, currentFast, sc.EMA_SLOW, currentSlow)},
+			{"breakout", k.Close < low5, fmt.Sprintf("close %.2f, low5 %.2f", k.Close, low5)},
+			{"volumeOK", volumeOK, fmt.Sprintf("volRatio %.2f >= %.2f", volRatio, sc.VOL_RATIO_THRESHOLD)},
+			{"positionOK", shortOK && short.Exposure < e.positionSize, fmt.Sprintf("仓位 %.0f%%", short.Exposure*100)},
+		},
+	}
+	if len(orders) > 0 {
+		e.last.Signal = orders[0].Signal
+	}
+	return orders
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// 会话记录类型
+const (
+	RecordKlines = "klines"
+	RecordTicker = "ticker"
+)
+
+// SessionRecord 实盘消费的一条行情数据
+type SessionRecord struct {
+	Type   string  `json:"type"`
+	Time   int64   `json:"time"` // 接收时间（Unix 秒）
+	Symbol string  `json:"symbol"`
+	Klines []Kline `json:"klines,omitempty"`
+	Price  float64 `json:"price,omitempty"`
+}
+
+// SessionRecorder 将行情数据逐行追加到会话文件（JSON Lines）
+type SessionRecorder struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// NewSessionRecorder 打开（追加）会话文件
+func NewSessionRecorder(path string) (*SessionRecorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionRecorder{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Record 写入一条记录，未启用录制时为空操作
+func (r *SessionRecorder) Record(rec SessionRecord) {
+	if r == nil {
+		return
+	}
+	if rec.Time == 0 {
+		rec.Time = time.Now().Unix()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(rec); err != nil {
+		log.Printf("写入会话记录失败: %v", err)
+	}
+}
+
+// Close 关闭会话文件
+func (r *SessionRecorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	return r.f.Close()
+}
+
+// loadSession 读取会话文件
+func loadSession(path string) ([]SessionRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []SessionRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var rec SessionRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, rec)
+	}
+
+	return records, scanner.Err()
+}
+
+// RunReplay 将录制的会话逐条送入策略（强制 dry-run），用于事后复盘
+func RunReplay(path string, config *Config) error {
+	records, err := loadSession(path)
+	if err != nil {
+		return err
+	}
+	log.Printf("加载会话 %s: %d 条记录", path, len(records))
+
+	replayConfig := *config
+	replayConfig.DryRun = true
+	replayConfig.Webhooks = nil
+	replayConfig.PublishAddr = ""
+	engine, err := NewEngine(replayConfig.Engine, &replayConfig)
+	if err != nil {
+		return err
+	}
+	interval, err := ParseInterval(replayConfig.Interval)
+	if err != nil {
+		return err
+	}
+	s := &Strategy{config: &replayConfig, engine: engine, interval: interval}
+
+	for _, rec := range records {
+		if rec.Symbol != "" {
+			replayConfig.Symbol = rec.Symbol
+		}
+		switch rec.Type {
+		case RecordKlines:
+			log.Printf("=== 回放 %s ===", time.Unix(rec.Time, 0).Format("2006-01-02 15:04:05"))
+			s.klines = rec.Klines
+			s.evaluate()
+		case RecordTicker:
+			log.Printf("[ticker] %s %.2f", time.Unix(rec.Time, 0).Format("15:04:05"), rec.Price)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// DataSource 模拟交易的行情来源
+type DataSource interface {
+	// Klines 按时间升序返回 K 线，Timestamp 为秒
+	Klines(ctx context.Context) ([]Kline, error)
+}
+
+// KlineSlice 内存中的 K 线，直接作为行情来源
+type KlineSlice []Kline
+
+// Klines 实现 DataSource
+func (s KlineSlice) Klines(ctx context.Context) ([]Kline, error) {
+	return s, nil
+}
+
+// DBSource 从 K 线数据库加载行情，列布局与回测命令相同
+type DBSource struct {
+	Path      string
+	Symbol    string
+	StartTime int64 // 秒，0 表示不限
+	EndTime   int64 // 秒，0 表示不限
+}
+
+// Klines 实现 DataSource
+func (s DBSource) Klines(ctx context.Context) ([]Kline, error) {
+	return loadKlinesFromDB(s.Path, s.Symbol, s.StartTime, s.EndTime)
+}
+
+// SimOption 模拟交易参数，按传入顺序作用于 DefaultBacktestConfig
+type SimOption func(c *BacktestConfig) error
+
+// WithConfig 以完整的回测配置为基础，之后的选项在其上修改
+func WithConfig(config BacktestConfig) SimOption {
+	return func(c *BacktestConfig) error {
+		*c = config
+		return nil
+	}
+}
+
+// WithBalance 初始资金 (USDT)
+func WithBalance(balance float64) SimOption {
+	return func(c *BacktestConfig) error {
+		if balance <= 0 {
+			return fmt.Errorf("start balance must be positive")
+		}
+		c.StartBalance = balance
+		return nil
+	}
+}
+
+// WithFees 手续费率，开仓和平仓按成交名义价值各收一次
+func WithFees(rate float64) SimOption {
+	return func(c *BacktestConfig) error {
+		if rate < 0 {
+			return fmt.Errorf("fee rate must be non-negative")
+		}
+		c.FeeRate = rate
+		return nil
+	}
+}
+
+// WithFeeTiers 手续费档位：按累计模拟成交额升档，替代 WithFees 的固定费率；
+// entryFill 为 EntryLimit 时入场按限价单（maker）成交
+func WithFeeTiers(tiers []FeeTier, entryFill string) SimOption {
+	return func(c *BacktestConfig) error {
+		if len(tiers) > 0 {
+			if err := ValidateFeeTiers(tiers); err != nil {
+				return err
+			}
+		}
+		if entryFill != EntryMarket && entryFill != EntryLimit {
+			return fmt.Errorf("unknown entry fill %q", entryFill)
+		}
+		c.FeeTiers = tiers
+		c.EntryFill = entryFill
+		return nil
+	}
+}
+
+// WithSlippage 滑点模型，对所有开平仓成交价施加不利滑点，mode 为空表示不加滑点
+func WithSlippage(mode string, bps, factor float64) SimOption {
+	return func(c *BacktestConfig) error {
+		model, err := ParseSlippageModel(mode, bps, factor)
+		if err != nil {
+			return err
+		}
+		c.Slippage = model
+		return nil
+	}
+}
+
+// WithSizing 仓位：positionSize 为引擎指令未给出比例时的仓位比例 (0-1)，
+// sizing 为自适应系数、名义价值上下限或固定数量
+func WithSizing(positionSize float64, sizing SizingConfig) SimOption {
+	return func(c *BacktestConfig) error {
+		if positionSize <= 0 || positionSize > 1 {
+			return fmt.Errorf("position size must be in (0, 1]")
+		}
+		if err := sizing.ValidateUnit(); err != nil {
+			return err
+		}
+		c.PositionSize = positionSize
+		c.Sizing = sizing
+		return nil
+	}
+}
+
+// WithLeverage 逐仓杠杆，用于计算保证金和强平，0 表示不模拟强平
+func WithLeverage(leverage float64) SimOption {
+	return func(c *BacktestConfig) error {
+		if leverage < 0 {
+			return fmt.Errorf("leverage must be non-negative")
+		}
+		c.Leverage = leverage
+		return nil
+	}
+}
+
+// WithStops 相对持仓均价的止损 / 止盈比例，盘中按 K 线最高 / 最低价触发，0 表示不设
+func WithStops(stopLossPct, takeProfitPct float64) SimOption {
+	return func(c *BacktestConfig) error {
+		if stopLossPct < 0 || takeProfitPct < 0 {
+			return fmt.Errorf("stop loss and take profit must be non-negative")
+		}
+		c.StopLossPct = stopLossPct
+		c.TakeProfitPct = takeProfitPct
+		return nil
+	}
+}
+
+// WithInterval 将行情重采样到 interval（如 5m、1h），按整点边界对齐
+func WithInterval(interval string) SimOption {
+	return func(c *BacktestConfig) error {
+		sec, err := ParseInterval(interval)
+		if err != nil {
+			return err
+		}
+		c.Interval = sec
+		return nil
+	}
+}
+
+// cancelEngine 包装策略引擎：ctx 取消后不再调用引擎，也不再产生指令
+type cancelEngine struct {
+	StrategyEngine
+	ctx context.Context
+}
+
+// OnKline 实现 StrategyEngine
+func (e cancelEngine) OnKline(k Kline, pos PositionView) []Order {
+	if e.ctx.Err() != nil {
+		return nil
+	}
+	return e.StrategyEngine.OnKline(k, pos)
+}
+
+// Simulate 供其他 Go 代码调用的模拟交易入口，与 -mode backtest 使用同一套执行逻辑（RunEngineBacktest）：
+//
+//   - 引擎逐根 K 线收到已收盘 K 线和当前持仓，指令按收盘价成交（WithConfig 可设置延迟成交 / 盘中判定）
+//   - 同向开仓为加仓，持仓期间的反向开仓被忽略，平仓指令一次平掉全部批次
+//   - 止损、止盈、强平先于引擎判定，在 K 线内按最高 / 最低价触发
+//   - 手续费和滑点计入每笔交易的盈亏，仓位按当前资金 × 仓位比例 × 自适应系数计算
+//
+// 未传选项时使用 DefaultBacktestConfig；engine 有状态，每次模拟需新建。
+// ctx 取消后停止产生指令并返回 ctx.Err()
+func Simulate(ctx context.Context, data DataSource, engine StrategyEngine, opts ...SimOption) (*BacktestResult, error) {
+	config := DefaultBacktestConfig
+	for _, opt := range opts {
+		if err := opt(&config); err != nil {
+			return nil, err
+		}
+	}
+
+	klines, err := data.Klines(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load klines: %w", err)
+	}
+	if len(klines) == 0 {
+		return nil, fmt.Errorf("no klines")
+	}
+	if config.Interval > 0 {
+		klines, err = resampleTo(klines, config.Interval)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result := RunEngineBacktest(klines, config, cancelEngine{StrategyEngine: engine, ctx: ctx})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
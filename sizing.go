@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// 仓位模式
+const (
+	SizingFixed          = ""                // 固定比例
+	SizingAntiMartingale = "anti-martingale" // 盈利后放大、亏损后缩小
+)
+
+// 仓位单位
+const (
+	UnitFraction = ""         // 按账户余额比例（position_size）
+	UnitCoin     = "coin"     // 每批固定币数量
+	UnitContract = "contract" // 每批固定合约张数，每张 contract_size 个币
+	UnitATR      = "atr"      // 按 ATR 计算数量：每批承担余额的 risk_per_trade，止损距离为 ATR × atr_mult
+)
+
+// ATR 仓位的默认参数
+const (
+	defaultATRPeriod = 14
+	defaultATRMult   = 2.0
+)
+
+// SizingConfig 自适应仓位配置
+type SizingConfig struct {
+	Mode          string  `json:"mode"`           // "" 或 "anti-martingale"
+	WinStep       float64 `json:"win_step"`       // 每次盈利后仓位系数乘以 (1+WinStep)
+	LossStep      float64 `json:"loss_step"`      // 每次亏损后仓位系数乘以 (1-LossStep)
+	MinMultiplier float64 `json:"min_multiplier"` // 仓位系数下限
+	MaxMultiplier float64 `json:"max_multiplier"` // 仓位系数上限
+	// 单笔名义价值下限 / 上限 (USDT)，0 表示不限制，与仓位模式无关
+	MinNotional float64 `json:"min_notional,omitempty"`
+	MaxNotional float64 `json:"max_notional,omitempty"`
+	// 固定数量：unit 为 coin / contract 时每批入场 quantity 个币 / 张，不再按余额比例，
+	// 也不受仓位系数和名义价值上下限影响；contract_size 为每张合约的币数量，默认 1（U 本位合约）
+	Unit         string  `json:"unit,omitempty"`
+	Quantity     float64 `json:"quantity,omitempty"`
+	ContractSize float64 `json:"contract_size,omitempty"`
+	// ATR 仓位：unit 为 atr 时每批数量 = 余额 × risk_per_trade / (ATR × atr_mult)，
+	// 止损设在开仓价 ∓ ATR × atr_mult，止损时每批约亏损余额的 risk_per_trade
+	RiskPerTrade float64 `json:"risk_per_trade,omitempty"`
+	ATRPeriod    int     `json:"atr_period,omitempty"` // 默认 14
+	ATRMult      float64 `json:"atr_mult,omitempty"`   // 默认 2
+}
+
+// ValidateUnit 校验仓位单位和固定数量
+func (c SizingConfig) ValidateUnit() error {
+	switch c.Unit {
+	case UnitFraction:
+		return nil
+	case UnitCoin, UnitContract:
+		if c.Quantity <= 0 {
+			return fmt.Errorf("sizing unit %q requires a positive quantity", c.Unit)
+		}
+		if c.ContractSize < 0 {
+			return fmt.Errorf("contract size must be non-negative")
+		}
+		return nil
+	case UnitATR:
+		if c.RiskPerTrade <= 0 || c.RiskPerTrade >= 1 {
+			return fmt.Errorf("sizing unit atr requires risk_per_trade in (0, 1)")
+		}
+		if c.ATRPeriod < 0 || c.ATRMult < 0 {
+			return fmt.Errorf("atr period and multiplier must be non-negative")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown sizing unit %q", c.Unit)
+}
+
+// atrPeriod ATR 周期，未设置时为 defaultATRPeriod
+func (c SizingConfig) atrPeriod() int {
+	if c.ATRPeriod > 0 {
+		return c.ATRPeriod
+	}
+	return defaultATRPeriod
+}
+
+// atrMult 止损距离的 ATR 倍数，未设置时为 defaultATRMult
+func (c SizingConfig) atrMult() float64 {
+	if c.ATRMult > 0 {
+		return c.ATRMult
+	}
+	return defaultATRMult
+}
+
+// NewATRTracker ATR 仓位模式下创建 ATR 计算器，其他模式返回 nil
+func (c SizingConfig) NewATRTracker() *ATRTracker {
+	if c.Unit != UnitATR {
+		return nil
+	}
+	return NewATRTracker(c.atrPeriod())
+}
+
+// ATRAmount ATR 仓位模式下一批入场的币数量：余额 × risk_per_trade × 该批相对 baseSize 的倍数 / (ATR × atr_mult)，
+// 名义价值按上下限限制；ATR 尚未就绪时返回 0
+func (c SizingConfig) ATRAmount(balance, size, baseSize, atr, price float64) float64 {
+	if atr <= 0 || price <= 0 {
+		return 0
+	}
+	risk := balance * c.RiskPerTrade
+	if size > 0 && baseSize > 0 {
+		risk *= size / baseSize
+	}
+	amount := risk / (atr * c.atrMult())
+	return c.ClampNotional(amount*price) / price
+}
+
+// ATRStopPct ATR 仓位模式下开仓时的止损距离（相对开仓价的比例），其他模式或 ATR 未就绪时返回 0
+func (c SizingConfig) ATRStopPct(atr, price float64) float64 {
+	if c.Unit != UnitATR || atr <= 0 || price <= 0 {
+		return 0
+	}
+	return atr * c.atrMult() / price
+}
+
+// FixedAmount 固定数量模式下一批入场的币数量：quantity（按张时乘以 contract_size）再乘以
+// 该批仓位比例相对 baseSize 的倍数，多批入场保持原有的批次比例；按余额比例时返回 0
+func (c SizingConfig) FixedAmount(size, baseSize float64) float64 {
+	if c.Unit == UnitFraction || c.Quantity <= 0 {
+		return 0
+	}
+	qty := c.Quantity
+	if c.Unit == UnitContract && c.ContractSize > 0 {
+		qty *= c.ContractSize
+	}
+	if size > 0 && baseSize > 0 {
+		qty *= size / baseSize
+	}
+	return qty
+}
+
+// EntryAmount 回测中一批入场的币数量：固定数量模式按 FixedAmount，
+// 否则按 资金基数 × 仓位比例 × 仓位系数 并限制名义价值后换算
+func (c SizingConfig) EntryAmount(balance, size, mult, baseSize, price float64) float64 {
+	if qty := c.FixedAmount(size, baseSize); qty > 0 {
+		return qty
+	}
+	return c.ClampNotional(balance*size*mult) / price
+}
+
+// DefaultAntiMartingale 反马丁格尔默认参数
+var DefaultAntiMartingale = SizingConfig{
+	Mode:          SizingAntiMartingale,
+	WinStep:       0.25,
+	LossStep:      0.25,
+	MinMultiplier: 0.5,
+	MaxMultiplier: 2.0,
+}
+
+// AdaptiveSizer 根据已平仓结果调整仓位系数
+type AdaptiveSizer struct {
+	config     SizingConfig
+	multiplier float64
+}
+
+// NewAdaptiveSizer 创建仓位调整器，初始系数为 1
+func NewAdaptiveSizer(config SizingConfig) *AdaptiveSizer {
+	return &AdaptiveSizer{config: config, multiplier: 1}
+}
+
+// Multiplier 当前仓位系数，固定模式下恒为 1
+func (s *AdaptiveSizer) Multiplier() float64 {
+	if s == nil || s.config.Mode != SizingAntiMartingale {
+		return 1
+	}
+	return s.multiplier
+}
+
+// Record 记录一笔完整持仓的盈亏并更新系数
+func (s *AdaptiveSizer) Record(pnl float64) {
+	if s == nil || s.config.Mode != SizingAntiMartingale {
+		return
+	}
+
+	if pnl > 0 {
+		s.multiplier *= 1 + s.config.WinStep
+	} else {
+		s.multiplier *= 1 - s.config.LossStep
+	}
+
+	if s.config.MaxMultiplier > 0 {
+		s.multiplier = math.Min(s.multiplier, s.config.MaxMultiplier)
+	}
+	if s.config.MinMultiplier > 0 {
+		s.multiplier = math.Max(s.multiplier, s.config.MinMultiplier)
+	}
+}
+
+// ClampNotional 将按比例计算的名义价值限制在 [MinNotional, MaxNotional] 内
+// 小账户抬高到交易所最小下单额，大账户限制单笔敞口
+func (c SizingConfig) ClampNotional(notional float64) float64 {
+	if notional <= 0 {
+		return notional
+	}
+	if c.MinNotional > 0 && notional < c.MinNotional {
+		notional = c.MinNotional
+	}
+	if c.MaxNotional > 0 && notional > c.MaxNotional {
+		notional = c.MaxNotional
+	}
+	return notional
+}
+
+// SizingBalance 计算仓位所用的资金基数
+type SizingBalance struct {
+	daily    bool
+	day      int64
+	dayStart float64
+}
+
+// NewSizingBalance daily 为 true 时以当日（UTC）开盘资金为基数，否则用实时余额
+func NewSizingBalance(daily bool) *SizingBalance {
+	return &SizingBalance{daily: daily, day: -1}
+}
+
+// Base 返回 ts 时刻的仓位资金基数
+func (b *SizingBalance) Base(ts int64, balance float64) float64 {
+	if !b.daily {
+		return balance
+	}
+	if day := ts / 86400; day != b.day {
+		b.day = day
+		b.dayStart = balance
+	}
+	return b.dayStart
+}
+
+// SymbolFilter 交易所下单限制：数量步长和最小名义价值
+type SymbolFilter struct {
+	StepSize    float64 // 数量步长，0 表示不取整
+	MinNotional float64 // 最小名义价值 (USDT)，低于该值交易所拒单
+}
+
+// knownSymbolFilters 常用 U 本位合约的下单限制，未列出的交易对需在配置中指定
+var knownSymbolFilters = map[string]SymbolFilter{
+	"BTCUSDT": {StepSize: 0.001, MinNotional: 100},
+	"ETHUSDT": {StepSize: 0.001, MinNotional: 20},
+	"BNBUSDT": {StepSize: 0.01, MinNotional: 5},
+	"SOLUSDT": {StepSize: 1, MinNotional: 5},
+	"XRPUSDT": {StepSize: 0.1, MinNotional: 5},
+}
+
+// defaultMinNotional 未知交易对的最小名义价值
+const defaultMinNotional = 5
+
+// RoundQty 数量按步长向下取整，避免超出可用资金或被交易所拒绝精度
+func (f SymbolFilter) RoundQty(qty float64) float64 {
+	if f.StepSize <= 0 || qty <= 0 {
+		return qty
+	}
+	steps := math.Floor(qty/f.StepSize + 1e-9)
+	// 按步长的小数位数四舍五入，消除浮点误差（如 0.30000000000000004）
+	decimals := 0
+	if s := strconv.FormatFloat(f.StepSize, 'f', -1, 64); strings.Contains(s, ".") {
+		decimals = len(s) - strings.Index(s, ".") - 1
+	}
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(steps*f.StepSize*scale) / scale
+}
+
+// IsDust 数量不足一个步长，或名义价值低于最小名义价值，无法单独下单平掉
+func (f SymbolFilter) IsDust(qty, price float64) bool {
+	if qty <= 1e-12 {
+		return true
+	}
+	if f.StepSize > 0 && qty < f.StepSize-1e-12 {
+		return true
+	}
+	return f.MinNotional > 0 && qty*price < f.MinNotional
+}
+
+// CloseQty 部分平仓数量：total × fraction 按步长向下取整；平仓后剩余为碎仓时
+// 连同剩余一起平掉，避免留下永远无法平掉的微小仓位
+func (f SymbolFilter) CloseQty(total, fraction, price float64) float64 {
+	qty := f.RoundQty(total * fraction)
+	if f.IsDust(total-qty, price) {
+		return total
+	}
+	return qty
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// 回测滑点模型
+const (
+	SlipNone   = ""       // 按 K 线价格成交
+	SlipFixed  = "fixed"  // 固定 bps
+	SlipVolume = "volume" // 基础 bps + 按成交量占 K 线成交量比例的冲击成本
+	SlipSpread = "spread" // 以 K 线振幅估算半价差
+	SlipImpact = "impact" // 基础 bps + 平方根冲击：K 线振幅 × sqrt(成交量占 K 线成交量比例)
+)
+
+// SlippageModel 回测成交滑点模型，对所有开平仓成交价施加不利滑点
+type SlippageModel struct {
+	Mode string
+	// fixed: 每笔滑点；volume: 基础滑点；spread: 最小半价差
+	Bps float64
+	// volume: 成交量每占 K 线成交量 1% 追加的 bps；spread: 半价差占 K 线振幅的比例；
+	// impact: 冲击系数，冲击 = Factor × K 线振幅 (bps) × sqrt(成交量 / K 线成交量)
+	Factor float64
+}
+
+// ParseSlippageModel 校验滑点模型参数
+func ParseSlippageModel(mode string, bps, factor float64) (SlippageModel, error) {
+	m := SlippageModel{Mode: mode, Bps: bps, Factor: factor}
+	switch mode {
+	case SlipNone, SlipFixed, SlipVolume, SlipSpread, SlipImpact:
+	default:
+		return m, fmt.Errorf("unknown slippage model %q", mode)
+	}
+	if bps < 0 || factor < 0 {
+		return m, fmt.Errorf("slippage parameters must be non-negative")
+	}
+	return m, nil
+}
+
+// bps 按 K 线估算 amount 成交的滑点 (bps)
+func (m SlippageModel) bps(amount float64, k Kline) float64 {
+	switch m.Mode {
+	case SlipFixed:
+		return m.Bps
+	case SlipVolume:
+		if k.Volume <= 0 {
+			return m.Bps
+		}
+		return m.Bps + m.Factor*amount/k.Volume*100
+	case SlipSpread:
+		if k.Close <= 0 {
+			return m.Bps
+		}
+		return max(m.Bps, (k.High-k.Low)/k.Close*1e4*m.Factor)
+	case SlipImpact:
+		// 平方根冲击：滑点随订单规模亚线性增长，K 线振幅代表当时的波动率；没有成交量的 K 线按全部吃掉计
+		if k.Close <= 0 {
+			return m.Bps
+		}
+		participation := 1.0
+		if k.Volume > 0 {
+			participation = amount / k.Volume
+		}
+		return m.Bps + m.Factor*(k.High-k.Low)/k.Close*1e4*math.Sqrt(participation)
+	}
+	return 0
+}
+
+// maxSlippageBps 单笔滑点上限：冲击模型在订单远超 K 线成交量时会给出 100% 以上的滑点，卖出成交价变为零或负数
+const maxSlippageBps = 5000
+
+// Fill 返回含滑点的成交价：买入（开多、平空）上移，卖出下移，滑点不超过 maxSlippageBps
+func (m SlippageModel) Fill(price float64, buy bool, amount float64, k Kline) float64 {
+	bps := min(m.bps(amount, k), maxSlippageBps)
+	if bps <= 0 {
+		return price
+	}
+	if buy {
+		return price * (1 + bps/1e4)
+	}
+	return price * (1 - bps/1e4)
+}
+
+// slippageTracker 累计回测滑点成本和订单占 K 线成交量的比例
+type slippageTracker struct {
+	cost     float64 // 滑点成本 (USDT)
+	notional float64 // 成交名义价值
+	// 按成交名义价值加权的成交量占比之和、最大占比，以及超过整根 K 线成交量的成交次数
+	participation    float64
+	maxParticipation float64
+	overVolume       int
+}
+
+// fill 按模型成交并记录相对 price 的滑点成本
+func (t *slippageTracker) fill(m SlippageModel, price float64, buy bool, amount float64, k Kline) float64 {
+	fill := m.Fill(price, buy, amount, k)
+	if fill > price {
+		t.cost += (fill - price) * amount
+	} else {
+		t.cost += (price - fill) * amount
+	}
+	t.notional += price * amount
+	if k.Volume > 0 {
+		p := amount / k.Volume
+		t.participation += p * price * amount
+		t.maxParticipation = max(t.maxParticipation, p)
+		if p > 1 {
+			t.overVolume++
+		}
+	}
+	return fill
+}
+
+// avgParticipation 按成交名义价值加权的平均成交量占比
+func (t *slippageTracker) avgParticipation() float64 {
+	if t.notional <= 0 {
+		return 0
+	}
+	return t.participation / t.notional
+}
+
+// summary 成交量占比统计
+func (t *slippageTracker) summary() ParticipationStats {
+	return ParticipationStats{Avg: t.avgParticipation(), Max: t.maxParticipation, OverVolume: t.overVolume}
+}
+
+// ParticipationStats 订单数量占成交所在 K 线成交量的比例
+type ParticipationStats struct {
+	Avg        float64 // 按成交名义价值加权的平均占比
+	Max        float64 // 最大占比
+	OverVolume int     // 超过整根 K 线成交量的成交次数
+}
+
+// printParticipation 打印成交量占比，用于判断资金规模是否超出该周期的容量；没有成交量数据时跳过
+func printParticipation(p ParticipationStats) {
+	if p.Max <= 0 {
+		return
+	}
+	fmt.Printf("成交量占比: 平均 %.2f%%, 最大 %.2f%%", p.Avg*100, p.Max*100)
+	if p.OverVolume > 0 {
+		fmt.Printf(", %d 笔超过整根 K 线成交量", p.OverVolume)
+	}
+	fmt.Println()
+}
+
+// avgBps 平均滑点 (bps)
+func (t *slippageTracker) avgBps() float64 {
+	if t.notional <= 0 {
+		return 0
+	}
+	return t.cost / t.notional * 1e4
+}
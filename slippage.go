@@ -0,0 +1,219 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hstcscolor/wex/binance"
+)
+
+// 滑点超限处理方式
+const (
+	SlippageAbort = "abort" // 放弃入场
+	SlippageLimit = "limit" // 改为限价单，价格为可接受的最差价
+)
+
+// errEntryAborted 订单被放弃（滑点保护或重复提交），不视为执行失败
+var errEntryAborted = errors.New("entry aborted")
+
+// 滑点超限改挂的限价单等待成交的时间和查询间隔，超时后撤掉未成交的部分
+const (
+	limitFillTimeout = 30 * time.Second
+	limitFillPoll    = time.Second
+)
+
+// estimateFillPrice 按盘口逐档估算市价单成交均价，深度不足时返回 false
+func estimateFillPrice(levels []binance.DepthItem, amount float64) (float64, bool) {
+	remaining := amount
+	cost := 0.0
+	for _, lv := range levels {
+		fill := lv.Amount
+		if fill > remaining {
+			fill = remaining
+		}
+		cost += fill * lv.Price
+		remaining -= fill
+		if remaining <= 0 {
+			return cost / amount, true
+		}
+	}
+	return 0, false
+}
+
+// chaseBps 成交价相对信号价朝信号方向偏离的幅度 (bps)，反向偏离为负
+func chaseBps(side string, signalPrice, price float64) float64 {
+	bps := (price - signalPrice) / signalPrice * 1e4
+	if side == "SHORT" {
+		bps = -bps
+	}
+	return bps
+}
+
+// openPosition 市价开仓，返回成交数量和价格（市价单按下单数量和 intended 计）；
+// 配置了滑点上限时先按盘口估算滑点，超限则放弃或改限价
+func (s *Strategy) openPosition(signal Signal, intended, amount, notional float64) (float64, float64, error) {
+	side := "LONG"
+	if signal == SignalShort {
+		side = "SHORT"
+	}
+
+	maxBps := s.config.MaxSlippageBps
+	if maxBps > 0 {
+		depth, err := s.client.FutureDepth(s.config.Symbol, 100)
+		if err != nil {
+			return 0, 0, wrapExchangeError("depth", err)
+		}
+
+		levels := depth.Asks
+		if side == "SHORT" {
+			levels = depth.Bids
+		}
+		fillPrice, ok := estimateFillPrice(levels, amount)
+
+		slippageBps := 0.0
+		if ok {
+			slippageBps = (fillPrice - intended) / intended * 1e4
+			if side == "SHORT" {
+				slippageBps = -slippageBps
+			}
+		}
+
+		if !ok || slippageBps > maxBps {
+			reason := fmt.Sprintf("预估滑点 %.1f bps 超过上限 %.1f bps", slippageBps, maxBps)
+			if !ok {
+				reason = "盘口深度不足"
+			}
+
+			if s.config.SlippageAction == SlippageLimit {
+				limitPrice := intended * (1 + maxBps/1e4)
+				if side == "SHORT" {
+					limitPrice = intended * (1 - maxBps/1e4)
+				}
+				log.Printf("%s，改为限价单 %s %.4f @ %.2f", reason, side, amount, limitPrice)
+				return s.openLimit(signal, limitPrice, amount, notional)
+			}
+
+			log.Printf("放弃入场: %s", reason)
+			if err := s.journal.Record(JournalEntry{
+				Symbol:   s.config.Symbol,
+				Event:    JournalAborted,
+				Side:     side,
+				Price:    intended,
+				Amount:   amount,
+				Notional: notional,
+				Reason:   reason,
+				Strategy: s.engine.Name(),
+			}); err != nil {
+				log.Printf("写入交易日志失败: %v", err)
+			}
+			return 0, 0, errEntryAborted
+		}
+	}
+
+	err := s.submitOnce(signal, intended, amount, notional, func(clientOrderID string) error {
+		var err error
+		if side == "LONG" {
+			log.Printf("开多仓: %.4f @ %.2f (%s)", amount, intended, clientOrderID)
+			_, err = s.client.FutureOpenLongMarketWithID(s.config.Symbol, notional, clientOrderID)
+		} else {
+			log.Printf("开空仓: %.4f @ %.2f (%s)", amount, intended, clientOrderID)
+			_, err = s.client.FutureOpenShortMarketWithID(s.config.Symbol, notional, clientOrderID)
+		}
+		return wrapExchangeError("open market", err)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return amount, intended, nil
+}
+
+// openLimit 以确定的 clientOrderId 挂限价开仓单并等待成交，超时撤掉未成交的部分；
+// 返回实际成交数量和均价，完全未成交时放弃入场
+func (s *Strategy) openLimit(signal Signal, limitPrice, amount, notional float64) (float64, float64, error) {
+	var id string
+	err := s.submitOnce(signal, limitPrice, amount, notional, func(clientOrderID string) error {
+		id = clientOrderID
+		var err error
+		if signal == SignalLong {
+			_, err = s.client.FutureOpenLongLimitWithID(s.config.Symbol, limitPrice, amount, clientOrderID)
+		} else {
+			_, err = s.client.FutureOpenShortLimitWithID(s.config.Symbol, limitPrice, amount, clientOrderID)
+		}
+		return wrapExchangeError("open limit", err)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	info, err := s.awaitLimitFill(id)
+	if err != nil {
+		return 0, 0, err
+	}
+	if info.ExecutedQty <= 0 || info.AvgPrice <= 0 {
+		log.Printf("限价单 %s 在 %v 内未成交，已撤单", id, limitFillTimeout)
+		return 0, 0, errEntryAborted
+	}
+	if info.ExecutedQty < amount {
+		log.Printf("限价单 %s 部分成交 %.4f / %.4f @ %.2f，未成交部分已撤单", id, info.ExecutedQty, amount, info.AvgPrice)
+	}
+	return info.ExecutedQty, info.AvgPrice, nil
+}
+
+// awaitLimitFill 等待限价单完全成交，超时后撤单并返回撤单后的订单状态（含已成交的部分）
+func (s *Strategy) awaitLimitFill(id string) (*binance.OrderInfo, error) {
+	deadline := time.Now().Add(limitFillTimeout)
+	for time.Now().Before(deadline) {
+		info, err := s.client.FutureGetOrderByClientID(s.config.Symbol, id)
+		if err != nil {
+			log.Printf("查询限价单 %s 失败: %v", id, err)
+		} else if info != nil && info.Status == "FILLED" {
+			return info, nil
+		}
+		time.Sleep(limitFillPoll)
+	}
+
+	// 撤单时订单已成交或已撤（-2011）不算失败，以随后查询到的状态为准
+	if err := s.client.FutureCancelOrderByClientID(s.config.Symbol, id); err != nil && !isUnknownOrder(err) {
+		return nil, wrapExchangeError("cancel limit", err)
+	}
+	info, err := s.client.FutureGetOrderByClientID(s.config.Symbol, id)
+	if err != nil {
+		return nil, wrapExchangeError("order", err)
+	}
+	if info == nil {
+		return nil, fmt.Errorf("order %s not found", id)
+	}
+	return info, nil
+}
+
+// missedEntry 下单时价格已朝信号方向跑出 max_chase_bps 以上（行情已错过）时放弃入场并记录
+func (s *Strategy) missedEntry(signal Signal, price float64) bool {
+	if s.config.MaxChaseBps <= 0 || len(s.klines) == 0 {
+		return false
+	}
+	side := "LONG"
+	if signal == SignalShort {
+		side = "SHORT"
+	}
+	signalClose := s.klines[len(s.klines)-1].Close
+	bps := chaseBps(side, signalClose, price)
+	if bps <= s.config.MaxChaseBps {
+		return false
+	}
+
+	reason := fmt.Sprintf("价格 %.2f 偏离信号收盘价 %.2f 达 %.1f bps，超过上限 %.1f bps", price, signalClose, bps, s.config.MaxChaseBps)
+	log.Printf("放弃入场: %s", reason)
+	if err := s.journal.Record(JournalEntry{
+		Symbol:   s.config.Symbol,
+		Event:    JournalAborted,
+		Side:     side,
+		Price:    price,
+		Reason:   reason,
+		Strategy: s.engine.Name(),
+	}); err != nil {
+		log.Printf("写入交易日志失败: %v", err)
+	}
+	return true
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// SoakOptions 浸泡测试选项
+type SoakOptions struct {
+	Duration time.Duration // 运行时长
+	Cycle    time.Duration // 策略轮询周期，比实盘的 5 分钟短以加快覆盖
+	Report   time.Duration // 打印运行状况的间隔
+	Mock     MockExchangeConfig
+}
+
+// soakSample 一次运行状况采样
+type soakSample struct {
+	At         time.Time
+	Goroutines int
+	HeapMB     float64
+}
+
+// sampleRuntime 采样 goroutine 数和堆内存
+func sampleRuntime() soakSample {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	return soakSample{At: time.Now(), Goroutines: runtime.NumGoroutine(), HeapMB: float64(mem.HeapAlloc) / (1 << 20)}
+}
+
+// soakConfig 浸泡测试使用的配置副本：强制实盘下单（发往模拟交易所），交易日志、运行状态和实例锁放到临时目录，
+// 关闭 webhook、InfluxDB、控制接口和信号广播，避免影响生产环境
+func soakConfig(config Config, dir string) *Config {
+	config.DryRun = false
+	config.ApiKey, config.SecretKey = "soak-key", "soak-secret"
+	config.JournalPath = filepath.Join(dir, "journal.db")
+	config.StatePath = filepath.Join(dir, "state.db")
+	config.Lock = LockFile
+	config.LockDir = dir
+	config.Webhooks = nil
+	config.InfluxURL = ""
+	config.ControlAddr = ""
+	config.PublishAddr = ""
+	return &config
+}
+
+// runSoakCmd 浸泡测试：启动模拟交易所，把发往币安的请求改发到模拟交易所，按实盘代码路径运行策略 opts.Duration，
+// 定期打印 goroutine、内存和请求 / 故障统计；结束时核对持仓并检查资源泄漏，发现问题时以非零状态退出
+func runSoakCmd(base *Config, opts SoakOptions) {
+	dir, err := os.MkdirTemp("", "rsi-strat-soak-")
+	if err != nil {
+		log.Fatalf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	opts.Mock.Symbol = base.Symbol
+	if opts.Mock.Leverage <= 0 {
+		opts.Mock.Leverage = float64(max(base.Leverage, 1))
+	}
+	mock := NewMockExchange(opts.Mock)
+	addr, err := ServeMockExchange(mock, "127.0.0.1:0")
+	if err != nil {
+		log.Fatalf("启动模拟交易所失败: %v", err)
+	}
+	// 交易所客户端和行情核对等请求都走默认 Transport，统一改发到模拟交易所
+	http.DefaultTransport = &mockTransport{target: addr, base: http.DefaultTransport}
+	log.Printf("模拟交易所: http://%s，延迟 %v + 抖动 %v，错误率 %.2f%%，限频率 %.2f%%，断连率 %.2f%%",
+		addr, opts.Mock.Latency, opts.Mock.Jitter, opts.Mock.ErrorRate*100, opts.Mock.RateLimitRate*100, opts.Mock.DropRate*100)
+
+	config := soakConfig(*base, dir)
+	strategy, err := NewStrategy(config)
+	if err != nil {
+		log.Fatalf("创建策略失败: %v", err)
+	}
+	strategy.cycle = opts.Cycle
+
+	done := make(chan error, 1)
+	go func() { done <- strategy.Run() }()
+
+	// 客户端若不经过默认 Transport，请求会发到真实交易所，此时立即停止
+	time.Sleep(5 * time.Second)
+	if mock.Stats().ByEndpoint["klines"] == 0 {
+		strategy.Stop()
+		log.Fatalf("模拟交易所未收到 K 线请求：交易所客户端没有使用默认 Transport，无法进行浸泡测试")
+	}
+
+	log.Printf("浸泡测试开始: %s，运行 %v，轮询周期 %v", config.Symbol, opts.Duration, opts.Cycle)
+	baseline := sampleRuntime()
+	peak := baseline
+	report := time.NewTicker(opts.Report)
+	defer report.Stop()
+	deadline := time.After(opts.Duration)
+	var runErr error
+	stopped := false
+loop:
+	for {
+		select {
+		case <-report.C:
+			sample := sampleRuntime()
+			peak.Goroutines = max(peak.Goroutines, sample.Goroutines)
+			peak.HeapMB = max(peak.HeapMB, sample.HeapMB)
+			stats := mock.Stats()
+			amt, _ := mock.Position()
+			log.Printf("[浸泡] 已运行 %v | goroutine %d | 堆内存 %.1f MB | 请求 %d (注入错误 %d, 限频 %d, 断连 %d) | 成交 %d | 持仓 %.6f",
+				time.Since(baseline.At).Round(time.Second), sample.Goroutines, sample.HeapMB,
+				stats.Requests, stats.Errors, stats.RateLimited, stats.Dropped, stats.Fills, amt)
+		case <-deadline:
+			break loop
+		case runErr = <-done:
+			stopped = true
+			break loop
+		}
+	}
+	if !stopped {
+		strategy.Stop()
+		select {
+		case runErr = <-done:
+		case <-time.After(30 * time.Second):
+			log.Printf("[浸泡] 策略 30 秒内未退出")
+			runErr = fmt.Errorf("strategy did not stop")
+		}
+	}
+
+	final := sampleRuntime()
+	if !printSoakSummary(mock, strategy, baseline, peak, final, stopped, runErr) {
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+}
+
+// printSoakSummary 打印浸泡测试结论：提前退出、持仓不一致、goroutine 持续增长或堆内存翻倍视为失败
+func printSoakSummary(mock *MockExchange, s *Strategy, baseline, peak, final soakSample, stopped bool, runErr error) bool {
+	stats := mock.Stats()
+	amt, entry := mock.Position()
+	fmt.Println("\n========== 浸泡测试结果 ==========")
+	fmt.Printf("运行时长: %v\n", final.At.Sub(baseline.At).Round(time.Second))
+	fmt.Printf("请求: %d, 注入错误 %d, 限频 %d, 断连 %d, 业务拒绝 %d, 成交 %d\n",
+		stats.Requests, stats.Errors, stats.RateLimited, stats.Dropped, stats.Rejected, stats.Fills)
+	endpoints := make([]string, 0, len(stats.ByEndpoint))
+	for name := range stats.ByEndpoint {
+		endpoints = append(endpoints, name)
+	}
+	sort.Strings(endpoints)
+	for _, name := range endpoints {
+		fmt.Printf("  %-18s %d\n", name, stats.ByEndpoint[name])
+	}
+	fmt.Printf("goroutine: 开始 %d, 峰值 %d, 结束 %d\n", baseline.Goroutines, peak.Goroutines, final.Goroutines)
+	fmt.Printf("堆内存: 开始 %.1f MB, 峰值 %.1f MB, 结束 %.1f MB\n", baseline.HeapMB, peak.HeapMB, final.HeapMB)
+	fmt.Printf("交易所持仓: %.6f @ %.2f, 策略跟踪: %s %.6f @ %.2f\n", amt, entry, s.entrySide, s.entryAmount, s.entryPrice)
+
+	ok := true
+	fail := func(format string, args ...any) {
+		fmt.Printf("[失败] "+format+"\n", args...)
+		ok = false
+	}
+	if stopped {
+		fail("策略提前退出: %v", runErr)
+	} else if runErr != nil {
+		fail("策略停止时出错: %v", runErr)
+	}
+	for path, n := range stats.Unknown {
+		fmt.Printf("[警告] 未实现的接口 %s: %d 次\n", path, n)
+	}
+	// 只发信号期间（资金保护、净值曲线过滤）跟踪的是虚拟持仓，交易所应无对应持仓
+	tracked := s.entryAmount
+	if s.entrySide == "SHORT" {
+		tracked = -tracked
+	}
+	if s.entrySide == "" || s.paperPosition() {
+		tracked = 0
+	}
+	if math.Abs(tracked-amt) > 1e-9 {
+		fail("策略跟踪的持仓与交易所不一致")
+	}
+	// 允许少量波动（HTTP 空闲连接等），持续增长视为泄漏
+	if final.Goroutines > baseline.Goroutines+20 {
+		fail("goroutine 从 %d 增长到 %d，可能泄漏", baseline.Goroutines, final.Goroutines)
+	}
+	if final.HeapMB > 2*baseline.HeapMB && final.HeapMB-baseline.HeapMB > 50 {
+		fail("堆内存从 %.1f MB 增长到 %.1f MB，可能泄漏", baseline.HeapMB, final.HeapMB)
+	}
+	if ok {
+		fmt.Println("通过")
+	}
+	return ok
+}
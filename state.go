@@ -0,0 +1,220 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// LiveState 实盘运行状态：持仓（含批次和入场时间，出场判断依赖）和运行时变量
+type LiveState struct {
+	Symbol         string             `json:"symbol,omitempty"`
+	Engine         string             `json:"engine,omitempty"` // 持仓所属的策略引擎，重启后引擎变更时按 orphan_policy 处理
+	Owner          string             `json:"owner,omitempty"`  // 写入该行的实例（instance_id），其他实例写入的行不作为遗留持仓处理
+	Side           string             `json:"side,omitempty"`
+	EntryPrice     float64            `json:"entry_price,omitempty"`
+	EntryAmount    float64            `json:"entry_amount,omitempty"`
+	EntryCount     int                `json:"entry_count,omitempty"`
+	EntryTime      int64              `json:"entry_time,omitempty"`
+	EntryExposure  float64            `json:"entry_exposure,omitempty"`
+	StopPct        float64            `json:"stop_pct,omitempty"`      // ATR 仓位模式下开仓时确定的止损距离
+	TrailExtreme   float64            `json:"trail_extreme,omitempty"` // 持仓以来的最有利价格（移动止损）
+	TrailStop      float64            `json:"trail_stop,omitempty"`    // 当前的移动止损价
+	BatchTime      int64              `json:"batch_time,omitempty"`    // 上一批入场时的 K 线时间（分批建仓）
+	BatchPrice     float64            `json:"batch_price,omitempty"`   // 上一批入场价（分批建仓）
+	SizeMultiplier float64            `json:"size_multiplier"`
+	PeakEquity     float64            `json:"peak_equity,omitempty"`
+	Preserving     bool               `json:"preserving,omitempty"`
+	Shadow         bool               `json:"shadow,omitempty"` // 持仓为净值曲线过滤的虚拟持仓
+	Paper          bool               `json:"paper,omitempty"`  // 持仓为资金保护期间开的模拟持仓
+	EquityFilter   *EquityFilterState `json:"equity_filter,omitempty"`
+	Risk           *RiskState         `json:"risk,omitempty"`
+	Leverage       int                `json:"leverage,omitempty"` // 波动率目标当前生效的杠杆
+	UpdatedAt      int64              `json:"updated_at"`
+}
+
+// StateStore 实盘状态持久化（SQLite），每个交易对 + 账户一行
+type StateStore struct {
+	db *sql.DB
+}
+
+// stateMigrations 运行状态库的表结构版本
+var stateMigrations = []Migration{
+	{Version: 1, Name: "baseline", Up: execMigration(`
+		CREATE TABLE IF NOT EXISTS live_state (
+			key        TEXT PRIMARY KEY,
+			data       TEXT NOT NULL,
+			updated_at INTEGER NOT NULL
+		)`,
+	)},
+}
+
+// OpenStateStore 打开或创建状态文件
+func OpenStateStore(path string) (*StateStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrateSQLite(db, path, "state", stateMigrations); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &StateStore{db: db}, nil
+}
+
+// Load 读取状态，不存在时返回 nil
+func (st *StateStore) Load(key string) (*LiveState, error) {
+	if st == nil {
+		return nil, nil
+	}
+	var data string
+	err := st.db.QueryRow(`SELECT data FROM live_state WHERE key = ?`, key).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state LiveState
+	if err := json.Unmarshal([]byte(data), &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Save 写入状态
+func (st *StateStore) Save(key string, state LiveState) error {
+	if st == nil {
+		return nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	_, err = st.db.Exec(
+		`INSERT INTO live_state (key, data, updated_at) VALUES (?, ?, ?)
+		 ON CONFLICT(key) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at`,
+		key, string(data), state.UpdatedAt,
+	)
+	return err
+}
+
+// List 读取所有状态（key → 状态），用于查找交易对变更前遗留的持仓
+func (st *StateStore) List() (map[string]LiveState, error) {
+	if st == nil {
+		return nil, nil
+	}
+	rows, err := st.db.Query(`SELECT key, data FROM live_state`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := make(map[string]LiveState)
+	for rows.Next() {
+		var key, data string
+		if err := rows.Scan(&key, &data); err != nil {
+			return nil, err
+		}
+		var state LiveState
+		if err := json.Unmarshal([]byte(data), &state); err != nil {
+			return nil, fmt.Errorf("state %s: %w", key, err)
+		}
+		states[key] = state
+	}
+	return states, rows.Err()
+}
+
+// Delete 删除状态
+func (st *StateStore) Delete(key string) error {
+	if st == nil {
+		return nil
+	}
+	_, err := st.db.Exec(`DELETE FROM live_state WHERE key = ?`, key)
+	return err
+}
+
+// Close 关闭状态文件
+func (st *StateStore) Close() error {
+	if st == nil {
+		return nil
+	}
+	return st.db.Close()
+}
+
+// saveState 持仓或运行时变量变化后写入状态文件
+func (s *Strategy) saveState() {
+	if s.state == nil {
+		return
+	}
+	state := LiveState{
+		Symbol:         s.config.Symbol,
+		Engine:         s.engine.Name(),
+		Owner:          s.config.InstanceID,
+		Side:           s.entrySide,
+		EntryPrice:     s.entryPrice,
+		EntryAmount:    s.entryAmount,
+		EntryCount:     s.entryCount,
+		EntryTime:      s.entryTime,
+		EntryExposure:  s.entryExposure,
+		StopPct:        s.stopPct,
+		TrailExtreme:   s.trailExtreme,
+		TrailStop:      s.trailStop,
+		BatchTime:      s.batchTime,
+		BatchPrice:     s.batchPrice,
+		SizeMultiplier: s.sizer.Multiplier(),
+		PeakEquity:     s.peakEquity,
+		Preserving:     s.preserving.Load(),
+		Shadow:         s.shadow,
+		Paper:          s.paper,
+		EquityFilter:   s.equityFilter.State(),
+		Risk:           s.risk.State(),
+		Leverage:       s.volTarget.Leverage(),
+		UpdatedAt:      time.Now().Unix(),
+	}
+	if err := s.state.Save(lockKey(s.config), state); err != nil {
+		log.Printf("保存运行状态失败: %v", err)
+	}
+}
+
+// restoreState 启动时从状态文件恢复持仓和运行时变量
+func (s *Strategy) restoreState() error {
+	state, err := s.state.Load(lockKey(s.config))
+	if err != nil || state == nil {
+		return err
+	}
+
+	s.entrySide = state.Side
+	s.entryPrice = state.EntryPrice
+	s.entryAmount = state.EntryAmount
+	s.entryCount = state.EntryCount
+	s.entryTime = state.EntryTime
+	s.entryExposure = state.EntryExposure
+	s.stopPct = state.StopPct
+	s.trailExtreme, s.trailStop = state.TrailExtreme, state.TrailStop
+	s.batchTime, s.batchPrice = state.BatchTime, state.BatchPrice
+	if state.SizeMultiplier > 0 {
+		s.sizer.multiplier = state.SizeMultiplier
+	}
+	s.peakEquity = state.PeakEquity
+	s.preserving.Store(state.Preserving)
+	s.shadow = state.Shadow && state.Side != ""
+	s.paper = state.Paper && state.Side != ""
+	s.equityFilter.Restore(state.EquityFilter)
+	s.risk.Restore(state.Risk)
+	s.volTarget.Restore(state.Leverage)
+	if state.Side != "" && state.Engine != "" && state.Engine != s.engine.Name() {
+		s.priorEngine = state.Engine
+	}
+
+	side := state.Side
+	if side == "" {
+		side = "无"
+	}
+	log.Printf("恢复运行状态 (%s): 持仓 %s %.6f @ %.2f, %d 批, 仓位系数 %.2f",
+		time.Unix(state.UpdatedAt, 0).Format("01-02 15:04:05"),
+		side, state.EntryAmount, state.EntryPrice, state.EntryCount, s.sizer.Multiplier())
+	return nil
+}
@@ -0,0 +1,700 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EquityPoint 资金曲线上的一个采样点
+type EquityPoint struct {
+	Timestamp int64
+	Value     float64
+}
+
+// unrealizedPnL 持仓按 price 计的浮动盈亏（不含平仓手续费）
+func unrealizedPnL(side string, amount, avgPrice, price float64) float64 {
+	if side == "SHORT" {
+		return (avgPrice - price) * amount
+	}
+	return (price - avgPrice) * amount
+}
+
+// RollingWindowDays 滚动指标窗口（天）
+const RollingWindowDays = 30
+
+// DailyEquity 将逐 K 线的资金曲线按 UTC 日采样为日收盘资金
+// times 与 curve 一一对应
+func DailyEquity(times []int64, curve []float64) []EquityPoint {
+	n := len(times)
+	if len(curve) < n {
+		n = len(curve)
+	}
+
+	var daily []EquityPoint
+	for i := 0; i < n; i++ {
+		day := time.Unix(times[i], 0).UTC().Truncate(24 * time.Hour).Unix()
+		if len(daily) > 0 && daily[len(daily)-1].Timestamp == day {
+			daily[len(daily)-1].Value = curve[i]
+			continue
+		}
+		daily = append(daily, EquityPoint{Timestamp: day, Value: curve[i]})
+	}
+
+	return daily
+}
+
+// RollingSharpe 计算滚动夏普比率（日收益率，年化 sqrt(365)，无风险利率按 0）
+// 返回从第 window 天开始的序列
+func RollingSharpe(daily []EquityPoint, window int) []EquityPoint {
+	if window < 2 || len(daily) <= window {
+		return nil
+	}
+
+	returns := make([]float64, len(daily))
+	for i := 1; i < len(daily); i++ {
+		if daily[i-1].Value > 0 {
+			returns[i] = daily[i].Value/daily[i-1].Value - 1
+		}
+	}
+
+	var series []EquityPoint
+	for i := window; i < len(daily); i++ {
+		mean := 0.0
+		for j := i - window + 1; j <= i; j++ {
+			mean += returns[j]
+		}
+		mean /= float64(window)
+
+		variance := 0.0
+		for j := i - window + 1; j <= i; j++ {
+			variance += math.Pow(returns[j]-mean, 2)
+		}
+		variance /= float64(window - 1)
+
+		sharpe := 0.0
+		if variance > 0 {
+			sharpe = mean / math.Sqrt(variance) * math.Sqrt(365)
+		}
+		series = append(series, EquityPoint{Timestamp: daily[i].Timestamp, Value: sharpe})
+	}
+
+	return series
+}
+
+// SharpeRatio 整段资金曲线的夏普比率（日收益率，年化 sqrt(365)，无风险利率按 0）
+func SharpeRatio(daily []EquityPoint) float64 {
+	if len(daily) < 3 {
+		return 0
+	}
+	series := RollingSharpe(daily, len(daily)-1)
+	if len(series) == 0 {
+		return 0
+	}
+	return series[0].Value
+}
+
+// SortinoRatio 整段资金曲线的索提诺比率（日收益率，只计下行波动，年化 sqrt(365)，目标收益按 0）
+func SortinoRatio(daily []EquityPoint) float64 {
+	if len(daily) < 3 {
+		return 0
+	}
+	var mean, downside float64
+	n := 0
+	for i := 1; i < len(daily); i++ {
+		if daily[i-1].Value <= 0 {
+			continue
+		}
+		r := daily[i].Value/daily[i-1].Value - 1
+		mean += r
+		if r < 0 {
+			downside += r * r
+		}
+		n++
+	}
+	if n == 0 || downside == 0 {
+		return 0
+	}
+	mean /= float64(n)
+	return mean / math.Sqrt(downside/float64(n)) * math.Sqrt(365)
+}
+
+// CAGR 年化复合收益率，区间不足一天时返回 0
+func CAGR(startBalance, endBalance float64, seconds int64) float64 {
+	if startBalance <= 0 || endBalance <= 0 || seconds < 24*3600 {
+		return 0
+	}
+	years := float64(seconds) / (365 * 24 * 3600)
+	return math.Pow(endBalance/startBalance, 1/years) - 1
+}
+
+// CalmarRatio 年化收益率（复利）/ 最大回撤，区间不足一天或没有回撤时返回 0
+func CalmarRatio(startBalance, endBalance float64, seconds int64, maxDrawdown float64) float64 {
+	if maxDrawdown <= 0 {
+		return 0
+	}
+	return CAGR(startBalance, endBalance, seconds) / maxDrawdown
+}
+
+// PerformanceStats 按权益曲线计的年化收益、风险调整收益与持仓时间
+type PerformanceStats struct {
+	CAGR       float64
+	Sharpe     float64
+	Sortino    float64
+	Calmar     float64
+	AvgHold    int64 // 平均持仓时间（秒），每个持仓从第一批入场到全部平仓
+	MedianHold int64 // 持仓时间中位数（秒）
+}
+
+// ComputePerformance 由权益曲线（times 与 equity 一一对应）、最大回撤和每个持仓的持仓时间计算年化指标
+func ComputePerformance(times []int64, equity []float64, maxDrawdown float64, holds []int64) PerformanceStats {
+	var s PerformanceStats
+	if len(equity) < 2 || len(times) != len(equity) {
+		return s
+	}
+	span := times[len(times)-1] - times[0]
+	start, end := equity[0], equity[len(equity)-1]
+	daily := DailyEquity(times, equity)
+	s.CAGR = CAGR(start, end, span)
+	s.Sharpe = SharpeRatio(daily)
+	s.Sortino = SortinoRatio(daily)
+	s.Calmar = CalmarRatio(start, end, span, maxDrawdown)
+
+	if len(holds) > 0 {
+		sorted := append([]int64(nil), holds...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		var total int64
+		for _, h := range sorted {
+			total += h
+		}
+		s.AvgHold = total / int64(len(sorted))
+		mid := len(sorted) / 2
+		s.MedianHold = sorted[mid]
+		if len(sorted)%2 == 0 {
+			s.MedianHold = (sorted[mid-1] + sorted[mid]) / 2
+		}
+	}
+	return s
+}
+
+// printPerformanceStats 打印年化收益、风险调整收益和持仓时间，timeInMarket 为持仓 K 线占比
+func printPerformanceStats(s PerformanceStats, timeInMarket float64) {
+	fmt.Println("\n--- 收益与风险（按权益曲线） ---")
+	fmt.Printf("年化收益 (CAGR): %.2f%%\n", s.CAGR*100)
+	fmt.Printf("夏普: %.2f | 索提诺: %.2f | 卡玛: %.2f\n", s.Sharpe, s.Sortino, s.Calmar)
+	fmt.Printf("持仓时间占比: %.2f%%\n", timeInMarket*100)
+	if s.AvgHold > 0 {
+		fmt.Printf("持仓时间: 平均 %s | 中位数 %s\n", formatSpan(s.AvgHold), formatSpan(s.MedianHold))
+	}
+}
+
+// MaxConsecutiveLosses 按时间顺序的逐笔盈亏中最长的连续亏损笔数
+func MaxConsecutiveLosses(pnls []float64) int {
+	longest, run := 0, 0
+	for _, pnl := range pnls {
+		if pnl < 0 {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return longest
+}
+
+// RollingMaxDrawdown 计算滚动窗口内的最大回撤（0-1）
+// 返回从第 window 天开始的序列
+func RollingMaxDrawdown(daily []EquityPoint, window int) []EquityPoint {
+	if window < 1 || len(daily) <= window {
+		return nil
+	}
+
+	var series []EquityPoint
+	for i := window; i < len(daily); i++ {
+		peak := daily[i-window].Value
+		maxDD := 0.0
+		for j := i - window; j <= i; j++ {
+			if daily[j].Value > peak {
+				peak = daily[j].Value
+			}
+			if peak > 0 {
+				if dd := (peak - daily[j].Value) / peak; dd > maxDD {
+					maxDD = dd
+				}
+			}
+		}
+		series = append(series, EquityPoint{Timestamp: daily[i].Timestamp, Value: maxDD})
+	}
+
+	return series
+}
+
+// seriesRange 返回序列的最小值、最大值和最新值
+func seriesRange(series []EquityPoint) (min, max, last float64) {
+	if len(series) == 0 {
+		return 0, 0, 0
+	}
+	min, max = series[0].Value, series[0].Value
+	for _, p := range series {
+		if p.Value < min {
+			min = p.Value
+		}
+		if p.Value > max {
+			max = p.Value
+		}
+	}
+	return min, max, series[len(series)-1].Value
+}
+
+// printRollingStats 打印滚动指标摘要
+func printRollingStats(sharpe, drawdown []EquityPoint) {
+	if len(sharpe) == 0 && len(drawdown) == 0 {
+		return
+	}
+	fmt.Printf("\n--- 滚动 %d 天指标 ---\n", RollingWindowDays)
+	if len(sharpe) > 0 {
+		min, max, last := seriesRange(sharpe)
+		fmt.Printf("滚动夏普: 最低 %.2f | 最高 %.2f | 最新 %.2f\n", min, max, last)
+	}
+	if len(drawdown) > 0 {
+		min, max, last := seriesRange(drawdown)
+		fmt.Printf("滚动回撤: 最低 %.2f%% | 最高 %.2f%% | 最新 %.2f%%\n", min*100, max*100, last*100)
+	}
+}
+
+// ExposureStats 资金占用统计：只偶尔持仓的策略按实际占用的资金评估效率
+type ExposureStats struct {
+	TimeInMarket     float64 // 持仓 K 线占比
+	AvgUtilization   float64 // 平均资金占用率（持仓名义价值 / 账户资金，空仓 K 线计 0）
+	MaxUtilization   float64 // 最大资金占用率
+	AvgDeployed      float64 // 平均占用资金 (USDT)，空仓 K 线计 0
+	ReturnOnBalance  float64 // 总盈亏 / 初始资金
+	ReturnOnDeployed float64 // 总盈亏 / 平均占用资金，平均资金占用率低于 minDeployedUtilization 时为 0
+}
+
+// minDeployedUtilization 平均资金占用率低于该值时，按平均占用资金计的收益率分母过小、不具参考意义
+const minDeployedUtilization = 0.01
+
+// exposureTracker 逐根 K 线累计持仓名义价值
+type exposureTracker struct {
+	bars        int
+	inMarket    int
+	sumUtil     float64
+	maxUtil     float64
+	sumDeployed float64
+}
+
+// add 记录一根 K 线收盘时的持仓名义价值和账户资金
+func (t *exposureTracker) add(notional, balance float64) {
+	t.bars++
+	if notional <= 0 {
+		return
+	}
+	t.inMarket++
+	t.sumDeployed += notional
+	if balance > 0 {
+		util := notional / balance
+		t.sumUtil += util
+		t.maxUtil = math.Max(t.maxUtil, util)
+	}
+}
+
+// stats 汇总资金占用统计
+func (t *exposureTracker) stats(totalPnL, startBalance float64) ExposureStats {
+	var s ExposureStats
+	if startBalance > 0 {
+		s.ReturnOnBalance = totalPnL / startBalance
+	}
+	if t.bars == 0 {
+		return s
+	}
+	n := float64(t.bars)
+	s.TimeInMarket = float64(t.inMarket) / n
+	s.AvgUtilization = t.sumUtil / n
+	s.MaxUtilization = t.maxUtil
+	s.AvgDeployed = t.sumDeployed / n
+	if s.AvgDeployed > 0 && s.AvgUtilization >= minDeployedUtilization {
+		s.ReturnOnDeployed = totalPnL / s.AvgDeployed
+	}
+	return s
+}
+
+// printExposureStats 打印资金占用与按占用资金计的收益
+func printExposureStats(s ExposureStats) {
+	fmt.Println("\n--- 资金占用 ---")
+	fmt.Printf("资金占用率: 平均 %.2f%% | 最大 %.2f%%\n", s.AvgUtilization*100, s.MaxUtilization*100)
+	fmt.Printf("平均占用资金: $%.2f\n", s.AvgDeployed)
+	if s.AvgUtilization < minDeployedUtilization {
+		fmt.Printf("收益率: 按初始资金 %.2f%% | 按平均占用资金 -（平均资金占用率低于 %.0f%%，不具参考意义）\n", s.ReturnOnBalance*100, minDeployedUtilization*100)
+		return
+	}
+	fmt.Printf("收益率: 按初始资金 %.2f%% | 按平均占用资金 %.2f%%\n", s.ReturnOnBalance*100, s.ReturnOnDeployed*100)
+}
+
+// DrawdownStats 水下时间统计：回撤持续多久与回撤多深同样重要
+type DrawdownStats struct {
+	Episodes        int   // 已收复前高的回撤次数
+	LongestDuration int64 // 最长水下时间（秒），含尚未恢复的当前回撤
+	AvgRecovery     int64 // 已恢复回撤从前高到收复前高的平均时间（秒）
+	CurrentDuration int64 // 当前回撤已持续的时间（秒），不在回撤中为 0
+}
+
+// DrawdownDurations 由资金曲线计算回撤持续时间，times 与 curve 一一对应
+func DrawdownDurations(times []int64, curve []float64) DrawdownStats {
+	var s DrawdownStats
+	if len(curve) == 0 || len(times) != len(curve) {
+		return s
+	}
+
+	peak, peakTime := curve[0], times[0]
+	underwater := false
+	var totalRecovery int64
+	for i, v := range curve {
+		if v < peak {
+			underwater = true
+			continue
+		}
+		if underwater {
+			d := times[i] - peakTime
+			s.Episodes++
+			totalRecovery += d
+			s.LongestDuration = max(s.LongestDuration, d)
+			underwater = false
+		}
+		peak, peakTime = v, times[i]
+	}
+	if underwater {
+		s.CurrentDuration = times[len(times)-1] - peakTime
+		s.LongestDuration = max(s.LongestDuration, s.CurrentDuration)
+	}
+	if s.Episodes > 0 {
+		s.AvgRecovery = totalRecovery / int64(s.Episodes)
+	}
+	return s
+}
+
+// formatSpan 将秒数格式化为 "3天4小时" / "2小时15分"
+func formatSpan(secs int64) string {
+	d := time.Duration(secs) * time.Second
+	days := int64(d / (24 * time.Hour))
+	hours := int64(d % (24 * time.Hour) / time.Hour)
+	minutes := int64(d % time.Hour / time.Minute)
+	if days > 0 {
+		return fmt.Sprintf("%d天%d小时", days, hours)
+	}
+	return fmt.Sprintf("%d小时%d分", hours, minutes)
+}
+
+// printDrawdownStats 打印回撤持续时间
+func printDrawdownStats(s DrawdownStats) {
+	fmt.Println("\n--- 回撤持续时间 ---")
+	fmt.Printf("最长水下时间: %s\n", formatSpan(s.LongestDuration))
+	if s.Episodes > 0 {
+		fmt.Printf("已恢复回撤: %d 次, 平均恢复时间 %s\n", s.Episodes, formatSpan(s.AvgRecovery))
+	}
+	if s.CurrentDuration > 0 {
+		fmt.Printf("当前回撤已持续: %s\n", formatSpan(s.CurrentDuration))
+	}
+}
+
+// defaultRiskPct 未设置止损时计算 R 倍数使用的止损距离（相对入场价 0.5%）
+const defaultRiskPct = 0.005
+
+// sqnMaxTrades SQN 的交易数上限（Van Tharp：超过 100 笔时按 100 计，避免样本量掩盖质量）
+const sqnMaxTrades = 100
+
+// riskPct 计算 R 倍数使用的止损距离
+func riskPct(stopPct float64) float64 {
+	if stopPct > 0 {
+		return stopPct
+	}
+	return defaultRiskPct
+}
+
+// RMultiple 一笔交易的 R 倍数：盈亏 / 初始风险（入场名义价值 × 止损距离）
+func RMultiple(pnl, entryPrice, amount, stopPct float64) float64 {
+	risk := entryPrice * amount * riskPct(stopPct)
+	if risk <= 0 {
+		return 0
+	}
+	return pnl / risk
+}
+
+// RStats 以 R 倍数衡量的期望与系统质量
+type RStats struct {
+	Trades     int
+	Expectancy float64 // 平均每笔 R
+	StdR       float64 // R 的样本标准差
+	SQN        float64 // System Quality Number = sqrt(min(N, 100)) × 期望 / 标准差
+}
+
+// rTracker 逐笔累计 R 倍数（只保留和与平方和，流式回测内存恒定）
+type rTracker struct {
+	n          int
+	sum, sumSq float64
+}
+
+// add 记录一笔交易的 R 倍数
+func (t *rTracker) add(r float64) {
+	t.n++
+	t.sum += r
+	t.sumSq += r * r
+}
+
+// stats 汇总期望、标准差和 SQN
+func (t *rTracker) stats() RStats {
+	s := RStats{Trades: t.n}
+	if t.n == 0 {
+		return s
+	}
+	n := float64(t.n)
+	s.Expectancy = t.sum / n
+	if t.n > 1 {
+		s.StdR = math.Sqrt(math.Max(t.sumSq-n*s.Expectancy*s.Expectancy, 0) / (n - 1))
+	}
+	if s.StdR > 0 {
+		s.SQN = math.Sqrt(math.Min(n, sqnMaxTrades)) * s.Expectancy / s.StdR
+	}
+	return s
+}
+
+// printRStats 打印期望与 SQN
+func printRStats(s RStats, stopPct, atrMult float64) {
+	if s.Trades == 0 {
+		return
+	}
+	if atrMult > 0 {
+		fmt.Printf("\n--- 期望与 SQN (1R = 入场数量 × 开仓时 ATR × %g) ---\n", atrMult)
+	} else {
+		fmt.Printf("\n--- 期望与 SQN (1R = 入场名义价值 × %.2f%%) ---\n", riskPct(stopPct)*100)
+	}
+	fmt.Printf("期望: %.3fR | R 标准差: %.3f | SQN: %.2f\n", s.Expectancy, s.StdR, s.SQN)
+}
+
+// HistBucket 直方图分桶
+type HistBucket struct {
+	Low   float64
+	High  float64
+	Count int
+}
+
+// Histogram 将数据等宽分为 buckets 个桶
+func Histogram(values []float64, buckets int) []HistBucket {
+	if len(values) == 0 || buckets < 1 {
+		return nil
+	}
+
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		if v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+	}
+	if hi == lo {
+		return []HistBucket{{Low: lo, High: hi, Count: len(values)}}
+	}
+
+	width := (hi - lo) / float64(buckets)
+	hist := make([]HistBucket, buckets)
+	for i := range hist {
+		hist[i].Low = lo + width*float64(i)
+		hist[i].High = lo + width*float64(i+1)
+	}
+	for _, v := range values {
+		idx := int((v - lo) / width)
+		if idx >= buckets {
+			idx = buckets - 1
+		}
+		hist[idx].Count++
+	}
+
+	return hist
+}
+
+// Moments 计算均值、标准差、偏度和超额峰度
+func Moments(values []float64) (mean, std, skew, kurt float64) {
+	n := float64(len(values))
+	if n == 0 {
+		return 0, 0, 0, 0
+	}
+
+	for _, v := range values {
+		mean += v
+	}
+	mean /= n
+
+	var m2, m3, m4 float64
+	for _, v := range values {
+		d := v - mean
+		m2 += d * d
+		m3 += d * d * d
+		m4 += d * d * d * d
+	}
+	m2 /= n
+	m3 /= n
+	m4 /= n
+
+	std = math.Sqrt(m2)
+	if m2 > 0 {
+		skew = m3 / math.Pow(m2, 1.5)
+		kurt = m4/(m2*m2) - 3
+	}
+
+	return mean, std, skew, kurt
+}
+
+// printHistogram 以文本条形图打印直方图
+func printHistogram(title string, hist []HistBucket, format string) {
+	fmt.Println(title)
+	maxCount := 0
+	for _, b := range hist {
+		if b.Count > maxCount {
+			maxCount = b.Count
+		}
+	}
+	for _, b := range hist {
+		bar := 0
+		if maxCount > 0 {
+			bar = b.Count * 40 / maxCount
+		}
+		fmt.Printf("  ["+format+", "+format+") %6d %s\n", b.Low, b.High, b.Count, strings.Repeat("#", bar))
+	}
+}
+
+// printTradeDistribution 打印每笔盈亏与收益率分布
+// pnls 为每笔盈亏（$），returns 为每笔收益率（相对入场名义价值）
+func printTradeDistribution(pnls, returns []float64) {
+	if len(pnls) == 0 {
+		return
+	}
+
+	fmt.Println("\n--- 交易分布 ---")
+	printHistogram("每笔盈亏 ($):", Histogram(pnls, 10), "%8.2f")
+	_, _, skew, kurt := Moments(pnls)
+	fmt.Printf("  偏度: %.2f | 超额峰度: %.2f\n", skew, kurt)
+
+	pct := make([]float64, len(returns))
+	for i, r := range returns {
+		pct[i] = r * 100
+	}
+	printHistogram("每笔收益率 (%):", Histogram(pct, 10), "%6.3f")
+	_, _, skew, kurt = Moments(pct)
+	fmt.Printf("  偏度: %.2f | 超额峰度: %.2f\n", skew, kurt)
+}
+
+// BatchStats 单个批次的成交统计
+type BatchStats struct {
+	Batch     int
+	Trades    int
+	Wins      int
+	PnL       float64
+	AvgReturn float64
+}
+
+// BatchBreakdown 按入场批次汇总交易表现，按批次升序返回
+func BatchBreakdown(batches []int, pnls, returns []float64) []BatchStats {
+	byBatch := make(map[int]*BatchStats)
+	var order []int
+	for i, b := range batches {
+		st, ok := byBatch[b]
+		if !ok {
+			st = &BatchStats{Batch: b}
+			byBatch[b] = st
+			order = append(order, b)
+		}
+		st.Trades++
+		st.PnL += pnls[i]
+		st.AvgReturn += returns[i]
+		if pnls[i] > 0 {
+			st.Wins++
+		}
+	}
+
+	sort.Ints(order)
+	stats := make([]BatchStats, 0, len(order))
+	for _, b := range order {
+		st := byBatch[b]
+		st.AvgReturn /= float64(st.Trades)
+		stats = append(stats, *st)
+	}
+
+	return stats
+}
+
+// ReasonStats 单个入场 / 出场原因的成交统计
+type ReasonStats struct {
+	Reason string
+	Trades int
+	Wins   int
+	PnL    float64
+}
+
+// ReasonBreakdown 按原因汇总交易表现，按笔数降序返回
+func ReasonBreakdown(reasons []string, pnls []float64) []ReasonStats {
+	byReason := make(map[string]*ReasonStats)
+	for i, r := range reasons {
+		if r == "" {
+			r = "-"
+		}
+		st, ok := byReason[r]
+		if !ok {
+			st = &ReasonStats{Reason: r}
+			byReason[r] = st
+		}
+		st.Trades++
+		st.PnL += pnls[i]
+		if pnls[i] > 0 {
+			st.Wins++
+		}
+	}
+
+	stats := make([]ReasonStats, 0, len(byReason))
+	for _, st := range byReason {
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Trades != stats[j].Trades {
+			return stats[i].Trades > stats[j].Trades
+		}
+		return stats[i].Reason < stats[j].Reason
+	})
+	return stats
+}
+
+// printReasonBreakdown 打印按原因分组的笔数占比、胜率和盈亏
+func printReasonBreakdown(title string, reasons []string, pnls []float64) {
+	stats := ReasonBreakdown(reasons, pnls)
+	if len(stats) == 0 {
+		return
+	}
+
+	fmt.Printf("\n--- %s ---\n", title)
+	for _, st := range stats {
+		fmt.Printf("%s: %d 笔 (%.1f%%), 胜率 %.1f%%, 盈亏 $%.2f, 平均 $%.2f\n",
+			st.Reason, st.Trades, float64(st.Trades)/float64(len(reasons))*100,
+			float64(st.Wins)/float64(st.Trades)*100, st.PnL, st.PnL/float64(st.Trades))
+	}
+}
+
+// printBatchBreakdown 打印分批入场表现
+func printBatchBreakdown(batches []int, pnls, returns []float64, totalPnL float64) {
+	stats := BatchBreakdown(batches, pnls, returns)
+	if len(stats) == 0 {
+		return
+	}
+
+	fmt.Println("\n--- 分批统计 ---")
+	for _, st := range stats {
+		contribution := 0.0
+		if totalPnL != 0 {
+			contribution = st.PnL / math.Abs(totalPnL) * 100
+		}
+		fmt.Printf("第%d批: %d 笔, 胜率 %.1f%%, 平均收益率 %.3f%%, 盈亏 $%.2f (占总盈亏 %.1f%%)\n",
+			st.Batch, st.Trades, float64(st.Wins)/float64(st.Trades)*100, st.AvgReturn*100, st.PnL, contribution)
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+)
+
+// 合成行情模型
+const (
+	ModelGBM    = "gbm"    // 几何布朗运动
+	ModelRegime = "regime" // 低波动 / 高波动两状态切换
+	ModelJump   = "jump"   // 跳跃扩散（Merton）
+)
+
+// SyntheticConfig 合成 K 线参数，收益率和波动率均按每根 K 线计
+type SyntheticConfig struct {
+	Model      string  `json:"model"`
+	Bars       int     `json:"bars"`
+	Interval   int64   `json:"interval"` // K 线间隔（秒），0 表示 60
+	StartPrice float64 `json:"start_price"`
+	Drift      float64 `json:"drift"`      // 每根 K 线的对数收益均值
+	Volatility float64 `json:"volatility"` // 每根 K 线的对数收益标准差
+	BaseVolume float64 `json:"base_volume"`
+
+	// regime：每根 K 线以 SwitchProb 概率在低 / 高波动之间切换，高波动为 Volatility × HighVolMult
+	SwitchProb  float64 `json:"switch_prob,omitempty"`
+	HighVolMult float64 `json:"high_vol_mult,omitempty"`
+
+	// jump：每根 K 线以 JumpProb 概率叠加一次 N(JumpMean, JumpStd) 的对数跳跃
+	JumpProb float64 `json:"jump_prob,omitempty"`
+	JumpMean float64 `json:"jump_mean,omitempty"`
+	JumpStd  float64 `json:"jump_std,omitempty"`
+
+	// 闪崩注入（任意模型）：随机位置下跌 CrashDepth，之后 CrashRecovery 根 K 线内线性收复
+	CrashCount    int     `json:"crash_count,omitempty"`
+	CrashDepth    float64 `json:"crash_depth,omitempty"`
+	CrashRecovery int     `json:"crash_recovery,omitempty"`
+}
+
+// syntheticStart 合成 K 线的起始时间（2024-01-01 UTC）
+const syntheticStart = 1704067200
+
+// GenerateKlines 按配置生成合成 K 线，相同 seed 结果相同
+func GenerateKlines(config SyntheticConfig, seed int64) ([]Kline, error) {
+	if config.Bars <= 0 || config.StartPrice <= 0 || config.Volatility <= 0 {
+		return nil, fmt.Errorf("bars, start_price and volatility must be positive")
+	}
+	if config.CrashDepth < 0 || config.CrashDepth >= 1 {
+		return nil, fmt.Errorf("crash_depth must be in [0, 1)")
+	}
+	interval := config.Interval
+	if interval <= 0 {
+		interval = 60
+	}
+	baseVolume := config.BaseVolume
+	if baseVolume <= 0 {
+		baseVolume = 100
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+
+	// 对数收益序列及每根 K 线的波动率（用于影线和成交量）
+	returns := make([]float64, config.Bars)
+	vols := make([]float64, config.Bars)
+	highVol := false
+	for i := range returns {
+		vol := config.Volatility
+		switch config.Model {
+		case ModelGBM, "":
+		case ModelRegime:
+			if rng.Float64() < config.SwitchProb {
+				highVol = !highVol
+			}
+			if highVol {
+				vol *= config.HighVolMult
+			}
+		case ModelJump:
+		default:
+			return nil, fmt.Errorf("unknown model %q", config.Model)
+		}
+		vols[i] = vol
+		// 漂移减去 σ²/2，使价格期望按 Drift 增长
+		returns[i] = config.Drift - vol*vol/2 + vol*rng.NormFloat64()
+		if config.Model == ModelJump && rng.Float64() < config.JumpProb {
+			returns[i] += config.JumpMean + config.JumpStd*rng.NormFloat64()
+		}
+	}
+
+	// 闪崩：一根 K 线内跌去 CrashDepth，随后线性收复
+	crashed := make([]bool, config.Bars)
+	if config.CrashCount > 0 && config.CrashDepth > 0 {
+		recovery := max(config.CrashRecovery, 1)
+		drop := math.Log(1 - config.CrashDepth)
+		for c := 0; c < config.CrashCount; c++ {
+			at := rng.Intn(config.Bars)
+			crashed[at] = true
+			returns[at] += drop
+			for j := 1; j <= recovery && at+j < config.Bars; j++ {
+				returns[at+j] -= drop / float64(recovery)
+			}
+		}
+	}
+
+	klines := make([]Kline, config.Bars)
+	price := config.StartPrice
+	for i, r := range returns {
+		open := price
+		price *= math.Exp(r)
+		// 影线按当根波动率随机延伸，闪崩 K 线额外下探
+		high := math.Max(open, price) * (1 + math.Abs(rng.NormFloat64())*vols[i]/2)
+		low := math.Min(open, price) * (1 - math.Abs(rng.NormFloat64())*vols[i]/2)
+		// 成交量随波动放大：|收益| / 基准波动率越大，成交越多
+		volume := baseVolume * math.Exp(0.3*rng.NormFloat64()) * (1 + math.Abs(r)/config.Volatility)
+		if crashed[i] {
+			low *= 1 - config.CrashDepth/4
+			volume *= 10
+		}
+		klines[i] = Kline{
+			Timestamp: syntheticStart + int64(i)*interval,
+			Open:      open,
+			High:      high,
+			Low:       low,
+			Close:     price,
+			Volume:    volume,
+		}
+	}
+	return klines, nil
+}
+
+// StressScenario 压力测试场景
+type StressScenario struct {
+	Name   string          `json:"name"`
+	Config SyntheticConfig `json:"config"`
+}
+
+// DefaultStressScenarios 内置场景：1m K 线，每个约 7 天
+var DefaultStressScenarios = []StressScenario{
+	{"gbm-calm", SyntheticConfig{Model: ModelGBM, Bars: 10080, StartPrice: 40000, Volatility: 0.0008}},
+	{"gbm-bull", SyntheticConfig{Model: ModelGBM, Bars: 10080, StartPrice: 40000, Drift: 0.00003, Volatility: 0.001}},
+	{"gbm-bear", SyntheticConfig{Model: ModelGBM, Bars: 10080, StartPrice: 40000, Drift: -0.00003, Volatility: 0.001}},
+	{"regime", SyntheticConfig{Model: ModelRegime, Bars: 10080, StartPrice: 40000, Volatility: 0.0006, SwitchProb: 0.002, HighVolMult: 4}},
+	{"jump", SyntheticConfig{Model: ModelJump, Bars: 10080, StartPrice: 40000, Volatility: 0.0008, JumpProb: 0.002, JumpStd: 0.01}},
+	{"flash-crash", SyntheticConfig{Model: ModelGBM, Bars: 10080, StartPrice: 40000, Volatility: 0.0008, CrashCount: 3, CrashDepth: 0.08, CrashRecovery: 30}},
+}
+
+// LoadStressScenarios 从 JSON 文件读取场景列表
+func LoadStressScenarios(path string) ([]StressScenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var scenarios []StressScenario
+	if err := json.Unmarshal(data, &scenarios); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return scenarios, nil
+}
+
+// StressStats 一个策略在一个场景下多次运行的汇总
+type StressStats struct {
+	Scenario    string
+	Strategy    string
+	Runs        int
+	AvgPnL      float64
+	LossRate    float64 // 亏损运行占比
+	AvgTrades   float64
+	AvgWinRate  float64
+	AvgDrawdown float64
+	MaxDrawdown float64
+	WorstPnL    float64
+	WorstSeed   int64 // 最差一次的随机种子，用于复现
+}
+
+// stressStrategy 参与压力测试的策略
+type stressStrategy struct {
+	name string
+	run  func(klines []Kline) *BacktestResult
+}
+
+// stressStrategies 所有已注册的策略引擎
+func stressStrategies(config BacktestConfig) []stressStrategy {
+	var strategies []stressStrategy
+	for _, name := range EngineNames() {
+		name := name
+		strategies = append(strategies, stressStrategy{name, func(klines []Kline) *BacktestResult {
+			engineConfig := defaultConfig
+			engineConfig.setStrategyConfig(DefaultConfig)
+			engineConfig.PositionSize = config.PositionSize
+			engine, _ := NewEngine(name, &engineConfig)
+			return RunEngineBacktest(klines, config, engine)
+		}})
+	}
+	return strategies
+}
+
+// RunStressTest 每个场景用 runs 个种子生成行情，分别跑所有策略并汇总
+func RunStressTest(scenarios []StressScenario, runs int, seed int64, config BacktestConfig) ([]StressStats, error) {
+	strategies := stressStrategies(config)
+	var stats []StressStats
+	for _, scenario := range scenarios {
+		results := make([]StressStats, len(strategies))
+		for i, st := range strategies {
+			results[i] = StressStats{Scenario: scenario.Name, Strategy: st.name, WorstPnL: math.Inf(1)}
+		}
+
+		for r := 0; r < runs; r++ {
+			runSeed := seed + int64(r)
+			klines, err := GenerateKlines(scenario.Config, runSeed)
+			if err != nil {
+				return nil, fmt.Errorf("scenario %s: %w", scenario.Name, err)
+			}
+			for i, st := range strategies {
+				result := st.run(klines)
+				s := &results[i]
+				s.Runs++
+				s.AvgPnL += result.TotalPnL
+				s.AvgTrades += float64(result.TotalTrades)
+				s.AvgWinRate += result.WinRate
+				s.AvgDrawdown += result.MaxDrawdown
+				s.MaxDrawdown = math.Max(s.MaxDrawdown, result.MaxDrawdown)
+				if result.TotalPnL < 0 {
+					s.LossRate++
+				}
+				if result.TotalPnL < s.WorstPnL {
+					s.WorstPnL = result.TotalPnL
+					s.WorstSeed = runSeed
+				}
+			}
+		}
+
+		for i := range results {
+			s := &results[i]
+			if s.Runs == 0 {
+				continue
+			}
+			n := float64(s.Runs)
+			s.AvgPnL /= n
+			s.LossRate /= n
+			s.AvgTrades /= n
+			s.AvgWinRate /= n
+			s.AvgDrawdown /= n
+		}
+		stats = append(stats, results...)
+	}
+	return stats, nil
+}
+
+// PrintStressStats 打印压力测试结果，并按场景列出平均亏损最大的策略
+func PrintStressStats(stats []StressStats) {
+	fmt.Println("\n========== 压力测试 ==========")
+	fmt.Printf("%-14s %-16s %6s %10s %7s %7s %7s %8s %8s %10s %6s\n",
+		"场景", "策略", "次数", "平均盈亏", "亏损率", "交易数", "胜率", "平均回撤", "最大回撤", "最差盈亏", "种子")
+	for _, s := range stats {
+		fmt.Printf("%-14s %-16s %6d %10.2f %6.0f%% %7.1f %6.1f%% %7.2f%% %7.2f%% %10.2f %6d\n",
+			s.Scenario, s.Strategy, s.Runs, s.AvgPnL, s.LossRate*100, s.AvgTrades, s.AvgWinRate*100,
+			s.AvgDrawdown*100, s.MaxDrawdown*100, s.WorstPnL, s.WorstSeed)
+	}
+
+	// 失效场景：平均亏损的组合，按平均盈亏从差到好
+	var failures []StressStats
+	for _, s := range stats {
+		if s.AvgPnL < 0 {
+			failures = append(failures, s)
+		}
+	}
+	if len(failures) > 0 {
+		sort.Slice(failures, func(i, j int) bool { return failures[i].AvgPnL < failures[j].AvgPnL })
+		fmt.Println("\n--- 平均亏损的场景 ---")
+		for _, s := range failures {
+			fmt.Printf("%s / %s: 平均 $%.2f, %.0f%% 的运行亏损, 最大回撤 %.2f%%\n",
+				s.Scenario, s.Strategy, s.AvgPnL, s.LossRate*100, s.MaxDrawdown*100)
+		}
+	}
+	fmt.Println("================================")
+}
+
+// StressOptions 压力测试命令选项
+type StressOptions struct {
+	ScenariosPath string // 场景 JSON，为空使用 DefaultStressScenarios
+	Runs          int    // 每个场景生成的行情条数
+	Seed          int64  // 起始随机种子
+	ExportPath    string // 导出第一个场景首条合成行情的 CSV，便于检查
+}
+
+// runStressCmd 执行压力测试命令
+func runStressCmd(opts StressOptions, backtestOpts BacktestOptions) {
+	scenarios := DefaultStressScenarios
+	if opts.ScenariosPath != "" {
+		var err error
+		scenarios, err = LoadStressScenarios(opts.ScenariosPath)
+		if err != nil {
+			log.Fatalf("加载场景失败: %v", err)
+		}
+	}
+	if opts.Runs <= 0 {
+		log.Fatalf("-stress-runs 需大于 0")
+	}
+
+	config := DefaultBacktestConfig
+	config.Sizing = backtestOpts.Sizing
+	config.DailyCompounding = backtestOpts.DailyCompounding
+	config.Throttle = backtestOpts.Throttle
+	config.StopLossPct = backtestOpts.StopLossPct
+	config.StopMode = backtestOpts.StopMode
+	config.StopPenalty = backtestOpts.StopPenalty
+	config.TakeProfitPct = backtestOpts.TakeProfitPct
+	config.Trailing = backtestOpts.Trailing
+	config.Pyramid = backtestOpts.Pyramid
+	config.Hedge = backtestOpts.Hedge
+	config.IntrabarFill = backtestOpts.IntrabarFill
+	config.MaxChaseBps = backtestOpts.MaxChaseBps
+	config.FeeTiers = backtestOpts.FeeTiers
+	config.EntryFill = backtestOpts.EntryFill
+	config.EquityFilter = EquityFilterConfig{MATrades: backtestOpts.EquityMA}
+	config.VolTarget = backtestOpts.VolTarget
+	config.Maintenance = backtestOpts.maintenanceSchedule()
+	config.Slippage = backtestOpts.Slippage
+
+	if opts.ExportPath != "" && len(scenarios) > 0 {
+		klines, err := GenerateKlines(scenarios[0].Config, opts.Seed)
+		if err != nil {
+			log.Fatalf("生成行情失败: %v", err)
+		}
+		if err := WriteKlinesCSV(opts.ExportPath, klines); err != nil {
+			log.Fatalf("导出合成行情失败: %v", err)
+		}
+		log.Printf("合成行情已导出: %s (%s, seed %d)", opts.ExportPath, scenarios[0].Name, opts.Seed)
+	}
+
+	log.Printf("压力测试: %d 个场景 × %d 次", len(scenarios), opts.Runs)
+	stats, err := RunStressTest(scenarios, opts.Runs, opts.Seed, config)
+	if err != nil {
+		log.Fatalf("压力测试失败: %v", err)
+	}
+	PrintStressStats(stats)
+}
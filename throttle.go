@@ -0,0 +1,126 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// ThrottleConfig 入场频率限制，0 表示不限制
+// 按滚动窗口计数（最近 1 小时 / 24 小时），每一批入场都计一次
+type ThrottleConfig struct {
+	MaxPerHour       int `json:"max_per_hour"`        // 单交易对每小时最多入场次数
+	MaxPerDay        int `json:"max_per_day"`         // 单交易对每天最多入场次数
+	GlobalMaxPerHour int `json:"global_max_per_hour"` // 所有交易对合计（共享同一 journal_path）
+	GlobalMaxPerDay  int `json:"global_max_per_day"`
+}
+
+// enabled 是否配置了任一限制
+func (c ThrottleConfig) enabled() bool {
+	return c.MaxPerHour > 0 || c.MaxPerDay > 0 || c.GlobalMaxPerHour > 0 || c.GlobalMaxPerDay > 0
+}
+
+// withinLimits 判断最近 1 小时 / 24 小时的入场次数是否还允许再入场
+func withinLimits(hour, day, maxPerHour, maxPerDay int) bool {
+	if maxPerHour > 0 && hour >= maxPerHour {
+		return false
+	}
+	if maxPerDay > 0 && day >= maxPerDay {
+		return false
+	}
+	return true
+}
+
+// EntryThrottle 单交易对的入场计数（回测和实盘共用）
+type EntryThrottle struct {
+	config ThrottleConfig
+	times  []int64 // 最近 24 小时内的入场时间
+}
+
+// NewEntryThrottle 创建入场限频器
+func NewEntryThrottle(config ThrottleConfig) *EntryThrottle {
+	return &EntryThrottle{config: config}
+}
+
+// counts 返回 ts 之前 1 小时和 24 小时内的入场次数，并丢弃过期记录
+func (t *EntryThrottle) counts(ts int64) (hour, day int) {
+	if t == nil {
+		return 0, 0
+	}
+	keep := t.times[:0]
+	for _, at := range t.times {
+		if ts-at < 86400 {
+			keep = append(keep, at)
+		}
+	}
+	t.times = keep
+
+	for _, at := range t.times {
+		if ts-at < 3600 {
+			hour++
+		}
+	}
+	return hour, len(t.times)
+}
+
+// Allow 判断 ts 时刻是否允许入场
+// 单交易对回测中全局限制等同于单交易对限制
+func (t *EntryThrottle) Allow(ts int64) bool {
+	if t == nil || !t.config.enabled() {
+		return true
+	}
+	hour, day := t.counts(ts)
+	return withinLimits(hour, day, t.config.MaxPerHour, t.config.MaxPerDay) &&
+		withinLimits(hour, day, t.config.GlobalMaxPerHour, t.config.GlobalMaxPerDay)
+}
+
+// Record 记录一次入场
+func (t *EntryThrottle) Record(ts int64) {
+	if t == nil || !t.config.enabled() {
+		return
+	}
+	t.times = append(t.times, ts)
+}
+
+// allowEntry 实盘入场前检查频率限制：单交易对用内存计数，全局限制从交易日志统计
+func (s *Strategy) allowEntry() bool {
+	cfg := s.config.Throttle
+	now := time.Now().Unix()
+
+	hour, day := s.throttle.counts(now)
+	if !withinLimits(hour, day, cfg.MaxPerHour, cfg.MaxPerDay) {
+		return false
+	}
+
+	if cfg.GlobalMaxPerHour == 0 && cfg.GlobalMaxPerDay == 0 {
+		return true
+	}
+	if s.journal == nil {
+		// 没有共享日志时只能看到本进程的入场
+		return withinLimits(hour, day, cfg.GlobalMaxPerHour, cfg.GlobalMaxPerDay)
+	}
+	hour, err := s.journal.CountOpens(now - 3600)
+	if err != nil {
+		log.Printf("统计全局入场次数失败: %v", err)
+		return true
+	}
+	day, err = s.journal.CountOpens(now - 86400)
+	if err != nil {
+		log.Printf("统计全局入场次数失败: %v", err)
+		return true
+	}
+	return withinLimits(hour, day, cfg.GlobalMaxPerHour, cfg.GlobalMaxPerDay)
+}
+
+// seedThrottle 从交易日志恢复最近 24 小时的入场记录，重启后限频不清零
+func (s *Strategy) seedThrottle() error {
+	entries, err := s.journal.Entries(time.Now().Unix()-86400, 0)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Symbol == s.config.Symbol && e.Event == JournalOpen {
+			s.throttle.Record(e.Time)
+		}
+	}
+	return nil
+}
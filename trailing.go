@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+)
+
+// 移动止损方式
+const (
+	TrailNone = ""    // 不移动止损
+	TrailPct  = "pct" // 止损价跟随持仓后的最有利价格，保持固定比例的距离
+	TrailATR  = "atr" // 距离为 ATR × atr_mult，随波动率变化
+)
+
+// ExitTrailingStop 移动止损或保本止损出场
+const ExitTrailingStop = "移动止损"
+
+// TrailingConfig 移动止损和保本止损，回测与实盘共用。止损价只朝有利方向移动，与固定止损同时存在时取更近的一个
+type TrailingConfig struct {
+	Mode       string  `json:"mode,omitempty"`       // pct / atr，为空表示不移动止损
+	Pct        float64 `json:"pct,omitempty"`        // pct: 止损价与最有利价格的距离比例
+	ATRPeriod  int     `json:"atr_period,omitempty"` // atr: ATR 周期，默认 14
+	ATRMult    float64 `json:"atr_mult,omitempty"`   // atr: 止损距离为 ATR 的倍数，默认 3
+	Activation float64 `json:"activation,omitempty"` // 浮盈达到该比例（按最有利价格相对均价）后才开始移动，0 表示开仓即移动
+	BreakEven  float64 `json:"break_even,omitempty"` // 浮盈达到该比例后止损至少移到持仓均价，0 表示不设保本
+}
+
+// defaultTrailATRMult ATR 移动止损的默认倍数
+const defaultTrailATRMult = 3
+
+// Enabled 是否启用移动止损或保本止损
+func (c TrailingConfig) Enabled() bool {
+	return c.Mode != TrailNone || c.BreakEven > 0
+}
+
+// Validate 检查配置
+func (c TrailingConfig) Validate() error {
+	switch c.Mode {
+	case TrailNone:
+	case TrailPct:
+		if c.Pct <= 0 || c.Pct >= 1 {
+			return fmt.Errorf("trailing pct must be in (0, 1)")
+		}
+	case TrailATR:
+		if c.ATRPeriod < 0 || c.ATRMult < 0 {
+			return fmt.Errorf("trailing atr_period and atr_mult must be non-negative")
+		}
+	default:
+		return fmt.Errorf("unknown trailing mode %q (want pct or atr)", c.Mode)
+	}
+	if c.Activation < 0 || c.BreakEven < 0 {
+		return fmt.Errorf("trailing activation and break_even must be non-negative")
+	}
+	return nil
+}
+
+// atrPeriod ATR 周期
+func (c TrailingConfig) atrPeriod() int {
+	if c.ATRPeriod > 0 {
+		return c.ATRPeriod
+	}
+	return defaultATRPeriod
+}
+
+// atrMult ATR 倍数
+func (c TrailingConfig) atrMult() float64 {
+	if c.ATRMult > 0 {
+		return c.ATRMult
+	}
+	return defaultTrailATRMult
+}
+
+// NewATRTracker ATR 移动止损使用的逐根 ATR，其他方式返回 nil
+func (c TrailingConfig) NewATRTracker() *ATRTracker {
+	if c.Mode != TrailATR {
+		return nil
+	}
+	return NewATRTracker(c.atrPeriod())
+}
+
+// StopPrice 按持仓均价和持仓以来的最有利价格（多头为最高价，空头为最低价）计算止损价，未触发移动或保本时返回 0；
+// atr 为最新 ATR，不足周期时 ATR 移动止损不生效
+func (c TrailingConfig) StopPrice(side string, avgPrice, extreme, atr float64) float64 {
+	if avgPrice <= 0 || extreme <= 0 {
+		return 0
+	}
+	dir := 1.0
+	if side == "SHORT" {
+		dir = -1
+	}
+	gain := dir * (extreme/avgPrice - 1)
+
+	stop := 0.0
+	if c.Mode != TrailNone && gain >= c.Activation {
+		switch c.Mode {
+		case TrailPct:
+			stop = extreme * (1 - dir*c.Pct)
+		case TrailATR:
+			if atr > 0 {
+				stop = extreme - dir*atr*c.atrMult()
+			}
+		}
+	}
+	if c.BreakEven > 0 && gain >= c.BreakEven {
+		stop = tighterStop(side, stop, avgPrice)
+	}
+	if stop < 0 {
+		return 0
+	}
+	return stop
+}
+
+// tighterStop 两个止损价中离当前价更近的一个（多头取高、空头取低），0 表示未设置
+func tighterStop(side string, a, b float64) float64 {
+	switch {
+	case a <= 0:
+		return b
+	case b <= 0:
+		return a
+	case side == "SHORT":
+		return math.Min(a, b)
+	}
+	return math.Max(a, b)
+}
+
+// favorable 用 K 线更新持仓以来的最有利价格
+func favorable(side string, extreme float64, k Kline) float64 {
+	if side == "SHORT" {
+		if extreme <= 0 {
+			return k.Low
+		}
+		return math.Min(extreme, k.Low)
+	}
+	return math.Max(extreme, k.High)
+}
+
+// stopCrossed 价格是否已越过止损价
+func stopCrossed(side string, price, stop float64) bool {
+	if stop <= 0 {
+		return false
+	}
+	if side == "SHORT" {
+		return price >= stop
+	}
+	return price <= stop
+}
+
+// trailingName 移动止损配置的简短描述，用于回测报告
+func trailingName(c TrailingConfig) string {
+	if !c.Enabled() {
+		return "无"
+	}
+	var parts []string
+	switch c.Mode {
+	case TrailPct:
+		parts = append(parts, fmt.Sprintf("%g", c.Pct))
+	case TrailATR:
+		parts = append(parts, fmt.Sprintf("ATR(%d)×%g", c.atrPeriod(), c.atrMult()))
+	}
+	if c.Mode != TrailNone && c.Activation > 0 {
+		parts = append(parts, fmt.Sprintf("浮盈 %g 后启动", c.Activation))
+	}
+	if c.BreakEven > 0 {
+		parts = append(parts, fmt.Sprintf("浮盈 %g 保本", c.BreakEven))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// checkTrailing 每周期按最新 K 线更新持仓以来的最有利价格和移动止损价：止损价收紧时重挂交易所止损单，
+// 最新收盘价已越过止损价（dry-run 没有止损单，或止损单挂单失败）时平仓
+func (s *Strategy) checkTrailing() {
+	if !s.config.Trailing.Enabled() || s.entrySide == "" || s.entryPrice <= 0 || len(s.klines) == 0 {
+		return
+	}
+	last := s.klines[len(s.klines)-1]
+	s.trailExtreme = favorable(s.entrySide, s.trailExtreme, last)
+	atr := 0.0
+	if s.config.Trailing.Mode == TrailATR {
+		if values := CalculateATR(s.klines, s.config.Trailing.atrPeriod()); len(values) > 0 {
+			atr = values[len(values)-1]
+		}
+	}
+	stop := tighterStop(s.entrySide, s.trailStop, s.config.Trailing.StopPrice(s.entrySide, s.entryPrice, s.trailExtreme, atr))
+	if stop != s.trailStop {
+		log.Printf("[移动止损] %s 止损价 %.2f -> %.2f (最有利价格 %.2f, 均价 %.2f)", s.entrySide, s.trailStop, stop, s.trailExtreme, s.entryPrice)
+		s.trailStop = stop
+		if !s.paperPosition() {
+			s.placeProtection()
+		}
+		s.saveState()
+	}
+
+	if !stopCrossed(s.entrySide, last.Close, s.trailStop) {
+		return
+	}
+	signal := SignalCloseLong
+	if s.entrySide == "SHORT" {
+		signal = SignalCloseShort
+	}
+	log.Printf("[移动止损] 收盘价 %.2f 越过止损价 %.2f，平仓", last.Close, s.trailStop)
+	if _, _, err := s.executeSignalSized(signal, 0); err != nil {
+		s.handleExchangeError(err)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// influxBatchSize 每次写入的行数
+const influxBatchSize = 5000
+
+// InfluxExporter 通过 line protocol 写入 InfluxDB
+// URL 为完整写入地址，例如：
+//
+//	v2: http://localhost:8086/api/v2/write?org=me&bucket=rsi&precision=s
+//	v1: http://localhost:8086/write?db=rsi&precision=s
+//
+// 时间戳统一按秒写入，URL 需带 precision=s
+type InfluxExporter struct {
+	URL    string
+	Token  string // v2 API Token，为空时不带认证头
+	Tags   map[string]string
+	client *http.Client
+	buf    bytes.Buffer
+	lines  int
+}
+
+// NewInfluxExporter 创建 InfluxDB 导出器，Token 取自环境变量 INFLUX_TOKEN
+func NewInfluxExporter(url string, tags map[string]string) *InfluxExporter {
+	if url == "" {
+		return nil
+	}
+	return &InfluxExporter{
+		URL:    url,
+		Token:  os.Getenv("INFLUX_TOKEN"),
+		Tags:   tags,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// escapeTag 转义 line protocol 中 tag 的特殊字符
+func escapeTag(s string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(s)
+}
+
+// Point 写入一个数据点，缓冲满后自动发送
+func (e *InfluxExporter) Point(measurement string, fields map[string]float64, ts int64) error {
+	if e == nil || len(fields) == 0 {
+		return nil
+	}
+
+	e.buf.WriteString(escapeTag(measurement))
+	tagKeys := make([]string, 0, len(e.Tags))
+	for k := range e.Tags {
+		tagKeys = append(tagKeys, k)
+	}
+	sort.Strings(tagKeys)
+	for _, k := range tagKeys {
+		e.buf.WriteString("," + escapeTag(k) + "=" + escapeTag(e.Tags[k]))
+	}
+
+	fieldKeys := make([]string, 0, len(fields))
+	for k := range fields {
+		fieldKeys = append(fieldKeys, k)
+	}
+	sort.Strings(fieldKeys)
+	for i, k := range fieldKeys {
+		if i == 0 {
+			e.buf.WriteByte(' ')
+		} else {
+			e.buf.WriteByte(',')
+		}
+		e.buf.WriteString(escapeTag(k) + "=" + strconv.FormatFloat(fields[k], 'f', -1, 64))
+	}
+	e.buf.WriteString(" " + strconv.FormatInt(ts, 10) + "\n")
+	e.lines++
+
+	if e.lines >= influxBatchSize {
+		return e.Flush()
+	}
+	return nil
+}
+
+// Flush 发送缓冲中的数据点
+func (e *InfluxExporter) Flush() error {
+	if e == nil || e.lines == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.URL, bytes.NewReader(e.buf.Bytes()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if e.Token != "" {
+		req.Header.Set("Authorization", "Token "+e.Token)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return e.failed(err, false)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		// 4xx（限频除外）为数据本身被拒绝，重发也不会成功
+		rejected := resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests
+		return e.failed(fmt.Errorf("influx write: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body))), rejected)
+	}
+
+	e.buf.Reset()
+	e.lines = 0
+	return nil
+}
+
+// failed 写入失败时保留缓冲下次重发；数据被拒绝或缓冲已达一批时丢弃，避免 InfluxDB 长时间不可用时缓冲无限增长
+func (e *InfluxExporter) failed(err error, drop bool) error {
+	if drop || e.lines >= influxBatchSize {
+		err = fmt.Errorf("%w (dropped %d buffered points)", err, e.lines)
+		e.buf.Reset()
+		e.lines = 0
+	}
+	return err
+}
+
+// ExportBacktestSeries 导出回测资金曲线（已实现余额和含浮动盈亏的权益）和逐 K 线指标
+// indicators 中的序列与 klines 下标对齐，值为 0 的预热段跳过
+func ExportBacktestSeries(e *InfluxExporter, klines []Kline, times []int64, balance, equity []float64, indicators map[string][]float64) error {
+	for i := range times {
+		if i >= len(balance) || i >= len(equity) {
+			break
+		}
+		if err := e.Point("equity", map[string]float64{"balance": balance[i], "equity": equity[i]}, times[i]); err != nil {
+			return err
+		}
+	}
+
+	for i, k := range klines {
+		fields := map[string]float64{"close": k.Close, "volume": k.Volume}
+		for name, series := range indicators {
+			if i < len(series) && series[i] != 0 {
+				fields[name] = series[i]
+			}
+		}
+		if err := e.Point("indicators", fields, k.Timestamp); err != nil {
+			return err
+		}
+	}
+
+	return e.Flush()
+}
+
+// exportEquity 实盘每周期写入账户余额和含浮动盈亏的权益，字段与回测资金曲线一致；未配置 API 时跳过
+func (s *Strategy) exportEquity() {
+	if s.client == nil {
+		return
+	}
+	account, err := s.client.FutureGetAccount()
+	if err != nil {
+		log.Printf("查询账户权益失败: %v", wrapExchangeError("account", err))
+		return
+	}
+	asset, err := account.GetAsset("USDT")
+	if err == nil && asset == nil {
+		err = fmt.Errorf("USDT asset not found")
+	}
+	if err != nil {
+		log.Printf("查询账户权益失败: %v", err)
+		return
+	}
+	balance, err1 := strconv.ParseFloat(asset.WalletBalance, 64)
+	equity, err2 := strconv.ParseFloat(asset.MarginBalance, 64)
+	if err1 != nil || err2 != nil {
+		log.Printf("解析账户权益失败: %q / %q", asset.WalletBalance, asset.MarginBalance)
+		return
+	}
+	s.influx.Point("equity", map[string]float64{"balance": balance, "equity": equity}, time.Now().Unix())
+}
+
+// backtestTags 回测导出的标签，run 区分不同回测批次
+func backtestTags(strategy, symbol string) map[string]string {
+	return map[string]string{
+		"strategy": strategy,
+		"symbol":   symbol,
+		"mode":     "backtest",
+		"run":      time.Now().Format("20060102-150405"),
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// VolTargetConfig 波动率目标：定期按最近 Lookback 根 K 线估计标的年化波动率，调整杠杆使
+// 标的波动率 × 仓位比例 × 杠杆（持仓的年化波动率占资金的比例）接近 Target；Target 为 0 表示不启用
+type VolTargetConfig struct {
+	Target      float64 `json:"target"`                 // 目标年化波动率（小数），如 0.3
+	Lookback    int     `json:"lookback,omitempty"`     // 估计波动率的 K 线根数，默认 288
+	Rebalance   int64   `json:"rebalance,omitempty"`    // 重新估计的间隔（秒），默认 3600
+	MinLeverage int     `json:"min_leverage,omitempty"` // 杠杆下限，默认 1
+	MaxLeverage int     `json:"max_leverage,omitempty"` // 杠杆上限，默认 20
+}
+
+// 波动率目标默认值
+const (
+	defaultVolLookback    = 288
+	defaultVolRebalance   = 3600
+	defaultVolMinLeverage = 1
+	defaultVolMaxLeverage = 20
+	maxVolLookback        = 1499 // 实盘单次最多获取 1500 根 K 线
+	maxExchangeLeverage   = 125
+)
+
+// Validate 检查配置
+func (c VolTargetConfig) Validate() error {
+	if c.Target < 0 {
+		return fmt.Errorf("vol_target.target must be non-negative")
+	}
+	if c.Lookback < 0 || c.Lookback == 1 || c.Lookback > maxVolLookback {
+		return fmt.Errorf("vol_target.lookback must be between 2 and %d", maxVolLookback)
+	}
+	if c.Rebalance < 0 {
+		return fmt.Errorf("vol_target.rebalance must be non-negative")
+	}
+	if c.MinLeverage < 0 || c.MaxLeverage < 0 || c.MaxLeverage > maxExchangeLeverage {
+		return fmt.Errorf("vol_target leverage bounds must be between 1 and %d", maxExchangeLeverage)
+	}
+	if c.MaxLeverage > 0 && c.MinLeverage > c.MaxLeverage {
+		return fmt.Errorf("vol_target.min_leverage exceeds max_leverage")
+	}
+	return nil
+}
+
+// withDefaults 未设置的字段取默认值
+func (c VolTargetConfig) withDefaults() VolTargetConfig {
+	if c.Lookback == 0 {
+		c.Lookback = defaultVolLookback
+	}
+	if c.Rebalance == 0 {
+		c.Rebalance = defaultVolRebalance
+	}
+	if c.MinLeverage == 0 {
+		c.MinLeverage = defaultVolMinLeverage
+	}
+	if c.MaxLeverage == 0 {
+		c.MaxLeverage = max(defaultVolMaxLeverage, c.MinLeverage)
+	}
+	return c
+}
+
+// VolTarget 按波动率目标计算杠杆，nil 表示不启用。新杠杆只在空仓时生效（Commit），
+// 避免持仓期间调整杠杆改变已有持仓的保证金和强平价
+type VolTarget struct {
+	config   VolTargetConfig
+	exposure float64 // 1 倍杠杆时持仓名义价值占资金的比例
+	periods  float64 // 每年的 K 线根数
+	vol      float64 // 最近一次估计的标的年化波动率
+	target   int     // 按最近一次估计应使用的杠杆
+	leverage int     // 当前生效的杠杆
+	next     int64   // 下次估计的时间（秒）
+	changes  int     // 生效杠杆的变化次数
+}
+
+// NewVolTarget 创建波动率目标，未启用时返回 nil；leverage 为初始杠杆，exposure 为 1 倍杠杆时
+// 持仓名义价值占资金的比例，interval 为 K 线周期（秒）
+func NewVolTarget(c VolTargetConfig, leverage int, exposure float64, interval int64) *VolTarget {
+	if c.Target <= 0 || exposure <= 0 || interval <= 0 {
+		return nil
+	}
+	c = c.withDefaults()
+	// 初始杠杆尚未同步到交易所，第一次空仓时即 Commit
+	return &VolTarget{
+		config:   c,
+		exposure: exposure,
+		periods:  365 * 24 * 3600 / float64(interval),
+		target:   min(max(leverage, c.MinLeverage), c.MaxLeverage),
+	}
+}
+
+// Lookback 估计波动率需要的 K 线根数
+func (v *VolTarget) Lookback() int {
+	if v == nil {
+		return 0
+	}
+	return v.config.Lookback
+}
+
+// Update 到达估计时间时按 klines 最后 Lookback 根重新估计波动率和目标杠杆，now 为当前时间（秒）；
+// K 线不足时不估计。重新估计时返回 true
+func (v *VolTarget) Update(klines []Kline, now int64) bool {
+	if v == nil || now < v.next || len(klines) < v.config.Lookback {
+		return false
+	}
+	v.next = now + v.config.Rebalance
+	v.vol = realizedVol(klines[len(klines)-v.config.Lookback:]) * math.Sqrt(v.periods)
+	if v.vol <= 0 {
+		return true
+	}
+	target := int(math.Round(v.config.Target / (v.vol * v.exposure)))
+	v.target = min(max(target, v.config.MinLeverage), v.config.MaxLeverage)
+	return true
+}
+
+// Pending 目标杠杆与当前生效的杠杆不同
+func (v *VolTarget) Pending() bool {
+	return v != nil && v.target != v.leverage
+}
+
+// Commit 使目标杠杆生效，杠杆变化时返回 true
+func (v *VolTarget) Commit() bool {
+	if !v.Pending() {
+		return false
+	}
+	if v.leverage > 0 {
+		v.changes++
+	}
+	v.leverage = v.target
+	return true
+}
+
+// Leverage 当前生效的杠杆，第一次 Commit 之前为 0
+func (v *VolTarget) Leverage() int {
+	if v == nil {
+		return 0
+	}
+	return v.leverage
+}
+
+// Target 最近一次估计得出的目标杠杆
+func (v *VolTarget) Target() int {
+	if v == nil {
+		return 0
+	}
+	return v.target
+}
+
+// Vol 最近一次估计的标的年化波动率
+func (v *VolTarget) Vol() float64 {
+	if v == nil {
+		return 0
+	}
+	return v.vol
+}
+
+// Restore 恢复重启前生效的杠杆（交易所上的杠杆不会因重启改变）
+func (v *VolTarget) Restore(leverage int) {
+	if v == nil || leverage <= 0 {
+		return
+	}
+	v.leverage = leverage
+	v.target = leverage
+}
+
+// realizedVol K 线对数收益率的标准差（按单根 K 线）
+func realizedVol(klines []Kline) float64 {
+	if len(klines) < 2 {
+		return 0
+	}
+	returns := make([]float64, 0, len(klines)-1)
+	for i := 1; i < len(klines); i++ {
+		if klines[i-1].Close > 0 && klines[i].Close > 0 {
+			returns = append(returns, math.Log(klines[i].Close/klines[i-1].Close))
+		}
+	}
+	_, std, _, _ := Moments(returns)
+	return std
+}
+
+// VolTargetStats 回测中波动率目标的统计
+type VolTargetStats struct {
+	Target      float64 // 目标年化波动率
+	Changes     int     // 杠杆调整次数
+	MinLeverage int
+	MaxLeverage int
+	AvgLeverage float64 // 按持仓 K 线平均的生效杠杆
+	AvgVol      float64 // 各次估计的标的年化波动率均值
+	RealizedVol float64 // 权益曲线日收益率的年化波动率
+	estimates   int
+	holdBars    int
+	levSum      float64
+}
+
+// observe 记录一次估计
+func (s *VolTargetStats) observe(v *VolTarget) {
+	s.AvgVol += (v.Vol() - s.AvgVol) / float64(s.estimates+1)
+	s.estimates++
+}
+
+// hold 记录一根持仓 K 线的生效杠杆
+func (s *VolTargetStats) hold(leverage int) {
+	if s.holdBars == 0 {
+		s.MinLeverage, s.MaxLeverage = leverage, leverage
+	}
+	s.MinLeverage = min(s.MinLeverage, leverage)
+	s.MaxLeverage = max(s.MaxLeverage, leverage)
+	s.levSum += float64(leverage)
+	s.holdBars++
+	s.AvgLeverage = s.levSum / float64(s.holdBars)
+}
+
+// annualizedVol 日收盘权益的年化波动率（日收益率标准差 × sqrt(365)）
+func annualizedVol(daily []EquityPoint) float64 {
+	var returns []float64
+	for i := 1; i < len(daily); i++ {
+		if daily[i-1].Value > 0 {
+			returns = append(returns, daily[i].Value/daily[i-1].Value-1)
+		}
+	}
+	_, std, _, _ := Moments(returns)
+	return std * math.Sqrt(365)
+}
+
+// printVolTargetStats 打印波动率目标的统计
+func printVolTargetStats(s VolTargetStats) {
+	if s.Target <= 0 {
+		return
+	}
+	fmt.Printf("波动率目标 %.1f%%: 实际年化波动 %.1f%%, 标的平均年化波动 %.1f%%, 杠杆调整 %d 次",
+		s.Target*100, s.RealizedVol*100, s.AvgVol*100, s.Changes)
+	if s.holdBars > 0 {
+		fmt.Printf(", 持仓杠杆 %dx ~ %dx (平均 %.1fx)", s.MinLeverage, s.MaxLeverage, s.AvgLeverage)
+	}
+	fmt.Println()
+}
+
+// leverageURL 币安永续合约调整杠杆接口
+const leverageURL = "https://fapi.binance.com/fapi/v1/leverage"
+
+// changeLeverage 调整交易所上该交易对的杠杆（签名请求）
+func changeLeverage(apiKey, secretKey, symbol string, leverage int) error {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("leverage", strconv.Itoa(leverage))
+	params.Set("timestamp", strconv.FormatInt(time.Now().UnixMilli(), 10))
+	query := params.Encode()
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(query))
+	query += "&signature=" + hex.EncodeToString(mac.Sum(nil))
+
+	req, err := http.NewRequest(http.MethodPost, leverageURL+"?"+query, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-MBX-APIKEY", apiKey)
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Code int    `json:"code"`
+			Msg  string `json:"msg"`
+		}
+		json.Unmarshal(body, &apiErr)
+		return fmt.Errorf("http %d: code %d: %s", resp.StatusCode, apiErr.Code, apiErr.Msg)
+	}
+	var result struct {
+		Leverage int `json:"leverage"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("decode leverage response: %w", err)
+	}
+	if result.Leverage != leverage {
+		return fmt.Errorf("exchange set leverage %d, requested %d", result.Leverage, leverage)
+	}
+	return nil
+}
+
+// updateVolTarget 实盘每个周期按最新 K 线更新波动率目标；空仓且目标杠杆变化时调整交易所杠杆
+// （dry-run 只调整本地计算用的杠杆），成功后记录日志并告警
+func (s *Strategy) updateVolTarget() {
+	v := s.volTarget
+	if v.Update(s.klines, time.Now().Unix()) {
+		log.Printf("[波动率目标] 标的年化波动 %.1f%%，目标杠杆 %dx（当前 %dx）", v.Vol()*100, v.Target(), v.Leverage())
+	}
+	if !v.Pending() || s.entrySide != "" {
+		return
+	}
+	if !s.config.DryRun && s.client != nil {
+		if err := changeLeverage(s.config.ApiKey, s.config.SecretKey, s.config.Symbol, v.Target()); err != nil {
+			s.handleExchangeError(wrapExchangeError("leverage", err))
+			return
+		}
+	}
+	from := v.Leverage()
+	v.Commit()
+	msg := fmt.Sprintf("标的年化波动 %.1f%%，杠杆由 %dx 调整为 %dx，目标年化波动 %.1f%%",
+		v.Vol()*100, from, v.Leverage(), s.config.VolTarget.Target*100)
+	log.Printf("[波动率目标] %s", msg)
+	s.webhook.Alert("vol_target", s.config.Symbol, msg)
+}
+
+// leverage 实盘计算仓位和保证金使用的杠杆：启用波动率目标时为当前生效的杠杆
+func (s *Strategy) leverage() float64 {
+	if lev := s.volTarget.Leverage(); lev > 0 {
+		return float64(lev)
+	}
+	return float64(max(s.config.Leverage, 1))
+}
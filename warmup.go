@@ -0,0 +1,47 @@
+package main
+
+import "log"
+
+// warmupBuffer 指标最长周期之外额外要求的 K 线数，让 EMA 充分收敛
+const warmupBuffer = 20
+
+// requiredBars 生成信号前需要的连续 K 线数
+func (c StrategyConfig) requiredBars() int {
+	return max(c.RSI_PERIOD+2, c.EMA_FAST+1, c.EMA_SLOW+1) + warmupBuffer
+}
+
+// continuousTail 从最新一根往前数，时间间隔连续的 K 线数
+func continuousTail(klines []Kline, interval int64) int {
+	if len(klines) == 0 {
+		return 0
+	}
+	n := 1
+	for i := len(klines) - 1; i > 0; i-- {
+		if klines[i].Timestamp-klines[i-1].Timestamp != interval {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// warmupReady 连续 K 线足够时才允许生成信号；不足时记录预热进度
+// 启动时历史太短或中间有缺口都会让指标失真
+func (s *Strategy) warmupReady() bool {
+	required := s.engine.WarmupBars()
+	have := continuousTail(s.klines, s.interval)
+	if have >= required {
+		if !s.warmedUp {
+			log.Printf("预热完成: %d 根连续 K 线", have)
+			s.warmedUp = true
+		}
+		return true
+	}
+
+	if s.warmedUp {
+		log.Printf("K 线出现缺口，重新预热")
+		s.warmedUp = false
+	}
+	log.Printf("预热中: %d/%d 根连续 K 线", have, required)
+	return false
+}
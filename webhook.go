@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 仓位事件类型
+const (
+	EventPositionOpen         = "position_open"
+	EventPositionClose        = "position_close"
+	EventPositionPartialClose = "position_partial_close"
+	EventAlert                = "alert"
+)
+
+// WebhookConfig 外部回调配置
+type WebhookConfig struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"` // 为空表示订阅全部事件
+}
+
+// PositionEvent 仓位事件（JSON POST 负载）
+type PositionEvent struct {
+	Event     string  `json:"event"`
+	Symbol    string  `json:"symbol"`
+	Side      string  `json:"side"` // "LONG" or "SHORT"
+	Price     float64 `json:"price"`
+	Amount    float64 `json:"amount"`
+	Notional  float64 `json:"notional"`
+	DryRun    bool    `json:"dry_run"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// AlertEvent 运行告警（JSON POST 负载）
+type AlertEvent struct {
+	Event     string `json:"event"` // 固定为 "alert"
+	Kind      string `json:"kind"`
+	Symbol    string `json:"symbol"`
+	Message   string `json:"message"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// WebhookNotifier 向配置的 URL 推送仓位事件
+type WebhookNotifier struct {
+	hooks   []WebhookConfig
+	client  *http.Client
+	pending sync.WaitGroup // 进行中的推送
+}
+
+// NewWebhookNotifier 创建 webhook 推送器，未配置时返回 nil
+func NewWebhookNotifier(hooks []WebhookConfig) *WebhookNotifier {
+	if len(hooks) == 0 {
+		return nil
+	}
+	return &WebhookNotifier{
+		hooks:  hooks,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// subscribed 判断 webhook 是否订阅了该事件
+func (h WebhookConfig) subscribed(event string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify 异步推送事件，失败只记录日志，不影响交易
+func (w *WebhookNotifier) Notify(event PositionEvent) {
+	if w == nil {
+		return
+	}
+	if event.Timestamp == 0 {
+		event.Timestamp = time.Now().Unix()
+	}
+
+	w.post(event.Event, event)
+}
+
+// Alert 异步推送告警
+func (w *WebhookNotifier) Alert(kind, symbol, message string) {
+	if w == nil {
+		return
+	}
+	w.post(EventAlert, AlertEvent{
+		Event:     EventAlert,
+		Kind:      kind,
+		Symbol:    symbol,
+		Message:   message,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// post 向订阅了该事件的 webhook 发送 JSON
+func (w *WebhookNotifier) post(eventName string, payload any) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("webhook 序列化失败: %v", err)
+		return
+	}
+
+	for _, h := range w.hooks {
+		if !h.subscribed(eventName) {
+			continue
+		}
+		w.pending.Add(1)
+		go func(url string) {
+			defer w.pending.Done()
+			resp, err := w.client.Post(url, "application/json", bytes.NewReader(body))
+			if err != nil {
+				log.Printf("webhook 推送失败 %s: %v", url, err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				log.Printf("webhook 推送失败 %s: HTTP %d", url, resp.StatusCode)
+			}
+		}(h.URL)
+	}
+}
+
+// Flush 等待进行中的推送完成，最多等待 timeout；策略停止前调用，避免停止原因的告警随进程退出而丢失
+func (w *WebhookNotifier) Flush(timeout time.Duration) {
+	if w == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		w.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("webhook 推送未在 %s 内完成", timeout)
+	}
+}
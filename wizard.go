@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hstcscolor/wex/binance"
+)
+
+// riskProfile 风险偏好预设
+type riskProfile struct {
+	name         string
+	positionSize float64
+	leverage     int
+}
+
+var riskProfiles = []riskProfile{
+	{name: "保守", positionSize: 0.1, leverage: 1},
+	{name: "稳健", positionSize: 0.3, leverage: 3},
+	{name: "激进", positionSize: 0.5, leverage: 5},
+}
+
+// prompter 交互式输入
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask 提示并读取一行输入，空输入返回默认值
+func (p *prompter) ask(question, def string) string {
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+	line, _ := p.in.ReadString('\n')
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return def
+	}
+	return line
+}
+
+// askYesNo 提示是/否
+func (p *prompter) askYesNo(question string, def bool) bool {
+	defStr := "y/N"
+	if def {
+		defStr = "Y/n"
+	}
+	for {
+		answer := strings.ToLower(p.ask(question+" ("+defStr+")", ""))
+		switch answer {
+		case "":
+			return def
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		}
+		fmt.Fprintln(p.out, "请输入 y 或 n")
+	}
+}
+
+// askChoice 从选项中选择，返回下标
+func (p *prompter) askChoice(question string, options []string, def int) int {
+	fmt.Fprintln(p.out, question)
+	for i, opt := range options {
+		fmt.Fprintf(p.out, "  %d) %s\n", i+1, opt)
+	}
+	for {
+		answer := p.ask("请选择", strconv.Itoa(def+1))
+		idx, err := strconv.Atoi(answer)
+		if err == nil && idx >= 1 && idx <= len(options) {
+			return idx - 1
+		}
+		fmt.Fprintf(p.out, "请输入 1-%d\n", len(options))
+	}
+}
+
+// checkConnectivity 用测试 API 调用验证密钥
+func checkConnectivity(apiKey, secretKey string) error {
+	client := binance.NewBinFutureFromKey(apiKey, secretKey)
+	if client == nil {
+		return fmt.Errorf("failed to create binance client")
+	}
+	_, err := client.FutureGetAccount()
+	return err
+}
+
+// RunInitWizard 交互式生成配置文件
+func RunInitWizard(path string, in io.Reader, out io.Writer) error {
+	p := &prompter{in: bufio.NewReader(in), out: out}
+
+	if _, err := os.Stat(path); err == nil {
+		if !p.askYesNo(fmt.Sprintf("配置文件 %s 已存在，是否覆盖", path), false) {
+			return fmt.Errorf("cancelled")
+		}
+	}
+
+	config := defaultConfig
+
+	fmt.Fprintln(out, "\n== 交易所 ==")
+	config.ApiKey = p.ask("Binance API Key (留空则仅模拟运行)", "")
+	if config.ApiKey != "" {
+		config.SecretKey = p.ask("Binance Secret Key", "")
+	}
+
+	fmt.Fprintln(out, "\n== 交易对 ==")
+	config.Symbol = strings.ToUpper(p.ask("交易对", config.Symbol))
+
+	fmt.Fprintln(out, "\n== 风险偏好 ==")
+	var names []string
+	for _, rp := range riskProfiles {
+		names = append(names, fmt.Sprintf("%s (仓位 %.0f%%, %dx 杠杆)", rp.name, rp.positionSize*100, rp.leverage))
+	}
+	rp := riskProfiles[p.askChoice("选择风险偏好:", names, 0)]
+	config.PositionSize = rp.positionSize
+	config.Leverage = rp.leverage
+
+	fmt.Fprintln(out, "\n== 运行模式 ==")
+	config.DryRun = true
+	if config.ApiKey != "" {
+		config.DryRun = p.askChoice("选择运行模式:", []string{"模拟运行 (dry-run)", "实盘交易"}, 0) == 0
+	}
+
+	// 验证连接
+	if config.ApiKey != "" {
+		fmt.Fprintln(out, "\n验证 API 连接...")
+		if err := checkConnectivity(config.ApiKey, config.SecretKey); err != nil {
+			fmt.Fprintf(out, "连接失败: %v\n", err)
+			if !p.askYesNo("仍然保存配置", false) {
+				return fmt.Errorf("connectivity check failed: %w", err)
+			}
+		} else {
+			fmt.Fprintln(out, "连接成功")
+		}
+	}
+
+	if err := SaveConfig(path, &config); err != nil {
+		return err
+	}
+	log.Printf("配置已写入: %s", path)
+	return nil
+}